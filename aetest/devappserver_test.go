@@ -0,0 +1,194 @@
+//go:build !appengine
+// +build !appengine
+
+package aetest
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// TestFakeDevAppserver is not a real test. It is run as a child process by
+// the script from fakeDevAppserver, and logs and serves like dev_appserver.py
+// on the ports given by its flags until /quit is requested on its admin
+// server. It records its arguments in the file named by
+// AETEST_FAKE_DEV_APPSERVER_ARGS.
+func TestFakeDevAppserver(t *testing.T) {
+	argsFile := os.Getenv("AETEST_FAKE_DEV_APPSERVER_ARGS")
+	if argsFile == "" {
+		return
+	}
+	var args []string
+	for i, arg := range os.Args {
+		if arg == "--" {
+			args = os.Args[i+1:]
+			break
+		}
+	}
+	if err := ioutil.WriteFile(argsFile, []byte(strings.Join(args, "\n")), 0644); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	port := func(name string) string {
+		for _, arg := range args {
+			if strings.HasPrefix(arg, "--"+name+"=") {
+				return strings.TrimPrefix(arg, "--"+name+"=")
+			}
+		}
+		return "0"
+	}
+	listen := func(name string) net.Listener {
+		l, err := net.Listen("tcp", "localhost:"+port(name))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return l
+	}
+	api, admin := listen("api_port"), listen("admin_port")
+	fmt.Fprintf(os.Stderr, "INFO 2026-01-01 api_server.py:1] Starting gRPC API server at: http://localhost:1\n")
+	fmt.Fprintf(os.Stderr, "INFO 2026-01-01 api_server.py:2] Starting API server at: %s\n", api.Addr())
+	fmt.Fprintf(os.Stderr, "INFO 2026-01-01 admin_server.py:3] Starting admin server at: http://%s\n", admin.Addr())
+	go http.Serve(api, http.NotFoundHandler())
+	http.Serve(admin, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/quit" {
+			go os.Exit(0)
+		}
+	}))
+}
+
+// fakeDevAppserver writes a script that runs TestFakeDevAppserver with its
+// arguments, and returns its path and that of the file its arguments are
+// recorded in.
+func fakeDevAppserver(t *testing.T) (script, argsFile string) {
+	dir := t.TempDir()
+	script = filepath.Join(dir, "dev_appserver.py")
+	argsFile = filepath.Join(dir, "args")
+	t.Setenv("AETEST_FAKE_DEV_APPSERVER_ARGS", argsFile)
+	body := fmt.Sprintf("#!/bin/sh\nexec %s -test.run='^TestFakeDevAppserver$' -- \"$@\"\n", os.Args[0])
+	if err := ioutil.WriteFile(script, []byte(body), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return script, argsFile
+}
+
+func readArgs(t *testing.T, argsFile string) []string {
+	b, err := ioutil.ReadFile(argsFile)
+	if err != nil {
+		t.Fatalf("reading recorded arguments: %v", err)
+	}
+	return strings.Split(string(b), "\n")
+}
+
+func hasArg(args []string, want string) bool {
+	for _, a := range args {
+		if a == want {
+			return true
+		}
+	}
+	return false
+}
+
+func TestDevAppserverOptions(t *testing.T) {
+	script, argsFile := fakeDevAppserver(t)
+	apiPort, err := freePort()
+	if err != nil {
+		t.Fatal(err)
+	}
+	inst, err := NewInstance(&Options{
+		DevAppserverPath:        script,
+		ExtraFlags:              []string{"--support_datastore_emulator=true", "--enable_host_checking=false"},
+		APIPort:                 apiPort,
+		SuppressDevAppServerLog: true,
+	})
+	if err != nil {
+		t.Fatalf("NewInstance: %v", err)
+	}
+	defer func() {
+		if err := inst.Close(); err != nil {
+			t.Errorf("Close: %v", err)
+		}
+	}()
+
+	args := readArgs(t, argsFile)
+	for _, want := range []string{
+		"--api_port=" + strconv.Itoa(apiPort),
+		"--admin_port=0",
+		"--support_datastore_emulator=true",
+		"--enable_host_checking=false",
+	} {
+		if !hasArg(args, want) {
+			t.Errorf("dev_appserver.py arguments %q lack %q", args, want)
+		}
+	}
+	if last := args[len(args)-1]; filepath.Base(last) != "app" {
+		t.Errorf("last argument = %q, want the app directory", last)
+	}
+	if got := inst.(*instance).apiURL.Port(); got != strconv.Itoa(apiPort) {
+		t.Errorf("API server port = %s, want %d", got, apiPort)
+	}
+}
+
+func TestDevAppserverPython(t *testing.T) {
+	script, argsFile := fakeDevAppserver(t)
+	inst, err := NewInstance(&Options{
+		DevAppserverPath:        script,
+		PythonPath:              "sh",
+		SuppressDevAppServerLog: true,
+	})
+	if err != nil {
+		t.Fatalf("NewInstance: %v", err)
+	}
+	defer inst.Close()
+	if i := inst.(*instance); i.child.Path != mustLookPath(t, "sh") || i.child.Args[1] != script {
+		t.Errorf("command = %q, want sh %s ...", i.child.Args, script)
+	}
+	if args := readArgs(t, argsFile); !hasArg(args, "--port=0") {
+		t.Errorf("dev_appserver.py arguments %q lack --port=0", args)
+	}
+}
+
+func mustLookPath(t *testing.T, name string) string {
+	t.Helper()
+	p, err := exec.LookPath(name)
+	if err != nil {
+		t.Skipf("%s not found: %v", name, err)
+	}
+	return p
+}
+
+func TestDevAppserverStartupFailure(t *testing.T) {
+	script := filepath.Join(t.TempDir(), "dev_appserver.py")
+	body := "#!/bin/sh\necho 'ImportError: No module named google.appengine' >&2\nexit 1\n"
+	if err := ioutil.WriteFile(script, []byte(body), 0755); err != nil {
+		t.Fatal(err)
+	}
+	_, err := NewInstance(&Options{DevAppserverPath: script, SuppressDevAppServerLog: true})
+	if err == nil || !strings.Contains(err.Error(), "ImportError: No module named google.appengine") {
+		t.Errorf("NewInstance error = %v, want one with the stderr of dev_appserver.py", err)
+	}
+}
+
+func TestServerURL(t *testing.T) {
+	for _, tc := range []struct{ in, want string }{
+		{"http://localhost:8000", "http://localhost:8000"},
+		{"localhost:8000", "http://localhost:8000"},
+		{"http://127.0.0.1:41111.", "http://127.0.0.1:41111"},
+	} {
+		u, err := serverURL(tc.in)
+		if err != nil || u.String() != tc.want {
+			t.Errorf("serverURL(%q) = %v, %v; want %s", tc.in, u, err, tc.want)
+		}
+	}
+	if u, err := serverURL("http://"); err == nil {
+		t.Errorf("serverURL(http://) = %v, want error", u)
+	}
+}
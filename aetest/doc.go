@@ -38,5 +38,13 @@ An example test file:
 
 The environment variable APPENGINE_DEV_APPSERVER specifies the location of the
 dev_appserver.py executable to use. If unset, the system PATH is consulted.
+
+NewContextWithOptions can instead start the Cloud Datastore emulator, for code
+that uses the Cloud client libraries as second-generation apps do:
+
+	ctx, err := aetest.NewContextWithOptions(t, &aetest.NewContextOptions{UseEmulators: true})
+
+The emulator is started with gcloud unless NewContextOptions.DatastoreEmulator
+says otherwise, and is stopped when the test completes.
 */
 package aetest
@@ -0,0 +1,184 @@
+//go:build !appengine
+// +build !appengine
+
+package aetest
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	"google.golang.org/appengine"
+	"google.golang.org/appengine/datastore"
+	"google.golang.org/appengine/internal"
+)
+
+// TestFakeDatastoreEmulator is not a real test. It is run as a child process
+// by the command from fakeEmulator, and serves like the Cloud Datastore
+// emulator on the address given by its --host-port flag until it is shut
+// down.
+func TestFakeDatastoreEmulator(t *testing.T) {
+	var fake bool
+	var hostPort string
+	for _, arg := range os.Args {
+		if arg == fakeEmulatorArg {
+			fake = true
+		}
+		if strings.HasPrefix(arg, "--host-port=") {
+			hostPort = strings.TrimPrefix(arg, "--host-port=")
+		}
+	}
+	if !fake {
+		return
+	}
+	srv := &http.Server{Addr: hostPort}
+	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "Ok")
+	})
+	http.HandleFunc("/shutdown", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "Shutting down")
+		go os.Exit(0)
+	})
+	fmt.Fprintf(os.Stderr, "fake emulator serving on %s\n", hostPort)
+	srv.ListenAndServe()
+	os.Exit(1)
+}
+
+const fakeEmulatorArg = "aetest-fake-emulator"
+
+// fakeEmulator is the command that runs TestFakeDatastoreEmulator.
+var fakeEmulator = []string{os.Args[0], "-test.run=^TestFakeDatastoreEmulator$", "--", fakeEmulatorArg}
+
+func TestNewContextWithEmulators(t *testing.T) {
+	var host string
+	t.Run("instance", func(t *testing.T) {
+		ctx, err := NewContextWithOptions(t, &NewContextOptions{
+			Options:           Options{AppID: "my-project"},
+			UseEmulators:      true,
+			DatastoreEmulator: fakeEmulator,
+			Service:           "worker",
+			Version:           "v7",
+			SetEnv:            true,
+		})
+		if err != nil {
+			t.Fatalf("NewContextWithOptions: %v", err)
+		}
+		for name, want := range map[string]string{
+			"GOOGLE_CLOUD_PROJECT": "my-project",
+			"GAE_SERVICE":          "worker",
+			"GAE_VERSION":          "v7",
+			"DATASTORE_PROJECT_ID": "my-project",
+		} {
+			if got := os.Getenv(name); got != want {
+				t.Errorf("%s = %q, want %q", name, got, want)
+			}
+		}
+		host = os.Getenv("DATASTORE_EMULATOR_HOST")
+		if got := DatastoreEmulatorHost(ctx); got != host {
+			t.Errorf("DatastoreEmulatorHost = %q, want DATASTORE_EMULATOR_HOST=%q", got, host)
+		}
+		res, err := http.Get("http://" + host + "/")
+		if err != nil {
+			t.Fatalf("emulator at DATASTORE_EMULATOR_HOST=%q is not serving: %v", host, err)
+		}
+		res.Body.Close()
+
+		id := internal.BackgroundIdentityFromContext(ctx)
+		if id == nil || id.ProjectID != "my-project" || id.Service != "worker" || id.Version != "v7" {
+			t.Errorf("context identity = %+v, want my-project/worker/v7", id)
+		}
+
+		k := datastore.NewIncompleteKey(ctx, "Entity", nil)
+		_, err = datastore.Put(ctx, k, &struct{ Value string }{"foo"})
+		if err == nil || !strings.Contains(err.Error(), "UseEmulators") {
+			t.Errorf("datastore.Put error = %v, want it to reject the legacy call", err)
+		}
+		if ctx.Err() != nil {
+			t.Errorf("context done before the test completed: %v", ctx.Err())
+		}
+	})
+
+	// The emulator is stopped and the environment restored with the subtest.
+	if _, err := http.Get("http://" + host + "/"); err == nil {
+		t.Errorf("emulator at %s still serving after the test completed", host)
+	}
+	if v, ok := os.LookupEnv("DATASTORE_EMULATOR_HOST"); ok && v == host {
+		t.Errorf("DATASTORE_EMULATOR_HOST = %q after the test completed, want it restored", v)
+	}
+}
+
+func TestNewContextWithEmulatorsStartupFailure(t *testing.T) {
+	_, err := NewContextWithOptions(t, &NewContextOptions{
+		UseEmulators:      true,
+		DatastoreEmulator: []string{"sh", "-c", "echo no java found >&2; exit 3", "sh"},
+	})
+	if err == nil || !strings.Contains(err.Error(), "no java found") {
+		t.Errorf("NewContextWithOptions error = %v, want one with the emulator's output", err)
+	}
+}
+
+func TestNewContextWithNoDatastore(t *testing.T) {
+	t.Setenv("DATASTORE_EMULATOR_HOST", "")
+	ctx, err := NewContextWithOptions(t, &NewContextOptions{UseEmulators: true, NoDatastore: true, SetEnv: true})
+	if err != nil {
+		t.Fatalf("NewContextWithOptions: %v", err)
+	}
+	if got := DatastoreEmulatorHost(ctx); got != "" {
+		t.Errorf("DatastoreEmulatorHost = %q, want no emulator", got)
+	}
+	if got := os.Getenv("GOOGLE_CLOUD_PROJECT"); got != "testapp" {
+		t.Errorf("GOOGLE_CLOUD_PROJECT = %q, want testapp", got)
+	}
+	if got := os.Getenv("DATASTORE_EMULATOR_HOST"); got != "" {
+		t.Errorf("DATASTORE_EMULATOR_HOST = %q, want no emulator", got)
+	}
+}
+
+func TestParallelEmulatorInstances(t *testing.T) {
+	hosts := make(chan string, 2)
+	t.Run("group", func(t *testing.T) {
+		for _, name := range []string{"alpha", "beta"} {
+			name := name
+			t.Run(name, func(t *testing.T) {
+				t.Parallel()
+				ctx, err := NewContextWithOptions(t, &NewContextOptions{
+					Options:           Options{AppID: "project-" + name},
+					UseEmulators:      true,
+					DatastoreEmulator: fakeEmulator,
+					Service:           "service-" + name,
+					Version:           "version-" + name,
+				})
+				if err != nil {
+					t.Fatalf("NewContextWithOptions: %v", err)
+				}
+				if got, want := appengine.ModuleName(ctx), "service-"+name; got != want {
+					t.Errorf("ModuleName = %q, want %q", got, want)
+				}
+				if got, want := appengine.AppID(ctx), "project-"+name; got != want {
+					t.Errorf("AppID = %q, want %q", got, want)
+				}
+				id := internal.BackgroundIdentityFromContext(ctx)
+				if id == nil || id.Version != "version-"+name {
+					t.Errorf("context identity = %+v, want version-%s", id, name)
+				}
+				if k := datastore.NewKey(ctx, "Entity", "e", 0, nil); k.AppID() != "project-"+name {
+					t.Errorf("key app ID = %q, want project-%s", k.AppID(), name)
+				}
+				if v := os.Getenv("GAE_SERVICE"); strings.HasPrefix(v, "service-") {
+					t.Errorf("GAE_SERVICE = %q, want the environment untouched", v)
+				}
+				hosts <- DatastoreEmulatorHost(ctx)
+			})
+		}
+	})
+	close(hosts)
+	var seen []string
+	for h := range hosts {
+		seen = append(seen, h)
+	}
+	if len(seen) != 2 || seen[0] == "" || seen[0] == seen[1] {
+		t.Errorf("emulator hosts = %q, want one per instance", seen)
+	}
+}
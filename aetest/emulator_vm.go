@@ -0,0 +1,202 @@
+//go:build !appengine
+// +build !appengine
+
+package aetest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os/exec"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+
+	"google.golang.org/appengine/internal"
+)
+
+var defaultDatastoreEmulator = []string{"gcloud", "beta", "emulators", "datastore", "start", "--no-store-on-disk"}
+
+// newEmulatorContext implements NewContextWithOptions with UseEmulators.
+func newEmulatorContext(t testing.TB, opts *NewContextOptions) (context.Context, error) {
+	appID := opts.AppID
+	if appID == "" {
+		appID = "testapp"
+	}
+	service, version := opts.Service, opts.Version
+	if service == "" {
+		service = "default"
+	}
+	if version == "" {
+		version = "1"
+	}
+	startupTimeout := opts.StartupTimeout
+	if startupTimeout <= 0 {
+		startupTimeout = 15 * time.Second
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	if !opts.NoDatastore {
+		cmd := opts.DatastoreEmulator
+		if len(cmd) == 0 {
+			cmd = defaultDatastoreEmulator
+		}
+		e, err := startEmulator(cmd, appID, t.TempDir(), startupTimeout)
+		if err != nil {
+			return nil, err
+		}
+		t.Cleanup(func() {
+			if err := e.stop(); err != nil {
+				t.Errorf("aetest: stopping the Cloud Datastore emulator: %v", err)
+			}
+		})
+		ctx = context.WithValue(ctx, &emulatorHostKey, e.host)
+		if opts.SetEnv {
+			t.Setenv("DATASTORE_EMULATOR_HOST", e.host)
+			t.Setenv("DATASTORE_PROJECT_ID", appID)
+		}
+	}
+	if opts.SetEnv {
+		t.Setenv("GOOGLE_CLOUD_PROJECT", appID)
+		t.Setenv("GAE_SERVICE", service)
+		t.Setenv("GAE_VERSION", version)
+	}
+
+	ctx = internal.WithBackgroundIdentity(ctx, &internal.BackgroundIdentity{
+		ProjectID: appID,
+		Service:   service,
+		Version:   version,
+	})
+	ctx = internal.WithCallOverride(ctx, rejectLegacyCall)
+	ctx = internal.WithAppIDOverride(ctx, appID)
+	ctx = internal.WithLogOverride(ctx, func(level int64, format string, args ...interface{}) {
+		t.Logf(logLevels[level]+": "+format, args...)
+	})
+	return ctx, nil
+}
+
+var logLevels = map[int64]string{
+	0: "DEBUG",
+	1: "INFO",
+	2: "WARNING",
+	3: "ERROR",
+	4: "CRITICAL",
+}
+
+// rejectLegacyCall fails the legacy API calls made with a context from
+// newEmulatorContext, which has no API server to send them to.
+func rejectLegacyCall(ctx context.Context, service, method string, in, out proto.Message) error {
+	if service == "datastore_v3" {
+		return fmt.Errorf("aetest: legacy datastore call %s.%s is not available with UseEmulators; use cloud.google.com/go/datastore with the emulator at aetest.DatastoreEmulatorHost", service, method)
+	}
+	return fmt.Errorf("aetest: legacy API call %s.%s is not available with UseEmulators", service, method)
+}
+
+// emulator is a running Cloud Datastore emulator.
+type emulator struct {
+	cmd    *exec.Cmd
+	host   string // host:port it serves on
+	output *syncBuffer
+	done   chan error // receives the result of cmd.Wait
+}
+
+// freePort returns a TCP port on localhost that is free at the time of the
+// call.
+func freePort() (int, error) {
+	l, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		return 0, err
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
+
+// startEmulator runs cmd with the flags to serve project on a free port from
+// dataDir, and waits until it is ready to serve.
+func startEmulator(cmd []string, project, dataDir string, timeout time.Duration) (*emulator, error) {
+	port, err := freePort()
+	if err != nil {
+		return nil, fmt.Errorf("aetest: finding a free port: %v", err)
+	}
+	e := &emulator{
+		host:   fmt.Sprintf("localhost:%d", port),
+		output: &syncBuffer{},
+		done:   make(chan error, 1),
+	}
+	args := append(cmd[1:len(cmd):len(cmd)], "--host-port="+e.host, "--project="+project, "--data-dir="+dataDir)
+	e.cmd = exec.Command(cmd[0], args...)
+	e.cmd.Stdout = e.output
+	e.cmd.Stderr = e.output
+	// Do not wait for processes the command leaves behind to close its output.
+	e.cmd.WaitDelay = 5 * time.Second
+	if err := e.cmd.Start(); err != nil {
+		return nil, fmt.Errorf("aetest: starting the Cloud Datastore emulator: %v", err)
+	}
+	go func() {
+		e.done <- e.cmd.Wait()
+	}()
+
+	// The emulator answers "Ok" on its root once it is ready.
+	deadline := time.Now().Add(timeout)
+	for {
+		res, err := http.Get("http://" + e.host + "/")
+		if err == nil {
+			res.Body.Close()
+			if res.StatusCode == http.StatusOK {
+				return e, nil
+			}
+		}
+		select {
+		case err := <-e.done:
+			return nil, fmt.Errorf("aetest: the Cloud Datastore emulator exited (%v) before it was ready; output:\n%s", err, e.output)
+		case <-time.After(100 * time.Millisecond):
+		}
+		if time.Now().After(deadline) {
+			e.cmd.Process.Kill()
+			<-e.done
+			return nil, fmt.Errorf("aetest: timeout starting the Cloud Datastore emulator; output:\n%s", e.output)
+		}
+	}
+}
+
+// stop shuts the emulator down, killing it if it does not exit in time.
+func (e *emulator) stop() error {
+	res, err := http.Post("http://"+e.host+"/shutdown", "text/plain", nil)
+	if err == nil {
+		res.Body.Close()
+	}
+	select {
+	case <-e.done:
+		return nil
+	case <-time.After(15 * time.Second):
+		e.cmd.Process.Kill()
+		<-e.done
+		return errors.New("timeout waiting for the emulator to exit; killed it")
+	}
+}
+
+// syncBuffer is a bytes.Buffer that is safe for concurrent use, for the
+// output of a child process.
+type syncBuffer struct {
+	mu sync.Mutex
+	b  bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.b.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return strings.TrimSpace(b.b.String())
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"io"
 	"net/http"
+	"testing"
 	"time"
 
 	"google.golang.org/appengine"
@@ -15,6 +16,9 @@ type Instance interface {
 	io.Closer
 	// NewRequest returns an *http.Request associated with this instance.
 	NewRequest(method, urlStr string, body io.Reader) (*http.Request, error)
+	// NewRequestWithContext is like NewRequest, with a request context
+	// derived from ctx.
+	NewRequestWithContext(ctx context.Context, method, urlStr string, body io.Reader) (*http.Request, error)
 }
 
 // Options is used to specify options when creating an Instance.
@@ -34,6 +38,21 @@ type Options struct {
 	// StartupTimeout is a duration to wait for instance startup.
 	// By default, 15 seconds.
 	StartupTimeout time.Duration
+	// DevAppserverPath is the dev_appserver.py to run, in place of the one
+	// named by the APPENGINE_DEV_APPSERVER environment variable or found in
+	// the system PATH. It is executed directly unless PythonPath is set.
+	DevAppserverPath string
+	// PythonPath is the Python interpreter to run dev_appserver.py with. By
+	// default, dev_appserver.py is executed directly if its path is given,
+	// and run with python2.7 or python from the system PATH otherwise.
+	PythonPath string
+	// ExtraFlags are passed to dev_appserver.py after the flags set by this
+	// package, which they can override, for example
+	// "--support_datastore_emulator=true".
+	ExtraFlags []string
+	// Port, APIPort and AdminPort are the ports of the app, API and admin
+	// servers of dev_appserver.py. By default, free ports are chosen.
+	Port, APIPort, AdminPort int
 }
 
 // NewContext starts an instance of the development API server, and returns
@@ -55,6 +74,76 @@ func NewContext() (context.Context, func(), error) {
 	}, nil
 }
 
+// NewContextOptions is used to specify options when creating a context with
+// NewContextWithOptions.
+type NewContextOptions struct {
+	// Options configures dev_appserver.py, and with UseEmulators, its AppID
+	// and StartupTimeout apply to the emulators.
+	Options
+
+	// UseEmulators is whether to start the Cloud Datastore emulator instead
+	// of dev_appserver.py. Legacy API calls made with the context fail with
+	// an error that says so; code under test should use the Cloud client
+	// libraries, which find the emulator through DATASTORE_EMULATOR_HOST.
+	UseEmulators bool
+	// NoDatastore is whether to start no emulator at all with UseEmulators,
+	// for tests that only need the environment of a second-generation app.
+	NoDatastore bool
+	// DatastoreEmulator is the command that starts the Cloud Datastore
+	// emulator, to which the --host-port, --project and --data-dir flags are
+	// appended. By default,
+	// "gcloud beta emulators datastore start --no-store-on-disk".
+	DatastoreEmulator []string
+	// Service and Version are the service and version that the app appears
+	// to be deployed as with UseEmulators. By default, "default" and "1".
+	Service, Version string
+	// SetEnv is whether to also set the environment variables that
+	// second-generation apps find their identity and the emulator in, for
+	// code that reads them rather than the context, such as the Cloud client
+	// libraries. As with t.Setenv, t then cannot be parallel.
+	SetEnv bool
+}
+
+// NewContextWithOptions is like NewContext, but configured by opts, and
+// stops the servers it starts when t and its subtests complete. If opts is
+// nil the default values are used. Each call starts its own servers, on free
+// ports and with their own files, so parallel tests do not share state.
+//
+// With UseEmulators, the identity of the app is carried by the context, for
+// functions such as appengine.ModuleName and module.CurrentVersion, and
+// DatastoreEmulatorHost returns the address of the emulator. With SetEnv,
+// the environment variables GOOGLE_CLOUD_PROJECT, GAE_SERVICE and GAE_VERSION,
+// and unless NoDatastore is set, DATASTORE_EMULATOR_HOST and
+// DATASTORE_PROJECT_ID are set for the duration of t.
+func NewContextWithOptions(t testing.TB, opts *NewContextOptions) (context.Context, error) {
+	if opts == nil {
+		opts = &NewContextOptions{}
+	}
+	if opts.UseEmulators {
+		return newEmulatorContext(t, opts)
+	}
+	inst, err := NewInstance(&opts.Options)
+	if err != nil {
+		return nil, err
+	}
+	t.Cleanup(func() { inst.Close() })
+	req, err := inst.NewRequestWithContext(context.Background(), "GET", "/", nil)
+	if err != nil {
+		return nil, err
+	}
+	return appengine.NewContext(req), nil
+}
+
+var emulatorHostKey = "holds the host:port of the Cloud Datastore emulator"
+
+// DatastoreEmulatorHost returns the host and port of the Cloud Datastore
+// emulator started for ctx by NewContextWithOptions, or "" if there is none.
+// It is the value DATASTORE_EMULATOR_HOST would have.
+func DatastoreEmulatorHost(ctx context.Context) string {
+	h, _ := ctx.Value(&emulatorHostKey).(string)
+	return h
+}
+
 // PrepareDevAppserver is a hook which, if set, will be called before the
 // dev_appserver.py is started, each time it is started. If aetest.NewContext
 // is invoked from the goapp test tool, this hook is unnecessary.
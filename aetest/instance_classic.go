@@ -3,7 +3,15 @@
 
 package aetest
 
-import "appengine/aetest"
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+
+	"appengine/aetest"
+)
 
 // NewInstance launches a running instance of api_server.py which can be used
 // for multiple test Contexts that delegate all App Engine API calls to that
@@ -18,5 +26,26 @@ func NewInstance(opts *Options) (Instance, error) {
 			StronglyConsistentDatastore: opts.StronglyConsistentDatastore,
 		}
 	}
-	return aetest.NewInstance(aeOpts)
+	inst, err := aetest.NewInstance(aeOpts)
+	if err != nil {
+		return nil, err
+	}
+	return classicInstance{inst}, nil
+}
+
+// classicInstance adds NewRequestWithContext to the instances of the
+// appengine/aetest package.
+type classicInstance struct {
+	aetest.Instance
+}
+
+// NewRequestWithContext returns a request from NewRequest. The appengine
+// package finds the context of a request by its address, so the request
+// cannot be copied to derive its context from ctx.
+func (i classicInstance) NewRequestWithContext(ctx context.Context, method, urlStr string, body io.Reader) (*http.Request, error) {
+	return i.NewRequest(method, urlStr, body)
+}
+
+func newEmulatorContext(t testing.TB, opts *NewContextOptions) (context.Context, error) {
+	return nil, errors.New("aetest: UseEmulators is not supported by the appengine build")
 }
@@ -1,6 +1,7 @@
 package aetest
 
 import (
+	"context"
 	"os"
 	"testing"
 
@@ -117,3 +118,43 @@ func TestUsers(t *testing.T) {
 		t.Errorf("user.Current after logout %v, want nil", user)
 	}
 }
+
+func TestParallelInstances(t *testing.T) {
+	// Only run the test if APPENGINE_DEV_APPSERVER is explicitly set.
+	if os.Getenv("APPENGINE_DEV_APPSERVER") == "" {
+		t.Skip("APPENGINE_DEV_APPSERVER not set")
+	}
+
+	type Entity struct{ Value string }
+	for _, name := range []string{"alpha", "beta"} {
+		name := name
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			inst, err := NewInstance(&Options{AppID: "app-" + name, StronglyConsistentDatastore: true})
+			if err != nil {
+				t.Fatalf("NewInstance: %v", err)
+			}
+			defer inst.Close()
+			req, err := inst.NewRequestWithContext(context.Background(), "GET", "http://example.com/page", nil)
+			if err != nil {
+				t.Fatalf("NewRequestWithContext: %v", err)
+			}
+			ctx := appengine.NewContext(req)
+
+			if got, want := appengine.AppID(ctx), "app-"+name; got != want {
+				t.Errorf("AppID = %q, want %q", got, want)
+			}
+			k := datastore.NewKey(ctx, "Entity", "shared", 0, nil)
+			if _, err := datastore.Put(ctx, k, &Entity{Value: name}); err != nil {
+				t.Fatalf("datastore.Put: %v", err)
+			}
+			var es []Entity
+			if _, err := datastore.NewQuery("Entity").GetAll(ctx, &es); err != nil {
+				t.Fatalf("GetAll: %v", err)
+			}
+			if len(es) != 1 || es[0].Value != name {
+				t.Errorf("entities = %+v, want only this instance's", es)
+			}
+		})
+	}
+}
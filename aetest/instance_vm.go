@@ -5,6 +5,7 @@ package aetest
 
 import (
 	"bufio"
+	"context"
 	"crypto/rand"
 	"errors"
 	"fmt"
@@ -16,6 +17,8 @@ import (
 	"os/exec"
 	"path/filepath"
 	"regexp"
+	"strings"
+	"sync"
 	"time"
 
 	"google.golang.org/appengine/internal"
@@ -64,7 +67,13 @@ type instance struct {
 
 // NewRequest returns an *http.Request associated with this instance.
 func (i *instance) NewRequest(method, urlStr string, body io.Reader) (*http.Request, error) {
-	req, err := http.NewRequest(method, urlStr, body)
+	return i.NewRequestWithContext(context.Background(), method, urlStr, body)
+}
+
+// NewRequestWithContext is like NewRequest, with a request context derived
+// from ctx.
+func (i *instance) NewRequestWithContext(ctx context.Context, method, urlStr string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, urlStr, body)
 	if err != nil {
 		return nil, err
 	}
@@ -136,8 +145,108 @@ func findDevAppserver() (string, error) {
 	return exec.LookPath("dev_appserver.py")
 }
 
-var apiServerAddrRE = regexp.MustCompile(`Starting API server at: (\S+)`)
-var adminServerAddrRE = regexp.MustCompile(`Starting admin server at: (\S+)`)
+// The addresses of the servers are logged as "Starting API server at:
+// http://localhost:port", with the scheme omitted by some versions. The gRPC
+// API server is not the one API calls are sent to.
+var apiServerAddrRE = regexp.MustCompile(`(?i)Starting API server at:?\s+(\S+)`)
+var adminServerAddrRE = regexp.MustCompile(`(?i)Starting admin server at:?\s+(\S+)`)
+
+// serverURL returns the URL logged by dev_appserver.py as addr.
+func serverURL(addr string) (*url.URL, error) {
+	addr = strings.TrimRight(addr, ".,;")
+	if !strings.Contains(addr, "://") {
+		addr = "http://" + addr
+	}
+	u, err := url.Parse(addr)
+	if err != nil {
+		return nil, err
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("no host in %q", addr)
+	}
+	return u, nil
+}
+
+// command returns the executable and the arguments that start
+// dev_appserver.py, except for the flags.
+func (i *instance) command() (executable string, args []string, err error) {
+	var opts Options
+	if i.opts != nil {
+		opts = *i.opts
+	}
+	devAppserver := opts.DevAppserverPath
+	if devAppserver == "" {
+		devAppserver = os.Getenv("APPENGINE_DEV_APPSERVER")
+	}
+	if devAppserver == "" || opts.PythonPath != "" {
+		if devAppserver == "" {
+			if devAppserver, err = findDevAppserver(); err != nil {
+				return "", nil, fmt.Errorf("Could not find dev_appserver.py: %v", err)
+			}
+		}
+		executable = opts.PythonPath
+		if executable == "" {
+			if executable, err = findPython(); err != nil {
+				return "", nil, fmt.Errorf("Could not find python interpreter: %v", err)
+			}
+		}
+		return executable, []string{devAppserver}, nil
+	}
+	return devAppserver, nil, nil
+}
+
+// flags returns the flags that dev_appserver.py is run with for the given
+// datastore path.
+func (i *instance) flags(datastorePath string) []string {
+	var opts Options
+	if i.opts != nil {
+		opts = *i.opts
+	}
+	flags := []string{
+		fmt.Sprintf("--port=%d", opts.Port),
+		fmt.Sprintf("--api_port=%d", opts.APIPort),
+		fmt.Sprintf("--admin_port=%d", opts.AdminPort),
+		"--automatic_restart=false",
+		"--skip_sdk_update_check=true",
+		"--clear_datastore=true",
+		"--clear_search_indexes=true",
+		"--datastore_path", datastorePath,
+		// Keep the other state of the instance, shared by default by those
+		// of the same app ID, in its own directory.
+		"--storage_path", filepath.Join(i.appDir, "storage"),
+	}
+	if opts.StronglyConsistentDatastore {
+		flags = append(flags, "--datastore_consistency_policy=consistent")
+	}
+	if opts.SupportDatastoreEmulator != nil {
+		flags = append(flags, fmt.Sprintf("--support_datastore_emulator=%t", *opts.SupportDatastoreEmulator))
+	}
+	return append(flags, opts.ExtraFlags...)
+}
+
+// stderrTail keeps the last lines written to the stderr of dev_appserver.py,
+// to report them if it fails to start.
+type stderrTail struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+const stderrTailLines = 50
+
+func (t *stderrTail) add(line string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.lines = append(t.lines, line)
+	if len(t.lines) > stderrTailLines {
+		t.lines = t.lines[len(t.lines)-stderrTailLines:]
+	}
+}
+
+func (t *stderrTail) String() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return strings.Join(t.lines, "\n")
+}
 
 func (i *instance) startChild() (err error) {
 	if PrepareDevAppserver != nil {
@@ -145,18 +254,9 @@ func (i *instance) startChild() (err error) {
 			return err
 		}
 	}
-	executable := os.Getenv("APPENGINE_DEV_APPSERVER")
-	var appserverArgs []string
-	if len(executable) == 0 {
-		executable, err = findPython()
-		if err != nil {
-			return fmt.Errorf("Could not find python interpreter: %v", err)
-		}
-		devAppserver, err := findDevAppserver()
-		if err != nil {
-			return fmt.Errorf("Could not find dev_appserver.py: %v", err)
-		}
-		appserverArgs = append(appserverArgs, devAppserver)
+	executable, appserverArgs, err := i.command()
+	if err != nil {
+		return err
 	}
 
 	i.appDir, err = ioutil.TempDir("", "appengine-aetest")
@@ -181,27 +281,13 @@ func (i *instance) startChild() (err error) {
 		return err
 	}
 
+	// A datastore path from the environment is shared by all instances.
 	datastorePath := os.Getenv("APPENGINE_DEV_APPSERVER_DATASTORE_PATH")
 	if len(datastorePath) == 0 {
 		datastorePath = filepath.Join(i.appDir, "datastore")
 	}
 
-	appserverArgs = append(appserverArgs,
-		"--port=0",
-		"--api_port=0",
-		"--admin_port=0",
-		"--automatic_restart=false",
-		"--skip_sdk_update_check=true",
-		"--clear_datastore=true",
-		"--clear_search_indexes=true",
-		"--datastore_path", datastorePath,
-	)
-	if i.opts != nil && i.opts.StronglyConsistentDatastore {
-		appserverArgs = append(appserverArgs, "--datastore_consistency_policy=consistent")
-	}
-	if i.opts != nil && i.opts.SupportDatastoreEmulator != nil {
-		appserverArgs = append(appserverArgs, fmt.Sprintf("--support_datastore_emulator=%t", *i.opts.SupportDatastoreEmulator))
-	}
+	appserverArgs = append(appserverArgs, i.flags(datastorePath)...)
 	appserverArgs = append(appserverArgs, filepath.Join(i.appDir, "app"))
 
 	i.child = exec.Command(executable, appserverArgs...)
@@ -216,18 +302,28 @@ func (i *instance) startChild() (err error) {
 	if err = i.child.Start(); err != nil {
 		return err
 	}
+	defer func() {
+		if err != nil {
+			if p := i.child.Process; p != nil {
+				p.Kill()
+			}
+			i.child = nil
+		}
+	}()
 
 	// Read stderr until we have read the URLs of the API server and admin interface.
+	var tail stderrTail
 	errc := make(chan error, 1)
 	go func() {
 		s := bufio.NewScanner(stderr)
 		for s.Scan() {
+			tail.add(s.Text())
 			// Pass stderr along as we go so the user can see it.
 			if !(i.opts != nil && i.opts.SuppressDevAppServerLog) {
 				fmt.Fprintln(os.Stderr, s.Text())
 			}
 			if match := apiServerAddrRE.FindStringSubmatch(s.Text()); match != nil {
-				u, err := url.Parse(match[1])
+				u, err := serverURL(match[1])
 				if err != nil {
 					errc <- fmt.Errorf("failed to parse API URL %q: %v", match[1], err)
 					return
@@ -235,7 +331,12 @@ func (i *instance) startChild() (err error) {
 				i.apiURL = u
 			}
 			if match := adminServerAddrRE.FindStringSubmatch(s.Text()); match != nil {
-				i.adminURL = match[1]
+				u, err := serverURL(match[1])
+				if err != nil {
+					errc <- fmt.Errorf("failed to parse admin URL %q: %v", match[1], err)
+					return
+				}
+				i.adminURL = strings.TrimSuffix(u.String(), "/")
 			}
 			if i.adminURL != "" && i.apiURL != nil {
 				// Pass along stderr to the user after we're done with it.
@@ -250,24 +351,30 @@ func (i *instance) startChild() (err error) {
 
 	select {
 	case <-time.After(i.startupTimeout):
-		if p := i.child.Process; p != nil {
-			p.Kill()
-		}
-		return errors.New("timeout starting child process")
+		return startupError("timeout starting child process", &tail)
 	case err := <-errc:
 		if err != nil {
-			return fmt.Errorf("error reading child process stderr: %v", err)
+			return startupError(fmt.Sprintf("error reading child process stderr: %v", err), &tail)
 		}
 	}
 	if i.adminURL == "" {
-		return errors.New("unable to find admin server URL")
+		return startupError("unable to find admin server URL", &tail)
 	}
 	if i.apiURL == nil {
-		return errors.New("unable to find API server URL")
+		return startupError("unable to find API server URL", &tail)
 	}
 	return nil
 }
 
+// startupError returns an error with msg, and the end of the stderr of
+// dev_appserver.py if it wrote any.
+func startupError(msg string, tail *stderrTail) error {
+	if s := tail.String(); s != "" {
+		return fmt.Errorf("%s; stderr:\n%s", msg, s)
+	}
+	return errors.New(msg)
+}
+
 func (i *instance) appYAML() string {
 	return fmt.Sprintf(appYAMLTemplate, i.appID)
 }
@@ -63,6 +63,36 @@ func IsDevAppServer() bool {
 	return internal.IsDevAppServer()
 }
 
+// RuntimeEnvironment describes where the app runs, as reported by
+// Environment.
+type RuntimeEnvironment struct {
+	// Local reports whether the app runs in local development rather than
+	// in production.
+	Local bool
+
+	// Reason is a human-readable explanation of how Local was decided.
+	Reason string
+}
+
+// Environment reports whether the app runs in local development, and why.
+// Unlike IsDevAppServer, it also recognizes an app started with "go run"
+// away from App Engine. In order, it considers:
+//   - the APPENGINE_DEV_MODE environment variable, which, if set to a boolean
+//     value such as "true", "yes" or "off", decides the answer;
+//   - the development App Server, which means local development;
+//   - the App Engine runtimes, including second-generation runtimes that set
+//     GAE_ENV, which mean production;
+//   - the metadata server of Google Cloud, which means production if it
+//     answers and local development otherwise.
+//
+// Only the first call may have to wait briefly for the metadata server.
+// Environment does not affect IsDevAppServer, which still only reports the
+// development App Server.
+func Environment() RuntimeEnvironment {
+	local, reason := internal.Environment()
+	return RuntimeEnvironment{Local: local, Reason: reason}
+}
+
 // IsStandard reports whether the App Engine app is running in the standard
 // environment. This includes both the first generation runtimes (<= Go 1.9)
 // and the second generation runtimes (>= Go 1.11).
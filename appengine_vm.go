@@ -9,6 +9,10 @@ package appengine
 
 import (
 	"context"
+	"net"
+	"net/http"
+
+	"google.golang.org/appengine/internal"
 )
 
 // BackgroundContext returns a context not associated with a request.
@@ -18,3 +22,65 @@ import (
 func BackgroundContext() context.Context {
 	return context.Background()
 }
+
+// NewBackgroundContext returns a context not associated with a request, for
+// work that outlives one, such as polling with module.Watch. Unlike
+// BackgroundContext, it carries the identity of the running app, read from
+// the environment of second-generation runtimes when it is called: the
+// project, service and version. The module package resolves its defaults
+// from that identity and uses the Admin API with it.
+//
+// Legacy App Engine API calls cannot be made with the context, as there is
+// no request to make them on behalf of; they fail with an error that matches
+// ErrBackgroundContext with errors.Is.
+func NewBackgroundContext() context.Context {
+	return internal.NewBackgroundContext(context.Background())
+}
+
+// MainOptions configures MainWithOptions. The zero value gives the behavior
+// of Main.
+type MainOptions struct {
+	// Listener, if not nil, is the listener the app serves on. Otherwise the
+	// app listens on Addr.
+	Listener net.Listener
+
+	// Addr is the TCP address to listen on if Listener is nil. If empty, the
+	// port in the PORT environment variable is used, or 8080.
+	Addr string
+
+	// Handler serves the requests, wrapped with Middleware. If nil,
+	// http.DefaultServeMux is used. A health check handler is installed on
+	// the handler if it is an *http.ServeMux that lacks one.
+	Handler http.Handler
+
+	// Context, if not nil, shuts the app down when it is done, as SIGTERM
+	// does.
+	Context context.Context
+}
+
+// MainWithOptions is like Main, but serves as configured by opts, and returns
+// once the app has shut down instead of exiting. It returns an error if it
+// cannot listen or serve, or if shutdown overruns its deadline.
+func MainWithOptions(opts MainOptions) error {
+	return internal.MainWithOptions(internal.MainOptions{
+		Listener: opts.Listener,
+		Addr:     opts.Addr,
+		Handler:  opts.Handler,
+		Context:  opts.Context,
+	})
+}
+
+// RegisterShutdownHook registers f to be called when an app started with Main
+// is shut down, such as when its instance is stopped during scale-down. On
+// SIGTERM or SIGINT, Main stops accepting connections, waits for in-flight
+// requests to complete and then calls the hooks in the order they were
+// registered, before exiting. The context passed to f expires at the end of
+// the time the platform allows for shutdown. A hook that panics does not
+// prevent the others from running.
+func RegisterShutdownHook(f func(context.Context)) {
+	internal.RegisterShutdownHook(f)
+}
+
+// ErrBackgroundContext is returned by legacy API calls made with a context
+// from NewBackgroundContext.
+var ErrBackgroundContext = internal.ErrBackgroundContext
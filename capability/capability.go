@@ -6,7 +6,10 @@
 Package capability exposes information about outages and scheduled downtime
 for specific API capabilities.
 
-This package does not work in App Engine "flexible environment".
+On first-generation runtimes, the status comes from the capability service.
+Elsewhere, where that service is not available, it comes from a table of the
+legacy APIs that have been retired, unless a resolver is installed with
+SetResolver.
 
 Example:
 
@@ -18,6 +21,7 @@ package capability // import "google.golang.org/appengine/capability"
 
 import (
 	"context"
+	"sync"
 
 	"google.golang.org/appengine/internal"
 	"google.golang.org/appengine/log"
@@ -25,11 +29,58 @@ import (
 	pb "google.golang.org/appengine/internal/capability"
 )
 
+// retired holds the legacy APIs that are not available outside of
+// first-generation runtimes. All the other APIs are reported as enabled
+// there.
+var retired = map[string]bool{
+	"channel": true,
+	"file":    true,
+	"mail":    true,
+	"xmpp":    true,
+}
+
+var resolver struct {
+	sync.Mutex
+	f func(api, capability string) (bool, error)
+}
+
+// SetResolver installs f as the source of the status reported by Enabled,
+// in place of the capability service and the table of retired APIs, so that
+// an app can consult its own status source. Passing nil restores the default.
+func SetResolver(f func(api, capability string) (bool, error)) {
+	resolver.Lock()
+	resolver.f = f
+	resolver.Unlock()
+}
+
+// useService reports whether Enabled asks the capability service, which is
+// only available on first-generation runtimes. It is a variable so that tests
+// can override it.
+var useService = func() bool {
+	return internal.IsStandard() && !internal.IsSecondGen()
+}
+
 // Enabled returns whether an API's capabilities are enabled.
 // The wildcard "*" capability matches every capability of an API.
-// If the underlying RPC fails (if the package is unknown, for example),
-// false is returned and information is written to the application log.
+// If the underlying RPC or the resolver fails (if the package is unknown, for
+// example), false is returned and information is written to the application
+// log.
 func Enabled(ctx context.Context, api, capability string) bool {
+	resolver.Lock()
+	f := resolver.f
+	resolver.Unlock()
+	if f != nil {
+		ok, err := f(api, capability)
+		if err != nil {
+			log.Warningf(ctx, "capability.Enabled: resolver failed: %v", err)
+			return false
+		}
+		return ok
+	}
+	if !useService() {
+		return !retired[api]
+	}
+
 	// For non datastore*/write requests always return ENABLED
 	if !(api == "datastore_v3" && capability == "write") {
 		return true
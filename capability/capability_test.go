@@ -0,0 +1,90 @@
+// Copyright 2026 Google LLC. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package capability
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"google.golang.org/appengine/internal"
+	"google.golang.org/appengine/internal/aetesting"
+	pb "google.golang.org/appengine/internal/capability"
+)
+
+func TestEnabledStaticTable(t *testing.T) {
+	defer func(f func() bool) { useService = f }(useService)
+	useService = func() bool { return false }
+
+	tests := []struct {
+		api, capability string
+		want            bool
+	}{
+		{"datastore_v3", "write", true},
+		{"memcache", "*", true},
+		{"taskqueue", "*", true},
+		{"mail", "*", false},
+		{"xmpp", "send", false},
+	}
+	for _, tc := range tests {
+		if got := Enabled(context.Background(), tc.api, tc.capability); got != tc.want {
+			t.Errorf("Enabled(%q, %q) = %v, want %v", tc.api, tc.capability, got, tc.want)
+		}
+	}
+}
+
+func TestEnabledResolver(t *testing.T) {
+	defer SetResolver(nil)
+	var gotAPI, gotCapability string
+	SetResolver(func(api, capability string) (bool, error) {
+		gotAPI, gotCapability = api, capability
+		return api != "datastore_v3", nil
+	})
+	if Enabled(context.Background(), "datastore_v3", "write") {
+		t.Errorf("Enabled(datastore_v3, write) = true, want the resolver's false")
+	}
+	if gotAPI != "datastore_v3" || gotCapability != "write" {
+		t.Errorf("resolver called with (%q, %q), want (datastore_v3, write)", gotAPI, gotCapability)
+	}
+	if !Enabled(context.Background(), "mail", "*") {
+		t.Errorf("Enabled(mail, *) = false, want the resolver's true")
+	}
+
+	var logged []string
+	c := internal.WithLogOverride(context.Background(), func(level int64, format string, args ...interface{}) {
+		logged = append(logged, fmt.Sprintf(format, args...))
+	})
+	SetResolver(func(api, capability string) (bool, error) {
+		return true, errors.New("status source unavailable")
+	})
+	if Enabled(c, "memcache", "*") {
+		t.Errorf("Enabled with a failing resolver = true, want false")
+	}
+	if len(logged) != 1 || !strings.Contains(logged[0], "status source unavailable") {
+		t.Errorf("logged %q, want the resolver's error", logged)
+	}
+}
+
+func TestEnabledService(t *testing.T) {
+	defer func(f func() bool) { useService = f }(useService)
+	useService = func() bool { return true }
+
+	c := aetesting.FakeSingleContext(t, "capability_service", "IsEnabled", func(req *pb.IsEnabledRequest, res *pb.IsEnabledResponse) error {
+		if req.GetPackage() != "datastore_v3" || len(req.Capability) != 1 || req.Capability[0] != "write" {
+			t.Errorf("IsEnabled request = %v, want datastore_v3 write", req)
+		}
+		res.SummaryStatus = pb.IsEnabledResponse_DISABLED.Enum()
+		return nil
+	})
+	if Enabled(c, "datastore_v3", "write") {
+		t.Errorf("Enabled(datastore_v3, write) = true, want the service's DISABLED")
+	}
+	// Only datastore writes are asked about; the fake fails any other call.
+	if !Enabled(c, "mail", "*") {
+		t.Errorf("Enabled(mail, *) = false, want true on first-generation runtimes")
+	}
+}
@@ -0,0 +1,183 @@
+// Copyright 2026 Google LLC. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package delay
+
+// This file implements the Cloud Tasks mode of the package, for runtimes
+// without the legacy task queue API.
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	cloudtasks "google.golang.org/api/cloudtasks/v2"
+	"google.golang.org/api/idtoken"
+	"google.golang.org/api/option"
+
+	"google.golang.org/appengine"
+	"google.golang.org/appengine/internal"
+	"google.golang.org/appengine/taskqueue"
+)
+
+// cloudTasksOptions holds extra client options used when constructing the
+// Cloud Tasks client. It is a variable so that tests can point the client at
+// a fake server.
+var cloudTasksOptions []option.ClientOption
+
+// validateToken validates an OIDC token and returns its payload. It is a
+// variable so that tests can substitute a fake for Google's verification.
+var validateToken = idtoken.Validate
+
+// useCloudTasks reports whether DELAY_USE_CLOUD_TASKS selects the Cloud
+// Tasks mode, as parsed by internal.EnvBool.
+func useCloudTasks() (bool, error) {
+	v, err := internal.EnvBool("DELAY_USE_CLOUD_TASKS")
+	if err != nil {
+		return false, fmt.Errorf("delay: %v", err)
+	}
+	return v, nil
+}
+
+// cloudTasksConfig is where and how the tasks of the Cloud Tasks mode are
+// created.
+type cloudTasksConfig struct {
+	queue          string // resource name, as in "projects/p/locations/l/queues/q"
+	url            string // URL of the handler the tasks are sent to
+	serviceAccount string // email of the account the OIDC tokens are issued to
+}
+
+// loadCloudTasksConfig returns the configuration of the Cloud Tasks mode,
+// from the DELAY_LOCATION, DELAY_QUEUE, DELAY_TARGET_HOST and
+// DELAY_SERVICE_ACCOUNT environment variables, or the defaults derived from
+// the running app: its region, the default queue, the host of its default
+// version and its default service account.
+func loadCloudTasksConfig(c context.Context) (*cloudTasksConfig, error) {
+	project := internal.ProjectID(c)
+	if project == "" {
+		return nil, errors.New("delay: cannot determine the project ID; set GOOGLE_CLOUD_PROJECT")
+	}
+	location := os.Getenv("DELAY_LOCATION")
+	if location == "" {
+		var err error
+		if location, err = internal.MetadataRegion(c); err != nil {
+			return nil, fmt.Errorf("delay: cannot determine the Cloud Tasks location (%v); set DELAY_LOCATION", err)
+		}
+	}
+	queueName := os.Getenv("DELAY_QUEUE")
+	if queueName == "" {
+		queueName = "default"
+	}
+	host := targetHost(c)
+	if host == "" {
+		return nil, errNoTargetHost
+	}
+	account, err := tasksServiceAccount(c)
+	if err != nil {
+		return nil, err
+	}
+	return &cloudTasksConfig{
+		queue:          fmt.Sprintf("projects/%s/locations/%s/queues/%s", project, location, queueName),
+		url:            "https://" + host + path,
+		serviceAccount: account,
+	}, nil
+}
+
+var errNoTargetHost = errors.New("delay: cannot determine the host of the app; set DELAY_TARGET_HOST")
+
+// targetHost returns the host that tasks are sent to: DELAY_TARGET_HOST, or
+// the host of the default version of the current service. It depends only on
+// the configuration of the app, so that the handler, which checks that the
+// tokens of tasks are for that host, never trusts the Host of a request.
+func targetHost(c context.Context) string {
+	if h := os.Getenv("DELAY_TARGET_HOST"); h != "" {
+		return h
+	}
+	h := appengine.DefaultVersionHostname(c)
+	if h == "" {
+		return ""
+	}
+	if s := os.Getenv("GAE_SERVICE"); s != "" && s != "default" {
+		h = s + "-dot-" + h
+	}
+	return h
+}
+
+// tasksServiceAccount returns the service account that the OIDC tokens of
+// tasks are issued to: DELAY_SERVICE_ACCOUNT, or that of the app.
+func tasksServiceAccount(c context.Context) (string, error) {
+	if a := os.Getenv("DELAY_SERVICE_ACCOUNT"); a != "" {
+		return a, nil
+	}
+	a, _, err := internal.ServiceAccount(c)
+	if err != nil {
+		return "", fmt.Errorf("delay: cannot determine the service account for task tokens (%v); set DELAY_SERVICE_ACCOUNT", err)
+	}
+	return a, nil
+}
+
+// addCloudTask creates an HTTP task for t, a task from Function.Task, that
+// sends its payload to the delay handler of the app with an OIDC token.
+func addCloudTask(c context.Context, t *taskqueue.Task) error {
+	cfg, err := loadCloudTasksConfig(c)
+	if err != nil {
+		return err
+	}
+	opts := append([]option.ClientOption{option.WithUserAgent("appengine-delay-go-client")}, cloudTasksOptions...)
+	svc, err := cloudtasks.NewService(c, opts...)
+	if err != nil {
+		return fmt.Errorf("delay: creating Cloud Tasks client: %v", err)
+	}
+	req := &cloudtasks.CreateTaskRequest{
+		Task: &cloudtasks.Task{
+			HttpRequest: &cloudtasks.HttpRequest{
+				HttpMethod: "POST",
+				Url:        cfg.url,
+				Headers:    map[string]string{"Content-Type": "application/octet-stream"},
+				Body:       base64.StdEncoding.EncodeToString(t.Payload),
+				OidcToken: &cloudtasks.OidcToken{
+					ServiceAccountEmail: cfg.serviceAccount,
+					Audience:            cfg.url,
+				},
+			},
+		},
+	}
+	if _, err := svc.Projects.Locations.Queues.Tasks.Create(cfg.queue, req).Context(c).Do(); err != nil {
+		return fmt.Errorf("delay: creating task in %s: %w", cfg.queue, err)
+	}
+	return nil
+}
+
+// verifyTaskToken checks that req carries an OIDC token that Google issued
+// to the service account of the tasks, for the URL of the handler on the
+// host that tasks are sent to. It returns the status to fail the request with
+// if not.
+func verifyTaskToken(c context.Context, req *http.Request) (int, error) {
+	tok := strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ")
+	if tok == "" || tok == req.Header.Get("Authorization") {
+		return http.StatusUnauthorized, errors.New("no bearer token")
+	}
+	host := targetHost(c)
+	if host == "" {
+		return http.StatusInternalServerError, errNoTargetHost
+	}
+	p, err := validateToken(c, tok, "https://"+host+path)
+	if err != nil {
+		return http.StatusUnauthorized, fmt.Errorf("invalid token: %v", err)
+	}
+	account, err := tasksServiceAccount(c)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	email, _ := p.Claims["email"].(string)
+	verified, _ := p.Claims["email_verified"].(bool)
+	if email != account || !verified {
+		return http.StatusForbidden, fmt.Errorf("token is for %q, not the task service account %q", email, account)
+	}
+	return 0, nil
+}
@@ -0,0 +1,225 @@
+// Copyright 2026 Google LLC. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package delay
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	cloudtasks "google.golang.org/api/cloudtasks/v2"
+	"google.golang.org/api/idtoken"
+	"google.golang.org/api/option"
+)
+
+const testServiceAccount = "tasks@my-project.iam.gserviceaccount.com"
+
+// fakeCloudTasks serves CreateTask and records the tasks it is asked to
+// create.
+func fakeCloudTasks(t *testing.T) *[]*cloudtasks.CreateTaskRequest {
+	var created []*cloudtasks.CreateTaskRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.URL.Path != "/v2/projects/my-project/locations/us-east1/queues/work/tasks" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			http.NotFound(w, r)
+			return
+		}
+		req := &cloudtasks.CreateTaskRequest{}
+		if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+			t.Errorf("decoding CreateTaskRequest: %v", err)
+		}
+		created = append(created, req)
+		task := *req.Task
+		task.Name = "projects/my-project/locations/us-east1/queues/work/tasks/1234"
+		json.NewEncoder(w).Encode(&task)
+	}))
+	t.Cleanup(srv.Close)
+
+	old := cloudTasksOptions
+	cloudTasksOptions = []option.ClientOption{option.WithEndpoint(srv.URL), option.WithoutAuthentication()}
+	t.Cleanup(func() { cloudTasksOptions = old })
+
+	t.Setenv("DELAY_USE_CLOUD_TASKS", "true")
+	t.Setenv("GOOGLE_CLOUD_PROJECT", "my-project")
+	t.Setenv("DELAY_LOCATION", "us-east1")
+	t.Setenv("DELAY_QUEUE", "work")
+	t.Setenv("DELAY_TARGET_HOST", "my-project.appspot.com")
+	t.Setenv("DELAY_SERVICE_ACCOUNT", testServiceAccount)
+	return &created
+}
+
+// fakeValidateToken accepts the token "valid-<email>" for the delay handler
+// of my-project.appspot.com, and "evil-<email>" for that of
+// evil.example.com, as issued to email.
+func fakeValidateToken(t *testing.T) {
+	old := validateToken
+	validateToken = func(ctx context.Context, token, audience string) (*idtoken.Payload, error) {
+		tokenAudiences := map[string]string{
+			"valid-": "https://my-project.appspot.com/_ah/queue/go/delay",
+			"evil-":  "https://evil.example.com/_ah/queue/go/delay",
+		}
+		var email string
+		for prefix, aud := range tokenAudiences {
+			if e, ok := strings.CutPrefix(token, prefix); ok {
+				if audience != aud {
+					return nil, errors.New("audience mismatch")
+				}
+				email = e
+			}
+		}
+		if email == "" {
+			return nil, errors.New("bad signature")
+		}
+		return &idtoken.Payload{Audience: audience, Claims: map[string]interface{}{
+			"email":          email,
+			"email_verified": true,
+		}}, nil
+	}
+	t.Cleanup(func() { validateToken = old })
+}
+
+func TestCloudTasksCall(t *testing.T) {
+	created := fakeCloudTasks(t)
+	fakeValidateToken(t)
+	c := newFakeContext()
+
+	regFuncRuns, regFuncMsg = 0, ""
+	if err := regFunc.Call(c.ctx, "over the cloud"); err != nil {
+		t.Fatalf("Call: %v", err)
+	}
+	if len(*created) != 1 {
+		t.Fatalf("created %d tasks, want 1", len(*created))
+	}
+	hr := (*created)[0].Task.HttpRequest
+	if hr == nil {
+		t.Fatalf("task has no HTTP request: %+v", (*created)[0].Task)
+	}
+	if want := "https://my-project.appspot.com/_ah/queue/go/delay"; hr.Url != want || hr.HttpMethod != "POST" {
+		t.Errorf("task request = %s %s, want POST %s", hr.HttpMethod, hr.Url, want)
+	}
+	if tok := hr.OidcToken; tok == nil || tok.ServiceAccountEmail != testServiceAccount || tok.Audience != hr.Url {
+		t.Errorf("task OIDC token = %+v, want one for %s with audience %s", tok, testServiceAccount, hr.Url)
+	}
+
+	// The payload is the one of the legacy task, and runs the function.
+	payload, err := base64.StdEncoding.DecodeString(hr.Body)
+	if err != nil {
+		t.Fatalf("decoding task body: %v", err)
+	}
+	legacy, err := regFunc.Task("over the cloud")
+	if err != nil {
+		t.Fatalf("Task: %v", err)
+	}
+	if !bytes.Equal(payload, legacy.Payload) {
+		t.Errorf("task body differs from the payload of Function.Task")
+	}
+	req, _ := http.NewRequest("POST", hr.Url, bytes.NewReader(payload))
+	req.Header.Set("Authorization", "Bearer valid-"+testServiceAccount)
+	rec := httptest.NewRecorder()
+	serveFunc(c.ctx, rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("handler status = %d, want 200", rec.Code)
+	}
+	if regFuncRuns != 1 || regFuncMsg != "over the cloud" {
+		t.Errorf("function ran %d times with %q, want once with %q", regFuncRuns, regFuncMsg, "over the cloud")
+	}
+}
+
+func TestCloudTasksHandlerVerification(t *testing.T) {
+	fakeCloudTasks(t)
+	fakeValidateToken(t)
+	c := newFakeContext()
+	legacy, err := regFunc.Task("unverified")
+	if err != nil {
+		t.Fatalf("Task: %v", err)
+	}
+
+	tests := []struct {
+		desc, auth string
+		host       string // Host of the request; empty for the target host
+		want       int
+	}{
+		{"no token", "", "", http.StatusUnauthorized},
+		{"not a bearer token", "Basic dXNlcjpwYXNz", "", http.StatusUnauthorized},
+		{"invalid token", "Bearer forged", "", http.StatusUnauthorized},
+		{"other account", "Bearer valid-someone@example.com", "", http.StatusForbidden},
+		// A token of the account for another audience is not accepted by
+		// claiming that audience in the Host header.
+		{"spoofed host", "Bearer evil-" + testServiceAccount, "evil.example.com", http.StatusUnauthorized},
+	}
+	for _, tc := range tests {
+		regFuncRuns = 0
+		req, _ := http.NewRequest("POST", "https://my-project.appspot.com/_ah/queue/go/delay", bytes.NewReader(legacy.Payload))
+		if tc.host != "" {
+			req.Host = tc.host
+		}
+		if tc.auth != "" {
+			req.Header.Set("Authorization", tc.auth)
+		}
+		rec := httptest.NewRecorder()
+		serveFunc(c.ctx, rec, req)
+		if rec.Code != tc.want {
+			t.Errorf("%s: handler status = %d, want %d", tc.desc, rec.Code, tc.want)
+		}
+		if regFuncRuns != 0 {
+			t.Errorf("%s: function ran, want it rejected", tc.desc)
+		}
+	}
+}
+
+func TestCloudTasksHandlerSpoofedHost(t *testing.T) {
+	fakeCloudTasks(t)
+	fakeValidateToken(t)
+	c := newFakeContext()
+	legacy, err := regFunc.Task("spoofed")
+	if err != nil {
+		t.Fatalf("Task: %v", err)
+	}
+	// Without a configured host, the Host of the request is not used instead.
+	t.Setenv("DELAY_TARGET_HOST", "")
+	regFuncRuns = 0
+	req, _ := http.NewRequest("POST", "https://evil.example.com/_ah/queue/go/delay", bytes.NewReader(legacy.Payload))
+	req.Header.Set("Authorization", "Bearer evil-"+testServiceAccount)
+	rec := httptest.NewRecorder()
+	serveFunc(c.ctx, rec, req)
+	if rec.Code == http.StatusOK || regFuncRuns != 0 {
+		t.Errorf("handler status = %d with %d runs, want the request rejected", rec.Code, regFuncRuns)
+	}
+}
+
+func TestUseCloudTasks(t *testing.T) {
+	for v, want := range map[string]bool{"": false, "true": true, "yes": true, "ON": true, "no": false, "0": false} {
+		t.Setenv("DELAY_USE_CLOUD_TASKS", v)
+		if got, err := useCloudTasks(); got != want || err != nil {
+			t.Errorf("useCloudTasks with %q = %v, %v; want %v", v, got, err, want)
+		}
+	}
+	t.Setenv("DELAY_USE_CLOUD_TASKS", "sometimes")
+	if _, err := useCloudTasks(); err == nil || !strings.Contains(err.Error(), "DELAY_USE_CLOUD_TASKS") {
+		t.Errorf("useCloudTasks with an invalid value: got %v, want an error naming the variable", err)
+	}
+}
+
+func TestCloudTasksConfigErrors(t *testing.T) {
+	fakeCloudTasks(t)
+	c := newFakeContext()
+
+	t.Setenv("DELAY_USE_CLOUD_TASKS", "sometimes")
+	if err := regFunc.Call(c.ctx, "x"); err == nil {
+		t.Errorf("Call with an invalid DELAY_USE_CLOUD_TASKS succeeded, want an error")
+	}
+	t.Setenv("DELAY_USE_CLOUD_TASKS", "true")
+	t.Setenv("GOOGLE_CLOUD_PROJECT", "")
+	t.Setenv("GAE_APPLICATION", "")
+	if err := regFunc.Call(c.ctx, "x"); err == nil {
+		t.Errorf("Call without a project succeeded, want an error")
+	}
+}
@@ -54,6 +54,18 @@ The delay package uses the Task Queue API to create tasks that call the
 reserved application path "/_ah/queue/go/delay".
 This path must not be marked as "login: required" in app.yaml;
 it must be marked as "login: admin" or have no access restriction.
+
+On runtimes without the Task Queue API, setting the environment variable
+DELAY_USE_CLOUD_TASKS to true makes Call create Cloud Tasks HTTP tasks that
+call the same path with an OIDC token, which the handler verifies before
+running the function. The tasks are configured by these environment
+variables:
+  - DELAY_QUEUE: the queue, by default "default"
+  - DELAY_LOCATION: the location of the queue, by default the region of the app
+  - DELAY_TARGET_HOST: the host the tasks are sent to, by default that of the
+    default version of the service
+  - DELAY_SERVICE_ACCOUNT: the service account the tokens are issued to, by
+    default that of the app; the app needs roles/iam.serviceAccountUser on it
 */
 package delay // import "google.golang.org/appengine/delay"
 
@@ -217,11 +229,21 @@ type invocation struct {
 //
 //	t, _ := f.Task(...)
 //	_, err := taskqueue.Add(c, t, "")
+//
+// In the Cloud Tasks mode, an HTTP task with an equivalent request is created
+// with the Cloud Tasks API instead.
 func (f *Function) Call(c context.Context, args ...interface{}) error {
 	t, err := f.Task(args...)
 	if err != nil {
 		return err
 	}
+	cloud, err := useCloudTasks()
+	if err != nil {
+		return err
+	}
+	if cloud {
+		return addCloudTask(c, t)
+	}
 	_, err = taskqueueAdder(c, t, queue)
 	return err
 }
@@ -310,10 +332,29 @@ var taskqueueAdder = taskqueue.Add // for testing
 
 func init() {
 	http.HandleFunc(path, func(w http.ResponseWriter, req *http.Request) {
-		runFunc(appengine.NewContext(req), w, req)
+		serveFunc(appengine.NewContext(req), w, req)
 	})
 }
 
+// serveFunc handles a request of a task, checking its token first in the
+// Cloud Tasks mode.
+func serveFunc(c context.Context, w http.ResponseWriter, req *http.Request) {
+	cloud, err := useCloudTasks()
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if cloud {
+		if status, err := verifyTaskToken(c, req); err != nil {
+			log.Errorf(c, "delay: rejecting task request: %v", err)
+			http.Error(w, http.StatusText(status), status)
+			return
+		}
+	}
+	runFunc(c, w, req)
+}
+
 func runFunc(c context.Context, w http.ResponseWriter, req *http.Request) {
 	defer req.Body.Close()
 
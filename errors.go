@@ -13,10 +13,9 @@ import (
 )
 
 // IsOverQuota reports whether err represents an API call failure
-// due to insufficient available quota.
+// due to insufficient available quota. err may wrap such a failure.
 func IsOverQuota(err error) bool {
-	callErr, ok := err.(*internal.CallError)
-	return ok && callErr.Code == 4
+	return internal.IsOverQuota(err)
 }
 
 // MultiError is returned by batch operations when there are errors with
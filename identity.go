@@ -18,18 +18,40 @@ import (
 // domain prefix for custom domain deployments (e.g. "example.com:appid").
 func AppID(c context.Context) string { return internal.AppID(c) }
 
+// ParseFullAppID splits a fully-qualified application ID, as found in
+// $GAE_APPLICATION, into the project ID and the partition. The project ID
+// keeps the domain prefix of custom domain deployments, so
+// "s~example.com:appid" yields "example.com:appid" and "s". An ID without a
+// partition prefix yields an empty partition.
+func ParseFullAppID(fullAppID string) (projectID, partition string) {
+	return internal.ParseFullAppID(fullAppID)
+}
+
 // DefaultVersionHostname returns the standard hostname of the default version
 // of the current application (e.g. "my-app.appspot.com"). This is suitable for
 // use in constructing URLs.
+// On second-generation runtimes, where requests do not carry it, the hostname
+// is looked up with the App Engine Admin API if the module package is linked
+// into the program, and is the empty string otherwise; see
+// module.DefaultVersionHostname.
 func DefaultVersionHostname(c context.Context) string {
 	return internal.DefaultVersionHostname(c)
 }
 
 // ModuleName returns the module name of the current instance.
+// It reads $GAE_SERVICE, which second-generation runtimes set, and falls back
+// to the legacy runtime. If neither is available, it returns "default";
+// use ModuleNameErr to detect that case.
 func ModuleName(c context.Context) string {
 	return internal.ModuleName(c)
 }
 
+// ModuleNameErr is like ModuleName, but also returns an error if the module
+// name could not be determined, in which case the name is "default".
+func ModuleNameErr(c context.Context) (string, error) {
+	return internal.ModuleNameErr(c)
+}
+
 // ModuleHostname returns a hostname of a module instance.
 // If module is the empty string, it refers to the module of the current instance.
 // If version is empty, it refers to the version of the current instance if valid,
@@ -57,11 +79,26 @@ func ModuleHostname(c context.Context, module, version, instance string) (string
 // It will be of the form "X.Y", where X is specified in app.yaml,
 // and Y is a number generated when each version of the app is uploaded.
 // It does not include a module name.
+// On second-generation runtimes it is read from the environment, and Y is the
+// deployment ID; if the runtime does not provide one, the result is X alone.
+// If neither the environment nor the legacy runtime is available, it returns
+// the empty string; use VersionIDErr to detect that case.
 func VersionID(c context.Context) string { return internal.VersionID(c) }
 
+// VersionIDErr is like VersionID, but also returns an error if the version ID
+// could not be determined.
+func VersionIDErr(c context.Context) (string, error) { return internal.VersionIDErr(c) }
+
 // InstanceID returns a mostly-unique identifier for this instance.
+// It reads $GAE_INSTANCE, which second-generation runtimes set, and falls back
+// to the legacy runtime. If neither is available, it returns the empty string;
+// use InstanceIDErr to detect that case.
 func InstanceID() string { return internal.InstanceID() }
 
+// InstanceIDErr is like InstanceID, but also returns an error if the instance
+// ID could not be determined.
+func InstanceIDErr() (string, error) { return internal.InstanceIDErr() }
+
 // Datacenter returns an identifier for the datacenter that the instance is running in.
 func Datacenter(c context.Context) string { return internal.Datacenter(c) }
 
@@ -71,20 +108,28 @@ func Datacenter(c context.Context) string { return internal.Datacenter(c) }
 func ServerSoftware() string { return internal.ServerSoftware() }
 
 // RequestID returns a string that uniquely identifies the request.
+// On second-generation runtimes, it is read from the headers of the request
+// that c was created for with NewContext or WithContext: the request log ID if
+// present, or else the trace ID of the request.
 func RequestID(c context.Context) string { return internal.RequestID(c) }
 
 // AccessToken generates an OAuth2 access token for the specified scopes on
 // behalf of service account of this application. This token will expire after
 // the returned time.
+// Where the legacy App Identity API is not available, as on second-generation
+// runtimes, the token is obtained from the metadata server and cached until
+// shortly before it expires.
 func AccessToken(c context.Context, scopes ...string) (token string, expiry time.Time, err error) {
 	req := &pb.GetAccessTokenRequest{Scope: scopes}
 	res := &pb.GetAccessTokenResponse{}
 
-	err = internal.Call(c, "app_identity_service", "GetAccessToken", req, res)
-	if err != nil {
-		return "", time.Time{}, err
+	if ok, err := internal.LegacyIdentityCall(c, "GetAccessToken", req, res); ok {
+		if err != nil {
+			return "", time.Time{}, err
+		}
+		return res.GetAccessToken(), time.Unix(res.GetExpirationTime(), 0), nil
 	}
-	return res.GetAccessToken(), time.Unix(res.GetExpirationTime(), 0), nil
+	return internal.MetadataAccessToken(c, scopes)
 }
 
 // Certificate represents a public certificate for the app.
@@ -95,17 +140,39 @@ type Certificate struct {
 
 // PublicCertificates retrieves the public certificates for the app.
 // They can be used to verify a signature returned by SignBytes.
+// Where the legacy App Identity API is not available, as on second-generation
+// runtimes, they are the certificates of the keys of the account returned by
+// ServiceAccount, published by Google, and are cached for as long as Google
+// allows.
 func PublicCertificates(c context.Context) ([]Certificate, error) {
 	req := &pb.GetPublicCertificateForAppRequest{}
 	res := &pb.GetPublicCertificateForAppResponse{}
-	if err := internal.Call(c, "app_identity_service", "GetPublicCertificatesForApp", req, res); err != nil {
+	if ok, err := internal.LegacyIdentityCall(c, "GetPublicCertificatesForApp", req, res); ok {
+		if err != nil {
+			return nil, err
+		}
+		var cs []Certificate
+		for _, pc := range res.PublicCertificateList {
+			cs = append(cs, Certificate{
+				KeyName: pc.GetKeyName(),
+				Data:    []byte(pc.GetX509CertificatePem()),
+			})
+		}
+		return cs, nil
+	}
+	account, err := ServiceAccount(c)
+	if err != nil {
+		return nil, err
+	}
+	pcs, err := internal.ServiceAccountCertificates(c, account)
+	if err != nil {
 		return nil, err
 	}
 	var cs []Certificate
-	for _, pc := range res.PublicCertificateList {
+	for _, pc := range pcs {
 		cs = append(cs, Certificate{
-			KeyName: pc.GetKeyName(),
-			Data:    []byte(pc.GetX509CertificatePem()),
+			KeyName: pc.KeyName,
+			Data:    []byte(pc.PEM),
 		})
 	}
 	return cs, nil
@@ -113,26 +180,54 @@ func PublicCertificates(c context.Context) ([]Certificate, error) {
 
 // ServiceAccount returns a string representing the service account name, in
 // the form of an email address (typically app_id@appspot.gserviceaccount.com).
+// Where the legacy App Identity API is not available, as on second-generation
+// runtimes, it is the service account of the runtime as reported by the
+// metadata server, or else the default service account of the project; see
+// ServiceAccountSource.
 func ServiceAccount(c context.Context) (string, error) {
+	name, _, err := ServiceAccountSource(c)
+	return name, err
+}
+
+// ServiceAccountSource is like ServiceAccount, but also reports where the
+// name came from, for debugging: "legacy" for the legacy App Identity API,
+// "metadata" for the metadata server, or "project" if it was constructed from
+// the project ID as PROJECT_ID@appspot.gserviceaccount.com because neither
+// was available. Names from the metadata server or the project ID are cached.
+func ServiceAccountSource(c context.Context) (name, source string, err error) {
 	req := &pb.GetServiceAccountNameRequest{}
 	res := &pb.GetServiceAccountNameResponse{}
 
-	err := internal.Call(c, "app_identity_service", "GetServiceAccountName", req, res)
-	if err != nil {
-		return "", err
+	if ok, err := internal.LegacyIdentityCall(c, "GetServiceAccountName", req, res); ok {
+		if err != nil {
+			return "", "", err
+		}
+		return res.GetServiceAccountName(), "legacy", nil
 	}
-	return res.GetServiceAccountName(), err
+	return internal.ServiceAccount(c)
 }
 
 // SignBytes signs bytes using a private key unique to your application.
+// Where the legacy App Identity API is not available, as on second-generation
+// runtimes, the bytes are signed with a key of the account returned by
+// ServiceAccount, using the signBlob method of the IAM Credentials API. The
+// account then needs the iam.serviceAccounts.signBlob permission on itself,
+// and keyName is the ID of the key used.
 func SignBytes(c context.Context, bytes []byte) (keyName string, signature []byte, err error) {
 	req := &pb.SignForAppRequest{BytesToSign: bytes}
 	res := &pb.SignForAppResponse{}
 
-	if err := internal.Call(c, "app_identity_service", "SignForApp", req, res); err != nil {
+	if ok, err := internal.LegacyIdentityCall(c, "SignForApp", req, res); ok {
+		if err != nil {
+			return "", nil, err
+		}
+		return res.GetKeyName(), res.GetSignatureBytes(), nil
+	}
+	account, err := ServiceAccount(c)
+	if err != nil {
 		return "", nil, err
 	}
-	return res.GetKeyName(), res.GetSignatureBytes(), nil
+	return internal.SignBlob(c, account, bytes)
 }
 
 func init() {
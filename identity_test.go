@@ -0,0 +1,27 @@
+// Copyright 2026 Google LLC. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package appengine
+
+import (
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+
+	"google.golang.org/appengine/internal/aetesting"
+	pb "google.golang.org/appengine/internal/app_identity"
+)
+
+func TestServiceAccountSourceLegacy(t *testing.T) {
+	c := aetesting.FakeSingleContext(t, "app_identity_service", "GetServiceAccountName", func(req *pb.GetServiceAccountNameRequest, res *pb.GetServiceAccountNameResponse) error {
+		res.ServiceAccountName = proto.String("legacy-app@appspot.gserviceaccount.com")
+		return nil
+	})
+	// The legacy API takes precedence over the metadata server.
+	t.Setenv("GOOGLE_CLOUD_PROJECT", "my-project")
+	name, source, err := ServiceAccountSource(c)
+	if err != nil || name != "legacy-app@appspot.gserviceaccount.com" || source != "legacy" {
+		t.Errorf("ServiceAccountSource = %q, %q, %v; want the legacy name", name, source, err)
+	}
+}
@@ -0,0 +1,96 @@
+// Copyright 2026 Google LLC. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package aetesting
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"google.golang.org/appengine/internal"
+)
+
+// AdminProject is the project ID used by contexts returned from
+// FakeAdminContext.
+const AdminProject = "test-project"
+
+// AdminRequest describes a request received by a fake Admin API server.
+type AdminRequest struct {
+	Method     string
+	Path       string // e.g. "/v1/apps/test-project/services/default"
+	UpdateMask string
+	Query      url.Values
+	Header     http.Header
+	Body       []byte
+}
+
+// Decode unmarshals the JSON body of the request into v.
+func (r *AdminRequest) Decode(v interface{}) error {
+	return json.Unmarshal(r.Body, v)
+}
+
+// Expect fails the test unless the request has the given method, path and
+// update mask.
+func (r *AdminRequest) Expect(t *testing.T, method, path, updateMask string) {
+	t.Helper()
+	if r.Method != method || r.Path != path || r.UpdateMask != updateMask {
+		t.Errorf("Admin API request = %s %s (updateMask %q), want %s %s (updateMask %q)",
+			r.Method, r.Path, r.UpdateMask, method, path, updateMask)
+	}
+}
+
+// AdminHandler responds to a request to a fake Admin API server. It returns
+// the HTTP status code, where zero means 200, and a value to encode as the JSON
+// response. For error statuses, a string response is used as the message of an
+// Admin API error.
+type AdminHandler func(req *AdminRequest) (status int, resp interface{})
+
+// FakeAdminContext returns a context whose App Engine Admin API calls are
+// served by h. It enables the Admin API paths of the packages under test by
+// setting MODULES_USE_ADMIN_API and GOOGLE_CLOUD_PROJECT for the duration of
+// the test. The fake server is shut down when the test finishes.
+func FakeAdminContext(t *testing.T, h AdminHandler) context.Context {
+	t.Setenv("MODULES_USE_ADMIN_API", "true")
+	t.Setenv("GOOGLE_CLOUD_PROJECT", AdminProject)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("reading Admin API request body: %v", err)
+		}
+		req := &AdminRequest{
+			Method:     r.Method,
+			Path:       r.URL.Path,
+			UpdateMask: r.URL.Query().Get("updateMask"),
+			Query:      r.URL.Query(),
+			Header:     r.Header,
+			Body:       body,
+		}
+		status, resp := h(req)
+		if status == 0 {
+			status = http.StatusOK
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		if status >= 400 {
+			msg, ok := resp.(string)
+			if !ok {
+				msg = http.StatusText(status)
+			}
+			resp = map[string]interface{}{
+				"error": map[string]interface{}{"code": status, "message": msg},
+			}
+		}
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			t.Errorf("encoding Admin API response: %v", err)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return internal.WithAdminEndpointOverride(context.Background(), fmt.Sprintf("%s/", srv.URL))
+}
@@ -11,6 +11,8 @@ import (
 	"fmt"
 	"net/http"
 	"reflect"
+	"strings"
+	"sync"
 	"testing"
 
 	"github.com/golang/protobuf/proto"
@@ -21,31 +23,42 @@ import (
 // FakeSingleContext returns a context whose Call invocations will be serviced
 // by f, which should be a function that has two arguments of the input and output
 // protocol buffer type, and one error return.
+// Any number of calls to service.method is allowed.
 func FakeSingleContext(t *testing.T, service, method string, f interface{}) context.Context {
-	fv := reflect.ValueOf(f)
-	if fv.Kind() != reflect.Func {
-		t.Fatal("not a function")
-	}
-	ft := fv.Type()
-	if ft.NumIn() != 2 || ft.NumOut() != 1 {
-		t.Fatalf("f has %d in and %d out, want 2 in and 1 out", ft.NumIn(), ft.NumOut())
-	}
-	for i := 0; i < 2; i++ {
-		at := ft.In(i)
-		if !at.Implements(protoMessageType) {
-			t.Fatalf("arg %d does not implement proto.Message", i)
-		}
-	}
-	if ft.Out(0) != errorType {
-		t.Fatalf("f's return is %v, want error", ft.Out(0))
-	}
-	s := &single{
-		t:       t,
-		service: service,
-		method:  method,
-		f:       fv,
-	}
-	return internal.WithCallOverride(internal.ContextForTesting(&http.Request{}), s.call)
+	return FakeMultiContext(t, Expectation{Service: service, Method: method, F: f, Times: AnyTimes})
+}
+
+// AnyTimes is the Times of an Expectation that allows any number of calls,
+// including none.
+const AnyTimes = -1
+
+// Expectation is an API call expected by the context of FakeMultiContext.
+type Expectation struct {
+	Service, Method string
+
+	// F services the call. It has the same form as the function passed to
+	// FakeSingleContext.
+	F interface{}
+
+	// Times is the number of consecutive calls expected. Zero means one;
+	// AnyTimes allows any number.
+	Times int
+}
+
+// FakeMultiContext returns a context whose Call invocations are serviced by
+// the expectations, which must be met in order. A call that does not match the
+// next expectations fails the test, and so do expectations that have not been
+// met when the test ends.
+func FakeMultiContext(t *testing.T, expectations ...Expectation) context.Context {
+	return newMulti(t, false, expectations).context()
+}
+
+// FakeUnorderedContext is like FakeMultiContext, but a call is serviced by the
+// first unmet expectation for the same service and method, so that calls to
+// different methods may happen in any order, as when they are made
+// concurrently.
+func FakeUnorderedContext(t *testing.T, expectations ...Expectation) context.Context {
+	return newMulti(t, true, expectations).context()
 }
 
 var (
@@ -53,29 +66,139 @@ var (
 	errorType        = reflect.TypeOf((*error)(nil)).Elem()
 )
 
-type single struct {
-	t               *testing.T
-	service, method string
-	f               reflect.Value
+// reporter is the part of *testing.T used by multi, so that tests of this
+// package can observe the failures it reports.
+type reporter interface {
+	Helper()
+	Errorf(format string, args ...interface{})
+	Fatalf(format string, args ...interface{})
+	Cleanup(func())
+}
+
+// multi services the calls of a context of FakeMultiContext.
+type multi struct {
+	t         reporter
+	unordered bool
+
+	mu    sync.Mutex
+	exps  []*expectation
+	calls []string // calls serviced so far, for error messages
+}
+
+type expectation struct {
+	Expectation
+	f     reflect.Value
+	calls int
 }
 
-func (s *single) call(ctx context.Context, service, method string, in, out proto.Message) error {
+func newMulti(t reporter, unordered bool, expectations []Expectation) *multi {
+	t.Helper()
+	m := &multi{t: t, unordered: unordered}
+	for i, e := range expectations {
+		fv := reflect.ValueOf(e.F)
+		if fv.Kind() != reflect.Func {
+			t.Fatalf("expectation %d (/%s.%s): not a function", i, e.Service, e.Method)
+			return m
+		}
+		ft := fv.Type()
+		if ft.NumIn() != 2 || ft.NumOut() != 1 {
+			t.Fatalf("expectation %d (/%s.%s): f has %d in and %d out, want 2 in and 1 out", i, e.Service, e.Method, ft.NumIn(), ft.NumOut())
+			return m
+		}
+		for j := 0; j < 2; j++ {
+			if !ft.In(j).Implements(protoMessageType) {
+				t.Fatalf("expectation %d (/%s.%s): arg %d does not implement proto.Message", i, e.Service, e.Method, j)
+				return m
+			}
+		}
+		if ft.Out(0) != errorType {
+			t.Fatalf("expectation %d (/%s.%s): f's return is %v, want error", i, e.Service, e.Method, ft.Out(0))
+			return m
+		}
+		if e.Times == 0 {
+			e.Times = 1
+		}
+		m.exps = append(m.exps, &expectation{Expectation: e, f: fv})
+	}
+	t.Cleanup(m.verify)
+	return m
+}
+
+func (m *multi) context() context.Context {
+	return internal.WithCallOverride(internal.ContextForTesting(&http.Request{}), m.call)
+}
+
+func (e *expectation) met() bool  { return e.Times == AnyTimes || e.calls >= e.Times }
+func (e *expectation) full() bool { return e.Times != AnyTimes && e.calls >= e.Times }
+
+func (m *multi) call(ctx context.Context, service, method string, in, out proto.Message) error {
 	if service == "__go__" {
 		if method == "GetNamespace" {
 			return nil // always yield an empty namespace
 		}
 		return fmt.Errorf("Unknown API call /%s.%s", service, method)
 	}
-	if service != s.service || method != s.method {
-		s.t.Fatalf("Unexpected call to /%s.%s", service, method)
+	e := m.match(service, method)
+	if e == nil {
+		return fmt.Errorf("aetesting: unexpected call to /%s.%s", service, method)
 	}
-	ins := []reflect.Value{
-		reflect.ValueOf(in),
-		reflect.ValueOf(out),
-	}
-	outs := s.f.Call(ins)
+	outs := e.f.Call([]reflect.Value{reflect.ValueOf(in), reflect.ValueOf(out)})
 	if outs[0].IsNil() {
 		return nil
 	}
 	return outs[0].Interface().(error)
 }
+
+// match finds the expectation that services a call to service.method and
+// records the call, or reports the call as unexpected and returns nil.
+func (m *multi) match(service, method string) *expectation {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	name := "/" + service + "." + method
+	for _, e := range m.exps {
+		if e.full() {
+			continue
+		}
+		if e.Service == service && e.Method == method {
+			e.calls++
+			m.calls = append(m.calls, name)
+			return e
+		}
+		if !m.unordered && !e.met() {
+			break
+		}
+	}
+	m.t.Errorf("Unexpected call to %s after %v; want %s", name, m.calls, m.pending())
+	return nil
+}
+
+// pending describes the expectations that are not met yet. m.mu is held.
+func (m *multi) pending() string {
+	var p []string
+	for _, e := range m.exps {
+		if !e.met() {
+			p = append(p, fmt.Sprintf("/%s.%s (%d of %d calls made)", e.Service, e.Method, e.calls, e.Times))
+		}
+	}
+	if len(p) == 0 {
+		return "no more calls"
+	}
+	return strings.Join(p, ", ")
+}
+
+func (m *multi) verify() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, e := range m.exps {
+		if !e.met() {
+			m.t.Errorf("Missing calls: %s", m.pending())
+			return
+		}
+	}
+}
+
+// APIError returns the error that internal.Call reports when service answers
+// a call with the application error code and detail, for fakes to return.
+func APIError(service string, code int32, detail string) error {
+	return &internal.APIError{Service: service, Code: code, Detail: detail}
+}
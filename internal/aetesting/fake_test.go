@@ -0,0 +1,119 @@
+// Copyright 2026 Google LLC. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package aetesting
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+
+	"google.golang.org/appengine/internal"
+	basepb "google.golang.org/appengine/internal/base"
+)
+
+// failureLog is a reporter that records failures instead of failing the test.
+type failureLog struct {
+	errors   []string
+	cleanups []func()
+}
+
+func (r *failureLog) Helper() {}
+func (r *failureLog) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+func (r *failureLog) Fatalf(format string, args ...interface{}) { r.Errorf(format, args...) }
+func (r *failureLog) Cleanup(f func())                          { r.cleanups = append(r.cleanups, f) }
+
+func (r *failureLog) finish() {
+	for i := len(r.cleanups) - 1; i >= 0; i-- {
+		r.cleanups[i]()
+	}
+}
+
+// echo is an expectation handler that copies its input to its output.
+func echo(in, out *basepb.StringProto) error {
+	out.Value = proto.String(in.GetValue())
+	return nil
+}
+
+func call(ctx context.Context, service, method string) error {
+	return internal.Call(ctx, service, method, &basepb.StringProto{Value: proto.String(method)}, &basepb.StringProto{})
+}
+
+func TestFakeMultiContext(t *testing.T) {
+	tests := []struct {
+		name      string
+		unordered bool
+		exps      []Expectation
+		calls     []string // methods of service "s" called in order
+		wantErr   string   // substring of the reported failure; empty for none
+	}{
+		{
+			name:  "InOrder",
+			exps:  []Expectation{{Service: "s", Method: "A", F: echo}, {Service: "s", Method: "B", F: echo, Times: 2}},
+			calls: []string{"A", "B", "B"},
+		},
+		{
+			name:    "OutOfOrder",
+			exps:    []Expectation{{Service: "s", Method: "A", F: echo}, {Service: "s", Method: "B", F: echo}},
+			calls:   []string{"B", "A"},
+			wantErr: "Unexpected call to /s.B",
+		},
+		{
+			name:      "OutOfOrderUnordered",
+			unordered: true,
+			exps:      []Expectation{{Service: "s", Method: "A", F: echo}, {Service: "s", Method: "B", F: echo}},
+			calls:     []string{"B", "A"},
+		},
+		{
+			name:    "TooMany",
+			exps:    []Expectation{{Service: "s", Method: "A", F: echo}},
+			calls:   []string{"A", "A"},
+			wantErr: "Unexpected call to /s.A",
+		},
+		{
+			name:    "Unconsumed",
+			exps:    []Expectation{{Service: "s", Method: "A", F: echo}, {Service: "s", Method: "B", F: echo, Times: 2}},
+			calls:   []string{"A", "B"},
+			wantErr: "Missing calls: /s.B (1 of 2 calls made)",
+		},
+		{
+			name:  "AnyTimes",
+			exps:  []Expectation{{Service: "s", Method: "A", F: echo, Times: AnyTimes}, {Service: "s", Method: "B", F: echo}},
+			calls: []string{"B"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &failureLog{}
+			ctx := newMulti(r, tt.unordered, tt.exps).context()
+			for _, method := range tt.calls {
+				call(ctx, "s", method)
+			}
+			r.finish()
+			got := strings.Join(r.errors, "\n")
+			if tt.wantErr == "" && got != "" {
+				t.Errorf("reported %q, want no failure", got)
+			}
+			if tt.wantErr != "" && !strings.Contains(got, tt.wantErr) {
+				t.Errorf("reported %q, want a failure containing %q", got, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestFakeMultiContextServices(t *testing.T) {
+	ctx := FakeMultiContext(t, Expectation{Service: "s", Method: "Echo", F: echo})
+	out := &basepb.StringProto{}
+	if err := internal.Call(ctx, "s", "Echo", &basepb.StringProto{Value: proto.String("hi")}, out); err != nil {
+		t.Fatalf("Call: %v", err)
+	}
+	if out.GetValue() != "hi" {
+		t.Errorf("Call returned %q, want %q", out.GetValue(), "hi")
+	}
+}
@@ -0,0 +1,80 @@
+// Copyright 2026 Google LLC. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package aetesting
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/golang/protobuf/proto"
+
+	"google.golang.org/appengine/internal"
+)
+
+// RecordedCall is an API call captured by a Recorder.
+type RecordedCall struct {
+	Service, Method string
+	Request         proto.Message // a copy of the request as it was sent
+	Err             error         // error the call returned
+}
+
+// Recorder captures the API calls made with a context returned by Record.
+// It is safe for concurrent use.
+type Recorder struct {
+	mu    sync.Mutex
+	calls []RecordedCall
+}
+
+// Record returns a copy of ctx, typically one from FakeSingleContext or
+// FakeMultiContext, whose API calls are captured by the returned Recorder
+// before being serviced as they would be with ctx.
+func Record(ctx context.Context) (context.Context, *Recorder) {
+	r := &Recorder{}
+	return internal.WithCallOverride(ctx, func(ctx context.Context, service, method string, in, out proto.Message) error {
+		req := proto.Clone(in)
+		err := internal.Call(ctx, service, method, in, out)
+		r.mu.Lock()
+		r.calls = append(r.calls, RecordedCall{Service: service, Method: method, Request: req, Err: err})
+		r.mu.Unlock()
+		return err
+	}), r
+}
+
+// Calls returns the calls recorded so far, in the order they completed.
+func (r *Recorder) Calls() []RecordedCall {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]RecordedCall(nil), r.calls...)
+}
+
+// Count returns the number of calls to service.method recorded so far.
+func (r *Recorder) Count(service, method string) int {
+	n := 0
+	for _, c := range r.Calls() {
+		if c.Service == service && c.Method == method {
+			n++
+		}
+	}
+	return n
+}
+
+// Requests stores the requests of the calls to service.method recorded so far
+// in the slice that dst points to, such as a *[]*pb.SetNumInstancesRequest.
+// It panics if the requests do not have the element type of the slice.
+func (r *Recorder) Requests(service, method string, dst interface{}) {
+	v := reflect.ValueOf(dst)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Slice {
+		panic(fmt.Sprintf("aetesting: Requests needs a pointer to a slice, not %T", dst))
+	}
+	s := reflect.MakeSlice(v.Elem().Type(), 0, 0)
+	for _, c := range r.Calls() {
+		if c.Service == service && c.Method == method {
+			s = reflect.Append(s, reflect.ValueOf(c.Request))
+		}
+	}
+	v.Elem().Set(s)
+}
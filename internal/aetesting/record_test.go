@@ -0,0 +1,49 @@
+// Copyright 2026 Google LLC. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package aetesting
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+
+	"google.golang.org/appengine/internal"
+	basepb "google.golang.org/appengine/internal/base"
+)
+
+func TestRecorder(t *testing.T) {
+	errB := errors.New("B failed")
+	ctx, rec := Record(FakeMultiContext(t,
+		Expectation{Service: "s", Method: "A", F: echo, Times: 2},
+		Expectation{Service: "s", Method: "B", F: func(in, out *basepb.StringProto) error { return errB }},
+	))
+	for _, v := range []string{"one", "two"} {
+		in := &basepb.StringProto{Value: proto.String(v)}
+		if err := internal.Call(ctx, "s", "A", in, &basepb.StringProto{}); err != nil {
+			t.Fatalf("Call A: %v", err)
+		}
+		in.Value = proto.String("changed") // the recorded copy must not change
+	}
+	if err := internal.Call(ctx, "s", "B", &basepb.StringProto{}, &basepb.StringProto{}); err != errB {
+		t.Fatalf("Call B = %v, want %v", err, errB)
+	}
+
+	if n := rec.Count("s", "A"); n != 2 {
+		t.Errorf("Count(A) = %d, want 2", n)
+	}
+	if n := rec.Count("s", "C"); n != 0 {
+		t.Errorf("Count(C) = %d, want 0", n)
+	}
+	var reqs []*basepb.StringProto
+	rec.Requests("s", "A", &reqs)
+	if len(reqs) != 2 || reqs[0].GetValue() != "one" || reqs[1].GetValue() != "two" {
+		t.Errorf("Requests(A) = %v, want one, two", reqs)
+	}
+	calls := rec.Calls()
+	if len(calls) != 3 || calls[2].Method != "B" || calls[2].Err != errB {
+		t.Errorf("Calls = %+v, want 3 calls ending with the failed B", calls)
+	}
+}
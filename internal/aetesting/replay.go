@@ -0,0 +1,179 @@
+// Copyright 2026 Google LLC. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package aetesting
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// Interaction is a recorded HTTP request and its response, as stored in a
+// fixture file.
+type Interaction struct {
+	Method     string          `json:"method"`
+	Path       string          `json:"path"`
+	UpdateMask string          `json:"updateMask,omitempty"`
+	Request    json.RawMessage `json:"request,omitempty"`
+	Status     int             `json:"status"`
+	Response   json.RawMessage `json:"response,omitempty"`
+}
+
+func (i *Interaction) key() string {
+	return i.Method + " " + i.Path + "?updateMask=" + i.UpdateMask
+}
+
+// RecordTransport returns a transport that sends requests with base and
+// records every request/response pair. When the test finishes, the pairs are
+// written as JSON to the fixture file at path. Occurrences of project in paths
+// and bodies are replaced by AdminProject, so that the fixture can be replayed
+// against any project. Only the method, path, update mask and bodies are
+// stored; in particular, Authorization headers are never written.
+func RecordTransport(t *testing.T, path string, base http.RoundTripper, project string) http.RoundTripper {
+	r := &recorder{t: t, base: base, project: project}
+	t.Cleanup(func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		b, err := json.MarshalIndent(r.interactions, "", "  ")
+		if err != nil {
+			t.Errorf("encoding fixture %s: %v", path, err)
+			return
+		}
+		if err := ioutil.WriteFile(path, append(b, '\n'), 0644); err != nil {
+			t.Errorf("writing fixture: %v", err)
+		}
+	})
+	return r
+}
+
+type recorder struct {
+	t       *testing.T
+	base    http.RoundTripper
+	project string
+
+	mu           sync.Mutex
+	interactions []*Interaction
+}
+
+func (r *recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	var reqBody []byte
+	if req.Body != nil {
+		var err error
+		if reqBody, err = ioutil.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		req.Body.Close()
+		req.Body = ioutil.NopCloser(bytes.NewReader(reqBody))
+	}
+	resp, err := r.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(respBody))
+
+	in := &Interaction{
+		Method:     req.Method,
+		Path:       r.scrub(req.URL.Path),
+		UpdateMask: req.URL.Query().Get("updateMask"),
+		Request:    r.scrubJSON(reqBody),
+		Status:     resp.StatusCode,
+		Response:   r.scrubJSON(respBody),
+	}
+	r.mu.Lock()
+	r.interactions = append(r.interactions, in)
+	r.mu.Unlock()
+	return resp, nil
+}
+
+func (r *recorder) scrub(s string) string {
+	if r.project == "" {
+		return s
+	}
+	return strings.Replace(s, r.project, AdminProject, -1)
+}
+
+func (r *recorder) scrubJSON(b []byte) json.RawMessage {
+	if len(bytes.TrimSpace(b)) == 0 {
+		return nil
+	}
+	s := []byte(r.scrub(string(b)))
+	if !json.Valid(s) {
+		// Keep non-JSON bodies as strings.
+		s, _ = json.Marshal(string(s))
+	}
+	return s
+}
+
+// ReplayTransport returns a transport that serves requests from the fixture
+// file at path, written by RecordTransport. Requests are matched on method,
+// URL path and update mask; requests with the same key are answered in the
+// order they were recorded. A request without a matching interaction fails
+// the test.
+func ReplayTransport(t *testing.T, path string) http.RoundTripper {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading fixture: %v", err)
+	}
+	var interactions []*Interaction
+	if err := json.Unmarshal(b, &interactions); err != nil {
+		t.Fatalf("decoding fixture %s: %v", path, err)
+	}
+	r := &replayer{t: t, path: path, pending: make(map[string][]*Interaction)}
+	for _, in := range interactions {
+		r.pending[in.key()] = append(r.pending[in.key()], in)
+	}
+	return r
+}
+
+type replayer struct {
+	t    *testing.T
+	path string
+
+	mu      sync.Mutex
+	pending map[string][]*Interaction
+}
+
+func (r *replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+	key := (&Interaction{
+		Method:     req.Method,
+		Path:       req.URL.Path,
+		UpdateMask: req.URL.Query().Get("updateMask"),
+	}).key()
+	r.mu.Lock()
+	queue := r.pending[key]
+	var in *Interaction
+	if len(queue) > 0 {
+		in, r.pending[key] = queue[0], queue[1:]
+	}
+	r.mu.Unlock()
+	if in == nil {
+		r.t.Errorf("no interaction in %s matches %s", r.path, key)
+		return nil, fmt.Errorf("aetesting: unmatched request %s", key)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", in.Status, http.StatusText(in.Status)),
+		StatusCode:    in.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": {"application/json"}},
+		Body:          ioutil.NopCloser(bytes.NewReader(in.Response)),
+		ContentLength: int64(len(in.Response)),
+		Request:       req,
+	}, nil
+}
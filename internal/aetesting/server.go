@@ -0,0 +1,106 @@
+// Copyright 2026 Google LLC. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+//go:build !appengine
+// +build !appengine
+
+package aetesting
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+
+	"google.golang.org/appengine/internal"
+	remotepb "google.golang.org/appengine/internal/remote_api"
+)
+
+// APIServer is a fake API server started by FakeAPIServer.
+type APIServer struct {
+	Host, Port string
+}
+
+// FakeAPIServer starts an API server that services calls to service.method
+// with f, which has the same form as for FakeSingleContext, and directs the
+// API calls of the process to it with internal.SetAPIEndpoint until the end
+// of the test. Unlike FakeSingleContext, it tests the calls as they are sent
+// over the wire. It returns a context for making the calls and the server,
+// whose address can be passed to internal.SetAPIEndpoint to switch back to it
+// after another server has been started.
+func FakeAPIServer(t *testing.T, service, method string, f interface{}) (context.Context, *APIServer) {
+	fv := reflect.ValueOf(f)
+	if fv.Kind() != reflect.Func {
+		t.Fatal("not a function")
+	}
+	ft := fv.Type()
+	if ft.NumIn() != 2 || ft.NumOut() != 1 || !ft.In(0).Implements(protoMessageType) || !ft.In(1).Implements(protoMessageType) || ft.Out(0) != errorType {
+		t.Fatalf("f is %v, want a func(in, out proto.Message) error", ft)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		res := serveAPICall(t, service, method, fv, r)
+		b, err := proto.Marshal(res)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Write(b)
+	}))
+	t.Cleanup(srv.Close)
+
+	u, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatalf("url.Parse(%q): %v", srv.URL, err)
+	}
+	host, port, err := net.SplitHostPort(u.Host)
+	if err != nil {
+		t.Fatalf("net.SplitHostPort(%q): %v", u.Host, err)
+	}
+	internal.SetAPIEndpoint(host, port)
+	t.Cleanup(func() { internal.SetAPIEndpoint("", "") })
+	return internal.ContextForTesting(&http.Request{Header: http.Header{}}), &APIServer{Host: host, Port: port}
+}
+
+// serveAPICall decodes the API call in r, services it with f and returns the
+// response to send.
+func serveAPICall(t *testing.T, service, method string, f reflect.Value, r *http.Request) *remotepb.Response {
+	rpcError := func(code remotepb.RpcError_ErrorCode, detail string) *remotepb.Response {
+		return &remotepb.Response{RpcError: &remotepb.RpcError{Code: proto.Int32(int32(code)), Detail: proto.String(detail)}}
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return rpcError(remotepb.RpcError_UNKNOWN, err.Error())
+	}
+	req := &remotepb.Request{}
+	if err := proto.Unmarshal(body, req); err != nil {
+		return rpcError(remotepb.RpcError_PARSE_ERROR, err.Error())
+	}
+	if req.GetServiceName() != service || req.GetMethod() != method {
+		t.Errorf("Unexpected call to /%s.%s", req.GetServiceName(), req.GetMethod())
+		return rpcError(remotepb.RpcError_CALL_NOT_FOUND, "unexpected call")
+	}
+	ft := f.Type()
+	in := reflect.New(ft.In(0).Elem())
+	out := reflect.New(ft.In(1).Elem())
+	if err := proto.Unmarshal(req.Request, in.Interface().(proto.Message)); err != nil {
+		return rpcError(remotepb.RpcError_PARSE_ERROR, err.Error())
+	}
+	if errv := f.Call([]reflect.Value{in, out})[0]; !errv.IsNil() {
+		if apiErr, ok := errv.Interface().(*internal.APIError); ok {
+			return &remotepb.Response{ApplicationError: &remotepb.ApplicationError{Code: proto.Int32(apiErr.Code), Detail: proto.String(apiErr.Detail)}}
+		}
+		return rpcError(remotepb.RpcError_UNKNOWN, errv.Interface().(error).Error())
+	}
+	b, err := proto.Marshal(out.Interface().(proto.Message))
+	if err != nil {
+		return rpcError(remotepb.RpcError_UNKNOWN, err.Error())
+	}
+	return &remotepb.Response{Response: b}
+}
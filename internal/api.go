@@ -67,17 +67,41 @@ var (
 	}
 )
 
+// apiEndpoint holds the API server set with SetAPIEndpoint.
+var apiEndpoint struct {
+	sync.RWMutex
+	host, port string
+}
+
+// SetAPIEndpoint directs subsequent API calls to the API server at host and
+// port instead of the one named by $API_HOST and $API_PORT. Empty strings
+// restore the use of the environment. It is safe to call SetAPIEndpoint
+// concurrently with API calls.
+func SetAPIEndpoint(host, port string) {
+	apiEndpoint.Lock()
+	apiEndpoint.host, apiEndpoint.port = host, port
+	apiEndpoint.Unlock()
+}
+
 func apiURL(ctx context.Context) *url.URL {
 	host, port := "appengine.googleapis.internal", "10001"
 	if h := os.Getenv("API_HOST"); h != "" {
 		host = h
 	}
-	if hostOverride := ctx.Value(apiHostOverrideKey); hostOverride != nil {
-		host = hostOverride.(string)
-	}
 	if p := os.Getenv("API_PORT"); p != "" {
 		port = p
 	}
+	apiEndpoint.RLock()
+	if apiEndpoint.host != "" {
+		host = apiEndpoint.host
+	}
+	if apiEndpoint.port != "" {
+		port = apiEndpoint.port
+	}
+	apiEndpoint.RUnlock()
+	if hostOverride := ctx.Value(apiHostOverrideKey); hostOverride != nil {
+		host = hostOverride.(string)
+	}
 	if portOverride := ctx.Value(apiPortOverrideKey); portOverride != nil {
 		port = portOverride.(string)
 	}
@@ -284,20 +308,38 @@ func toContext(c *aeContext) context.Context {
 }
 
 func IncomingHeaders(ctx context.Context) http.Header {
-	if c := fromContext(ctx); c != nil {
-		return c.req.Header
+	if req := incomingRequest(ctx); req != nil {
+		return req.Header
 	}
 	return nil
 }
 
+var requestKey = "holds the *http.Request of a context without a *context"
+
+// incomingRequest returns the request ctx was made for, if any.
+func incomingRequest(ctx context.Context) *http.Request {
+	if c := fromContext(ctx); c != nil {
+		return c.req
+	}
+	req, _ := ctx.Value(&requestKey).(*http.Request)
+	return req
+}
+
 func ReqContext(req *http.Request) context.Context {
-	return req.Context()
+	ctx := req.Context()
+	if fromContext(ctx) == nil {
+		// The request did not go through Middleware, as on second-generation
+		// runtimes that do not use Main. Keep it for the functions that read
+		// its headers.
+		ctx = context.WithValue(ctx, &requestKey, req)
+	}
+	return ctx
 }
 
 func WithContext(parent context.Context, req *http.Request) context.Context {
 	return jointContext{
 		base:       parent,
-		valuesOnly: req.Context(),
+		valuesOnly: ReqContext(req),
 	}
 }
 
@@ -403,6 +445,7 @@ func post(ctx context.Context, body []byte, timeout time.Duration) (b []byte, er
 		return nil, &CallError{
 			Detail: fmt.Sprintf("service bridge HTTP failed: %v", err),
 			Code:   int32(remotepb.RpcError_UNKNOWN),
+			Err:    err,
 		}
 	}
 	defer hresp.Body.Close()
@@ -422,7 +465,7 @@ func post(ctx context.Context, body []byte, timeout time.Duration) (b []byte, er
 	return hrespBody, nil
 }
 
-func Call(ctx context.Context, service, method string, in, out proto.Message) error {
+func call(ctx context.Context, service, method string, in, out proto.Message) error {
 	if ns := NamespaceFromContext(ctx); ns != "" {
 		if fn, ok := NamespaceMods[service]; ok {
 			fn(in, ns)
@@ -432,6 +475,9 @@ func Call(ctx context.Context, service, method string, in, out proto.Message) er
 	if f, ctx, ok := callOverrideFromContext(ctx); ok {
 		return f(ctx, service, method, in, out)
 	}
+	if err := backgroundCallError(ctx, service, method); err != nil {
+		return err
+	}
 
 	// Handle already-done contexts quickly.
 	select {
@@ -451,10 +497,7 @@ func Call(ctx context.Context, service, method string, in, out proto.Message) er
 	}
 
 	// Default RPC timeout is 60s.
-	timeout := 60 * time.Second
-	if deadline, ok := ctx.Deadline(); ok {
-		timeout = deadline.Sub(time.Now())
-	}
+	timeout := callTimeout(ctx, 60*time.Second)
 
 	data, err := proto.Marshal(in)
 	if err != nil {
@@ -481,6 +524,12 @@ func Call(ctx context.Context, service, method string, in, out proto.Message) er
 
 	hrespBody, err := post(ctx, hreqBody, timeout)
 	if err != nil {
+		if ce, ok := err.(*CallError); ok {
+			// Copy, since post may return the shared errTimeout.
+			ce := *ce
+			ce.Service, ce.Method = service, method
+			return &ce
+		}
 		return err
 	}
 
@@ -490,8 +539,10 @@ func Call(ctx context.Context, service, method string, in, out proto.Message) er
 	}
 	if res.RpcError != nil {
 		ce := &CallError{
-			Detail: res.RpcError.GetDetail(),
-			Code:   *res.RpcError.Code,
+			Service: service,
+			Method:  method,
+			Detail:  res.RpcError.GetDetail(),
+			Code:    *res.RpcError.Code,
 		}
 		switch remotepb.RpcError_ErrorCode(ce.Code) {
 		case remotepb.RpcError_CANCELLED, remotepb.RpcError_DEADLINE_EXCEEDED:
@@ -502,6 +553,7 @@ func Call(ctx context.Context, service, method string, in, out proto.Message) er
 	if res.ApplicationError != nil {
 		return &APIError{
 			Service: *req.ServiceName,
+			Method:  method,
 			Detail:  res.ApplicationError.GetDetail(),
 			Code:    *res.ApplicationError.Code,
 		}
@@ -509,8 +561,10 @@ func Call(ctx context.Context, service, method string, in, out proto.Message) er
 	if res.Exception != nil || res.JavaException != nil {
 		// This shouldn't happen, but let's be defensive.
 		return &CallError{
-			Detail: "service bridge returned exception",
-			Code:   int32(remotepb.RpcError_UNKNOWN),
+			Service: service,
+			Method:  method,
+			Detail:  "service bridge returned exception",
+			Code:    int32(remotepb.RpcError_UNKNOWN),
 		}
 	}
 	return proto.Unmarshal(res.Response, out)
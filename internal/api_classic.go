@@ -12,7 +12,6 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
-	"time"
 
 	"appengine"
 	"appengine_internal"
@@ -88,7 +87,7 @@ func ContextForTesting(req *http.Request) context.Context {
 	return withContext(context.Background(), &testingContext{req: req})
 }
 
-func Call(ctx context.Context, service, method string, in, out proto.Message) error {
+func call(ctx context.Context, service, method string, in, out proto.Message) error {
 	if ns := NamespaceFromContext(ctx); ns != "" {
 		if fn, ok := NamespaceMods[service]; ok {
 			fn(in, ns)
@@ -98,6 +97,9 @@ func Call(ctx context.Context, service, method string, in, out proto.Message) er
 	if f, ctx, ok := callOverrideFromContext(ctx); ok {
 		return f(ctx, service, method, in, out)
 	}
+	if err := backgroundCallError(ctx, service, method); err != nil {
+		return err
+	}
 
 	// Handle already-done contexts quickly.
 	select {
@@ -121,9 +123,9 @@ func Call(ctx context.Context, service, method string, in, out proto.Message) er
 	}
 
 	var opts *appengine_internal.CallOptions
-	if d, ok := ctx.Deadline(); ok {
+	if timeout := callTimeout(ctx, 0); timeout != 0 {
 		opts = &appengine_internal.CallOptions{
-			Timeout: d.Sub(time.Now()),
+			Timeout: timeout,
 		}
 	}
 
@@ -132,11 +134,14 @@ func Call(ctx context.Context, service, method string, in, out proto.Message) er
 	case *appengine_internal.APIError:
 		return &APIError{
 			Service: v.Service,
+			Method:  method,
 			Detail:  v.Detail,
 			Code:    v.Code,
 		}
 	case *appengine_internal.CallError:
 		return &CallError{
+			Service: service,
+			Method:  method,
 			Detail:  v.Detail,
 			Code:    v.Code,
 			Timeout: v.Timeout,
@@ -8,6 +8,8 @@ import (
 	"context"
 	"errors"
 	"os"
+	"sync/atomic"
+	"time"
 
 	"github.com/golang/protobuf/proto"
 )
@@ -73,6 +75,61 @@ func withAPIPortOverride(ctx context.Context, apiPort string) context.Context {
 	return context.WithValue(ctx, apiPortOverrideKey, apiPort)
 }
 
+// CallHook is a function called after every API call made with Call.
+type CallHook func(service, method string, d time.Duration, err error)
+
+// callHook holds a callHookHolder, since an atomic.Value cannot hold nil.
+var callHook atomic.Value
+
+type callHookHolder struct{ f CallHook }
+
+// SetCallHook registers f to be called after every API call, with the
+// service and method called, the time the call took and the error it
+// returned. f is called synchronously, so it should return quickly. Passing
+// nil removes the hook. SetCallHook may be called at any time, including
+// concurrently with API calls.
+func SetCallHook(f CallHook) {
+	callHook.Store(callHookHolder{f})
+}
+
+// Call makes an API call to method of service, reporting it to the hook set
+// with SetCallHook.
+func Call(ctx context.Context, service, method string, in, out proto.Message) error {
+	h, _ := callHook.Load().(callHookHolder)
+	if h.f == nil {
+		return call(ctx, service, method, in, out)
+	}
+	start := time.Now()
+	err := call(ctx, service, method, in, out)
+	h.f(service, method, time.Since(start), err)
+	return err
+}
+
+var callTimeoutKey = "holds a time.Duration, being the timeout of each API call"
+
+// WithCallTimeout returns a copy of ctx in which each API call times out after
+// d instead of the default, without setting a deadline for ctx itself. A
+// deadline of ctx that is earlier still applies.
+func WithCallTimeout(ctx context.Context, d time.Duration) context.Context {
+	return context.WithValue(ctx, &callTimeoutKey, d)
+}
+
+// callTimeout returns the timeout of an API call made with ctx: the timeout
+// set with WithCallTimeout or else def, shortened to the time left until the
+// deadline of ctx. A zero result means that there is no timeout.
+func callTimeout(ctx context.Context, def time.Duration) time.Duration {
+	timeout := def
+	if d, ok := ctx.Value(&callTimeoutKey).(time.Duration); ok && d > 0 {
+		timeout = d
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		if left := time.Until(deadline); timeout == 0 || left < timeout {
+			timeout = left
+		}
+	}
+	return timeout
+}
+
 var namespaceKey = "holds the namespace string"
 
 func withNamespace(ctx context.Context, ns string) context.Context {
@@ -139,3 +196,19 @@ func SetTestEnv() func() {
 		}
 	}
 }
+
+var adminEndpointOverrideKey = ctxKey("holds a string, being the alternate App Engine Admin API endpoint")
+
+// WithAdminEndpointOverride returns a copy of ctx that causes App Engine Admin
+// API clients to send unauthenticated requests to endpoint. It is intended for
+// tests that serve the Admin API locally.
+func WithAdminEndpointOverride(ctx context.Context, endpoint string) context.Context {
+	return context.WithValue(ctx, adminEndpointOverrideKey, endpoint)
+}
+
+// AdminEndpointOverride returns the endpoint set by WithAdminEndpointOverride,
+// if any.
+func AdminEndpointOverride(ctx context.Context) (string, bool) {
+	endpoint, ok := ctx.Value(adminEndpointOverrideKey).(string)
+	return endpoint, ok
+}
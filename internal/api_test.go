@@ -11,6 +11,7 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -266,6 +267,83 @@ func TestAPICallDialFailure(t *testing.T) {
 	}
 }
 
+func TestAPICallTimeouts(t *testing.T) {
+	f, c, cleanup := setup()
+	defer cleanup()
+	f.hang = make(chan int)
+
+	testCases := []struct {
+		desc     string
+		ctx      func() (context.Context, context.CancelFunc)
+		deadline time.Duration // expected timeout of the call
+	}{
+		{
+			desc: "context deadline",
+			ctx: func() (context.Context, context.CancelFunc) {
+				return context.WithTimeout(toContext(c), 100*time.Millisecond)
+			},
+			deadline: 100 * time.Millisecond,
+		},
+		{
+			desc: "call timeout",
+			ctx: func() (context.Context, context.CancelFunc) {
+				return WithCallTimeout(toContext(c), 100*time.Millisecond), func() {}
+			},
+			deadline: 100 * time.Millisecond,
+		},
+		{
+			desc: "earlier context deadline",
+			ctx: func() (context.Context, context.CancelFunc) {
+				return context.WithTimeout(WithCallTimeout(toContext(c), time.Hour), 100*time.Millisecond)
+			},
+			deadline: 100 * time.Millisecond,
+		},
+		{
+			desc: "earlier call timeout",
+			ctx: func() (context.Context, context.CancelFunc) {
+				return context.WithTimeout(WithCallTimeout(toContext(c), 100*time.Millisecond), time.Hour)
+			},
+			deadline: 100 * time.Millisecond,
+		},
+	}
+	for _, tc := range testCases {
+		ctx, cancel := tc.ctx()
+		start := time.Now()
+		err := Call(ctx, "errors", "RunSlowly", &basepb.VoidProto{}, &basepb.VoidProto{})
+		elapsed := time.Since(start)
+		cancel()
+		f.hang <- 1 // release the HTTP handler
+
+		if !errors.Is(err, errTimeout) {
+			t.Errorf("%s: err = %v, want errTimeout", tc.desc, err)
+		}
+		if elapsed < tc.deadline || elapsed > tc.deadline+time.Second {
+			t.Errorf("%s: call returned after %v, want about %v", tc.desc, elapsed, tc.deadline)
+		}
+	}
+}
+
+func TestCallTimeout(t *testing.T) {
+	ctx := context.Background()
+	if got := callTimeout(ctx, time.Minute); got != time.Minute {
+		t.Errorf("callTimeout without deadline = %v, want the default", got)
+	}
+	if got := callTimeout(ctx, 0); got != 0 {
+		t.Errorf("callTimeout without deadline or default = %v, want none", got)
+	}
+	if got := callTimeout(WithCallTimeout(ctx, time.Second), time.Minute); got != time.Second {
+		t.Errorf("callTimeout with call timeout = %v, want 1s", got)
+	}
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Hour)
+	defer cancel()
+	if got := callTimeout(ctx, time.Minute); got != time.Minute {
+		t.Errorf("callTimeout with later deadline = %v, want the default", got)
+	}
+	if got := callTimeout(ctx, 0); got <= time.Hour || got > 2*time.Hour {
+		t.Errorf("callTimeout with deadline and no default = %v, want the deadline", got)
+	}
+}
+
 func TestDelayedLogFlushing(t *testing.T) {
 	defer restoreEnvVar(logserviceEnvVarKey)()
 
@@ -26,3 +26,10 @@ func appID(fullAppID string) string {
 	}
 	return dis
 }
+
+// ParseFullAppID is the implementation of the wrapper function of the same
+// name in ../identity.go. See that file for commentary.
+func ParseFullAppID(fullAppID string) (projectID, partition string) {
+	part, _, _ := parseFullAppID(fullAppID)
+	return appID(fullAppID), part
+}
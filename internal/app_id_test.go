@@ -32,3 +32,22 @@ func TestAppIDParsing(t *testing.T) {
 		}
 	}
 }
+
+func TestParseFullAppID(t *testing.T) {
+	testCases := []struct {
+		in                   string
+		projectID, partition string
+	}{
+		{"simple-app-id", "simple-app-id", ""},
+		{"s~partition-app-id", "partition-app-id", "s"},
+		{"e~domain.com:domain-app-id", "domain.com:domain-app-id", "e"},
+		{"domain.com:domain-app-id", "domain.com:domain-app-id", ""},
+	}
+
+	for _, tc := range testCases {
+		id, part := ParseFullAppID(tc.in)
+		if id != tc.projectID || part != tc.partition {
+			t.Errorf("ParseFullAppID(%q) = %q, %q; want %q, %q", tc.in, id, part, tc.projectID, tc.partition)
+		}
+	}
+}
@@ -0,0 +1,356 @@
+// Copyright 2026 Google LLC. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package internal
+
+// This file has the second-generation counterparts of the legacy
+// app_identity_service API, used by ../identity.go where that API is not
+// available.
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+
+	remotepb "google.golang.org/appengine/internal/remote_api"
+)
+
+// legacyIdentityDown is set to 1 once a call to the legacy app_identity_service
+// API has found it missing. Later calls go straight to the second-generation
+// implementations.
+var legacyIdentityDown int32
+
+// LegacyIdentityCall makes a call to the legacy app_identity_service API,
+// unless a previous call found it missing. It reports false if the API is
+// unavailable, in which case the caller should use the second-generation
+// implementation instead; otherwise it returns the result of the call. Only a
+// missing API is remembered: if the call cannot be made from ctx, or the
+// service bridge cannot be reached for now, later calls try the API again.
+func LegacyIdentityCall(ctx context.Context, method string, in, out proto.Message) (bool, error) {
+	if atomic.LoadInt32(&legacyIdentityDown) != 0 {
+		return false, nil
+	}
+	err := Call(ctx, "app_identity_service", method, in, out)
+	switch {
+	case err == nil:
+		return true, nil
+	case legacyMissing(err):
+		atomic.StoreInt32(&legacyIdentityDown, 1)
+		return false, nil
+	case legacyUnreachable(err):
+		return false, nil
+	}
+	return true, err
+}
+
+// legacyMissing reports whether err, returned by a legacy API call, means
+// that the legacy APIs do not exist for the app, as on second-generation
+// runtimes without bundled services: the service bridge does not know the
+// API, or there is no service bridge to resolve.
+func legacyMissing(err error) bool {
+	var ce *CallError
+	if !errors.As(err, &ce) {
+		return false
+	}
+	var dnsErr *net.DNSError
+	return remotepb.RpcError_ErrorCode(ce.Code) == remotepb.RpcError_CALL_NOT_FOUND ||
+		errors.As(ce.Err, &dnsErr) && dnsErr.IsNotFound
+}
+
+// legacyUnreachable reports whether err, returned by a legacy API call, means
+// that this one call could not reach the legacy APIs: ctx is not a request
+// context, or the service bridge could not be reached.
+func legacyUnreachable(err error) bool {
+	if errors.Is(err, ErrBackgroundContext) || errors.Is(err, errNotAppEngineContext) {
+		return true
+	}
+	var ce *CallError
+	return errors.As(err, &ce) && ce.Err != nil
+}
+
+// tokenRefreshMargin is how long before their expiry cached access tokens are
+// replaced.
+const tokenRefreshMargin = time.Minute
+
+type cachedToken struct {
+	token  string
+	expiry time.Time
+}
+
+var tokenCache struct {
+	sync.Mutex
+	tokens map[string]cachedToken // by scopeKey
+}
+
+// scopeKey returns the sorted, deduplicated scopes joined with commas, as the
+// metadata server expects them.
+func scopeKey(scopes []string) string {
+	s := append([]string(nil), scopes...)
+	sort.Strings(s)
+	n := 0
+	for i, scope := range s {
+		if scope != "" && (i == 0 || scope != s[i-1]) {
+			s[n] = scope
+			n++
+		}
+	}
+	return strings.Join(s[:n], ",")
+}
+
+// MetadataAccessToken returns an OAuth2 access token for the given scopes
+// from the token endpoint of the metadata server, which issues tokens for the
+// service account of the runtime. Without scopes, the token has the default
+// scopes of the account. Tokens are cached until shortly before they expire.
+func MetadataAccessToken(ctx context.Context, scopes []string) (string, time.Time, error) {
+	key := scopeKey(scopes)
+	tokenCache.Lock()
+	defer tokenCache.Unlock()
+	if t, ok := tokenCache.tokens[key]; ok && time.Until(t.expiry) > tokenRefreshMargin {
+		return t.token, t.expiry, nil
+	}
+
+	path := "instance/service-accounts/default/token"
+	if key != "" {
+		path += "?" + url.Values{"scopes": {key}}.Encode()
+	}
+	b, err := getMetadataContext(ctx, path)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("appengine: fetching access token from the metadata server: %v", err)
+	}
+	var res struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"` // seconds
+	}
+	if err := json.Unmarshal(b, &res); err != nil {
+		return "", time.Time{}, fmt.Errorf("appengine: decoding access token from the metadata server: %v", err)
+	}
+	if res.AccessToken == "" {
+		return "", time.Time{}, errors.New("appengine: metadata server returned no access token")
+	}
+	t := cachedToken{token: res.AccessToken, expiry: time.Now().Add(time.Duration(res.ExpiresIn) * time.Second)}
+	if tokenCache.tokens == nil {
+		tokenCache.tokens = make(map[string]cachedToken)
+	}
+	tokenCache.tokens[key] = t
+	return t.token, t.expiry, nil
+}
+
+// The sources of the service account reported by ServiceAccount.
+const (
+	ServiceAccountFromMetadata = "metadata" // the metadata server
+	ServiceAccountFromProject  = "project"  // constructed from the project ID
+)
+
+// serviceAccountRetryInterval is how long a service account constructed from
+// the project ID is remembered before the metadata server is asked again.
+const serviceAccountRetryInterval = time.Minute
+
+var serviceAccountCache struct {
+	sync.Mutex
+	name, source string
+	expiry       time.Time // zero if the name does not expire
+}
+
+// ServiceAccount returns the email address of the service account of the
+// runtime, as reported by the metadata server, and ServiceAccountFromMetadata.
+// If the metadata server is unavailable, it returns the default service
+// account of App Engine apps, PROJECT_ID@appspot.gserviceaccount.com, and
+// ServiceAccountFromProject. The result is cached.
+func ServiceAccount(ctx context.Context) (name, source string, err error) {
+	serviceAccountCache.Lock()
+	defer serviceAccountCache.Unlock()
+	c := &serviceAccountCache
+	if c.name != "" && (c.expiry.IsZero() || time.Now().Before(c.expiry)) {
+		return c.name, c.source, nil
+	}
+
+	b, merr := getMetadataContext(ctx, "instance/service-accounts/default/email")
+	if name := strings.TrimSpace(string(b)); merr == nil && name != "" {
+		c.name, c.source, c.expiry = name, ServiceAccountFromMetadata, time.Time{}
+		return c.name, c.source, nil
+	}
+	project := projectIDFromEnv()
+	if project == "" || strings.Contains(project, ":") {
+		// The default service accounts of domain-scoped projects have
+		// another form.
+		return "", "", fmt.Errorf("appengine: cannot determine the service account: metadata server: %v, and no project ID to construct it from", merr)
+	}
+	c.name, c.source = project+"@appspot.gserviceaccount.com", ServiceAccountFromProject
+	c.expiry = time.Now().Add(serviceAccountRetryInterval)
+	return c.name, c.source, nil
+}
+
+// iamCredentialsEndpoint is the base URL of the IAM Credentials API. It is a
+// variable so that tests can point it at a fake server.
+var iamCredentialsEndpoint = "https://iamcredentials.googleapis.com/v1/"
+
+const cloudPlatformScope = "https://www.googleapis.com/auth/cloud-platform"
+
+// SignBlob signs b with a system-managed private key of the service account
+// with the given email address, using the signBlob method of the IAM
+// Credentials API. It returns the ID of the key and the signature.
+func SignBlob(ctx context.Context, account string, b []byte) (keyName string, signature []byte, err error) {
+	token, _, err := MetadataAccessToken(ctx, []string{cloudPlatformScope})
+	if err != nil {
+		return "", nil, err
+	}
+	body, err := json.Marshal(map[string][]byte{"payload": b}) // []byte is encoded in base64
+	if err != nil {
+		return "", nil, err
+	}
+	u := iamCredentialsEndpoint + "projects/-/serviceAccounts/" + url.PathEscape(account) + ":signBlob"
+	req, err := http.NewRequestWithContext(ctx, "POST", u, bytes.NewReader(body))
+	if err != nil {
+		return "", nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", nil, fmt.Errorf("appengine: signBlob: %v", err)
+	}
+	defer resp.Body.Close()
+	rb, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", nil, fmt.Errorf("appengine: signBlob: reading response: %v", err)
+	}
+	switch {
+	case resp.StatusCode == http.StatusForbidden:
+		return "", nil, fmt.Errorf("appengine: signBlob: service account %s lacks the iam.serviceAccounts.signBlob permission; grant it the Service Account Token Creator role (roles/iam.serviceAccountTokenCreator) on itself: %s", account, apiErrorMessage(rb))
+	case resp.StatusCode != http.StatusOK:
+		return "", nil, fmt.Errorf("appengine: signBlob: HTTP %d: %s", resp.StatusCode, apiErrorMessage(rb))
+	}
+	var res struct {
+		KeyID      string `json:"keyId"`
+		SignedBlob []byte `json:"signedBlob"` // decoded from base64
+	}
+	if err := json.Unmarshal(rb, &res); err != nil {
+		return "", nil, fmt.Errorf("appengine: signBlob: decoding response: %v", err)
+	}
+	return res.KeyID, res.SignedBlob, nil
+}
+
+// apiErrorMessage returns the message of the JSON error response b of a
+// Google API, or b itself if it is not one.
+func apiErrorMessage(b []byte) string {
+	var res struct {
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if json.Unmarshal(b, &res) == nil && res.Error.Message != "" {
+		return res.Error.Message
+	}
+	return strings.TrimSpace(string(b))
+}
+
+// robotCertsEndpoint is the base URL of the public certificates of service
+// accounts. It is a variable so that tests can point it at a fake server.
+var robotCertsEndpoint = "https://www.googleapis.com/service_accounts/v1/metadata/x509/"
+
+// PublicCertificate is a PEM-encoded X.509 certificate of a service account
+// key, as returned by ServiceAccountCertificates.
+type PublicCertificate struct {
+	KeyName string
+	PEM     string
+}
+
+type cachedCerts struct {
+	certs  []PublicCertificate
+	expiry time.Time
+}
+
+var certCache struct {
+	sync.Mutex
+	certs map[string]cachedCerts // by service account
+}
+
+// ServiceAccountCertificates returns the public certificates of the keys of
+// the service account with the given email address, sorted by key name. They
+// are cached for as long as the Cache-Control header of the response allows.
+func ServiceAccountCertificates(ctx context.Context, account string) ([]PublicCertificate, error) {
+	certCache.Lock()
+	defer certCache.Unlock()
+	if c, ok := certCache.certs[account]; ok && time.Now().Before(c.expiry) {
+		return c.certs, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", robotCertsEndpoint+url.PathEscape(account), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("appengine: fetching public certificates: %v", err)
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("appengine: fetching public certificates: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("appengine: fetching public certificates of %s: HTTP %d: %s", account, resp.StatusCode, apiErrorMessage(b))
+	}
+	var byKey map[string]string
+	if err := json.Unmarshal(b, &byKey); err != nil {
+		return nil, fmt.Errorf("appengine: decoding public certificates: %v", err)
+	}
+	certs := make([]PublicCertificate, 0, len(byKey))
+	for key, data := range byKey {
+		if block, _ := pem.Decode([]byte(data)); block == nil || block.Type != "CERTIFICATE" {
+			return nil, fmt.Errorf("appengine: public certificate %s of %s is not a PEM-encoded certificate", key, account)
+		}
+		certs = append(certs, PublicCertificate{KeyName: key, PEM: data})
+	}
+	sort.Slice(certs, func(i, j int) bool { return certs[i].KeyName < certs[j].KeyName })
+
+	if maxAge := cacheMaxAge(resp.Header); maxAge > 0 {
+		if certCache.certs == nil {
+			certCache.certs = make(map[string]cachedCerts)
+		}
+		certCache.certs[account] = cachedCerts{certs: certs, expiry: time.Now().Add(maxAge)}
+	}
+	return certs, nil
+}
+
+// cacheMaxAge returns how long a response with header h may be cached, from
+// the max-age directive of its Cache-Control header or else its Expires
+// header. It returns zero if the response must not be cached.
+func cacheMaxAge(h http.Header) time.Duration {
+	cc := h.Get("Cache-Control")
+	for _, d := range strings.Split(cc, ",") {
+		d = strings.TrimSpace(strings.ToLower(d))
+		if d == "no-store" || d == "no-cache" {
+			return 0
+		}
+	}
+	for _, d := range strings.Split(cc, ",") {
+		if v, ok := strings.CutPrefix(strings.TrimSpace(strings.ToLower(d)), "max-age="); ok {
+			secs, err := strconv.Atoi(v)
+			if err != nil || secs < 0 {
+				return 0
+			}
+			return time.Duration(secs) * time.Second
+		}
+	}
+	if t, err := http.ParseTime(h.Get("Expires")); err == nil {
+		return time.Until(t)
+	}
+	return 0
+}
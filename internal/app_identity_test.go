@@ -0,0 +1,374 @@
+// Copyright 2026 Google LLC. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+//go:build !appengine
+// +build !appengine
+
+package internal
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+
+	pb "google.golang.org/appengine/internal/app_identity"
+	remotepb "google.golang.org/appengine/internal/remote_api"
+)
+
+// resetIdentity clears the state of the second-generation identity
+// implementations for the duration of a test.
+func resetIdentity(t *testing.T) {
+	reset := func() {
+		atomic.StoreInt32(&legacyIdentityDown, 0)
+		tokenCache.Lock()
+		tokenCache.tokens = nil
+		tokenCache.Unlock()
+	}
+	reset()
+	t.Cleanup(reset)
+}
+
+// fakeMetadataHandler points the metadata host at a server running h.
+func fakeMetadataHandler(t *testing.T, h http.HandlerFunc) {
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+	oldHost := metadataHost
+	metadataHost = strings.TrimPrefix(srv.URL, "http://")
+	t.Cleanup(func() { metadataHost = oldHost })
+}
+
+func TestLegacyIdentityCall(t *testing.T) {
+	resetIdentity(t)
+	var calls int
+	var callErr error
+	ctx := WithCallOverride(context.Background(), func(ctx context.Context, service, method string, in, out proto.Message) error {
+		calls++
+		if callErr != nil {
+			return callErr
+		}
+		out.(*pb.GetAccessTokenResponse).AccessToken = proto.String("legacy-token")
+		return nil
+	})
+	legacyCall := func(ctx context.Context) (bool, error) {
+		res := &pb.GetAccessTokenResponse{}
+		ok, err := LegacyIdentityCall(ctx, "GetAccessToken", &pb.GetAccessTokenRequest{}, res)
+		if ok && err == nil && res.GetAccessToken() != "legacy-token" {
+			t.Errorf("LegacyIdentityCall token = %q, want legacy-token", res.GetAccessToken())
+		}
+		return ok, err
+	}
+	if ok, err := legacyCall(ctx); !ok || err != nil {
+		t.Errorf("LegacyIdentityCall = %t, %v; want the legacy token", ok, err)
+	}
+
+	// Calls that cannot reach the API fall back only for themselves.
+	if ok, err := legacyCall(NewBackgroundContext(context.Background())); ok {
+		t.Errorf("LegacyIdentityCall from a background context = true, %v; want false", err)
+	}
+	callErr = &CallError{Detail: "service bridge HTTP failed", Err: errors.New("connection reset by peer")}
+	if ok, err := legacyCall(ctx); ok {
+		t.Errorf("LegacyIdentityCall with a transport error = true, %v; want false", err)
+	}
+	callErr = &CallError{Detail: "internal error", Code: int32(remotepb.RpcError_UNKNOWN)}
+	if ok, err := legacyCall(ctx); !ok || err == nil {
+		t.Errorf("LegacyIdentityCall with a failed call = %t, %v; want true and the error", ok, err)
+	}
+	callErr = nil
+	if ok, err := legacyCall(ctx); !ok || err != nil || calls != 4 {
+		t.Errorf("LegacyIdentityCall from a later request context = %t, %v with %d legacy calls; want the legacy token with 4", ok, err, calls)
+	}
+
+	// A missing API is not tried again.
+	for _, err := range []error{
+		&CallError{Detail: "unknown service", Code: int32(remotepb.RpcError_CALL_NOT_FOUND)},
+		&CallError{Detail: "service bridge HTTP failed", Err: &url.Error{Op: "Post", Err: &net.DNSError{Err: "no such host", Name: "appengine.googleapis.internal", IsNotFound: true}}},
+	} {
+		atomic.StoreInt32(&legacyIdentityDown, 0)
+		calls, callErr = 0, err
+		if ok, err := legacyCall(ctx); ok {
+			t.Errorf("LegacyIdentityCall with %v = true, %v; want false", callErr, err)
+		}
+		callErr = nil
+		if ok, _ := legacyCall(ctx); ok || calls != 1 {
+			t.Errorf("LegacyIdentityCall after %v = %t with %d legacy calls, want false with 1", err, ok, calls)
+		}
+	}
+}
+
+func TestMetadataAccessToken(t *testing.T) {
+	resetIdentity(t)
+	var requests []string
+	expiresIn := 3600
+	fakeMetadataHandler(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Metadata-Flavor") != "Google" {
+			t.Errorf("Metadata-Flavor = %q, want Google", r.Header.Get("Metadata-Flavor"))
+		}
+		if r.URL.Path != "/computeMetadata/v1/instance/service-accounts/default/token" {
+			http.NotFound(w, r)
+			return
+		}
+		scopes := r.URL.Query().Get("scopes")
+		requests = append(requests, scopes)
+		fmt.Fprintf(w, `{"access_token": "token%d", "expires_in": %d, "token_type": "Bearer"}`, len(requests), expiresIn)
+	})
+
+	ctx := context.Background()
+	tok, expiry, err := MetadataAccessToken(ctx, []string{"scope-b", "scope-a"})
+	if err != nil || tok != "token1" || expiry.IsZero() {
+		t.Fatalf("MetadataAccessToken = %q, %v, %v; want token1", tok, expiry, err)
+	}
+	// The same scopes in another order, or repeated, share the token.
+	if tok, _, err := MetadataAccessToken(ctx, []string{"scope-a", "scope-b", "scope-a"}); err != nil || tok != "token1" {
+		t.Errorf("cached MetadataAccessToken = %q, %v; want token1", tok, err)
+	}
+	if tok, _, err := MetadataAccessToken(ctx, nil); err != nil || tok != "token2" {
+		t.Errorf("MetadataAccessToken without scopes = %q, %v; want token2", tok, err)
+	}
+	if want := []string{"scope-a,scope-b", ""}; fmt.Sprint(requests) != fmt.Sprint(want) {
+		t.Errorf("requested scopes %q, want %q", requests, want)
+	}
+
+	// A token about to expire is replaced.
+	expiresIn = 30
+	if tok, _, err := MetadataAccessToken(ctx, []string{"scope-c"}); err != nil || tok != "token3" {
+		t.Errorf("MetadataAccessToken = %q, %v; want token3", tok, err)
+	}
+	if tok, _, err := MetadataAccessToken(ctx, []string{"scope-c"}); err != nil || tok != "token4" {
+		t.Errorf("MetadataAccessToken near expiry = %q, %v; want token4", tok, err)
+	}
+}
+
+func TestMetadataAccessTokenErrors(t *testing.T) {
+	resetIdentity(t)
+	fakeMetadataHandler(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("scopes") == "bad-json" {
+			fmt.Fprint(w, "not json")
+			return
+		}
+		http.Error(w, "no such scope", http.StatusBadRequest)
+	})
+	for _, scope := range []string{"bad-json", "unknown"} {
+		if tok, _, err := MetadataAccessToken(context.Background(), []string{scope}); err == nil {
+			t.Errorf("MetadataAccessToken(%q) = %q, want an error", scope, tok)
+		}
+	}
+}
+
+func TestSignBlob(t *testing.T) {
+	resetIdentity(t)
+	fakeMetadataHandler(t, func(w http.ResponseWriter, r *http.Request) {
+		if got := r.URL.Query().Get("scopes"); got != cloudPlatformScope {
+			t.Errorf("token scopes = %q, want %q", got, cloudPlatformScope)
+		}
+		fmt.Fprint(w, `{"access_token": "sa-token", "expires_in": 3600}`)
+	})
+	const account = "my-app@appspot.gserviceaccount.com"
+	status := http.StatusOK
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if want := "/v1/projects/-/serviceAccounts/" + account + ":signBlob"; r.Method != "POST" || r.URL.Path != want {
+			t.Errorf("request %s %s, want POST %s", r.Method, r.URL.Path, want)
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer sa-token" {
+			t.Errorf("Authorization = %q, want Bearer sa-token", got)
+		}
+		var req struct {
+			Payload string `json:"payload"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decoding request: %v", err)
+		}
+		if payload, err := base64.StdEncoding.DecodeString(req.Payload); err != nil || string(payload) != "\x00to sign\xff" {
+			t.Errorf("payload = %q (%v), want the bytes to sign in base64", req.Payload, err)
+		}
+		switch status {
+		case http.StatusOK:
+			fmt.Fprintf(w, `{"keyId": "key-1", "signedBlob": %q}`, base64.StdEncoding.EncodeToString([]byte("signature")))
+		default:
+			w.WriteHeader(status)
+			fmt.Fprint(w, `{"error": {"code": 403, "message": "Permission 'iam.serviceAccounts.signBlob' denied", "status": "PERMISSION_DENIED"}}`)
+		}
+	}))
+	defer srv.Close()
+	defer func(e string) { iamCredentialsEndpoint = e }(iamCredentialsEndpoint)
+	iamCredentialsEndpoint = srv.URL + "/v1/"
+
+	key, sig, err := SignBlob(context.Background(), account, []byte("\x00to sign\xff"))
+	if err != nil || key != "key-1" || string(sig) != "signature" {
+		t.Errorf("SignBlob = %q, %q, %v; want key-1, signature", key, sig, err)
+	}
+
+	status = http.StatusForbidden
+	_, _, err = SignBlob(context.Background(), account, []byte("\x00to sign\xff"))
+	if err == nil || !strings.Contains(err.Error(), "roles/iam.serviceAccountTokenCreator") || !strings.Contains(err.Error(), account) {
+		t.Errorf("SignBlob without permission: %v, want an error naming the account and the role to grant", err)
+	}
+	status = http.StatusInternalServerError
+	if _, _, err = SignBlob(context.Background(), account, []byte("\x00to sign\xff")); err == nil || !strings.Contains(err.Error(), "HTTP 500") {
+		t.Errorf("SignBlob on server error: %v, want an HTTP 500 error", err)
+	}
+}
+
+// testCertificatePEM returns a self-signed PEM-encoded certificate.
+func testCertificatePEM(t *testing.T) string {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{SerialNumber: big.NewInt(1), NotAfter: time.Now().Add(time.Hour)}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+}
+
+func TestServiceAccountCertificates(t *testing.T) {
+	defer func() {
+		certCache.Lock()
+		certCache.certs = nil
+		certCache.Unlock()
+	}()
+	const account = "my-app@appspot.gserviceaccount.com"
+	cert := testCertificatePEM(t)
+	var requests int
+	cacheControl := "public, max-age=3600"
+	body := fmt.Sprintf(`{"key-b": %q, "key-a": %q}`, cert, cert)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if want := "/x509/" + account; r.URL.Path != want {
+			t.Errorf("path = %q, want %q", r.URL.Path, want)
+		}
+		w.Header().Set("Cache-Control", cacheControl)
+		fmt.Fprint(w, body)
+	}))
+	defer srv.Close()
+	defer func(e string) { robotCertsEndpoint = e }(robotCertsEndpoint)
+	robotCertsEndpoint = srv.URL + "/x509/"
+
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		certs, err := ServiceAccountCertificates(ctx, account)
+		if err != nil {
+			t.Fatalf("ServiceAccountCertificates: %v", err)
+		}
+		if len(certs) != 2 || certs[0].KeyName != "key-a" || certs[1].KeyName != "key-b" || certs[0].PEM != cert {
+			t.Errorf("ServiceAccountCertificates = %+v, want key-a and key-b", certs)
+		}
+	}
+	if requests != 1 {
+		t.Errorf("%d requests for cached certificates, want 1", requests)
+	}
+
+	// Expired certificates are fetched again, and not cached if the response
+	// forbids it.
+	certCache.Lock()
+	c := certCache.certs[account]
+	c.expiry = time.Now().Add(-time.Second)
+	certCache.certs[account] = c
+	certCache.Unlock()
+	cacheControl = "no-cache"
+	for i := 0; i < 2; i++ {
+		if _, err := ServiceAccountCertificates(ctx, account); err != nil {
+			t.Fatalf("ServiceAccountCertificates: %v", err)
+		}
+	}
+	if requests != 3 {
+		t.Errorf("%d requests after expiry, want 3", requests)
+	}
+
+	body = `{"key-a": "not a certificate"}`
+	if certs, err := ServiceAccountCertificates(ctx, account); err == nil || !strings.Contains(err.Error(), "key-a") {
+		t.Errorf("ServiceAccountCertificates with malformed PEM = %+v, %v; want an error naming the key", certs, err)
+	}
+}
+
+func TestCacheMaxAge(t *testing.T) {
+	tests := []struct {
+		header http.Header
+		want   time.Duration
+	}{
+		{http.Header{"Cache-Control": {"public, max-age=19231, must-revalidate, no-transform"}}, 19231 * time.Second},
+		{http.Header{"Cache-Control": {"max-age=60, no-store"}}, 0},
+		{http.Header{"Cache-Control": {"max-age=bad"}}, 0},
+		{http.Header{}, 0},
+	}
+	for _, tt := range tests {
+		if got := cacheMaxAge(tt.header); got != tt.want {
+			t.Errorf("cacheMaxAge(%v) = %v, want %v", tt.header, got, tt.want)
+		}
+	}
+	h := http.Header{"Expires": {time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)}}
+	if got := cacheMaxAge(h); got < 59*time.Minute || got > time.Hour {
+		t.Errorf("cacheMaxAge(%v) = %v, want about an hour", h, got)
+	}
+}
+
+func TestServiceAccount(t *testing.T) {
+	reset := func() {
+		serviceAccountCache.Lock()
+		serviceAccountCache.name, serviceAccountCache.source, serviceAccountCache.expiry = "", "", time.Time{}
+		serviceAccountCache.Unlock()
+	}
+	tests := []struct {
+		name       string
+		email      string // served by the metadata server; empty if it is unavailable
+		project    string
+		wantName   string
+		wantSource string
+	}{
+		{"Metadata", "runtime@my-project.iam.gserviceaccount.com", "my-project", "runtime@my-project.iam.gserviceaccount.com", ServiceAccountFromMetadata},
+		{"NoMetadata", "", "my-project", "my-project@appspot.gserviceaccount.com", ServiceAccountFromProject},
+		{"NoMetadataDomainProject", "", "example.com:my-project", "", ""},
+		{"NoMetadataNoProject", "", "", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reset()
+			t.Cleanup(reset)
+			var requests int
+			fakeMetadataHandler(t, func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				if tt.email == "" || r.URL.Path != "/computeMetadata/v1/instance/service-accounts/default/email" {
+					http.NotFound(w, r)
+					return
+				}
+				fmt.Fprint(w, tt.email)
+			})
+			t.Setenv("GOOGLE_CLOUD_PROJECT", tt.project)
+			t.Setenv("GAE_APPLICATION", "")
+
+			for i := 0; i < 2; i++ {
+				name, source, err := ServiceAccount(context.Background())
+				if tt.wantName == "" {
+					if err == nil {
+						t.Errorf("ServiceAccount = %q, %q; want an error", name, source)
+					}
+				} else if err != nil || name != tt.wantName || source != tt.wantSource {
+					t.Errorf("ServiceAccount = %q, %q, %v; want %q, %q", name, source, err, tt.wantName, tt.wantSource)
+				}
+			}
+			if tt.wantName != "" && requests != 1 {
+				t.Errorf("metadata server asked %d times, want once", requests)
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+// Copyright 2026 Google LLC. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// ErrBackgroundContext is returned by API calls made with a context from
+// NewBackgroundContext, which has no incoming request to make them on behalf
+// of.
+var ErrBackgroundContext = errors.New("appengine: legacy API calls are not available from a background context")
+
+// BackgroundIdentity is the identity of the running app captured by
+// NewBackgroundContext.
+type BackgroundIdentity struct {
+	ProjectID string // e.g. "my-project", from GOOGLE_CLOUD_PROJECT or GAE_APPLICATION
+	Service   string // from GAE_SERVICE
+	Version   string // from GAE_VERSION, without the deployment ID
+}
+
+var backgroundKey = "holds a *BackgroundIdentity"
+
+// NewBackgroundContext is the implementation of the wrapper function of the
+// same name in ../appengine_vm.go. See that file for commentary.
+func NewBackgroundContext(parent context.Context) context.Context {
+	return WithBackgroundIdentity(parent, &BackgroundIdentity{
+		ProjectID: projectIDFromEnv(),
+		Service:   os.Getenv("GAE_SERVICE"),
+		Version:   os.Getenv("GAE_VERSION"),
+	})
+}
+
+// WithBackgroundIdentity returns a background context like those from
+// NewBackgroundContext, with the identity id instead of that taken from the
+// environment. It is used by aetest to run instances side by side.
+func WithBackgroundIdentity(parent context.Context, id *BackgroundIdentity) context.Context {
+	return context.WithValue(parent, &backgroundKey, id)
+}
+
+// projectIDFromEnv returns the project ID of the running app from the
+// GOOGLE_CLOUD_PROJECT or GAE_APPLICATION environment variables.
+func projectIDFromEnv() string {
+	if p := os.Getenv("GOOGLE_CLOUD_PROJECT"); p != "" {
+		return p
+	}
+	p, _ := ParseFullAppID(os.Getenv("GAE_APPLICATION"))
+	return p
+}
+
+// ProjectID returns the project ID of the running app: that of the identity
+// of ctx if it is a background context, and otherwise that from the
+// GOOGLE_CLOUD_PROJECT or GAE_APPLICATION environment variables.
+func ProjectID(ctx context.Context) string {
+	if id := BackgroundIdentityFromContext(ctx); id != nil && id.ProjectID != "" {
+		return id.ProjectID
+	}
+	return projectIDFromEnv()
+}
+
+// BackgroundIdentityFromContext returns the identity carried by a context
+// from NewBackgroundContext, or nil if ctx is not such a context.
+func BackgroundIdentityFromContext(ctx context.Context) *BackgroundIdentity {
+	id, _ := ctx.Value(&backgroundKey).(*BackgroundIdentity)
+	return id
+}
+
+// backgroundCallError returns the error of an API call to service.method made
+// with ctx if ctx is a background context, and nil otherwise.
+func backgroundCallError(ctx context.Context, service, method string) error {
+	if BackgroundIdentityFromContext(ctx) == nil {
+		return nil
+	}
+	return fmt.Errorf("%s: %w", callName(service, method), ErrBackgroundContext)
+}
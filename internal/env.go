@@ -0,0 +1,35 @@
+// Copyright 2026 Google LLC. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package internal
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// EnvBool reports whether the environment variable name is set to a true
+// value, as the packages do for the variables that select their backends.
+// Besides the spellings accepted by strconv.ParseBool, "yes", "on", "no" and
+// "off" are recognized, ignoring case and surrounding whitespace. An unset or
+// empty variable is false. An error, which callers prefix with the name of
+// their package, is returned for any other value.
+func EnvBool(name string) (bool, error) {
+	s := strings.ToLower(strings.TrimSpace(os.Getenv(name)))
+	switch s {
+	case "":
+		return false, nil
+	case "yes", "on":
+		return true, nil
+	case "no", "off":
+		return false, nil
+	}
+	v, err := strconv.ParseBool(s)
+	if err != nil {
+		return false, fmt.Errorf("invalid boolean value %q for %s", os.Getenv(name), name)
+	}
+	return v, nil
+}
@@ -0,0 +1,40 @@
+// Copyright 2026 Google LLC. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package internal
+
+import "testing"
+
+func TestEnvBool(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    bool
+		wantErr bool
+	}{
+		{"", false, false},
+		{"true", true, false},
+		{"True ", true, false},
+		{" TRUE", true, false},
+		{"1", true, false},
+		{"t", true, false},
+		{"yes", true, false},
+		{"Yes", true, false},
+		{"on", true, false},
+		{"ON", true, false},
+		{"false", false, false},
+		{"0", false, false},
+		{"no", false, false},
+		{"off", false, false},
+		{"enabled", false, true},
+		{"2", false, true},
+		{"tru", false, true},
+	}
+	for _, tt := range tests {
+		t.Setenv("APPENGINE_TEST_BOOL", tt.value)
+		got, err := EnvBool("APPENGINE_TEST_BOOL")
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("EnvBool(%q) = %v, %v; want %v, error %v", tt.value, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
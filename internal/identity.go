@@ -6,6 +6,7 @@ package internal
 
 import (
 	"context"
+	"errors"
 	"os"
 )
 
@@ -26,6 +27,104 @@ func AppID(c context.Context) string {
 	return appID(FullyQualifiedAppID(c))
 }
 
+var (
+	errNoModuleName = errors.New("appengine: cannot determine the module name: GAE_SERVICE is not set and the legacy runtime is not available")
+	errNoInstanceID = errors.New("appengine: cannot determine the instance ID: GAE_INSTANCE is not set and the legacy runtime is not available")
+	errNoVersionID  = errors.New("appengine: cannot determine the version ID: GAE_VERSION is not set and the legacy runtime is not available")
+)
+
+// DefaultVersionHostnameFallback, if set, supplies the default version
+// hostname when the request does not carry it, as on second-generation
+// runtimes. The module package sets it to look the hostname up with the App
+// Engine Admin API.
+var DefaultVersionHostnameFallback func(ctx context.Context) string
+
+// ModuleName is the implementation of the wrapper function of the same name in
+// ../identity.go. See that file for commentary.
+func ModuleName(c context.Context) string {
+	m, _ := ModuleNameErr(c)
+	return m
+}
+
+// ModuleNameErr is the implementation of the wrapper function of the same name
+// in ../identity.go. See that file for commentary.
+func ModuleNameErr(c context.Context) (string, error) {
+	if id := BackgroundIdentityFromContext(c); id != nil && id.Service != "" {
+		return id.Service, nil
+	}
+	if s := os.Getenv("GAE_SERVICE"); s != "" {
+		return s, nil
+	}
+	s, err := legacyModuleName(c)
+	if err == nil && s == "" {
+		err = errNoModuleName
+	}
+	if err != nil {
+		return "default", err
+	}
+	return s, nil
+}
+
+// VersionID is the implementation of the wrapper function of the same name in
+// ../identity.go. See that file for commentary.
+func VersionID(c context.Context) string {
+	v, _ := VersionIDErr(c)
+	return v
+}
+
+// VersionIDErr is the implementation of the wrapper function of the same name
+// in ../identity.go. See that file for commentary.
+func VersionIDErr(c context.Context) (string, error) {
+	if id := BackgroundIdentityFromContext(c); id != nil && id.Version != "" {
+		return id.Version, nil
+	}
+	// The flexible environment sets the full version with its minor version
+	// in the legacy variables, besides $GAE_VERSION.
+	if s1, s2 := os.Getenv("GAE_MODULE_VERSION"), os.Getenv("GAE_MINOR_VERSION"); s1 != "" && s2 != "" {
+		return s1 + "." + s2, nil
+	}
+	// Second-generation runtimes have no legacy identity service.
+	// $GAE_DEPLOYMENT_ID supplies the minor version; if it is unset, the
+	// result is $GAE_VERSION alone, with no minor version.
+	if s := os.Getenv("GAE_VERSION"); s != "" {
+		if minor := os.Getenv("GAE_DEPLOYMENT_ID"); minor != "" {
+			return s + "." + minor, nil
+		}
+		return s, nil
+	}
+	s, err := legacyVersionID(c)
+	if err == nil && s == "" {
+		err = errNoVersionID
+	}
+	if err != nil {
+		return "", err
+	}
+	return s, nil
+}
+
+// InstanceID is the implementation of the wrapper function of the same name in
+// ../identity.go. See that file for commentary.
+func InstanceID() string {
+	i, _ := InstanceIDErr()
+	return i
+}
+
+// InstanceIDErr is the implementation of the wrapper function of the same name
+// in ../identity.go. See that file for commentary.
+func InstanceIDErr() (string, error) {
+	if s := os.Getenv("GAE_INSTANCE"); s != "" {
+		return s, nil
+	}
+	s, err := legacyInstanceID()
+	if err == nil && s == "" {
+		err = errNoInstanceID
+	}
+	if err != nil {
+		return "", err
+	}
+	return s, nil
+}
+
 // IsStandard is the implementation of the wrapper function of the same name in
 // ../appengine.go. See that file for commentary.
 func IsStandard() bool {
@@ -52,3 +151,23 @@ func IsFlex() bool {
 func IsAppEngine() bool {
 	return IsStandard() || IsFlex()
 }
+
+// Environment is the implementation of the wrapper function of the same name
+// in ../appengine.go. See that file for commentary.
+func Environment() (local bool, reason string) {
+	if s := os.Getenv("APPENGINE_DEV_MODE"); s != "" {
+		if b, err := EnvBool("APPENGINE_DEV_MODE"); err == nil {
+			return b, "APPENGINE_DEV_MODE is " + s
+		}
+	}
+	if IsDevAppServer() {
+		return true, "running in the development server"
+	}
+	if IsAppEngine() {
+		return false, "running on App Engine"
+	}
+	if metadataServerAvailable() {
+		return false, "the metadata server is available"
+	}
+	return true, "no App Engine environment and no metadata server"
+}
@@ -9,6 +9,7 @@ package internal
 
 import (
 	"context"
+	"os"
 
 	"appengine"
 )
@@ -27,7 +28,6 @@ func DefaultVersionHostname(ctx context.Context) string {
 
 func Datacenter(_ context.Context) string { return appengine.Datacenter() }
 func ServerSoftware() string              { return appengine.ServerSoftware() }
-func InstanceID() string                  { return appengine.InstanceID() }
 func IsDevAppServer() bool                { return appengine.IsDevAppServer() }
 
 func RequestID(ctx context.Context) string {
@@ -38,24 +38,29 @@ func RequestID(ctx context.Context) string {
 	return appengine.RequestID(c)
 }
 
-func ModuleName(ctx context.Context) string {
+func legacyModuleName(ctx context.Context) (string, error) {
 	c := fromContext(ctx)
 	if c == nil {
-		panic(errNotAppEngineContext)
+		return "", errNotAppEngineContext
 	}
-	return appengine.ModuleName(c)
+	return appengine.ModuleName(c), nil
 }
-func VersionID(ctx context.Context) string {
+func legacyVersionID(ctx context.Context) (string, error) {
 	c := fromContext(ctx)
 	if c == nil {
-		panic(errNotAppEngineContext)
+		return "", errNotAppEngineContext
 	}
-	return appengine.VersionID(c)
+	return appengine.VersionID(c), nil
 }
 
+func legacyInstanceID() (string, error) { return appengine.InstanceID(), nil }
+
 func fullyQualifiedAppID(ctx context.Context) string {
 	c := fromContext(ctx)
 	if c == nil {
+		if s := os.Getenv("GAE_APPLICATION"); s != "" {
+			return s
+		}
 		panic(errNotAppEngineContext)
 	}
 	return c.FullyQualifiedAppID()
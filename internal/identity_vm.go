@@ -9,7 +9,6 @@ package internal
 
 import (
 	"context"
-	"log"
 	"net/http"
 	"os"
 	"strings"
@@ -25,19 +24,30 @@ const (
 )
 
 func ctxHeaders(ctx context.Context) http.Header {
-	c := fromContext(ctx)
-	if c == nil {
-		return nil
-	}
-	return c.Request().Header
+	return IncomingHeaders(ctx)
 }
 
 func DefaultVersionHostname(ctx context.Context) string {
-	return ctxHeaders(ctx).Get(hDefaultVersionHostname)
+	if h := ctxHeaders(ctx).Get(hDefaultVersionHostname); h != "" {
+		return h
+	}
+	if f := DefaultVersionHostnameFallback; f != nil {
+		return f(ctx)
+	}
+	return ""
 }
 
 func RequestID(ctx context.Context) string {
-	return ctxHeaders(ctx).Get(hRequestLogId)
+	h := ctxHeaders(ctx)
+	if id := h.Get(hRequestLogId); id != "" {
+		return id
+	}
+	// The trace context has the form TRACE_ID/SPAN_ID;o=OPTIONS.
+	tc := h.Get(traceHeader)
+	if i := strings.IndexAny(tc, "/;"); i != -1 {
+		tc = tc[:i]
+	}
+	return tc
 }
 
 func Datacenter(ctx context.Context) string {
@@ -45,17 +55,7 @@ func Datacenter(ctx context.Context) string {
 		return dc
 	}
 	// If the header isn't set, read zone from the metadata service.
-	// It has the format projects/[NUMERIC_PROJECT_ID]/zones/[ZONE]
-	zone, err := getMetadata("instance/zone")
-	if err != nil {
-		log.Printf("Datacenter: %v", err)
-		return ""
-	}
-	parts := strings.Split(string(zone), "/")
-	if len(parts) == 0 {
-		return ""
-	}
-	return parts[len(parts)-1]
+	return metadataZone()
 }
 
 func ServerSoftware() string {
@@ -71,34 +71,48 @@ func ServerSoftware() string {
 
 // TODO(dsymonds): Remove the metadata fetches.
 
-func ModuleName(_ context.Context) string {
+func legacyModuleName(_ context.Context) (string, error) {
 	if s := os.Getenv("GAE_MODULE_NAME"); s != "" {
-		return s
+		return s, nil
 	}
-	if s := os.Getenv("GAE_SERVICE"); s != "" {
-		return s
+	// Off App Engine there is no metadata server to ask.
+	if !IsAppEngine() {
+		return "", errNoModuleName
 	}
-	return string(mustGetMetadata("instance/attributes/gae_backend_name"))
+	b, err := getMetadata("instance/attributes/gae_backend_name")
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
 }
 
-func VersionID(_ context.Context) string {
-	if s1, s2 := os.Getenv("GAE_MODULE_VERSION"), os.Getenv("GAE_MINOR_VERSION"); s1 != "" && s2 != "" {
-		return s1 + "." + s2
+func legacyVersionID(_ context.Context) (string, error) {
+	if !IsAppEngine() {
+		return "", errNoVersionID
 	}
-	if s1, s2 := os.Getenv("GAE_VERSION"), os.Getenv("GAE_DEPLOYMENT_ID"); s1 != "" && s2 != "" {
-		return s1 + "." + s2
+	major, err := getMetadata("instance/attributes/gae_backend_version")
+	if err != nil {
+		return "", err
+	}
+	minor, err := getMetadata("instance/attributes/gae_backend_minor_version")
+	if err != nil {
+		return "", err
 	}
-	return string(mustGetMetadata("instance/attributes/gae_backend_version")) + "." + string(mustGetMetadata("instance/attributes/gae_backend_minor_version"))
+	return string(major) + "." + string(minor), nil
 }
 
-func InstanceID() string {
+func legacyInstanceID() (string, error) {
 	if s := os.Getenv("GAE_MODULE_INSTANCE"); s != "" {
-		return s
+		return s, nil
 	}
-	if s := os.Getenv("GAE_INSTANCE"); s != "" {
-		return s
+	if !IsAppEngine() {
+		return "", errNoInstanceID
+	}
+	b, err := getMetadata("instance/attributes/gae_backend_instance")
+	if err != nil {
+		return "", err
 	}
-	return string(mustGetMetadata("instance/attributes/gae_backend_instance"))
+	return string(b), nil
 }
 
 func partitionlessAppID() string {
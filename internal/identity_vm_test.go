@@ -0,0 +1,286 @@
+// Copyright 2026 Google LLC. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+//go:build !appengine
+// +build !appengine
+
+package internal
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestVersionID(t *testing.T) {
+	testCases := []struct {
+		desc string
+		env  map[string]string
+		want string
+	}{
+		{
+			desc: "second-gen",
+			env:  map[string]string{"GAE_VERSION": "v1", "GAE_DEPLOYMENT_ID": "123"},
+			want: "v1.123",
+		},
+		{
+			desc: "second-gen without deployment ID",
+			env:  map[string]string{"GAE_VERSION": "v1"},
+			want: "v1",
+		},
+		{
+			desc: "legacy",
+			env:  map[string]string{"GAE_MODULE_VERSION": "v2", "GAE_MINOR_VERSION": "456"},
+			want: "v2.456",
+		},
+		{
+			desc: "both, full legacy version wins",
+			env: map[string]string{
+				"GAE_VERSION": "v1", "GAE_DEPLOYMENT_ID": "123",
+				"GAE_MODULE_VERSION": "v2", "GAE_MINOR_VERSION": "456",
+			},
+			want: "v2.456",
+		},
+		{
+			desc: "flex, keeps the minor version",
+			env: map[string]string{
+				"GAE_VERSION":        "v2",
+				"GAE_MODULE_VERSION": "v2", "GAE_MINOR_VERSION": "456",
+			},
+			want: "v2.456",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			for _, k := range []string{"GAE_VERSION", "GAE_DEPLOYMENT_ID", "GAE_MODULE_VERSION", "GAE_MINOR_VERSION"} {
+				t.Setenv(k, tc.env[k])
+			}
+			if got := VersionID(context.Background()); got != tc.want {
+				t.Errorf("VersionID = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestModuleNameErr(t *testing.T) {
+	testCases := []struct {
+		desc    string
+		env     map[string]string
+		want    string
+		wantErr bool
+	}{
+		{desc: "second-gen", env: map[string]string{"GAE_SERVICE": "worker"}, want: "worker"},
+		{desc: "legacy", env: map[string]string{"GAE_MODULE_NAME": "backend"}, want: "backend"},
+		{
+			desc: "both, second-gen wins",
+			env:  map[string]string{"GAE_SERVICE": "worker", "GAE_MODULE_NAME": "backend"},
+			want: "worker",
+		},
+		{desc: "neither", want: "default", wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			for _, k := range []string{"GAE_SERVICE", "GAE_MODULE_NAME", "GAE_ENV"} {
+				t.Setenv(k, tc.env[k])
+			}
+			got, err := ModuleNameErr(context.Background())
+			if got != tc.want || (err != nil) != tc.wantErr {
+				t.Errorf("ModuleNameErr = %q, %v; want %q, error %t", got, err, tc.want, tc.wantErr)
+			}
+			if got := ModuleName(context.Background()); got != tc.want {
+				t.Errorf("ModuleName = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestInstanceIDErr(t *testing.T) {
+	testCases := []struct {
+		desc    string
+		env     map[string]string
+		want    string
+		wantErr bool
+	}{
+		{desc: "second-gen", env: map[string]string{"GAE_INSTANCE": "00c61b117c"}, want: "00c61b117c"},
+		{desc: "legacy", env: map[string]string{"GAE_MODULE_INSTANCE": "3"}, want: "3"},
+		{desc: "neither", want: "", wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			for _, k := range []string{"GAE_INSTANCE", "GAE_MODULE_INSTANCE", "GAE_ENV"} {
+				t.Setenv(k, tc.env[k])
+			}
+			got, err := InstanceIDErr()
+			if got != tc.want || (err != nil) != tc.wantErr {
+				t.Errorf("InstanceIDErr = %q, %v; want %q, error %t", got, err, tc.want, tc.wantErr)
+			}
+			if got := InstanceID(); got != tc.want {
+				t.Errorf("InstanceID = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestVersionIDErr(t *testing.T) {
+	testCases := []struct {
+		desc    string
+		env     map[string]string
+		want    string
+		wantErr bool
+	}{
+		{desc: "second-gen", env: map[string]string{"GAE_VERSION": "v1", "GAE_DEPLOYMENT_ID": "123"}, want: "v1.123"},
+		{desc: "legacy", env: map[string]string{"GAE_MODULE_VERSION": "v2", "GAE_MINOR_VERSION": "456"}, want: "v2.456"},
+		{desc: "legacy without minor version", env: map[string]string{"GAE_MODULE_VERSION": "v2"}, want: "", wantErr: true},
+		{desc: "neither", want: "", wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			for _, k := range []string{"GAE_VERSION", "GAE_DEPLOYMENT_ID", "GAE_MODULE_VERSION", "GAE_MINOR_VERSION", "GAE_ENV"} {
+				t.Setenv(k, tc.env[k])
+			}
+			got, err := VersionIDErr(context.Background())
+			if got != tc.want || (err != nil) != tc.wantErr {
+				t.Errorf("VersionIDErr = %q, %v; want %q, error %t", got, err, tc.want, tc.wantErr)
+			}
+			if got := VersionID(context.Background()); got != tc.want {
+				t.Errorf("VersionID = %q, want %q", got, tc.want)
+			}
+		})
+	}
+
+	// Background contexts report the version of their own identity.
+	t.Setenv("GAE_VERSION", "v1")
+	t.Setenv("GAE_DEPLOYMENT_ID", "123")
+	bg := WithBackgroundIdentity(context.Background(), &BackgroundIdentity{ProjectID: "other-project", Version: "v3"})
+	if got, err := VersionIDErr(bg); err != nil || got != "v3" {
+		t.Errorf("VersionIDErr of a background context = %q, %v; want v3", got, err)
+	}
+	if got := VersionID(bg); got != "v3" {
+		t.Errorf("VersionID of a background context = %q, want v3", got)
+	}
+}
+
+func TestAppIDFromEnv(t *testing.T) {
+	testCases := []struct {
+		desc string
+		env  map[string]string
+		want string
+	}{
+		{desc: "plain", env: map[string]string{"GAE_APPLICATION": "my-app"}, want: "my-app"},
+		{desc: "partitioned", env: map[string]string{"GAE_APPLICATION": "s~my-app"}, want: "my-app"},
+		{desc: "domain-scoped", env: map[string]string{"GAE_APPLICATION": "s~example.com:my-app"}, want: "example.com:my-app"},
+		{
+			desc: "legacy",
+			env:  map[string]string{"GAE_LONG_APP_ID": "example.com:my-app", "GAE_PARTITION": "e"},
+			want: "example.com:my-app",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			for _, k := range []string{"GAE_APPLICATION", "GAE_LONG_APP_ID", "GAE_PARTITION", "GOOGLE_CLOUD_PROJECT"} {
+				t.Setenv(k, tc.env[k])
+			}
+			if got := AppID(context.Background()); got != tc.want {
+				t.Errorf("AppID = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestEnvironment(t *testing.T) {
+	testCases := []struct {
+		desc      string
+		env       map[string]string
+		metadata  bool
+		wantLocal bool
+	}{
+		{desc: "prod", env: map[string]string{"GAE_ENV": "standard"}, wantLocal: false},
+		{desc: "dev server", env: map[string]string{"RUN_WITH_DEVAPPSERVER": "1"}, wantLocal: true},
+		{desc: "localdev", env: map[string]string{"GAE_ENV": "localdev"}, wantLocal: true},
+		{desc: "laptop", wantLocal: true},
+		{desc: "compute engine", metadata: true, wantLocal: false},
+		{desc: "override dev", env: map[string]string{"GAE_ENV": "standard", "APPENGINE_DEV_MODE": "true"}, wantLocal: true},
+		{desc: "override prod", env: map[string]string{"APPENGINE_DEV_MODE": "0"}, wantLocal: false},
+		{desc: "override dev with yes", env: map[string]string{"GAE_ENV": "standard", "APPENGINE_DEV_MODE": "yes"}, wantLocal: true},
+		{desc: "override dev with on", env: map[string]string{"GAE_ENV": "standard", "APPENGINE_DEV_MODE": " ON "}, wantLocal: true},
+		{desc: "override prod with off", env: map[string]string{"APPENGINE_DEV_MODE": "off"}, wantLocal: false},
+		{desc: "invalid override", env: map[string]string{"APPENGINE_DEV_MODE": "maybe"}, wantLocal: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			for _, k := range []string{"GAE_ENV", "RUN_WITH_DEVAPPSERVER", "APPENGINE_DEV_MODE"} {
+				t.Setenv(k, tc.env[k])
+			}
+			fakeMetadataServer(t, tc.metadata)
+			got, reason := Environment()
+			if got != tc.wantLocal {
+				t.Errorf("Environment() = %t (%s), want %t", got, reason, tc.wantLocal)
+			}
+			if reason == "" {
+				t.Error("Environment() gave no reason")
+			}
+		})
+	}
+}
+
+// fakeMetadataServer points the metadata lookups at a server that answers
+// every request if available, and fails them otherwise, and clears the
+// cached availability for the duration of the test.
+func fakeMetadataServer(t *testing.T, available bool) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !available {
+			http.Error(w, "not found", http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	oldHost := metadataHost
+	metadataHost = strings.TrimPrefix(srv.URL, "http://")
+	resetMetadataProbe := func() {
+		metadataProbe.Lock()
+		metadataProbe.done, metadataProbe.ok = false, false
+		metadataProbe.Unlock()
+	}
+	resetMetadataProbe()
+	t.Cleanup(func() {
+		metadataHost = oldHost
+		resetMetadataProbe()
+	})
+}
+
+func TestRequestID(t *testing.T) {
+	testCases := []struct {
+		desc    string
+		headers map[string]string
+		want    string
+	}{
+		{desc: "none", want: ""},
+		{desc: "log ID", headers: map[string]string{hRequestLogId: "5f2a"}, want: "5f2a"},
+		{desc: "trace", headers: map[string]string{traceHeader: "105445aa7843bc8bf206b120001000/1;o=1"}, want: "105445aa7843bc8bf206b120001000"},
+		{desc: "trace without span", headers: map[string]string{traceHeader: "105445aa7843bc8bf206b120001000"}, want: "105445aa7843bc8bf206b120001000"},
+		{
+			desc:    "both, log ID wins",
+			headers: map[string]string{hRequestLogId: "5f2a", traceHeader: "105445aa7843bc8bf206b120001000/1;o=1"},
+			want:    "5f2a",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			for k, v := range tc.headers {
+				req.Header.Set(k, v)
+			}
+			if got := RequestID(ReqContext(req)); got != tc.want {
+				t.Errorf("RequestID(ReqContext) = %q, want %q", got, tc.want)
+			}
+			if got := RequestID(WithContext(context.Background(), req)); got != tc.want {
+				t.Errorf("RequestID(WithContext) = %q, want %q", got, tc.want)
+			}
+			if got := RequestID(ContextForTesting(req)); got != tc.want {
+				t.Errorf("RequestID(ContextForTesting) = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
@@ -9,6 +9,8 @@
 package internal
 
 import (
+	"context"
+	"errors"
 	"fmt"
 
 	"github.com/golang/protobuf/proto"
@@ -42,10 +44,19 @@ func RegisterTimeoutErrorCode(service string, code int32) {
 // a taskqueue API call failing with TaskQueueServiceError::UNKNOWN_QUEUE.
 type APIError struct {
 	Service string
+	Method  string // method called, if known
 	Detail  string
 	Code    int32 // API-specific error code
 }
 
+// callName names the method of service that was called, for error messages.
+func callName(service, method string) string {
+	if method == "" {
+		return service
+	}
+	return service + "." + method
+}
+
 func (e *APIError) Error() string {
 	if e.Code == 0 {
 		if e.Detail == "" {
@@ -55,10 +66,10 @@ func (e *APIError) Error() string {
 	}
 	s := fmt.Sprintf("API error %d", e.Code)
 	if m, ok := errorCodeMaps[e.Service]; ok {
-		s += " (" + e.Service + ": " + m[e.Code] + ")"
+		s += " (" + callName(e.Service, e.Method) + ": " + m[e.Code] + ")"
 	} else {
 		// Shouldn't happen, but provide a bit more detail if it does.
-		s = e.Service + " " + s
+		s = callName(e.Service, e.Method) + " " + s
 	}
 	if e.Detail != "" {
 		s += ": " + e.Detail
@@ -70,20 +81,31 @@ func (e *APIError) IsTimeout() bool {
 	return timeoutCodes[timeoutCodeKey{e.Service, e.Code}]
 }
 
+// Is reports whether target is an *APIError for the same service and code,
+// and for the same method unless target's is empty, so that
+// errors.Is(err, &APIError{Service: "modules", Code: 2}) tests for a
+// particular error.
+func (e *APIError) Is(target error) bool {
+	t, ok := target.(*APIError)
+	return ok && t.Service == e.Service && t.Code == e.Code && (t.Method == "" || t.Method == e.Method)
+}
+
 // CallError is the type returned by appengine.Context's Call method when an
 // API call fails in a generic way, such as RpcError::CAPABILITY_DISABLED.
 type CallError struct {
-	Detail string
-	Code   int32
+	Service, Method string // the API call that failed, if known
+	Detail          string
+	Code            int32
 	// TODO: Remove this if we get a distinguishable error code.
 	Timeout bool
+	Err     error // underlying error, if any
 }
 
 func (e *CallError) Error() string {
 	var msg string
 	switch remotepb.RpcError_ErrorCode(e.Code) {
 	case remotepb.RpcError_UNKNOWN:
-		return e.Detail
+		msg = e.Detail
 	case remotepb.RpcError_OVER_QUOTA:
 		msg = "Over quota"
 	case remotepb.RpcError_CAPABILITY_DISABLED:
@@ -93,13 +115,48 @@ func (e *CallError) Error() string {
 	default:
 		msg = fmt.Sprintf("Call error %d", e.Code)
 	}
-	s := msg + ": " + e.Detail
+	s := msg
+	if remotepb.RpcError_ErrorCode(e.Code) != remotepb.RpcError_UNKNOWN {
+		s += ": " + e.Detail
+	}
+	if e.Service != "" {
+		s = callName(e.Service, e.Method) + ": " + s
+	}
 	if e.Timeout {
 		s += " (timeout)"
 	}
 	return s
 }
 
+func (e *CallError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is a *CallError with the same code and timeout
+// flag, and for the same service and method unless target's are empty.
+func (e *CallError) Is(target error) bool {
+	t, ok := target.(*CallError)
+	return ok && t.Code == e.Code && t.Timeout == e.Timeout &&
+		(t.Service == "" || t.Service == e.Service) && (t.Method == "" || t.Method == e.Method)
+}
+
+// IsTimeout reports whether err, or an error it wraps, is a timeout: an API
+// call error that reports a timeout, or context.DeadlineExceeded.
+func IsTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var t interface{ IsTimeout() bool }
+	return errors.As(err, &t) && t.IsTimeout()
+}
+
+// IsOverQuota reports whether err, or an error it wraps, is an API call error
+// caused by insufficient quota.
+func IsOverQuota(err error) bool {
+	var ce *CallError
+	return errors.As(err, &ce) && remotepb.RpcError_ErrorCode(ce.Code) == remotepb.RpcError_OVER_QUOTA
+}
+
 func (e *CallError) IsTimeout() bool {
 	return e.Timeout
 }
@@ -0,0 +1,85 @@
+// Copyright 2026 Google LLC. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+
+	remotepb "google.golang.org/appengine/internal/remote_api"
+)
+
+func TestCallErrorWrapping(t *testing.T) {
+	overQuota := &CallError{Service: "memcache", Method: "Get", Code: int32(remotepb.RpcError_OVER_QUOTA), Detail: "too many calls"}
+	timeout := &CallError{Service: "datastore_v3", Method: "RunQuery", Code: int32(remotepb.RpcError_DEADLINE_EXCEEDED), Timeout: true}
+	appErr := &APIError{Service: "modules", Method: "GetVersions", Code: 2}
+
+	wrap := func(err error) error {
+		return fmt.Errorf("outer: %w", fmt.Errorf("inner: %w", err))
+	}
+	tests := []struct {
+		err                error
+		timeout, overQuota bool
+	}{
+		{wrap(overQuota), false, true},
+		{wrap(timeout), true, false},
+		{wrap(appErr), false, false},
+		{wrap(context.DeadlineExceeded), true, false},
+		{wrap(io.EOF), false, false},
+	}
+	for _, tt := range tests {
+		if got := IsTimeout(tt.err); got != tt.timeout {
+			t.Errorf("IsTimeout(%v) = %t, want %t", tt.err, got, tt.timeout)
+		}
+		if got := IsOverQuota(tt.err); got != tt.overQuota {
+			t.Errorf("IsOverQuota(%v) = %t, want %t", tt.err, got, tt.overQuota)
+		}
+	}
+
+	if !errors.Is(wrap(overQuota), &CallError{Code: int32(remotepb.RpcError_OVER_QUOTA)}) {
+		t.Error("errors.Is does not match a CallError by code")
+	}
+	if errors.Is(wrap(overQuota), &CallError{Service: "datastore_v3", Code: int32(remotepb.RpcError_OVER_QUOTA)}) {
+		t.Error("errors.Is matches a CallError of another service")
+	}
+	if !errors.Is(wrap(appErr), &APIError{Service: "modules", Code: 2}) {
+		t.Error("errors.Is does not match an APIError by service and code")
+	}
+	if errors.Is(wrap(appErr), &APIError{Service: "modules", Method: "GetModules", Code: 2}) {
+		t.Error("errors.Is matches an APIError of another method")
+	}
+	var ce *CallError
+	if !errors.As(wrap(timeout), &ce) || ce != timeout {
+		t.Errorf("errors.As = %v, want %v", ce, timeout)
+	}
+
+	bridge := &CallError{Service: "memcache", Method: "Set", Code: int32(remotepb.RpcError_UNKNOWN), Detail: "service bridge HTTP failed", Err: io.ErrUnexpectedEOF}
+	if !errors.Is(wrap(bridge), io.ErrUnexpectedEOF) {
+		t.Error("errors.Is does not find the error wrapped by a CallError")
+	}
+}
+
+func TestCallErrorMessages(t *testing.T) {
+	RegisterErrorCodeMap("errorsvc", map[int32]string{1: "BAD_REQUEST"})
+	t.Cleanup(func() { delete(errorCodeMaps, "errorsvc") })
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{&CallError{Service: "memcache", Method: "Get", Code: int32(remotepb.RpcError_OVER_QUOTA), Detail: "too many calls"}, "memcache.Get: Over quota: too many calls"},
+		{&CallError{Service: "datastore_v3", Method: "RunQuery", Code: int32(remotepb.RpcError_CANCELLED), Detail: "Deadline exceeded", Timeout: true}, "datastore_v3.RunQuery: Canceled: Deadline exceeded (timeout)"},
+		{&CallError{Code: int32(remotepb.RpcError_UNKNOWN), Detail: "no service"}, "no service"},
+		{&APIError{Service: "errorsvc", Method: "Get", Code: 1, Detail: "oops"}, "API error 1 (errorsvc.Get: BAD_REQUEST): oops"},
+		{&APIError{Service: "nosuch", Method: "Call", Code: 3}, "nosuch.Call API error 3"},
+	}
+	for _, tt := range tests {
+		if got := tt.err.Error(); got != tt.want {
+			t.Errorf("Error() = %q, want %q", got, tt.want)
+		}
+	}
+}
@@ -4,9 +4,17 @@
 package internal
 
 import (
+	"context"
 	"go/build"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"path/filepath"
+	"reflect"
 	"testing"
+	"time"
 )
 
 func TestFindMainPath(t *testing.T) {
@@ -17,3 +25,118 @@ func TestFindMainPath(t *testing.T) {
 		t.Errorf("findMainPath: want %s, got %s", want, got)
 	}
 }
+
+func TestRunShutdownHooks(t *testing.T) {
+	defer func(hooks []func(context.Context)) { shutdownHooks.hooks = hooks }(shutdownHooks.hooks)
+	shutdownHooks.hooks = nil
+
+	var order []int
+	RegisterShutdownHook(func(context.Context) { order = append(order, 1) })
+	RegisterShutdownHook(func(context.Context) { panic("hook failed") })
+	RegisterShutdownHook(func(ctx context.Context) {
+		if _, ok := ctx.Deadline(); !ok {
+			t.Error("hook context has no deadline")
+		}
+		order = append(order, 3)
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	runShutdownHooks(ctx)
+	if want := []int{1, 3}; !reflect.DeepEqual(order, want) {
+		t.Errorf("hooks ran in order %v, want %v", order, want)
+	}
+}
+
+func TestShutdownCompletesRequests(t *testing.T) {
+	defer func(hooks []func(context.Context)) { shutdownHooks.hooks = hooks }(shutdownHooks.hooks)
+	shutdownHooks.hooks = nil
+
+	started, release, finished := make(chan bool), make(chan bool), make(chan bool)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- true
+		<-release
+		io.WriteString(w, "done")
+		close(finished)
+	}))
+	defer srv.Close()
+
+	respc := make(chan string, 1)
+	go func() {
+		resp, err := http.Get(srv.URL)
+		if err != nil {
+			respc <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		b, _ := ioutil.ReadAll(resp.Body)
+		respc <- string(b)
+	}()
+	<-started
+	var hookRan bool
+	RegisterShutdownHook(func(context.Context) {
+		select {
+		case <-finished:
+		default:
+			t.Error("shutdown hook ran before the in-flight request completed")
+		}
+		hookRan = true
+	})
+
+	errc := make(chan error, 1)
+	go func() { errc <- shutdown(srv.Config, 5*time.Second) }()
+	time.Sleep(50 * time.Millisecond) // let Shutdown stop accepting connections
+	if resp, err := http.Get(srv.URL); err == nil {
+		resp.Body.Close()
+		t.Error("server accepted a request after shutdown began")
+	}
+	close(release)
+	if err := <-errc; err != nil {
+		t.Errorf("shutdown: %v", err)
+	}
+	if got := <-respc; got != "done" {
+		t.Errorf("in-flight request got %q, want done", got)
+	}
+	if !hookRan {
+		t.Error("shutdown hook did not run")
+	}
+}
+
+func TestMainWithOptions(t *testing.T) {
+	defer func(p string) { MainPath = p }(MainPath)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen: %v", err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/hello", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "hello")
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	go func() { errc <- MainWithOptions(MainOptions{Listener: ln, Handler: mux, Context: ctx}) }()
+
+	for path, want := range map[string]string{"/hello": "hello", "/_ah/health": "ok"} {
+		resp, err := http.Get("http://" + ln.Addr().String() + path)
+		if err != nil {
+			t.Fatalf("GET %s: %v", path, err)
+		}
+		b, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil || string(b) != want {
+			t.Errorf("GET %s = %q, %v; want %q", path, b, err, want)
+		}
+	}
+
+	cancel()
+	select {
+	case err := <-errc:
+		if err != nil {
+			t.Errorf("MainWithOptions: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("MainWithOptions did not return after its context was canceled")
+	}
+	if _, err := http.Get("http://" + ln.Addr().String() + "/hello"); err == nil {
+		t.Error("server still accepts requests after shutdown")
+	}
+}
@@ -8,19 +8,89 @@
 package internal
 
 import (
+	"context"
+	"fmt"
 	"io"
 	"log"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"runtime"
+	"sync"
+	"syscall"
+	"time"
 )
 
 func Main() {
+	if err := MainWithOptions(MainOptions{}); err != nil {
+		log.Fatalf("appengine: %v", err)
+	}
+	os.Exit(0)
+}
+
+// MainOptions is the implementation of the type of the same name in
+// ../appengine_vm.go. See that file for commentary.
+type MainOptions struct {
+	Listener net.Listener
+	Addr     string
+	Handler  http.Handler
+	Context  context.Context
+}
+
+// MainWithOptions is the implementation of the wrapper function of the same
+// name in ../appengine_vm.go. See that file for commentary.
+func MainWithOptions(opts MainOptions) error {
 	MainPath = filepath.Dir(findMainPath())
-	installHealthChecker(http.DefaultServeMux)
+	handler := opts.Handler
+	if handler == nil {
+		handler = http.DefaultServeMux
+	}
+	if mux, ok := handler.(*http.ServeMux); ok {
+		installHealthChecker(mux)
+	}
 
+	ln := opts.Listener
+	if ln == nil {
+		addr := opts.Addr
+		if addr == "" {
+			addr = defaultAddr()
+		}
+		var err error
+		if ln, err = net.Listen("tcp", addr); err != nil {
+			return err
+		}
+	}
+	srv := &http.Server{Handler: Middleware(handler)}
+	errc := make(chan error, 1)
+	go func() { errc <- srv.Serve(ln) }()
+
+	var done <-chan struct{}
+	if opts.Context != nil {
+		done = opts.Context.Done()
+	}
+	sigc := make(chan os.Signal, 1)
+	signal.Notify(sigc, syscall.SIGTERM, os.Interrupt)
+	defer signal.Stop(sigc)
+	select {
+	case err := <-errc:
+		return fmt.Errorf("http.Serve: %v", err)
+	case sig := <-sigc:
+		log.Printf("appengine: received %v, shutting down", sig)
+	case <-done:
+	}
+	if err := shutdown(srv, shutdownGracePeriod); err != nil {
+		return fmt.Errorf("shutdown: %v", err)
+	}
+	return nil
+}
+
+// defaultAddr returns the address that Main listens on: the port in the PORT
+// environment variable, or 8080, on all interfaces except on the development
+// app server.
+func defaultAddr() string {
 	port := "8080"
 	if s := os.Getenv("PORT"); s != "" {
 		port = s
@@ -30,8 +100,51 @@ func Main() {
 	if IsDevAppServer() {
 		host = "127.0.0.1"
 	}
-	if err := http.ListenAndServe(host+":"+port, Middleware(http.DefaultServeMux)); err != nil {
-		log.Fatalf("http.ListenAndServe: %v", err)
+	return host + ":" + port
+}
+
+// shutdownGracePeriod is the time the platform gives an instance between
+// sending SIGTERM and stopping it forcibly, less a margin for exiting.
+var shutdownGracePeriod = 9 * time.Second
+
+var shutdownHooks struct {
+	sync.Mutex
+	hooks []func(context.Context)
+}
+
+// RegisterShutdownHook is the implementation of the wrapper function of the
+// same name in ../appengine_vm.go. See that file for commentary.
+func RegisterShutdownHook(f func(context.Context)) {
+	shutdownHooks.Lock()
+	defer shutdownHooks.Unlock()
+	shutdownHooks.hooks = append(shutdownHooks.hooks, f)
+}
+
+// shutdown stops srv from accepting connections, waits for its in-flight
+// requests to complete and then runs the shutdown hooks, all within grace.
+func shutdown(srv *http.Server, grace time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), grace)
+	defer cancel()
+	err := srv.Shutdown(ctx)
+	runShutdownHooks(ctx)
+	return err
+}
+
+// runShutdownHooks runs the registered shutdown hooks in registration order.
+// A hook that panics is logged and does not prevent the others from running.
+func runShutdownHooks(ctx context.Context) {
+	shutdownHooks.Lock()
+	hooks := append([](func(context.Context))(nil), shutdownHooks.hooks...)
+	shutdownHooks.Unlock()
+	for _, f := range hooks {
+		func() {
+			defer func() {
+				if x := recover(); x != nil {
+					log.Printf("appengine: shutdown hook panicked: %v", x)
+				}
+			}()
+			f(ctx)
+		}()
 	}
 }
 
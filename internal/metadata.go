@@ -10,18 +10,23 @@ package internal
 //	https://cloud.google.com/compute/docs/metadata
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
+	"log"
 	"net/http"
 	"net/url"
+	"strings"
+	"sync"
+	"time"
 )
 
-const (
-	metadataHost = "metadata"
-	metadataPath = "/computeMetadata/v1/"
-)
+const metadataPath = "/computeMetadata/v1/"
 
 var (
+	// metadataHost is a variable so that tests can point it at a fake server.
+	metadataHost = "metadata"
+
 	metadataRequestHeaders = http.Header{
 		"Metadata-Flavor": []string{"Google"},
 	}
@@ -37,18 +42,26 @@ func mustGetMetadata(key string) []byte {
 }
 
 func getMetadata(key string) ([]byte, error) {
-	// TODO(dsymonds): May need to use url.Parse to support keys with query args.
+	return getMetadataContext(context.Background(), key)
+}
+
+// getMetadataContext fetches key, which may have query arguments as in
+// "instance/service-accounts/default/token?scopes=...", from the metadata
+// server.
+func getMetadataContext(ctx context.Context, key string) ([]byte, error) {
+	key, query, _ := strings.Cut(key, "?")
 	req := &http.Request{
 		Method: "GET",
 		URL: &url.URL{
-			Scheme: "http",
-			Host:   metadataHost,
-			Path:   metadataPath + key,
+			Scheme:   "http",
+			Host:     metadataHost,
+			Path:     metadataPath + key,
+			RawQuery: query,
 		},
 		Header: metadataRequestHeaders,
 		Host:   metadataHost,
 	}
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
 	if err != nil {
 		return nil, err
 	}
@@ -58,3 +71,123 @@ func getMetadata(key string) ([]byte, error) {
 	}
 	return ioutil.ReadAll(resp.Body)
 }
+
+const (
+	// zoneLookupTimeout bounds the metadata request for the zone.
+	zoneLookupTimeout = time.Second
+	// zoneRetryInterval is how long a failed zone lookup is remembered before
+	// it is retried in the background.
+	zoneRetryInterval = time.Minute
+)
+
+// zoneCache holds the zone of the instance, which never changes, once the
+// metadata server has been asked for it.
+var zoneCache struct {
+	lookup sync.Mutex // held by the first, blocking lookup
+
+	sync.Mutex
+	resolved   bool // a lookup has completed
+	zone       string
+	retryAt    time.Time // when to retry a failed lookup; zero after a success
+	refreshing bool      // a background lookup is running
+}
+
+// metadataZone returns the short name of the zone of the instance, such as
+// "us-central1-a", or the empty string if the metadata server cannot tell.
+// Only the first call waits for the metadata server, and for no longer than
+// zoneLookupTimeout; later calls answer from the cache and retry a failed
+// lookup in the background.
+func metadataZone() string {
+	if zone, ok := cachedZone(); ok {
+		return zone
+	}
+	zoneCache.lookup.Lock()
+	defer zoneCache.lookup.Unlock()
+	if zone, ok := cachedZone(); ok {
+		return zone
+	}
+	refreshZone()
+	zone, _ := cachedZone()
+	return zone
+}
+
+// cachedZone returns the cached zone and whether there is one, starting a
+// background lookup if the last one failed long enough ago.
+func cachedZone() (string, bool) {
+	zoneCache.Lock()
+	defer zoneCache.Unlock()
+	if !zoneCache.resolved {
+		return "", false
+	}
+	if !zoneCache.retryAt.IsZero() && !zoneCache.refreshing && time.Now().After(zoneCache.retryAt) {
+		zoneCache.refreshing = true
+		go refreshZone()
+	}
+	return zoneCache.zone, true
+}
+
+// refreshZone asks the metadata server for the zone and caches the answer.
+func refreshZone() {
+	ctx, cancel := context.WithTimeout(context.Background(), zoneLookupTimeout)
+	defer cancel()
+	// It has the format projects/[NUMERIC_PROJECT_ID]/zones/[ZONE]
+	b, err := getMetadataContext(ctx, "instance/zone")
+
+	zoneCache.Lock()
+	defer zoneCache.Unlock()
+	zoneCache.resolved, zoneCache.refreshing = true, false
+	if err != nil {
+		log.Printf("Datacenter: %v", err)
+		zoneCache.retryAt = time.Now().Add(zoneRetryInterval)
+		return
+	}
+	parts := strings.Split(string(b), "/")
+	zoneCache.zone, zoneCache.retryAt = parts[len(parts)-1], time.Time{}
+}
+
+// metadataProbe caches whether the metadata server answers.
+var metadataProbe struct {
+	sync.Mutex
+	done, ok bool
+}
+
+// metadataServerAvailable reports whether the metadata server of Compute
+// Engine, which App Engine instances also have, answers requests. Only the
+// first call waits for it, and for no longer than zoneLookupTimeout.
+func metadataServerAvailable() bool {
+	metadataProbe.Lock()
+	defer metadataProbe.Unlock()
+	if !metadataProbe.done {
+		ctx, cancel := context.WithTimeout(context.Background(), zoneLookupTimeout)
+		defer cancel()
+		_, err := getMetadataContext(ctx, "instance/id")
+		metadataProbe.done, metadataProbe.ok = true, err == nil
+	}
+	return metadataProbe.ok
+}
+
+// regionCache holds the region of the instance once the metadata server has
+// been asked for it.
+var regionCache struct {
+	sync.Mutex
+	region string
+}
+
+// MetadataRegion returns the region of the instance, such as "us-central1",
+// from the metadata server of second-generation runtimes. Successful
+// lookups are cached.
+func MetadataRegion(ctx context.Context) (string, error) {
+	regionCache.Lock()
+	defer regionCache.Unlock()
+	if regionCache.region != "" {
+		return regionCache.region, nil
+	}
+	// It has the format projects/[NUMERIC_PROJECT_ID]/regions/[REGION]
+	b, err := getMetadataContext(ctx, "instance/region")
+	if err != nil {
+		return "", err
+	}
+	parts := strings.Split(strings.TrimSpace(string(b)), "/")
+	regionCache.region = parts[len(parts)-1]
+	return regionCache.region, nil
+}
@@ -0,0 +1,97 @@
+// Copyright 2026 Google LLC. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package internal
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeZoneServer points the metadata lookups at a server that answers zone
+// requests with status, and clears the cached zone for the duration of the
+// test. It returns the number of requests served.
+func fakeZoneServer(t *testing.T, status int) *int32 {
+	var hits int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		if r.URL.Path != metadataPath+"instance/zone" || r.Header.Get("Metadata-Flavor") != "Google" {
+			t.Errorf("unexpected metadata request %s %v", r.URL.Path, r.Header)
+		}
+		w.WriteHeader(status)
+		w.Write([]byte("projects/123456/zones/us-central1-a"))
+	}))
+	t.Cleanup(srv.Close)
+
+	oldHost := metadataHost
+	metadataHost = strings.TrimPrefix(srv.URL, "http://")
+	resetZoneCache()
+	t.Cleanup(func() {
+		metadataHost = oldHost
+		resetZoneCache()
+	})
+	return &hits
+}
+
+func resetZoneCache() {
+	zoneCache.Lock()
+	zoneCache.resolved, zoneCache.zone, zoneCache.retryAt, zoneCache.refreshing = false, "", time.Time{}, false
+	zoneCache.Unlock()
+}
+
+func TestMetadataZoneCached(t *testing.T) {
+	hits := fakeZoneServer(t, http.StatusOK)
+	for i := 0; i < 3; i++ {
+		if got, want := metadataZone(), "us-central1-a"; got != want {
+			t.Errorf("metadataZone() = %q, want %q", got, want)
+		}
+	}
+	if n := atomic.LoadInt32(hits); n != 1 {
+		t.Errorf("metadata server asked %d times, want once", n)
+	}
+}
+
+func TestMetadataZoneFailure(t *testing.T) {
+	hits := fakeZoneServer(t, http.StatusInternalServerError)
+	if got := metadataZone(); got != "" {
+		t.Errorf("metadataZone() = %q, want empty", got)
+	}
+	if got := metadataZone(); got != "" {
+		t.Errorf("metadataZone() = %q, want empty", got)
+	}
+	if n := atomic.LoadInt32(hits); n != 1 {
+		t.Errorf("metadata server asked %d times before the retry interval, want once", n)
+	}
+
+	// Once the retry interval has passed, the lookup is retried without
+	// blocking the caller.
+	zoneCache.Lock()
+	zoneCache.retryAt = time.Now().Add(-time.Second)
+	zoneCache.Unlock()
+	start := time.Now()
+	metadataZone()
+	if d := time.Since(start); d > 50*time.Millisecond {
+		t.Errorf("metadataZone() blocked for %v after the first lookup", d)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for atomic.LoadInt32(hits) < 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("failed lookup was not retried")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	for {
+		zoneCache.Lock()
+		refreshing := zoneCache.refreshing
+		zoneCache.Unlock()
+		if !refreshing {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
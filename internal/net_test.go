@@ -9,6 +9,7 @@ package internal
 
 import (
 	"context"
+	"errors"
 	"sync"
 	"testing"
 	"time"
@@ -47,7 +48,7 @@ func TestDialLimit(t *testing.T) {
 
 	ctx, _ := context.WithTimeout(toContext(c), 50*time.Millisecond)
 	err := Call(ctx, "errors", "Non200", &basepb.VoidProto{}, &basepb.VoidProto{})
-	if err != errTimeout {
+	if !errors.Is(err, errTimeout) {
 		t.Errorf("Non200 RPC returned with err %v, want errTimeout", err)
 	}
 
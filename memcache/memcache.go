@@ -26,6 +26,16 @@
 //	if err := memcache.Set(c, item1); err != nil {
 //		return err
 //	}
+//
+// On runtimes without the memcache service, setting the environment variable
+// MEMCACHE_USE_REDIS to a true value such as "true" or "yes" makes the package
+// use the Redis server, such as a Memorystore instance, at the address given by
+// REDIS_ADDR, authenticated with REDIS_PASSWORD if set. Get, GetMulti, Set,
+// SetMulti, Add, AddMulti, CompareAndSwap, CompareAndSwapMulti, Delete and
+// DeleteMulti then work as with the service, with items of each namespace kept
+// under keys prefixed by "memcache:<namespace>:"; the other operations fail.
+// Values larger than 1MB, or than MEMCACHE_MAX_VALUE_SIZE bytes if set, are
+// rejected with ErrServerError, as by the service.
 package memcache // import "google.golang.org/appengine/memcache"
 
 import (
@@ -186,6 +196,14 @@ func getMulti(c context.Context, key []string, forCas bool, forPeek bool) (map[s
 	if len(key) == 0 {
 		return nil, nil
 	}
+	if r, err := useRedis(); err != nil {
+		return nil, err
+	} else if r {
+		if forPeek {
+			return nil, errRedisUnsupported("Peek")
+		}
+		return getMultiRedis(c, key)
+	}
 	keyAsBytes := make([][]byte, len(key))
 	for i, k := range key {
 		keyAsBytes[i] = []byte(k)
@@ -223,6 +241,11 @@ func DeleteMulti(c context.Context, key []string) error {
 	if len(key) == 0 {
 		return nil
 	}
+	if r, err := useRedis(); err != nil {
+		return err
+	} else if r {
+		return deleteMultiRedis(c, key)
+	}
 	req := &pb.MemcacheDeleteRequest{
 		Item: make([]*pb.MemcacheDeleteRequest_Item, len(key)),
 	}
@@ -276,6 +299,11 @@ func IncrementExisting(c context.Context, key string, delta int64) (newValue uin
 }
 
 func incr(c context.Context, key string, delta int64, initialValue *uint64) (newValue uint64, err error) {
+	if r, err := useRedis(); err != nil {
+		return 0, err
+	} else if r {
+		return 0, errRedisUnsupported("Increment")
+	}
 	req := &pb.MemcacheIncrementRequest{
 		Key:          []byte(key),
 		InitialValue: initialValue,
@@ -303,6 +331,11 @@ func set(c context.Context, item []*Item, value [][]byte, policy pb.MemcacheSetR
 	if len(item) == 0 {
 		return nil
 	}
+	if r, err := useRedis(); err != nil {
+		return err
+	} else if r {
+		return setRedis(c, item, value, policy)
+	}
 	req := &pb.MemcacheSetRequest{
 		Item: make([]*pb.MemcacheSetRequest_Item, len(item)),
 	}
@@ -536,6 +569,11 @@ type Statistics struct {
 
 // Stats retrieves the current memcache statistics.
 func Stats(c context.Context) (*Statistics, error) {
+	if r, err := useRedis(); err != nil {
+		return nil, err
+	} else if r {
+		return nil, errRedisUnsupported("Stats")
+	}
 	req := &pb.MemcacheStatsRequest{}
 	res := &pb.MemcacheStatsResponse{}
 	if err := internal.Call(c, "memcache", "Stats", req, res); err != nil {
@@ -556,6 +594,11 @@ func Stats(c context.Context) (*Statistics, error) {
 
 // Flush flushes all items from memcache.
 func Flush(c context.Context) error {
+	if r, err := useRedis(); err != nil {
+		return err
+	} else if r {
+		return errRedisUnsupported("Flush")
+	}
 	req := &pb.MemcacheFlushRequest{}
 	res := &pb.MemcacheFlushResponse{}
 	return internal.Call(c, "memcache", "FlushAll", req, res)
@@ -0,0 +1,334 @@
+// Copyright 2026 Google LLC. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package memcache
+
+// This file implements the Redis backend of the package, for runtimes
+// without the legacy memcache service.
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"google.golang.org/appengine"
+	"google.golang.org/appengine/internal"
+	pb "google.golang.org/appengine/internal/memcache"
+)
+
+// useRedis reports whether MEMCACHE_USE_REDIS selects the Redis backend, as
+// parsed by internal.EnvBool.
+func useRedis() (bool, error) {
+	v, err := internal.EnvBool("MEMCACHE_USE_REDIS")
+	if err != nil {
+		return false, fmt.Errorf("memcache: %v", err)
+	}
+	return v, nil
+}
+
+// redisClients holds a client, and so a connection pool, for each Redis
+// server used, by address.
+var redisClients struct {
+	sync.Mutex
+	m map[string]*redis.Client
+}
+
+// redisClient returns the client for the Redis server at REDIS_ADDR, which
+// is authenticated with REDIS_PASSWORD if set, as for the AUTH string of a
+// Memorystore instance.
+func redisClient() (*redis.Client, error) {
+	addr := os.Getenv("REDIS_ADDR")
+	if addr == "" {
+		return nil, errors.New("memcache: MEMCACHE_USE_REDIS is set but REDIS_ADDR is not")
+	}
+	redisClients.Lock()
+	defer redisClients.Unlock()
+	if cl, ok := redisClients.m[addr]; ok {
+		return cl, nil
+	}
+	cl := redis.NewClient(&redis.Options{
+		Addr:     addr,
+		Password: os.Getenv("REDIS_PASSWORD"),
+		// RESP2 is supported by all the versions of Memorystore.
+		Protocol:        2,
+		DisableIdentity: true,
+	})
+	if redisClients.m == nil {
+		redisClients.m = make(map[string]*redis.Client)
+	}
+	redisClients.m[addr] = cl
+	return cl, nil
+}
+
+// defaultMaxValueSize is the largest value that the Redis backend stores by
+// default, as the memcache service does.
+const defaultMaxValueSize = 1 << 20
+
+// maxValueSize returns the largest value, in bytes, that the Redis backend
+// stores, as set by MEMCACHE_MAX_VALUE_SIZE or defaultMaxValueSize. Larger
+// values fail with ErrServerError, as with the memcache service.
+func maxValueSize() (int, error) {
+	v := strings.TrimSpace(os.Getenv("MEMCACHE_MAX_VALUE_SIZE"))
+	if v == "" {
+		return defaultMaxValueSize, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("memcache: invalid size %q for MEMCACHE_MAX_VALUE_SIZE", os.Getenv("MEMCACHE_MAX_VALUE_SIZE"))
+	}
+	return n, nil
+}
+
+// errRedisUnsupported returns the error of an operation that the Redis
+// backend does not implement.
+func errRedisUnsupported(op string) error {
+	return fmt.Errorf("memcache: %s is not supported by the Redis backend", op)
+}
+
+// redisError wraps err, from a Redis command, unless it is from c, which is
+// returned as it is.
+func redisError(c context.Context, err error) error {
+	if c.Err() != nil && errors.Is(err, c.Err()) {
+		return c.Err()
+	}
+	return fmt.Errorf("memcache: redis: %w", err)
+}
+
+// redisKey returns the Redis key of the item with key in the namespace of c,
+// so that namespaces are kept apart as by the memcache service.
+func redisKey(c context.Context, key string) string {
+	return "memcache:" + internal.NamespaceFromContext(c) + ":" + key
+}
+
+// Values are stored in Redis in an envelope holding the flags and the cas ID
+// of the item: a format byte, then the flags and the cas ID in big-endian
+// order, then the value. Each write stores a new random cas ID, which
+// CompareAndSwap checks. Envelopes of the first format have no cas ID.
+const (
+	envelopeFormat   = 2
+	envelopeHeader   = 1 + 4 + 8
+	envelopeV1Header = 1 + 4
+)
+
+func encodeEnvelope(value []byte, flags uint32, casID uint64) []byte {
+	b := make([]byte, envelopeHeader+len(value))
+	b[0] = envelopeFormat
+	binary.BigEndian.PutUint32(b[1:], flags)
+	binary.BigEndian.PutUint64(b[5:], casID)
+	copy(b[envelopeHeader:], value)
+	return b
+}
+
+// decodeEnvelope returns the value, flags and cas ID stored in b, and false
+// if b is not an envelope.
+func decodeEnvelope(b []byte) (value []byte, flags uint32, casID uint64, ok bool) {
+	switch {
+	case len(b) >= envelopeHeader && b[0] == envelopeFormat:
+		return b[envelopeHeader:], binary.BigEndian.Uint32(b[1:]), binary.BigEndian.Uint64(b[5:]), true
+	case len(b) >= envelopeV1Header && b[0] == 1:
+		return b[envelopeV1Header:], binary.BigEndian.Uint32(b[1:]), 0, true
+	}
+	return nil, 0, 0, false
+}
+
+// newCASID returns a cas ID for a write, which is never zero.
+func newCASID() uint64 {
+	for {
+		if id := rand.Uint64(); id != 0 {
+			return id
+		}
+	}
+}
+
+// casScript replaces the value of KEYS[1] with the envelope ARGV[2] if its
+// cas ID is ARGV[1], with the TTL ARGV[3] in milliseconds, none if zero, or
+// deletes it if negative. It returns 1 if the value was swapped, 0 if there
+// is none and -1 if its cas ID differs. Running it as a script makes the
+// check and the swap atomic, without tying a connection of the pool to a
+// WATCH.
+const casScript = `
+local cur = redis.call("GET", KEYS[1])
+if not cur then
+	return 0
+end
+if string.byte(cur, 1) ~= 2 or string.sub(cur, 6, 13) ~= ARGV[1] then
+	return -1
+end
+local ttl = tonumber(ARGV[3])
+if ttl < 0 then
+	redis.call("DEL", KEYS[1])
+elseif ttl > 0 then
+	redis.call("SET", KEYS[1], ARGV[2], "PX", ttl)
+else
+	redis.call("SET", KEYS[1], ARGV[2])
+end
+return 1
+`
+
+// redisTTL returns the TTL of an item that expires after d, converted as the
+// memcache service does: 0 means no expiration, and durations below a second
+// expire the item right away, for which ok is false.
+func redisTTL(d time.Duration) (ttl time.Duration, ok bool) {
+	if d == 0 {
+		return 0, true
+	}
+	if d < time.Second {
+		return 0, false
+	}
+	return d.Truncate(time.Second), true
+}
+
+// getMultiRedis implements getMulti with the Redis backend.
+func getMultiRedis(c context.Context, key []string) (map[string]*Item, error) {
+	cl, err := redisClient()
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, len(key))
+	for i, k := range key {
+		keys[i] = redisKey(c, k)
+	}
+	vs, err := cl.MGet(c, keys...).Result()
+	if err != nil {
+		return nil, redisError(c, err)
+	}
+	m := make(map[string]*Item, len(key))
+	for i, v := range vs {
+		s, ok := v.(string)
+		if !ok {
+			continue // a miss
+		}
+		value, flags, casID, ok := decodeEnvelope([]byte(s))
+		if !ok {
+			continue // not written by this package
+		}
+		m[key[i]] = &Item{Key: key[i], Value: value, Flags: flags, casID: casID}
+	}
+	return m, nil
+}
+
+// deleteMultiRedis implements DeleteMulti with the Redis backend.
+func deleteMultiRedis(c context.Context, key []string) error {
+	cl, err := redisClient()
+	if err != nil {
+		return err
+	}
+	cmds := make([]*redis.IntCmd, len(key))
+	_, err = cl.Pipelined(c, func(p redis.Pipeliner) error {
+		for i, k := range key {
+			cmds[i] = p.Del(c, redisKey(c, k))
+		}
+		return nil
+	})
+	if err != nil {
+		return redisError(c, err)
+	}
+	me, any := make(appengine.MultiError, len(key)), false
+	for i, cmd := range cmds {
+		if cmd.Val() == 0 {
+			me[i] = ErrCacheMiss
+			any = true
+		}
+	}
+	if any {
+		return me
+	}
+	return nil
+}
+
+// setRedis implements set with the Redis backend: SET writes each item, ADD
+// only those whose key is not taken, with SET NX, and CAS only those whose cas
+// ID is unchanged, with casScript. Items whose value is too large are not
+// sent.
+func setRedis(c context.Context, item []*Item, value [][]byte, policy pb.MemcacheSetRequest_SetPolicy) error {
+	cl, err := redisClient()
+	if err != nil {
+		return err
+	}
+	maxSize, err := maxValueSize()
+	if err != nil {
+		return err
+	}
+	add := policy == pb.MemcacheSetRequest_ADD
+	me := make(appengine.MultiError, len(item))
+	cmds := make([]redis.Cmder, len(item))
+	_, err = cl.Pipelined(c, func(p redis.Pipeliner) error {
+		for i, t := range item {
+			v := t.Value
+			if value != nil {
+				v = value[i]
+			}
+			if len(v) > maxSize {
+				me[i] = ErrServerError
+				continue
+			}
+			key := redisKey(c, t.Key)
+			ttl, ok := redisTTL(t.Expiration)
+			envelope := encodeEnvelope(v, t.Flags, newCASID())
+			switch {
+			case policy == pb.MemcacheSetRequest_CAS:
+				if t.casID == 0 {
+					// The item was not returned by Get, so there is
+					// nothing to compare with.
+					me[i] = ErrNotStored
+					continue
+				}
+				var expected [8]byte
+				binary.BigEndian.PutUint64(expected[:], t.casID)
+				ms := ttl.Milliseconds()
+				if !ok {
+					ms = -1
+				}
+				cmds[i] = p.Eval(c, casScript, []string{key}, expected[:], envelope, ms)
+			case !ok && add:
+				// The item would be added, then expire right away.
+				cmds[i] = p.Exists(c, key)
+			case !ok:
+				cmds[i] = p.Del(c, key)
+			case add:
+				cmds[i] = p.SetNX(c, key, envelope, ttl)
+			default:
+				cmds[i] = p.Set(c, key, envelope, ttl)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return redisError(c, err)
+	}
+	any := false
+	for i, cmd := range cmds {
+		switch cmd := cmd.(type) {
+		case *redis.BoolCmd: // SET NX
+			if !cmd.Val() {
+				me[i] = ErrNotStored
+			}
+		case *redis.IntCmd: // EXISTS or DEL
+			if add && cmd.Val() != 0 {
+				me[i] = ErrNotStored
+			}
+		case *redis.Cmd: // casScript
+			switch n, _ := cmd.Int64(); n {
+			case 0:
+				me[i] = ErrNotStored
+			case -1:
+				me[i] = ErrCASConflict
+			}
+		}
+		any = any || me[i] != nil
+	}
+	if any {
+		return me
+	}
+	return nil
+}
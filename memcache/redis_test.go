@@ -0,0 +1,607 @@
+// Copyright 2026 Google LLC. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package memcache
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+
+	"google.golang.org/appengine"
+)
+
+// fakeRedis is an in-process Redis server with the commands that the Redis
+// backend uses, except for scripts. Tests of CompareAndSwap, whose script
+// must really run, use newMiniRedis instead.
+type fakeRedis struct {
+	t *testing.T
+
+	mu       sync.Mutex
+	values   map[string]string
+	expiries map[string]time.Time // of keys with a TTL
+	conns    int                  // connections accepted
+}
+
+// newFakeRedis starts a fakeRedis and points the package at it with the
+// Redis backend selected.
+func newFakeRedis(t *testing.T) *fakeRedis {
+	l, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	f := &fakeRedis{t: t, values: make(map[string]string), expiries: make(map[string]time.Time)}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			f.mu.Lock()
+			f.conns++
+			f.mu.Unlock()
+			go f.serve(conn)
+		}
+	}()
+	useRedisServer(t, l.Addr().String())
+	return f
+}
+
+// newMiniRedis starts a miniredis server, which runs Lua scripts, and points
+// the package at it with the Redis backend selected.
+func newMiniRedis(t *testing.T) *miniredis.Miniredis {
+	m := miniredis.RunT(t)
+	useRedisServer(t, m.Addr())
+	return m
+}
+
+// useRedisServer selects the Redis backend with the server at addr for the
+// duration of the test.
+func useRedisServer(t *testing.T, addr string) {
+	t.Setenv("MEMCACHE_USE_REDIS", "true")
+	t.Setenv("REDIS_ADDR", addr)
+	t.Cleanup(func() {
+		redisClients.Lock()
+		if cl := redisClients.m[addr]; cl != nil {
+			cl.Close()
+			delete(redisClients.m, addr)
+		}
+		redisClients.Unlock()
+	})
+}
+
+// readCommand reads a command, sent as an array of bulk strings.
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(line, "*") {
+		return nil, fmt.Errorf("unexpected command line %q", line)
+	}
+	n, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+	args := make([]string, n)
+	for i := range args {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+		b := make([]byte, size+2)
+		if _, err := io.ReadFull(r, b); err != nil {
+			return nil, err
+		}
+		args[i] = string(b[:size])
+	}
+	return args, nil
+}
+
+func (f *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	r, w := bufio.NewReader(conn), bufio.NewWriter(conn)
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+		f.mu.Lock()
+		reply := f.do(args)
+		f.mu.Unlock()
+		w.WriteString(reply)
+		if r.Buffered() == 0 {
+			w.Flush()
+		}
+	}
+}
+
+func bulk(s string) string {
+	return fmt.Sprintf("$%d\r\n%s\r\n", len(s), s)
+}
+
+const nilReply = "$-1\r\n"
+
+// get returns the value of key, if it has not expired. f.mu must be held.
+func (f *fakeRedis) get(key string) (string, bool) {
+	if exp, ok := f.expiries[key]; ok && !time.Now().Before(exp) {
+		delete(f.values, key)
+		delete(f.expiries, key)
+	}
+	v, ok := f.values[key]
+	return v, ok
+}
+
+// do runs a command and returns its reply. f.mu must be held.
+func (f *fakeRedis) do(args []string) string {
+	switch cmd := strings.ToUpper(args[0]); cmd {
+	case "PING":
+		return "+PONG\r\n"
+	case "GET":
+		v, ok := f.get(args[1])
+		if !ok {
+			return nilReply
+		}
+		return bulk(v)
+	case "MGET":
+		reply := fmt.Sprintf("*%d\r\n", len(args)-1)
+		for _, k := range args[1:] {
+			if v, ok := f.get(k); ok {
+				reply += bulk(v)
+			} else {
+				reply += nilReply
+			}
+		}
+		return reply
+	case "SET":
+		key, value := args[1], args[2]
+		var ttl time.Duration
+		var nx bool
+		for i := 3; i < len(args); i++ {
+			switch strings.ToUpper(args[i]) {
+			case "EX":
+				n, _ := strconv.Atoi(args[i+1])
+				ttl = time.Duration(n) * time.Second
+				i++
+			case "PX":
+				n, _ := strconv.Atoi(args[i+1])
+				ttl = time.Duration(n) * time.Millisecond
+				i++
+			case "NX":
+				nx = true
+			default:
+				return "-ERR syntax error\r\n"
+			}
+		}
+		if _, ok := f.get(key); ok && nx {
+			return nilReply
+		}
+		f.values[key] = value
+		delete(f.expiries, key)
+		if ttl > 0 {
+			f.expiries[key] = time.Now().Add(ttl)
+		}
+		return "+OK\r\n"
+	case "DEL", "EXISTS":
+		n := 0
+		for _, k := range args[1:] {
+			if _, ok := f.get(k); ok {
+				n++
+				if cmd == "DEL" {
+					delete(f.values, k)
+					delete(f.expiries, k)
+				}
+			}
+		}
+		return fmt.Sprintf(":%d\r\n", n)
+	}
+	return fmt.Sprintf("-ERR unknown command '%s'\r\n", args[0])
+}
+
+// ttl returns the TTL of key, or zero if it has none.
+func (f *fakeRedis) ttl(key string) time.Duration {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	exp, ok := f.expiries[key]
+	if !ok {
+		return 0
+	}
+	return time.Until(exp)
+}
+
+func TestRedisGetSet(t *testing.T) {
+	f := newFakeRedis(t)
+	c := context.Background()
+
+	if _, err := Get(c, "lyric"); err != ErrCacheMiss {
+		t.Errorf("Get of a missing item: got %v, want ErrCacheMiss", err)
+	}
+	item := &Item{Key: "lyric", Value: []byte("Where the buffalo roam"), Flags: 0xdeadbeef}
+	if err := Set(c, item); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	got, err := Get(c, "lyric")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if got.Key != item.Key || string(got.Value) != string(item.Value) || got.Flags != item.Flags {
+		t.Errorf("Get = %+v, want %+v", got, item)
+	}
+	f.mu.Lock()
+	if _, ok := f.values["memcache::lyric"]; !ok {
+		t.Errorf("keys in Redis: %v, want memcache::lyric", f.values)
+	}
+	f.mu.Unlock()
+	if ttl := f.ttl("memcache::lyric"); ttl != 0 {
+		t.Errorf("item without expiration has TTL %v", ttl)
+	}
+
+	if err := Add(c, &Item{Key: "lyric", Value: []byte("Oh, give me a home")}); err != ErrNotStored {
+		t.Errorf("Add of an existing key: got %v, want ErrNotStored", err)
+	}
+	if err := Add(c, &Item{Key: "other", Value: []byte("Oh, give me a home")}); err != nil {
+		t.Errorf("Add of a new key: %v", err)
+	}
+
+	if err := Delete(c, "lyric"); err != nil {
+		t.Errorf("Delete: %v", err)
+	}
+	if err := Delete(c, "lyric"); err != ErrCacheMiss {
+		t.Errorf("Delete of a deleted item: got %v, want ErrCacheMiss", err)
+	}
+}
+
+func TestRedisMulti(t *testing.T) {
+	newFakeRedis(t)
+	c := context.Background()
+
+	if err := SetMulti(c, []*Item{{Key: "a", Value: []byte("1")}, {Key: "b", Value: []byte("2")}}); err != nil {
+		t.Fatalf("SetMulti: %v", err)
+	}
+	err := AddMulti(c, []*Item{{Key: "a"}, {Key: "c", Value: []byte("3")}, {Key: "b"}})
+	me, ok := err.(appengine.MultiError)
+	if !ok || me[0] != ErrNotStored || me[1] != nil || me[2] != ErrNotStored {
+		t.Errorf("AddMulti: got %v, want ErrNotStored for the existing keys only", err)
+	}
+
+	m, err := GetMulti(c, []string{"a", "missing", "c"})
+	if err != nil {
+		t.Fatalf("GetMulti: %v", err)
+	}
+	if len(m) != 2 || string(m["a"].Value) != "1" || string(m["c"].Value) != "3" {
+		t.Errorf("GetMulti = %v, want a and c", m)
+	}
+
+	err = DeleteMulti(c, []string{"a", "missing", "b"})
+	me, ok = err.(appengine.MultiError)
+	if !ok || me[0] != nil || me[1] != ErrCacheMiss || me[2] != nil {
+		t.Errorf("DeleteMulti: got %v, want ErrCacheMiss for the missing key only", err)
+	}
+	if m, err := GetMulti(c, nil); m != nil || err != nil {
+		t.Errorf("GetMulti without keys = %v, %v; want nil, nil", m, err)
+	}
+}
+
+func TestRedisExpiration(t *testing.T) {
+	f := newFakeRedis(t)
+	c := context.Background()
+
+	if err := Set(c, &Item{Key: "long", Value: []byte("v"), Expiration: 90*time.Second + 500*time.Millisecond}); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if ttl := f.ttl("memcache::long"); ttl <= 89*time.Second || ttl > 90*time.Second {
+		t.Errorf("TTL = %v, want 90s", ttl)
+	}
+
+	// Expirations below a second expire items right away.
+	if err := Set(c, &Item{Key: "long", Value: []byte("v"), Expiration: time.Millisecond}); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if _, err := Get(c, "long"); err != ErrCacheMiss {
+		t.Errorf("Get of an expired item: got %v, want ErrCacheMiss", err)
+	}
+	if err := Add(c, &Item{Key: "long", Value: []byte("v"), Expiration: time.Millisecond}); err != nil {
+		t.Errorf("Add of an item expiring right away: %v", err)
+	}
+	Set(c, &Item{Key: "taken", Value: []byte("v")})
+	if err := Add(c, &Item{Key: "taken", Value: []byte("v"), Expiration: time.Millisecond}); err != ErrNotStored {
+		t.Errorf("Add of a taken key: got %v, want ErrNotStored", err)
+	}
+	if _, err := Get(c, "taken"); err != nil {
+		t.Errorf("Get after a failed Add: %v", err)
+	}
+}
+
+func TestRedisNamespaces(t *testing.T) {
+	newFakeRedis(t)
+	c := context.Background()
+	ns, err := appengine.Namespace(c, "ns")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Set(ns, &Item{Key: "k", Value: []byte("in ns")}); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if _, err := Get(c, "k"); err != ErrCacheMiss {
+		t.Errorf("Get in the default namespace: got %v, want ErrCacheMiss", err)
+	}
+	if it, err := Get(ns, "k"); err != nil || string(it.Value) != "in ns" {
+		t.Errorf("Get in the namespace = %v, %v", it, err)
+	}
+}
+
+func TestRedisErrors(t *testing.T) {
+	newFakeRedis(t)
+
+	c, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := Get(c, "k"); !errors.Is(err, context.Canceled) {
+		t.Errorf("Get with a canceled context: got %v, want context.Canceled", err)
+	}
+	c = context.Background()
+	for name, err := range map[string]error{
+		"Peek":      func() error { _, err := Peek(c, "k"); return err }(),
+		"Increment": func() error { _, err := Increment(c, "k", 1, 0); return err }(),
+		"Flush":     Flush(c),
+	} {
+		if err == nil || !strings.Contains(err.Error(), "not supported") {
+			t.Errorf("%s: got %v, want an unsupported error", name, err)
+		}
+	}
+
+	t.Setenv("REDIS_ADDR", "")
+	if _, err := Get(c, "k"); err == nil {
+		t.Errorf("Get without REDIS_ADDR succeeded")
+	}
+	t.Setenv("MEMCACHE_USE_REDIS", "maybe")
+	if err := Set(c, &Item{Key: "k"}); err == nil || !strings.Contains(err.Error(), "MEMCACHE_USE_REDIS") {
+		t.Errorf("Set with an invalid MEMCACHE_USE_REDIS: got %v, want an error naming it", err)
+	}
+}
+
+func TestRedisCompareAndSwap(t *testing.T) {
+	m := newMiniRedis(t)
+	c := context.Background()
+
+	if err := Set(c, &Item{Key: "k", Value: []byte("v1")}); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	it, err := Get(c, "k")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	stale := *it
+	it.Value, it.Flags, it.Expiration = []byte("v2"), 7, time.Minute
+	if err := CompareAndSwap(c, it); err != nil {
+		t.Fatalf("CompareAndSwap: %v", err)
+	}
+	if got, err := Get(c, "k"); err != nil || string(got.Value) != "v2" || got.Flags != 7 {
+		t.Errorf("Get after CompareAndSwap = %+v, %v; want v2 with flags 7", got, err)
+	}
+	if ttl := m.TTL("memcache::k"); ttl != time.Minute {
+		t.Errorf("TTL after CompareAndSwap = %v, want 1m", ttl)
+	}
+	if err := CompareAndSwap(c, &stale); err != ErrCASConflict {
+		t.Errorf("CompareAndSwap of a stale item: got %v, want ErrCASConflict", err)
+	}
+	if err := CompareAndSwap(c, &Item{Key: "k", Value: []byte("v3")}); err != ErrNotStored {
+		t.Errorf("CompareAndSwap of an item not from Get: got %v, want ErrNotStored", err)
+	}
+
+	// Without expiration, the TTL is removed.
+	it, _ = Get(c, "k")
+	it.Expiration = 0
+	if err := CompareAndSwap(c, it); err != nil {
+		t.Fatalf("CompareAndSwap without expiration: %v", err)
+	}
+	if ttl := m.TTL("memcache::k"); ttl != 0 {
+		t.Errorf("TTL after CompareAndSwap without expiration = %v, want none", ttl)
+	}
+	// Expirations below a second delete the item.
+	it, _ = Get(c, "k")
+	it.Expiration = time.Millisecond
+	if err := CompareAndSwap(c, it); err != nil {
+		t.Fatalf("CompareAndSwap expiring right away: %v", err)
+	}
+	if m.Exists("memcache::k") {
+		t.Errorf("item swapped to expire right away is still in Redis")
+	}
+
+	Set(c, &Item{Key: "k", Value: []byte("v3")})
+	it, _ = Get(c, "k")
+	Set(c, &Item{Key: "k", Value: []byte("v3")})
+	if err := CompareAndSwap(c, it); err != ErrCASConflict {
+		t.Errorf("CompareAndSwap after a Set: got %v, want ErrCASConflict", err)
+	}
+	// Values of the first envelope format have no cas ID to match.
+	it, _ = Get(c, "k")
+	m.Set("memcache::k", "\x01\x00\x00\x00\x00v4")
+	if err := CompareAndSwap(c, it); err != ErrCASConflict {
+		t.Errorf("CompareAndSwap of an item without cas ID: got %v, want ErrCASConflict", err)
+	}
+	if it, err := Get(c, "k"); err != nil || string(it.Value) != "v4" {
+		t.Errorf("Get of an item without cas ID = %v, %v; want v4", it, err)
+	}
+	Set(c, &Item{Key: "k", Value: []byte("v5")})
+	it, _ = Get(c, "k")
+	Delete(c, "k")
+	if err := CompareAndSwap(c, it); err != ErrNotStored {
+		t.Errorf("CompareAndSwap of a deleted item: got %v, want ErrNotStored", err)
+	}
+
+	Set(c, &Item{Key: "a", Value: []byte("1")})
+	Set(c, &Item{Key: "b", Value: []byte("2")})
+	items, err := GetMulti(c, []string{"a", "b"})
+	if err != nil {
+		t.Fatalf("GetMulti: %v", err)
+	}
+	Set(c, &Item{Key: "b", Value: []byte("other")})
+	err = CompareAndSwapMulti(c, []*Item{items["a"], items["b"]})
+	me, ok := err.(appengine.MultiError)
+	if !ok || me[0] != nil || me[1] != ErrCASConflict {
+		t.Errorf("CompareAndSwapMulti: got %v, want ErrCASConflict for b only", err)
+	}
+}
+
+func TestRedisCompareAndSwapRace(t *testing.T) {
+	newMiniRedis(t)
+	c := context.Background()
+
+	if err := Set(c, &Item{Key: "k", Value: []byte("v")}); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	var items [2]*Item
+	for i := range items {
+		it, err := Get(c, "k")
+		if err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+		it.Value = []byte(fmt.Sprint("writer", i))
+		items[i] = it
+	}
+	var errs [2]error
+	var wg sync.WaitGroup
+	for i := range items {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = CompareAndSwap(c, items[i])
+		}(i)
+	}
+	wg.Wait()
+
+	winner := -1
+	for i, err := range errs {
+		switch err {
+		case nil:
+			winner = i
+		case ErrCASConflict:
+		default:
+			t.Errorf("CompareAndSwap of writer %d: %v", i, err)
+		}
+	}
+	if (errs[0] == nil) == (errs[1] == nil) {
+		t.Fatalf("CompareAndSwap errors = %v, want exactly one ErrCASConflict", errs)
+	}
+	if it, err := Get(c, "k"); err != nil || string(it.Value) != fmt.Sprint("writer", winner) {
+		t.Errorf("Get = %v, %v; want the value of writer %d", it, err, winner)
+	}
+}
+
+func TestRedisCodecs(t *testing.T) {
+	newMiniRedis(t)
+	c := context.Background()
+
+	type song struct {
+		Title string
+		Lines []string
+		Year  int
+	}
+	want := song{"Home on the Range", []string{"Oh, give me a home", "Where the buffalo roam"}, 1872}
+	for name, cd := range map[string]Codec{"Gob": Gob, "JSON": JSON} {
+		key := "song-" + name
+		if err := cd.Set(c, &Item{Key: key, Object: want, Flags: 42}); err != nil {
+			t.Fatalf("%s.Set: %v", name, err)
+		}
+		var got song
+		it, err := cd.Get(c, key, &got)
+		if err != nil {
+			t.Fatalf("%s.Get: %v", name, err)
+		}
+		if got.Title != want.Title || len(got.Lines) != 2 || got.Lines[1] != want.Lines[1] || got.Year != want.Year {
+			t.Errorf("%s.Get = %+v, want %+v", name, got, want)
+		}
+		if it.Flags != 42 {
+			t.Errorf("%s.Get flags = %d, want 42", name, it.Flags)
+		}
+
+		got.Year++
+		it.Object = got
+		if err := cd.CompareAndSwap(c, it); err != nil {
+			t.Errorf("%s.CompareAndSwap: %v", name, err)
+		}
+		if err := cd.Add(c, &Item{Key: key, Object: want}); err != ErrNotStored {
+			t.Errorf("%s.Add of an existing key: got %v, want ErrNotStored", name, err)
+		}
+	}
+}
+
+func TestRedisValueSize(t *testing.T) {
+	f := newFakeRedis(t)
+	c := context.Background()
+
+	big := make([]byte, defaultMaxValueSize)
+	if err := Set(c, &Item{Key: "max", Value: big}); err != nil {
+		t.Errorf("Set of a value of the maximum size: %v", err)
+	}
+	if err := Set(c, &Item{Key: "over", Value: append(big, 0)}); err != ErrServerError {
+		t.Errorf("Set of an oversized value: got %v, want ErrServerError", err)
+	}
+	if err := Gob.Set(c, &Item{Key: "over", Object: append(big, 0)}); err != ErrServerError {
+		t.Errorf("Gob.Set of an oversized value: got %v, want ErrServerError", err)
+	}
+
+	err := SetMulti(c, []*Item{{Key: "a", Value: []byte("1")}, {Key: "b", Value: append(big, 0)}, {Key: "c", Value: []byte("3")}})
+	me, ok := err.(appengine.MultiError)
+	if !ok || len(me) != 3 || me[0] != nil || me[1] != ErrServerError || me[2] != nil {
+		t.Errorf("SetMulti: got %v, want ErrServerError for b only", err)
+	}
+	f.mu.Lock()
+	_, stored := f.values["memcache::b"]
+	f.mu.Unlock()
+	if stored {
+		t.Errorf("oversized value was sent to Redis")
+	}
+	if m, err := GetMulti(c, []string{"a", "b", "c"}); err != nil || len(m) != 2 {
+		t.Errorf("GetMulti after SetMulti = %v, %v; want a and c", m, err)
+	}
+
+	t.Setenv("MEMCACHE_MAX_VALUE_SIZE", "10")
+	if err := Set(c, &Item{Key: "k", Value: make([]byte, 10)}); err != nil {
+		t.Errorf("Set of 10 bytes with a limit of 10: %v", err)
+	}
+	if err := Add(c, &Item{Key: "k2", Value: make([]byte, 11)}); err != ErrServerError {
+		t.Errorf("Add of 11 bytes with a limit of 10: got %v, want ErrServerError", err)
+	}
+	t.Setenv("MEMCACHE_MAX_VALUE_SIZE", "lots")
+	if err := Set(c, &Item{Key: "k"}); err == nil || !strings.Contains(err.Error(), "MEMCACHE_MAX_VALUE_SIZE") {
+		t.Errorf("Set with an invalid MEMCACHE_MAX_VALUE_SIZE: got %v, want an error naming it", err)
+	}
+}
+
+func TestRedisPool(t *testing.T) {
+	f := newFakeRedis(t)
+	c := context.Background()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			key := fmt.Sprint("k", i%5)
+			if err := Set(c, &Item{Key: key, Value: []byte(key)}); err != nil {
+				t.Errorf("Set: %v", err)
+			}
+			if it, err := Get(c, key); err != nil || string(it.Value) != key {
+				t.Errorf("Get(%q) = %v, %v", key, it, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.conns == 0 || f.conns > 50 {
+		t.Errorf("opened %d connections for 50 concurrent calls", f.conns)
+	}
+}
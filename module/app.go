@@ -0,0 +1,119 @@
+// Copyright 2026 Google LLC. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package module
+
+import (
+	"context"
+	"sync"
+
+	"google.golang.org/appengine"
+)
+
+// Application holds the application-level details returned by AppInfo.
+type Application struct {
+	ID              string `json:"id"`                        // application ID, e.g. "my-project"
+	LocationID      string `json:"locationId,omitempty"`      // region the application runs in, e.g. "us-central"
+	DefaultHostname string `json:"defaultHostname,omitempty"` // e.g. "my-project.appspot.com"
+	DefaultBucket   string `json:"defaultBucket,omitempty"`   // default Cloud Storage bucket
+	ServingStatus   string `json:"servingStatus,omitempty"`   // "SERVING", "USER_DISABLED" or "SYSTEM_DISABLED"
+	DatabaseType    string `json:"databaseType,omitempty"`    // e.g. "CLOUD_FIRESTORE"
+
+	// Legacy is set if the details come from the legacy runtime, which only
+	// provides ID; the other fields are then empty.
+	Legacy bool `json:"legacy,omitempty"`
+}
+
+// AppInfoOption configures a call to AppInfo.
+type AppInfoOption func(*appInfoOptions)
+
+type appInfoOptions struct {
+	refresh bool
+}
+
+// Refresh reports whether AppInfo should fetch the application details again
+// instead of returning the ones it remembered.
+func Refresh(refresh bool) AppInfoOption {
+	return func(o *appInfoOptions) { o.refresh = refresh }
+}
+
+// appInfoCache remembers the application details per project. Unlike the
+// cache enabled by EnableCache, it is always on: the details almost never
+// change.
+var appInfoCache struct {
+	sync.Mutex
+	apps map[string]*Application
+}
+
+// AppInfo returns details about the application, such as its region and
+// default hostname. The details are fetched once per project and remembered
+// for the life of the process, or until the Refresh option or InvalidateCache
+// is used. On the legacy backend, only the ID is available.
+func AppInfo(c context.Context, opts ...AppInfoOption) (_ *Application, err error) {
+	c, done := startCall(c, "AppInfo", "", "")
+	defer done(&err)
+	var o appInfoOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if !useAdminAPI(c) {
+		return legacyAppInfo(c), nil
+	}
+	projectID := getProjectID(c)
+	if !o.refresh {
+		appInfoCache.Lock()
+		app := appInfoCache.apps[projectID]
+		appInfoCache.Unlock()
+		if app != nil {
+			cp := *app
+			return &cp, nil
+		}
+	}
+	app, err := appInfoAdmin(c, projectID)
+	if fallBack(c, err) {
+		return legacyAppInfo(c), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	appInfoCache.Lock()
+	if appInfoCache.apps == nil {
+		appInfoCache.apps = make(map[string]*Application)
+	}
+	appInfoCache.apps[projectID] = app
+	appInfoCache.Unlock()
+	cp := *app
+	return &cp, nil
+}
+
+func appInfoAdmin(c context.Context, projectID string) (*Application, error) {
+	b, err := newAdminBackend(c, "get_application")
+	if err != nil {
+		return nil, err
+	}
+	a, err := b.GetApplication(c, projectID,
+		"id,locationId,defaultHostname,defaultBucket,servingStatus,databaseType")
+	if err != nil {
+		return nil, err
+	}
+	return &Application{
+		ID:              a.Id,
+		LocationID:      a.LocationId,
+		DefaultHostname: a.DefaultHostname,
+		DefaultBucket:   a.DefaultBucket,
+		ServingStatus:   a.ServingStatus,
+		DatabaseType:    a.DatabaseType,
+	}, nil
+}
+
+func legacyAppInfo(c context.Context) *Application {
+	return &Application{ID: appengine.AppID(c), Legacy: true}
+}
+
+// invalidateAppInfo discards the remembered application details.
+func invalidateAppInfo() {
+	appInfoCache.Lock()
+	appInfoCache.apps = nil
+	appInfoCache.Unlock()
+}
@@ -0,0 +1,72 @@
+// Copyright 2026 Google LLC. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package module
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	admin "google.golang.org/api/appengine/v1"
+	"google.golang.org/appengine/internal/aetesting"
+)
+
+func TestAppInfo(t *testing.T) {
+	InvalidateCache()
+	t.Cleanup(InvalidateCache)
+	hits := 0
+	ctx := aetesting.FakeAdminContext(t, func(req *aetesting.AdminRequest) (int, interface{}) {
+		req.Expect(t, "GET", "/v1/apps/test-project", "")
+		hits++
+		return http.StatusOK, &admin.Application{
+			Id:              "test-project",
+			LocationId:      "europe-west1",
+			DefaultHostname: "test-project.ew.r.appspot.com",
+			DefaultBucket:   "test-project.appspot.com",
+			ServingStatus:   "SERVING",
+			DatabaseType:    "CLOUD_FIRESTORE",
+		}
+	})
+	want := Application{
+		ID:              "test-project",
+		LocationID:      "europe-west1",
+		DefaultHostname: "test-project.ew.r.appspot.com",
+		DefaultBucket:   "test-project.appspot.com",
+		ServingStatus:   "SERVING",
+		DatabaseType:    "CLOUD_FIRESTORE",
+	}
+	for i := 0; i < 2; i++ {
+		app, err := AppInfo(ctx)
+		if err != nil {
+			t.Fatalf("AppInfo: %v", err)
+		}
+		if *app != want {
+			t.Errorf("AppInfo = %+v, want %+v", *app, want)
+		}
+		// Changes to the result must not affect the remembered details.
+		app.ID = "changed"
+	}
+	if hits != 1 {
+		t.Errorf("AppInfo made %d requests for two calls, want 1", hits)
+	}
+	if _, err := AppInfo(ctx, Refresh(true)); err != nil {
+		t.Fatalf("AppInfo with Refresh: %v", err)
+	}
+	if hits != 2 {
+		t.Errorf("AppInfo with Refresh made %d requests in total, want 2", hits)
+	}
+}
+
+func TestAppInfo_Legacy(t *testing.T) {
+	t.Setenv("MODULES_USE_ADMIN_API", "false")
+	t.Setenv("GAE_APPLICATION", "s~legacy-app")
+	app, err := AppInfo(context.Background())
+	if err != nil {
+		t.Fatalf("AppInfo: %v", err)
+	}
+	if want := (Application{ID: "legacy-app", Legacy: true}); *app != want {
+		t.Errorf("AppInfo = %+v, want %+v", *app, want)
+	}
+}
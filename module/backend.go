@@ -0,0 +1,210 @@
+// Copyright 2026 Google LLC. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package module
+
+import (
+	"context"
+	"net/http"
+
+	admin "google.golang.org/api/appengine/v1"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+)
+
+// adminBackend is the set of App Engine Admin API calls used by this package.
+// Every Admin API request goes through it, so that it is the single place for
+// cross-cutting behavior and can be replaced by a test double.
+type adminBackend interface {
+	GetApplication(ctx context.Context, project string, fields ...googleapi.Field) (*admin.Application, error)
+	ListServices(ctx context.Context, project, pageToken string, fields ...googleapi.Field) (*admin.ListServicesResponse, error)
+	GetService(ctx context.Context, project, module string, fields ...googleapi.Field) (*admin.Service, error)
+	PatchService(ctx context.Context, project, module string, s *admin.Service, updateMask string, migrateTraffic bool) (*admin.Operation, error)
+	ListVersions(ctx context.Context, project, module, pageToken, view string, fields ...googleapi.Field) (*admin.ListVersionsResponse, error)
+	GetVersion(ctx context.Context, project, module, version, view string, fields ...googleapi.Field) (*admin.Version, error)
+	PatchVersion(ctx context.Context, project, module, version string, v *admin.Version, updateMask string) (*admin.Operation, error)
+	DeleteVersion(ctx context.Context, project, module, version string) (*admin.Operation, error)
+	ListInstances(ctx context.Context, project, module, version, pageToken string) (*admin.ListInstancesResponse, error)
+	DebugInstance(ctx context.Context, project, module, version, instance string, req *admin.DebugInstanceRequest) (*admin.Operation, error)
+	GetOperation(ctx context.Context, project, operation string) (*admin.Operation, error)
+}
+
+// newAdminBackend returns the backend used for an Admin API call on behalf of
+// the named method. It is a variable so that tests can substitute a double.
+var newAdminBackend = func(ctx context.Context, methodName string) (adminBackend, error) {
+	var opts []option.ClientOption
+	if b := backendFromContext(ctx); b != nil {
+		if b.client != nil {
+			return withGuards(withLogging(b.client)), nil
+		}
+		opts = b.opts
+	}
+	svc, err := getAdminService(ctx, methodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return withGuards(withLogging(&adminService{svc})), nil
+}
+
+// Backend is an App Engine Admin API configuration that can be attached to a
+// context with WithBackend. Functions of this package called with such a
+// context use the Admin API with that configuration, regardless of the
+// MODULES_USE_ADMIN_API and project environment variables.
+type Backend struct {
+	project string
+	opts    []option.ClientOption
+	client  adminBackend // if set, used in place of the Admin API client
+}
+
+// NewBackend returns a Backend that operates on the given project, creating
+// its Admin API clients with opts. If project is the empty string, the
+// project of the running application is used.
+func NewBackend(project string, opts ...option.ClientOption) *Backend {
+	return &Backend{project: project, opts: opts}
+}
+
+type backendContextKey struct{}
+
+// WithBackend returns a copy of ctx that carries b. Calls made with the
+// returned context use b instead of the configuration taken from the
+// environment, which lets a request handler serve several projects, or tests
+// run in parallel, without changing process-wide state.
+func WithBackend(ctx context.Context, b *Backend) context.Context {
+	return context.WithValue(ctx, backendContextKey{}, b)
+}
+
+type projectContextKey struct{}
+
+// WithProjectContext returns a copy of ctx whose calls operate on the given
+// project, which lets one process administer several projects, for example
+// one per tenant. Like a Backend, it makes the calls use the Admin API, and
+// the project takes precedence over that of a Backend attached to ctx. It
+// can be combined with WithBackend and WithQuotaProject.
+func WithProjectContext(ctx context.Context, projectID string) context.Context {
+	return context.WithValue(ctx, projectContextKey{}, projectID)
+}
+
+// projectFromContext returns the project set on ctx by WithProjectContext, or
+// "".
+func projectFromContext(ctx context.Context) string {
+	p, _ := ctx.Value(projectContextKey{}).(string)
+	return p
+}
+
+// backendFromContext returns the Backend attached to ctx, or nil.
+func backendFromContext(ctx context.Context) *Backend {
+	b, _ := ctx.Value(backendContextKey{}).(*Backend)
+	return b
+}
+
+// adminService implements adminBackend with the generated Admin API client.
+type adminService struct {
+	svc *admin.APIService
+}
+
+// setRequestHeaders adds the headers derived from ctx, such as its trace
+// context, to the headers h of an outgoing Admin API request.
+func setRequestHeaders(ctx context.Context, h http.Header) {
+	setTraceHeader(ctx, h)
+	setQuotaProjectHeader(ctx, h)
+}
+
+func (s *adminService) GetApplication(ctx context.Context, project string, fields ...googleapi.Field) (*admin.Application, error) {
+	call := s.svc.Apps.Get(project).Context(ctx)
+	setRequestHeaders(ctx, call.Header())
+	if len(fields) > 0 {
+		call = call.Fields(fields...)
+	}
+	return call.Do()
+}
+
+func (s *adminService) ListServices(ctx context.Context, project, pageToken string, fields ...googleapi.Field) (*admin.ListServicesResponse, error) {
+	call := s.svc.Apps.Services.List(project).Context(ctx)
+	setRequestHeaders(ctx, call.Header())
+	if len(fields) > 0 {
+		call = call.Fields(fields...)
+	}
+	if pageToken != "" {
+		call = call.PageToken(pageToken)
+	}
+	return call.Do()
+}
+
+func (s *adminService) GetService(ctx context.Context, project, module string, fields ...googleapi.Field) (*admin.Service, error) {
+	call := s.svc.Apps.Services.Get(project, module).Context(ctx)
+	setRequestHeaders(ctx, call.Header())
+	if len(fields) > 0 {
+		call = call.Fields(fields...)
+	}
+	return call.Do()
+}
+
+func (s *adminService) PatchService(ctx context.Context, project, module string, service *admin.Service, updateMask string, migrateTraffic bool) (*admin.Operation, error) {
+	call := s.svc.Apps.Services.Patch(project, module, service).UpdateMask(updateMask).Context(ctx)
+	setRequestHeaders(ctx, call.Header())
+	if migrateTraffic {
+		call = call.MigrateTraffic(true)
+	}
+	return call.Do()
+}
+
+func (s *adminService) ListVersions(ctx context.Context, project, module, pageToken, view string, fields ...googleapi.Field) (*admin.ListVersionsResponse, error) {
+	call := s.svc.Apps.Services.Versions.List(project, module).Context(ctx)
+	setRequestHeaders(ctx, call.Header())
+	if len(fields) > 0 {
+		call = call.Fields(fields...)
+	}
+	if pageToken != "" {
+		call = call.PageToken(pageToken)
+	}
+	if view != "" {
+		call = call.View(view)
+	}
+	return call.Do()
+}
+
+func (s *adminService) GetVersion(ctx context.Context, project, module, version, view string, fields ...googleapi.Field) (*admin.Version, error) {
+	call := s.svc.Apps.Services.Versions.Get(project, module, version).Context(ctx)
+	setRequestHeaders(ctx, call.Header())
+	if len(fields) > 0 {
+		call = call.Fields(fields...)
+	}
+	if view != "" {
+		call = call.View(view)
+	}
+	return call.Do()
+}
+
+func (s *adminService) PatchVersion(ctx context.Context, project, module, version string, v *admin.Version, updateMask string) (*admin.Operation, error) {
+	call := s.svc.Apps.Services.Versions.Patch(project, module, version, v).UpdateMask(updateMask).Context(ctx)
+	setRequestHeaders(ctx, call.Header())
+	return call.Do()
+}
+
+func (s *adminService) DeleteVersion(ctx context.Context, project, module, version string) (*admin.Operation, error) {
+	call := s.svc.Apps.Services.Versions.Delete(project, module, version).Context(ctx)
+	setRequestHeaders(ctx, call.Header())
+	return call.Do()
+}
+
+func (s *adminService) ListInstances(ctx context.Context, project, module, version, pageToken string) (*admin.ListInstancesResponse, error) {
+	call := s.svc.Apps.Services.Versions.Instances.List(project, module, version).Context(ctx)
+	setRequestHeaders(ctx, call.Header())
+	if pageToken != "" {
+		call = call.PageToken(pageToken)
+	}
+	return call.Do()
+}
+
+func (s *adminService) DebugInstance(ctx context.Context, project, module, version, instance string, req *admin.DebugInstanceRequest) (*admin.Operation, error) {
+	call := s.svc.Apps.Services.Versions.Instances.Debug(project, module, version, instance, req).Context(ctx)
+	setRequestHeaders(ctx, call.Header())
+	return call.Do()
+}
+
+func (s *adminService) GetOperation(ctx context.Context, project, operation string) (*admin.Operation, error) {
+	call := s.svc.Apps.Operations.Get(project, operation).Context(ctx)
+	setRequestHeaders(ctx, call.Header())
+	return call.Do()
+}
@@ -0,0 +1,178 @@
+// Copyright 2026 Google LLC. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package module
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	admin "google.golang.org/api/appengine/v1"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+)
+
+// stubBackend is an adminBackend whose methods are provided by its function
+// fields. Calling a method whose field is nil panics through the nil embedded
+// interface, which fails the test.
+type stubBackend struct {
+	adminBackend
+
+	listServices func(pageToken string) (*admin.ListServicesResponse, error)
+	getService   func(module string) (*admin.Service, error)
+	listVersions func(module, pageToken string) (*admin.ListVersionsResponse, error)
+	getVersion   func(module, version string) (*admin.Version, error)
+	patchVersion func(module, version string, v *admin.Version, updateMask string) (*admin.Operation, error)
+}
+
+func (s *stubBackend) ListServices(ctx context.Context, project, pageToken string, fields ...googleapi.Field) (*admin.ListServicesResponse, error) {
+	return s.listServices(pageToken)
+}
+
+func (s *stubBackend) GetService(ctx context.Context, project, module string, fields ...googleapi.Field) (*admin.Service, error) {
+	return s.getService(module)
+}
+
+func (s *stubBackend) ListVersions(ctx context.Context, project, module, pageToken, view string, fields ...googleapi.Field) (*admin.ListVersionsResponse, error) {
+	return s.listVersions(module, pageToken)
+}
+
+func (s *stubBackend) GetVersion(ctx context.Context, project, module, version, view string, fields ...googleapi.Field) (*admin.Version, error) {
+	return s.getVersion(module, version)
+}
+
+func (s *stubBackend) PatchVersion(ctx context.Context, project, module, version string, v *admin.Version, updateMask string) (*admin.Operation, error) {
+	return s.patchVersion(module, version, v, updateMask)
+}
+
+// useStubBackend makes the Admin API paths of the package use b for the
+// duration of the test.
+func useStubBackend(t *testing.T, b adminBackend) {
+	t.Setenv("MODULES_USE_ADMIN_API", "true")
+	t.Setenv("GOOGLE_CLOUD_PROJECT", "test-project")
+	orig := newAdminBackend
+	newAdminBackend = func(context.Context, string) (adminBackend, error) { return b, nil }
+	t.Cleanup(func() { newAdminBackend = orig })
+}
+
+func TestWithBackendConcurrent(t *testing.T) {
+	t.Parallel()
+	tenants := map[string][]string{
+		"tenant-a": {"default", "api"},
+		"tenant-b": {"default", "worker", "batch"},
+	}
+	var wg sync.WaitGroup
+	for project, want := range tenants {
+		want := want
+		c := WithBackend(context.Background(), &Backend{
+			project: project,
+			client: &stubBackend{
+				listServices: func(pageToken string) (*admin.ListServicesResponse, error) {
+					resp := &admin.ListServicesResponse{}
+					for _, id := range want {
+						resp.Services = append(resp.Services, &admin.Service{Id: id})
+					}
+					return resp, nil
+				},
+			},
+		})
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				got, err := List(c)
+				if err != nil {
+					t.Errorf("List: %v", err)
+					return
+				}
+				if !reflect.DeepEqual(got, want) {
+					t.Errorf("List = %v, want %v", got, want)
+				}
+			}()
+		}
+	}
+	wg.Wait()
+}
+
+func TestNewBackendProject(t *testing.T) {
+	t.Parallel()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if want := "/v1/apps/other-project/services"; r.URL.Path != want {
+			t.Errorf("request path = %q, want %q", r.URL.Path, want)
+		}
+		fmt.Fprint(w, `{"services": [{"id": "default"}]}`)
+	}))
+	defer srv.Close()
+
+	c := WithBackend(context.Background(), NewBackend("other-project",
+		option.WithEndpoint(srv.URL+"/"), option.WithoutAuthentication()))
+	got, err := List(c)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if want := []string{"default"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("List = %v, want %v", got, want)
+	}
+}
+
+func TestWithProjectContextConcurrent(t *testing.T) {
+	t.Parallel()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var project string
+		if _, err := fmt.Sscanf(r.URL.Path, "/v1/apps/%s", &project); err != nil {
+			t.Errorf("unexpected request path %q", r.URL.Path)
+		}
+		project = strings.TrimSuffix(project, "/services")
+		if got := r.Header.Get(userProjectHeader); got != "billing-project" {
+			t.Errorf("outgoing %s header = %q, want billing-project", userProjectHeader, got)
+		}
+		fmt.Fprintf(w, `{"services": [{"id": "%s-service"}]}`, project)
+	}))
+	defer srv.Close()
+
+	base := WithQuotaProject(WithBackend(context.Background(), NewBackend("other-project",
+		option.WithEndpoint(srv.URL+"/"), option.WithoutAuthentication())), "billing-project")
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		project := []string{"tenant-a", "tenant-b"}[i%2]
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// The project is inherited by contexts derived from the one it
+			// was set on.
+			c, cancel := context.WithCancel(WithProjectContext(base, project))
+			defer cancel()
+			got, err := List(c)
+			if err != nil {
+				t.Errorf("List: %v", err)
+				return
+			}
+			if want := []string{project + "-service"}; !reflect.DeepEqual(got, want) {
+				t.Errorf("List = %v, want %v", got, want)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestWithProjectContextUsesAdminAPI(t *testing.T) {
+	var path string
+	newAdminTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		fmt.Fprint(w, `{"services": [{"id": "default"}]}`)
+	})
+	t.Setenv("MODULES_USE_ADMIN_API", "false")
+	if _, err := List(WithProjectContext(context.Background(), "tenant-a")); err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if want := "/v1/apps/tenant-a/services"; path != want {
+		t.Errorf("request path = %q, want %q", path, want)
+	}
+}
@@ -0,0 +1,51 @@
+// Copyright 2026 Google LLC. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package module
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	admin "google.golang.org/api/appengine/v1"
+	"google.golang.org/appengine"
+	"google.golang.org/appengine/internal"
+	"google.golang.org/appengine/internal/aetesting"
+)
+
+func TestBackgroundContext(t *testing.T) {
+	fake := aetesting.FakeAdminContext(t, func(req *aetesting.AdminRequest) (int, interface{}) {
+		req.Expect(t, "GET", "/v1/apps/bg-project/services", "")
+		return 0, &admin.ListServicesResponse{Services: []*admin.Service{{Id: "default"}, {Id: "worker"}}}
+	})
+	endpoint, _ := internal.AdminEndpointOverride(fake)
+	// The identity is captured when the context is created, and the Admin API
+	// is used even where the legacy backend is selected.
+	t.Setenv("MODULES_USE_ADMIN_API", "false")
+	t.Setenv("GOOGLE_CLOUD_PROJECT", "bg-project")
+	t.Setenv("GAE_SERVICE", "worker")
+	t.Setenv("GAE_VERSION", "v2")
+	ctx := internal.WithAdminEndpointOverride(appengine.NewBackgroundContext(), endpoint)
+	t.Setenv("GAE_SERVICE", "other")
+	t.Setenv("GAE_VERSION", "v3")
+
+	got, err := List(ctx)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if want := []string{"default", "worker"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("List = %v, want %v", got, want)
+	}
+	if m, err := CurrentModule(ctx); err != nil || m != "worker" {
+		t.Errorf("CurrentModule = %q, %v; want worker", m, err)
+	}
+	if v, err := CurrentVersion(ctx); err != nil || v != "v2" {
+		t.Errorf("CurrentVersion = %q, %v; want v2", v, err)
+	}
+
+	if _, err := ListLegacy(ctx); !errors.Is(err, appengine.ErrBackgroundContext) {
+		t.Errorf("ListLegacy error = %v, want ErrBackgroundContext", err)
+	}
+}
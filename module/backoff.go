@@ -0,0 +1,85 @@
+// Copyright 2026 Google LLC. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package module
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+// Backoff is a policy for the delays between repeated attempts, such as
+// retries of rejected requests and polls of pending operations. The first
+// delay is Initial, and each further delay is Multiplier times the previous
+// one, up to Max. A Multiplier below 1 keeps the delay constant, and a zero
+// Max does not limit it. Each delay is then randomized by up to the fraction
+// Jitter in either direction, so a Jitter of 0.1 yields delays within 10% of
+// the computed ones.
+//
+// A Backoff records the delays it has returned; use a fresh copy for every
+// sequence of attempts.
+type Backoff struct {
+	Initial    time.Duration
+	Max        time.Duration
+	Multiplier float64
+	Jitter     float64
+
+	cur time.Duration // last delay before jitter; zero before the first
+}
+
+// Next returns the delay before the next attempt.
+func (b *Backoff) Next() time.Duration {
+	if b.cur == 0 {
+		b.cur = b.Initial
+	} else if b.Multiplier > 1 {
+		b.cur = time.Duration(float64(b.cur) * b.Multiplier)
+	}
+	if b.Max > 0 && b.cur > b.Max {
+		b.cur = b.Max
+	}
+	d := b.cur
+	if j := b.Jitter; j > 0 {
+		if j > 1 {
+			j = 1
+		}
+		d = time.Duration(float64(d) * (1 + j*(2*rand.Float64()-1)))
+	}
+	return d
+}
+
+// Sleep waits for the delay returned by Next. It returns c's error if c is
+// done first.
+func (b *Backoff) Sleep(c context.Context) error {
+	t := time.NewTimer(b.Next())
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-c.Done():
+		return c.Err()
+	}
+}
+
+type backoffContextKey struct{}
+
+// WithBackoff returns a copy of ctx whose calls use b instead of the default
+// policies for retrying requests rejected for exceeding a quota, for polling
+// pending operations, and for retrying the polls of Watch after a failure.
+// The defaults start after one second for retries and polls, and after twice
+// the interval for Watch.
+func WithBackoff(ctx context.Context, b Backoff) context.Context {
+	b.cur = 0
+	return context.WithValue(ctx, backoffContextKey{}, b)
+}
+
+// backoffFor returns a fresh copy of the policy set on c with WithBackoff, or
+// of def if there is none.
+func backoffFor(c context.Context, def Backoff) *Backoff {
+	if b, ok := c.Value(backoffContextKey{}).(Backoff); ok {
+		def = b
+	}
+	def.cur = 0
+	return &def
+}
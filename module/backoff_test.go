@@ -0,0 +1,99 @@
+// Copyright 2026 Google LLC. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package module
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBackoffNext(t *testing.T) {
+	tests := []struct {
+		name string
+		b    Backoff
+		want []float64 // seconds
+	}{
+		{"Exponential", Backoff{Initial: time.Second, Multiplier: 2}, []float64{1, 2, 4, 8, 16}},
+		{"Max", Backoff{Initial: time.Second, Max: 5 * time.Second, Multiplier: 2}, []float64{1, 2, 4, 5, 5}},
+		{"Constant", Backoff{Initial: 3 * time.Second}, []float64{3, 3, 3}},
+		{"Fractional", Backoff{Initial: 2 * time.Second, Multiplier: 1.5}, []float64{2, 3, 4.5}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []time.Duration
+			for range tt.want {
+				got = append(got, tt.b.Next())
+			}
+			want := make([]time.Duration, len(tt.want))
+			for i, d := range tt.want {
+				want[i] = time.Duration(d * float64(time.Second))
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("delays = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestBackoffJitter(t *testing.T) {
+	b := Backoff{Initial: time.Second, Jitter: 0.25}
+	seen := make(map[time.Duration]bool)
+	for i := 0; i < 1000; i++ {
+		d := b.Next()
+		if d < 750*time.Millisecond || d > 1250*time.Millisecond {
+			t.Fatalf("delay %v outside [750ms, 1.25s]", d)
+		}
+		seen[d] = true
+	}
+	if len(seen) < 2 {
+		t.Error("jitter did not randomize the delays")
+	}
+}
+
+func TestBackoffSleepCanceled(t *testing.T) {
+	c, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	b := Backoff{Initial: time.Hour}
+	start := time.Now()
+	if err := b.Sleep(c); err != context.Canceled {
+		t.Errorf("Sleep = %v, want context.Canceled", err)
+	}
+	if d := time.Since(start); d > time.Minute {
+		t.Errorf("Sleep returned after %v", d)
+	}
+}
+
+func TestOperationPollingBacksOff(t *testing.T) {
+	var mu sync.Mutex
+	var polls []time.Time
+	newAdminTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if !strings.Contains(r.URL.Path, "/operations/") {
+			fmt.Fprint(w, `{"name": "apps/test-project/operations/op1"}`)
+			return
+		}
+		polls = append(polls, time.Now())
+		fmt.Fprintf(w, `{"name": "apps/test-project/operations/op1", "done": %t}`, len(polls) == 4)
+	})
+	c := WithBackoff(context.Background(), Backoff{Initial: 10 * time.Millisecond, Multiplier: 2})
+	if err := Start(c, "my-module", "v1"); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if len(polls) != 4 {
+		t.Fatalf("got %d polls, want 4", len(polls))
+	}
+	for i, want := range []time.Duration{20, 40, 80} {
+		if gap := polls[i+1].Sub(polls[i]); gap < want*time.Millisecond {
+			t.Errorf("gap before poll %d = %v, want at least %v", i+2, gap, want*time.Millisecond)
+		}
+	}
+}
@@ -0,0 +1,396 @@
+// Copyright 2026 Google LLC. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package module
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"google.golang.org/appengine"
+	"google.golang.org/appengine/internal"
+	pb "google.golang.org/appengine/internal/modules"
+)
+
+// batchConcurrency is the maximum number of concurrent Admin API requests
+// issued by the batch helpers.
+const batchConcurrency = 8
+
+// VersionError records the failure of an operation on a single version.
+type VersionError struct {
+	Module, Version string
+	Err             error
+}
+
+func (e *VersionError) Error() string {
+	return fmt.Sprintf("module: version %s of module %s: %v", e.Version, e.Module, e.Err)
+}
+
+func (e *VersionError) Unwrap() error { return e.Err }
+
+// batchError returns the errors of a batch as an appengine.MultiError indexed
+// like errs, with wrap(i, err) for the items that failed and nil for those
+// that succeeded, or nil if every item succeeded. If c asks for raw errors,
+// the errors are not wrapped.
+func batchError(c context.Context, errs []error, wrap func(i int, err error) error) error {
+	var me appengine.MultiError
+	for i, err := range errs {
+		if err == nil {
+			continue
+		}
+		if me == nil {
+			me = make(appengine.MultiError, len(errs))
+		}
+		if rawErrors(c) {
+			me[i] = err
+			continue
+		}
+		me[i] = wrap(i, err)
+	}
+	if me == nil {
+		return nil
+	}
+	return me
+}
+
+// IsPartialFailure reports whether err is the error of a batch helper, such
+// as SetNumInstancesBulk or AllVersions, in which individual items failed,
+// and returns the indexes of those items. The error is then an
+// appengine.MultiError indexed like the items of the batch, with nil entries
+// for the items that succeeded. It reports false for errors that prevented
+// the batch as a whole, such as a failure to list the versions of a module.
+func IsPartialFailure(err error) (failedIdx []int, ok bool) {
+	var me appengine.MultiError
+	if !errors.As(err, &me) {
+		return nil, false
+	}
+	for i, err := range me {
+		if err != nil {
+			failedIdx = append(failedIdx, i)
+		}
+	}
+	return failedIdx, len(failedIdx) > 0
+}
+
+// forEach calls f(i) for every i in [0, n), running at most limit calls
+// concurrently, and returns the errors of the calls indexed like their
+// arguments. Once c is done no further calls are started, and the remaining
+// entries are set to c.Err().
+func forEach(c context.Context, n, limit int, f func(i int) error) []error {
+	errs := make([]error, n)
+	sem := make(chan struct{}, limit)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		if c.Err() == nil {
+			select {
+			case sem <- struct{}{}:
+			case <-c.Done():
+			}
+		}
+		if err := c.Err(); err != nil {
+			for ; i < n; i++ {
+				errs[i] = err
+			}
+			break
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			errs[i] = f(i)
+		}(i)
+	}
+	wg.Wait()
+	return errs
+}
+
+// BatchOption configures a batch helper such as AllVersions.
+type BatchOption func(*batchOptions)
+
+type batchOptions struct {
+	concurrency int
+}
+
+// Concurrency sets the maximum number of Admin API requests a batch helper
+// issues concurrently. The default is 8.
+func Concurrency(n int) BatchOption {
+	return func(o *batchOptions) {
+		if n > 0 {
+			o.concurrency = n
+		}
+	}
+}
+
+func newBatchOptions(opts []BatchOption) batchOptions {
+	o := batchOptions{concurrency: batchConcurrency}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// StopAllVersionsExcept stops every version of the specified module other than
+// keep. Versions that are already stopped are skipped. If module is the empty
+// string, it means the default module.
+//
+// Failures do not prevent the remaining versions from being processed; they are
+// reported together as an appengine.MultiError with a *VersionError for each
+// version that failed. See IsPartialFailure.
+func StopAllVersionsExcept(c context.Context, module, keep string) (err error) {
+	c, done := startCall(c, "StopAllVersionsExcept", module, "")
+	defer done(&err)
+	if err := validateNames(module, keep); err != nil {
+		return err
+	}
+	return setAllServingStatus(c, module, "STOPPED", func(version string) bool { return version == keep })
+}
+
+// StartAllVersions starts every version of the specified module. Versions that
+// are already serving are skipped. If module is the empty string, it means the
+// default module.
+//
+// Failures do not prevent the remaining versions from being processed; they are
+// reported together as an appengine.MultiError with a *VersionError for each
+// version that failed. See IsPartialFailure.
+func StartAllVersions(c context.Context, module string) (err error) {
+	c, done := startCall(c, "StartAllVersions", module, "")
+	defer done(&err)
+	if err := validateNames(module, ""); err != nil {
+		return err
+	}
+	return setAllServingStatus(c, module, "SERVING", func(string) bool { return false })
+}
+
+func setAllServingStatus(c context.Context, module, status string, skip func(version string) bool) error {
+	if module == "" {
+		module = getModuleorDefault(c)
+	}
+	var versions []string
+	if useAdminAPI(c) {
+		projectID := getProjectID(c)
+		b, err := newAdminBackend(c, "list_versions")
+		if err != nil {
+			return err
+		}
+		all, err := listVersions(c, b, projectID, module, []string{"id", "servingStatus"})
+		if err != nil {
+			return err
+		}
+		for _, v := range all {
+			if v.ServingStatus != status {
+				versions = append(versions, v.Id)
+			}
+		}
+	} else {
+		// The legacy API does not report serving status; versions already in
+		// the target state are recognized by the error they produce below.
+		var err error
+		if versions, err = VersionsLegacy(c, module); err != nil {
+			return err
+		}
+	}
+	var targets []string
+	for _, v := range versions {
+		if !skip(v) {
+			targets = append(targets, v)
+		}
+	}
+
+	errs := forEach(c, len(targets), batchConcurrency, func(i int) error {
+		if status == "SERVING" {
+			return Start(c, module, targets[i])
+		}
+		return Stop(c, module, targets[i])
+	})
+	for i, err := range errs {
+		if isUnexpectedState(err) {
+			errs[i] = nil
+		}
+	}
+	return batchError(c, errs, func(i int, err error) error {
+		return &VersionError{Module: module, Version: targets[i], Err: err}
+	})
+}
+
+// InstanceTarget is the desired number of instances of a manual scaling
+// version, for use with SetNumInstancesBulk. Empty Module and Version fields
+// mean the defaults, as for SetNumInstances.
+type InstanceTarget struct {
+	Module, Version string
+	Instances       int
+}
+
+// SetNumInstancesBulk sets the number of instances of several versions at
+// once. The updates are issued concurrently, see Concurrency, and on the Admin
+// API SetNumInstancesBulk waits for all of them to take effect. If targets
+// contains several entries for the same module and version, only the last one
+// is applied.
+//
+// Failures do not prevent the remaining targets from being updated; they are
+// reported together as an appengine.MultiError indexed like targets, with a
+// *VersionError for each target that failed and nil for the others.
+func SetNumInstancesBulk(c context.Context, targets []InstanceTarget, opts ...BatchOption) (err error) {
+	c, done := startCall(c, "SetNumInstancesBulk", "", "")
+	defer done(&err)
+	o := newBatchOptions(opts)
+
+	type key struct{ module, version string }
+	index := make(map[key]int, len(targets))
+	var unique []InstanceTarget
+	for _, t := range targets {
+		k := key{t.Module, t.Version}
+		if i, ok := index[k]; ok {
+			unique[i] = t
+			continue
+		}
+		index[k] = len(unique)
+		unique = append(unique, t)
+	}
+
+	errs := forEach(c, len(unique), o.concurrency, func(i int) error {
+		t := unique[i]
+		if err := validateNames(t.Module, t.Version); err != nil {
+			return err
+		}
+		return setNumInstances(c, t.Module, t.Version, t.Instances, true)
+	})
+	// Entries superseded by a later one for the same version share its
+	// result.
+	results := make([]error, len(targets))
+	for i, t := range targets {
+		results[i] = errs[index[key{t.Module, t.Version}]]
+	}
+	return batchError(c, results, func(i int, err error) error {
+		return &VersionError{Module: targets[i].Module, Version: targets[i].Version, Err: err}
+	})
+}
+
+// StopStaleVersions stops the serving versions of the specified module that
+// were created more than olderThan ago and receive no traffic, and returns the
+// names of the versions it stopped. Only manual and basic scaling versions are
+// considered, since they are the ones that keep instances running while idle.
+// The default version of the module is never stopped. If module is the empty
+// string, it means the default module.
+//
+// Failures do not prevent the remaining versions from being stopped; they are
+// reported together as an appengine.MultiError with a *VersionError for each
+// version that failed, along with the versions that were stopped.
+// StopStaleVersions returns ErrNotSupported on the legacy backend.
+func StopStaleVersions(c context.Context, module string, olderThan time.Duration) (_ []string, err error) {
+	c, done := startCall(c, "StopStaleVersions", module, "")
+	defer done(&err)
+	if err := validateNames(module, ""); err != nil {
+		return nil, err
+	}
+	if !useAdminAPI(c) {
+		return nil, ErrNotSupported
+	}
+	if module == "" {
+		module = getModuleorDefault(c)
+	}
+	allocations, err := trafficAllocations(c, module)
+	if err != nil {
+		return nil, err
+	}
+	// The default version is looked up separately: a concurrent traffic
+	// change may have left it without an allocation in the split read above.
+	defaultVersion, err := DefaultVersion(c, module)
+	if err != nil {
+		return nil, err
+	}
+	projectID := getProjectID(c)
+	b, err := newAdminBackend(c, "stop_stale_versions")
+	if err != nil {
+		return nil, err
+	}
+	versions, err := listVersions(c, b, projectID, module,
+		[]string{"id", "createTime", "servingStatus", "automaticScaling", "basicScaling", "manualScaling"})
+	if err != nil {
+		return nil, err
+	}
+	var targets []string
+	now := time.Now()
+	for _, v := range versions {
+		if v.Id == defaultVersion || allocations[v.Id] > 0 || v.ServingStatus != "SERVING" {
+			continue
+		}
+		if st := scalingType(v); st != ScalingManual && st != ScalingBasic {
+			continue
+		}
+		created, err := time.Parse(time.RFC3339Nano, v.CreateTime)
+		if err != nil || now.Sub(created) <= olderThan {
+			continue
+		}
+		targets = append(targets, v.Id)
+	}
+
+	errs := forEach(c, len(targets), batchConcurrency, func(i int) error {
+		return Stop(c, module, targets[i])
+	})
+	var stopped []string
+	for i, err := range errs {
+		if err == nil {
+			stopped = append(stopped, targets[i])
+		}
+	}
+	return stopped, batchError(c, errs, func(i int, err error) error {
+		return &VersionError{Module: module, Version: targets[i], Err: err}
+	})
+}
+
+// ModuleError records the failure of an operation on a single module.
+type ModuleError struct {
+	Module string
+	Err    error
+}
+
+func (e *ModuleError) Error() string {
+	return fmt.Sprintf("module: module %s: %v", e.Module, e.Err)
+}
+
+func (e *ModuleError) Unwrap() error { return e.Err }
+
+// AllVersions returns the names of the versions of every module of this
+// application, keyed by module name. The versions of different modules are
+// fetched concurrently; see Concurrency.
+//
+// If the versions of some modules cannot be fetched, AllVersions returns the
+// modules that succeeded along with an appengine.MultiError indexed like the
+// modules returned by List, with a *ModuleError for each module that failed
+// and nil for the others.
+func AllVersions(c context.Context, opts ...BatchOption) (_ map[string][]string, err error) {
+	c, done := startCall(c, "AllVersions", "", "")
+	defer done(&err)
+	o := newBatchOptions(opts)
+	modules, err := List(c)
+	if err != nil {
+		return nil, err
+	}
+	versions := make([][]string, len(modules))
+	errs := forEach(c, len(modules), o.concurrency, func(i int) error {
+		var err error
+		versions[i], err = Versions(c, modules[i])
+		return err
+	})
+	all := make(map[string][]string, len(modules))
+	for i, m := range modules {
+		if errs[i] == nil {
+			all[m] = versions[i]
+		}
+	}
+	return all, batchError(c, errs, func(i int, err error) error {
+		return &ModuleError{Module: modules[i], Err: err}
+	})
+}
+
+// isUnexpectedState reports whether err is the legacy API's error for a
+// version that is already in the requested serving state.
+func isUnexpectedState(err error) bool {
+	return errors.Is(err, &internal.APIError{Service: "modules", Code: int32(pb.ModulesServiceError_UNEXPECTED_STATE)})
+}
@@ -0,0 +1,409 @@
+// Copyright 2026 Google LLC. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package module
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	admin "google.golang.org/api/appengine/v1"
+	"google.golang.org/appengine"
+	"google.golang.org/appengine/internal"
+	"google.golang.org/appengine/internal/aetesting"
+	pb "google.golang.org/appengine/internal/modules"
+)
+
+// servingStatusServer serves a module with the given versions and records the
+// serving status PATCHes it receives. PATCHes of versions in fail are rejected.
+func servingStatusServer(t *testing.T, versions []*admin.Version, fail string) (patched func() map[string]string) {
+	var mu sync.Mutex
+	got := make(map[string]string)
+	newAdminTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		const prefix = "/v1/apps/test-project/services/my-module/versions"
+		switch {
+		case r.Method == "GET" && r.URL.Path == prefix:
+			json.NewEncoder(w).Encode(&admin.ListVersionsResponse{Versions: versions})
+		case r.Method == "PATCH" && strings.HasPrefix(r.URL.Path, prefix+"/"):
+			id := strings.TrimPrefix(r.URL.Path, prefix+"/")
+			var v admin.Version
+			json.NewDecoder(r.Body).Decode(&v)
+			mu.Lock()
+			got[id] = v.ServingStatus
+			mu.Unlock()
+			if id == fail {
+				http.Error(w, `{"error":{"code":403,"message":"denied"}}`, http.StatusForbidden)
+				return
+			}
+			json.NewEncoder(w).Encode(&admin.Operation{Name: "apps/test-project/operations/" + id, Done: true})
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			http.Error(w, "unexpected", http.StatusBadRequest)
+		}
+	})
+	return func() map[string]string {
+		mu.Lock()
+		defer mu.Unlock()
+		return got
+	}
+}
+
+func TestStopAllVersionsExcept_AdminAPI(t *testing.T) {
+	patched := servingStatusServer(t, []*admin.Version{
+		{Id: "v1", ServingStatus: "SERVING"},
+		{Id: "v2", ServingStatus: "SERVING"},
+		{Id: "v3", ServingStatus: "STOPPED"},
+		{Id: "v4", ServingStatus: "SERVING"},
+		{Id: "v5", ServingStatus: "SERVING"},
+	}, "v2")
+
+	err := StopAllVersionsExcept(context.Background(), "my-module", "v1")
+	failed, ok := IsPartialFailure(err)
+	if !ok || len(failed) != 1 {
+		t.Fatalf("StopAllVersionsExcept = %v, want a partial failure of one version", err)
+	}
+	var ve *VersionError
+	if e := err.(appengine.MultiError)[failed[0]]; !errors.As(e, &ve) || ve.Version != "v2" || ve.Module != "my-module" {
+		t.Errorf("error = %v, want a VersionError for v2", e)
+	}
+
+	got := patched()
+	var ids []string
+	for id, status := range got {
+		if status != "STOPPED" {
+			t.Errorf("version %s patched to %q, want STOPPED", id, status)
+		}
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	if want := []string{"v2", "v4", "v5"}; strings.Join(ids, ",") != strings.Join(want, ",") {
+		t.Errorf("patched versions = %v, want %v", ids, want)
+	}
+}
+
+func TestStartAllVersions_AdminAPI(t *testing.T) {
+	patched := servingStatusServer(t, []*admin.Version{
+		{Id: "v1", ServingStatus: "SERVING"},
+		{Id: "v2", ServingStatus: "STOPPED"},
+		{Id: "v3", ServingStatus: "STOPPED"},
+	}, "")
+
+	if err := StartAllVersions(context.Background(), "my-module"); err != nil {
+		t.Fatalf("StartAllVersions: %v", err)
+	}
+	got := patched()
+	if len(got) != 2 || got["v2"] != "SERVING" || got["v3"] != "SERVING" {
+		t.Errorf("patched = %v, want v2 and v3 started", got)
+	}
+}
+
+func TestStopAllVersionsExcept_Legacy(t *testing.T) {
+	var mu sync.Mutex
+	var stopped []string
+	c := aetesting.FakeSingleContext(t, "modules", "GetVersions", func(req *pb.GetVersionsRequest, res *pb.GetVersionsResponse) error {
+		res.Version = []string{"v1", "v2", "v3"}
+		return nil
+	})
+	c = internal.WithCallOverride(c, func(ctx context.Context, service, method string, in, out proto.Message) error {
+		if method != "StopModule" {
+			return internal.Call(ctx, service, method, in, out)
+		}
+		v := in.(*pb.StopModuleRequest).GetVersion()
+		mu.Lock()
+		stopped = append(stopped, v)
+		mu.Unlock()
+		if v == "v3" {
+			// Already stopped.
+			return &internal.APIError{Service: "modules", Code: int32(pb.ModulesServiceError_UNEXPECTED_STATE)}
+		}
+		return nil
+	})
+	if err := StopAllVersionsExcept(c, "my-module", "v1"); err != nil {
+		t.Fatalf("StopAllVersionsExcept: %v", err)
+	}
+	sort.Strings(stopped)
+	if strings.Join(stopped, ",") != "v2,v3" {
+		t.Errorf("stopped = %v, want [v2 v3]", stopped)
+	}
+}
+
+func TestAllVersions_AdminAPI(t *testing.T) {
+	var mu sync.Mutex
+	inFlight, maxInFlight := 0, 0
+	newAdminTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		const prefix = "/v1/apps/test-project/services"
+		if r.URL.Path == prefix {
+			json.NewEncoder(w).Encode(&admin.ListServicesResponse{Services: []*admin.Service{
+				{Id: "default"}, {Id: "api"}, {Id: "gone"}, {Id: "worker"},
+			}})
+			return
+		}
+		mu.Lock()
+		inFlight++
+		if inFlight > maxInFlight {
+			maxInFlight = inFlight
+		}
+		mu.Unlock()
+		time.Sleep(50 * time.Millisecond)
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+
+		module := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, prefix+"/"), "/versions")
+		if module == "gone" {
+			http.Error(w, `{"error":{"code":404,"message":"not found"}}`, http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(&admin.ListVersionsResponse{Versions: []*admin.Version{{Id: module + "-v1"}}})
+	})
+
+	got, err := AllVersions(context.Background())
+	failed, ok := IsPartialFailure(err)
+	if !ok || len(failed) != 1 {
+		t.Fatalf("AllVersions error = %v, want a partial failure of one module", err)
+	}
+	var modErr *ModuleError
+	if e := err.(appengine.MultiError)[failed[0]]; !errors.As(e, &modErr) || modErr.Module != "gone" {
+		t.Errorf("error = %v, want ModuleError for gone", e)
+	}
+	want := map[string][]string{
+		"default": {"default-v1"},
+		"api":     {"api-v1"},
+		"worker":  {"worker-v1"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("AllVersions = %v, want %v", got, want)
+	}
+	if maxInFlight < 2 {
+		t.Errorf("max concurrent requests = %d, want requests to overlap", maxInFlight)
+	}
+}
+
+func TestAllVersions_Legacy(t *testing.T) {
+	t.Setenv("MODULES_USE_ADMIN_API", "false")
+	c := aetesting.FakeMultiContext(t,
+		aetesting.Expectation{Service: "modules", Method: "GetModules", F: func(req *pb.GetModulesRequest, res *pb.GetModulesResponse) error {
+			res.Module = []string{"default", "worker"}
+			return nil
+		}},
+		aetesting.Expectation{Service: "modules", Method: "GetVersions", Times: 2, F: func(req *pb.GetVersionsRequest, res *pb.GetVersionsResponse) error {
+			res.Version = []string{req.GetModule() + "-v1"}
+			return nil
+		}},
+	)
+	got, err := AllVersions(c)
+	if err != nil {
+		t.Fatalf("AllVersions: %v", err)
+	}
+	want := map[string][]string{"default": {"default-v1"}, "worker": {"worker-v1"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("AllVersions = %v, want %v", got, want)
+	}
+}
+
+func TestAllVersions_Canceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	newAdminTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/apps/test-project/services" {
+			var services []*admin.Service
+			for i := 0; i < 20; i++ {
+				services = append(services, &admin.Service{Id: fmt.Sprintf("m%d", i)})
+			}
+			json.NewEncoder(w).Encode(&admin.ListServicesResponse{Services: services})
+			return
+		}
+		cancel()
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	})
+
+	start := time.Now()
+	_, err := AllVersions(ctx, Concurrency(2))
+	if d := time.Since(start); d > 2*time.Second {
+		t.Errorf("AllVersions took %v after cancellation", d)
+	}
+	me, ok := err.(appengine.MultiError)
+	if !ok || len(me) != 20 {
+		t.Fatalf("AllVersions error = %v, want MultiError with 20 entries", err)
+	}
+	if !errors.Is(me[len(me)-1], context.Canceled) {
+		t.Errorf("last error = %v, want context.Canceled", me[len(me)-1])
+	}
+}
+
+func TestSetNumInstancesBulk(t *testing.T) {
+	var mu sync.Mutex
+	got := make(map[string][]int64)
+	newAdminTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "PATCH" || r.URL.Query().Get("updateMask") != "manualScaling.instances" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+		}
+		path := strings.TrimPrefix(r.URL.Path, "/v1/apps/test-project/services/")
+		var v admin.Version
+		json.NewDecoder(r.Body).Decode(&v)
+		mu.Lock()
+		got[path] = append(got[path], v.ManualScaling.Instances)
+		mu.Unlock()
+		if path == "api/versions/broken" {
+			http.Error(w, `{"error":{"code":400,"message":"not manual scaling"}}`, http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(&admin.Operation{Name: "apps/test-project/operations/op", Done: true})
+	})
+
+	err := SetNumInstancesBulk(context.Background(), []InstanceTarget{
+		{Module: "api", Version: "v1", Instances: 3},
+		{Module: "api", Version: "broken", Instances: 2},
+		{Module: "worker", Version: "v1", Instances: 5},
+		{Module: "api", Version: "v1", Instances: 3},
+		{Module: "batch", Version: "v2", Instances: 1},
+	})
+	failed, ok := IsPartialFailure(err)
+	if !ok || !reflect.DeepEqual(failed, []int{1}) {
+		t.Fatalf("SetNumInstancesBulk = %v, want a partial failure of target 1", err)
+	}
+	me := err.(appengine.MultiError)
+	if len(me) != 5 {
+		t.Errorf("MultiError has %d entries, want one per target", len(me))
+	}
+	var ve *VersionError
+	if !errors.As(me[1], &ve) || ve.Module != "api" || ve.Version != "broken" {
+		t.Errorf("error = %v, want a VersionError for api/broken", me[1])
+	}
+	want := map[string][]int64{
+		"api/versions/v1":     {3},
+		"api/versions/broken": {2},
+		"worker/versions/v1":  {5},
+		"batch/versions/v2":   {1},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("PATCHes = %v, want %v", got, want)
+	}
+}
+
+func TestSetNumInstancesBulk_Legacy(t *testing.T) {
+	t.Setenv("MODULES_USE_ADMIN_API", "false")
+	c, rec := aetesting.Record(aetesting.FakeSingleContext(t, "modules", "SetNumInstances", func(req *pb.SetNumInstancesRequest, res *pb.SetNumInstancesResponse) error {
+		if req.GetVersion() == "bad" {
+			return &internal.APIError{Service: "modules", Code: int32(pb.ModulesServiceError_TRANSIENT_ERROR)}
+		}
+		return nil
+	}))
+	targets := []InstanceTarget{
+		{Module: "default", Version: "v1", Instances: 1},
+		{Module: "worker", Version: "bad", Instances: 2},
+		{Module: "worker", Version: "v2", Instances: 3},
+		{Module: "default", Version: "v1", Instances: 4},
+	}
+	err := SetNumInstancesBulk(c, targets)
+	failed, ok := IsPartialFailure(err)
+	if !ok || !reflect.DeepEqual(failed, []int{1}) {
+		t.Errorf("SetNumInstancesBulk error = %v, want a partial failure of target 1", err)
+	}
+	// One attempt per distinct version, with no retry of the failed one.
+	if n := rec.Count("modules", "SetNumInstances"); n != 3 {
+		t.Errorf("SetNumInstances called %d times, want 3", n)
+	}
+	var reqs []*pb.SetNumInstancesRequest
+	rec.Requests("modules", "SetNumInstances", &reqs)
+	got := make(map[string]int64)
+	for _, r := range reqs {
+		got[r.GetModule()+"/"+r.GetVersion()] = r.GetInstances()
+	}
+	want := map[string]int64{"default/v1": 4, "worker/bad": 2, "worker/v2": 3}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("instances requested = %v, want %v", got, want)
+	}
+}
+
+func TestStopStaleVersions(t *testing.T) {
+	const prefix = "/v1/apps/test-project/services/my-module"
+	old := time.Now().Add(-72 * time.Hour).Format(time.RFC3339)
+	recent := time.Now().Add(-time.Hour).Format(time.RFC3339)
+	manual := &admin.ManualScaling{Instances: 1}
+	splits := []map[string]float64{
+		{"busy": 1},
+		// The default version changed between the two reads of the split.
+		{"new-default": 1},
+	}
+	var mu sync.Mutex
+	var stopped []string
+	ctx := aetesting.FakeAdminContext(t, func(req *aetesting.AdminRequest) (int, interface{}) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case req.Method == "GET" && req.Path == prefix:
+			split := splits[0]
+			if len(splits) > 1 {
+				splits = splits[1:]
+			}
+			return http.StatusOK, &admin.Service{Split: &admin.TrafficSplit{Allocations: split}}
+		case req.Method == "GET" && req.Path == prefix+"/versions":
+			return http.StatusOK, &admin.ListVersionsResponse{Versions: []*admin.Version{
+				{Id: "busy", CreateTime: old, ServingStatus: "SERVING", ManualScaling: manual},
+				{Id: "new-default", CreateTime: old, ServingStatus: "SERVING", ManualScaling: manual},
+				{Id: "stale-manual", CreateTime: old, ServingStatus: "SERVING", ManualScaling: manual},
+				{Id: "stale-basic", CreateTime: old, ServingStatus: "SERVING", BasicScaling: &admin.BasicScaling{MaxInstances: 2}},
+				{Id: "stale-automatic", CreateTime: old, ServingStatus: "SERVING", AutomaticScaling: &admin.AutomaticScaling{}},
+				{Id: "recent", CreateTime: recent, ServingStatus: "SERVING", ManualScaling: manual},
+				{Id: "stopped", CreateTime: old, ServingStatus: "STOPPED", ManualScaling: manual},
+			}}
+		case req.Method == "PATCH":
+			var v admin.Version
+			req.Decode(&v)
+			if v.ServingStatus != "STOPPED" {
+				t.Errorf("PATCH %s servingStatus = %q, want STOPPED", req.Path, v.ServingStatus)
+			}
+			stopped = append(stopped, strings.TrimPrefix(req.Path, prefix+"/versions/"))
+			return http.StatusOK, &admin.Operation{Name: "apps/test-project/operations/op", Done: true}
+		}
+		t.Errorf("unexpected request %s %s", req.Method, req.Path)
+		return http.StatusBadRequest, nil
+	})
+
+	got, err := StopStaleVersions(ctx, "my-module", 24*time.Hour)
+	if err != nil {
+		t.Fatalf("StopStaleVersions: %v", err)
+	}
+	sort.Strings(got)
+	sort.Strings(stopped)
+	want := []string{"stale-basic", "stale-manual"}
+	if !reflect.DeepEqual(got, want) || !reflect.DeepEqual(stopped, want) {
+		t.Errorf("StopStaleVersions = %v, stopped %v; want %v", got, stopped, want)
+	}
+}
+
+func TestBatchError(t *testing.T) {
+	wrap := func(i int, err error) error { return fmt.Errorf("item %d: %w", i, err) }
+	errFailed := errors.New("failed")
+	err := batchError(context.Background(), []error{nil, errFailed, nil}, wrap)
+	failed, ok := IsPartialFailure(err)
+	if !ok || !reflect.DeepEqual(failed, []int{1}) {
+		t.Fatalf("IsPartialFailure(%v) = %v, %t; want [1], true", err, failed, ok)
+	}
+	me := err.(appengine.MultiError)
+	if len(me) != 3 || me[0] != nil || me[2] != nil || !errors.Is(me[1], errFailed) {
+		t.Errorf("MultiError = %#v, want the failure at index 1 only", me)
+	}
+
+	if err := batchError(context.Background(), []error{nil, nil, nil}, wrap); err != nil {
+		t.Errorf("batchError of a successful batch = %#v, want nil", err)
+	}
+	if _, ok := IsPartialFailure(errFailed); ok {
+		t.Error("IsPartialFailure reported a plain error as a partial failure")
+	}
+}
@@ -0,0 +1,116 @@
+// Copyright 2026 Google LLC. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package module
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// timeNow is time.Now, replaceable by tests.
+var timeNow = time.Now
+
+type cacheKey struct {
+	kind    string // "list", "versions" or "defaultVersion"
+	project string
+	module  string
+}
+
+type cacheEntry struct {
+	value   interface{}
+	expires time.Time
+}
+
+// cache memoizes the results of List, Versions and DefaultVersion. It is
+// disabled while ttl is zero.
+var cache struct {
+	sync.Mutex
+	ttl     time.Duration
+	entries map[cacheKey]cacheEntry
+}
+
+// EnableCache makes List, Versions and DefaultVersion remember their results
+// for ttl, so that repeated calls within that period do not reach the backend.
+// Results are cached per project and module. A ttl of zero or less disables
+// the cache and discards its contents. The cache is disabled by default.
+//
+// SetTraffic and SetDefaultVersion invalidate the cached default version of
+// the module they change. Changes made by other processes, such as a deploy,
+// are only noticed once the entries expire or InvalidateCache is called.
+func EnableCache(ttl time.Duration) {
+	cache.Lock()
+	defer cache.Unlock()
+	if ttl <= 0 {
+		ttl = 0
+	}
+	cache.ttl = ttl
+	cache.entries = nil
+}
+
+// InvalidateCache discards every result remembered by the cache, as well as
+// the application details remembered by AppInfo.
+func InvalidateCache() {
+	cache.Lock()
+	cache.entries = nil
+	cache.Unlock()
+	invalidateAppInfo()
+}
+
+// newCacheKey returns the cache key for the given kind of result about module
+// in the project that c refers to.
+func newCacheKey(c context.Context, kind, module string) cacheKey {
+	if kind != "list" && module == "" {
+		module = getModuleorDefault(c)
+	}
+	return cacheKey{kind: kind, project: getProjectID(c), module: module}
+}
+
+// cached returns the unexpired cached value for key, or calls f and caches its
+// result if it succeeds. The cache lock is not held while f runs.
+func cached(key cacheKey, f func() (interface{}, error)) (interface{}, error) {
+	cache.Lock()
+	ttl := cache.ttl
+	if ttl > 0 {
+		if e, ok := cache.entries[key]; ok && timeNow().Before(e.expires) {
+			cache.Unlock()
+			return e.value, nil
+		}
+	}
+	cache.Unlock()
+
+	v, err := f()
+	if err != nil || ttl <= 0 {
+		return v, err
+	}
+	cache.Lock()
+	if cache.ttl > 0 {
+		if cache.entries == nil {
+			cache.entries = make(map[cacheKey]cacheEntry)
+		}
+		cache.entries[key] = cacheEntry{value: v, expires: timeNow().Add(cache.ttl)}
+	}
+	cache.Unlock()
+	return v, nil
+}
+
+// cachedStrings is like cached for results that are string slices. It returns
+// copies so that callers cannot modify the cached value.
+func cachedStrings(key cacheKey, f func() ([]string, error)) ([]string, error) {
+	v, err := cached(key, func() (interface{}, error) { return f() })
+	s, _ := v.([]string)
+	if s == nil {
+		return nil, err
+	}
+	return append([]string(nil), s...), err
+}
+
+// invalidateCache discards the cached results of the given kind about module.
+func invalidateCache(c context.Context, kind, module string) {
+	key := newCacheKey(c, kind, module)
+	cache.Lock()
+	delete(cache.entries, key)
+	cache.Unlock()
+}
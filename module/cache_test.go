@@ -0,0 +1,119 @@
+// Copyright 2026 Google LLC. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package module
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	admin "google.golang.org/api/appengine/v1"
+)
+
+// enableTestCache enables the cache with a fake clock for the duration of the
+// test and returns a function that advances the clock.
+func enableTestCache(t *testing.T, ttl time.Duration) (advance func(time.Duration)) {
+	now := time.Unix(1700000000, 0)
+	oldNow := timeNow
+	timeNow = func() time.Time { return now }
+	EnableCache(ttl)
+	t.Cleanup(func() {
+		EnableCache(0)
+		timeNow = oldNow
+	})
+	return func(d time.Duration) { now = now.Add(d) }
+}
+
+func TestCache_DefaultVersion(t *testing.T) {
+	var gets int32
+	newAdminTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			atomic.AddInt32(&gets, 1)
+			json.NewEncoder(w).Encode(&admin.Service{Split: &admin.TrafficSplit{Allocations: map[string]float64{"v1": 1}}})
+		case "PATCH":
+			json.NewEncoder(w).Encode(&admin.Operation{Name: "apps/test-project/operations/op", Done: true})
+		}
+	})
+	advance := enableTestCache(t, time.Minute)
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		if v, err := DefaultVersion(ctx, "my-module"); err != nil || v != "v1" {
+			t.Fatalf("DefaultVersion = %q, %v; want v1", v, err)
+		}
+	}
+	if n := atomic.LoadInt32(&gets); n != 1 {
+		t.Errorf("got %d GETs within the TTL, want 1", n)
+	}
+
+	advance(2 * time.Minute)
+	DefaultVersion(ctx, "my-module")
+	if n := atomic.LoadInt32(&gets); n != 2 {
+		t.Errorf("got %d GETs after expiry, want 2", n)
+	}
+
+	if err := SetDefaultVersion(ctx, "my-module", "v1"); err != nil {
+		t.Fatalf("SetDefaultVersion: %v", err)
+	}
+	DefaultVersion(ctx, "my-module")
+	if n := atomic.LoadInt32(&gets); n != 3 {
+		t.Errorf("got %d GETs after SetDefaultVersion, want 3", n)
+	}
+
+	// Other modules are cached separately.
+	DefaultVersion(ctx, "other-module")
+	if n := atomic.LoadInt32(&gets); n != 4 {
+		t.Errorf("got %d GETs for a second module, want 4", n)
+	}
+}
+
+func TestCache_ListAndVersions(t *testing.T) {
+	var requests int32
+	newAdminTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		if r.URL.Path == "/v1/apps/test-project/services" {
+			json.NewEncoder(w).Encode(&admin.ListServicesResponse{Services: []*admin.Service{{Id: "default"}}})
+			return
+		}
+		json.NewEncoder(w).Encode(&admin.ListVersionsResponse{Versions: []*admin.Version{{Id: "v1"}}})
+	})
+	enableTestCache(t, time.Minute)
+	ctx := context.Background()
+
+	l, _ := List(ctx)
+	l[0] = "mutated"
+	if l, err := List(ctx); err != nil || l[0] != "default" {
+		t.Errorf("List = %v, %v; want cached [default]", l, err)
+	}
+	Versions(ctx, "default")
+	Versions(ctx, "default")
+	if n := atomic.LoadInt32(&requests); n != 2 {
+		t.Errorf("got %d requests, want 2", n)
+	}
+
+	InvalidateCache()
+	List(ctx)
+	Versions(ctx, "default")
+	if n := atomic.LoadInt32(&requests); n != 4 {
+		t.Errorf("got %d requests after InvalidateCache, want 4", n)
+	}
+}
+
+func TestCache_DisabledByDefault(t *testing.T) {
+	var gets int32
+	newAdminTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&gets, 1)
+		json.NewEncoder(w).Encode(&admin.ListServicesResponse{Services: []*admin.Service{{Id: "default"}}})
+	})
+	List(context.Background())
+	List(context.Background())
+	if n := atomic.LoadInt32(&gets); n != 2 {
+		t.Errorf("got %d requests, want 2", n)
+	}
+}
@@ -0,0 +1,100 @@
+// Copyright 2026 Google LLC. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package module
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	admin "google.golang.org/api/appengine/v1"
+
+	"google.golang.org/appengine/internal/aetesting"
+)
+
+// fakeAdminAPI serves every Admin API request the package makes with
+// plausible responses. Version "auto" uses automatic scaling, "basic" uses
+// basic scaling and every other version uses manual scaling.
+func fakeAdminAPI(req *aetesting.AdminRequest) (int, interface{}) {
+	done := &admin.Operation{Name: "apps/test-project/operations/op", Done: true}
+	parts := strings.Split(strings.TrimPrefix(req.Path, "/v1/apps/test-project/"), "/")
+	switch {
+	case req.Method == "POST" || req.Method == "PATCH" || parts[0] == "operations":
+		return http.StatusOK, done
+	case len(parts) == 1:
+		return http.StatusOK, &admin.ListServicesResponse{Services: []*admin.Service{{Id: "default"}, {Id: "worker"}}}
+	case len(parts) == 2:
+		return http.StatusOK, &admin.Service{Split: &admin.TrafficSplit{Allocations: map[string]float64{"v1": 1}}}
+	case len(parts) == 3:
+		return http.StatusOK, &admin.ListVersionsResponse{Versions: []*admin.Version{
+			{Id: "v1", ServingStatus: "SERVING"},
+			{Id: "v2", ServingStatus: "STOPPED"},
+		}}
+	}
+	switch parts[3] {
+	case "auto":
+		return http.StatusOK, &admin.Version{AutomaticScaling: &admin.AutomaticScaling{}}
+	case "basic":
+		return http.StatusOK, &admin.Version{BasicScaling: &admin.BasicScaling{MaxInstances: 1}}
+	}
+	return http.StatusOK, &admin.Version{ManualScaling: &admin.ManualScaling{Instances: 2}}
+}
+
+func TestConcurrentUse(t *testing.T) {
+	c := aetesting.FakeAdminContext(t, fakeAdminAPI)
+	t.Setenv("GAE_SERVICE", "default")
+	t.Setenv("GAE_VERSION", "v1")
+	oldInterval := operationPollInterval
+	operationPollInterval = time.Millisecond
+	defer func() { operationPollInterval = oldInterval }()
+	EnableCache(time.Minute)
+	defer EnableCache(0)
+	defer SetCallObserver(nil)
+	defer SetLogger(nil)
+
+	two := 2
+	calls := []func() error{
+		func() error { _, err := List(c); return err },
+		func() error { _, err := Versions(c, "worker"); return err },
+		func() error { _, err := DefaultVersion(c, "worker"); return err },
+		func() error { _, err := NumInstances(c, "worker", "v1"); return err },
+		func() error { return SetNumInstances(c, "worker", "v1", 3) },
+		func() error { return Start(c, "worker", "v1") },
+		func() error { return Stop(c, "worker", "v2") },
+		func() error { return SetTraffic(c, "worker", map[string]float64{"v1": 0.5, "v2": 0.5}) },
+		func() error { return SetDefaultVersion(c, "worker", "v1") },
+		func() error { return RolloutTraffic(c, "worker", "v2", []float64{0.5, 1}, nil) },
+		func() error {
+			return SetAutomaticScaling(c, "worker", "auto", AutomaticScalingSettings{MaxIdleInstances: &two})
+		},
+		func() error { return SetBasicScaling(c, "worker", "basic", 2, time.Minute) },
+		func() error { return SetInstanceClass(c, "worker", "v1", "B2") },
+		func() error { return DebugInstance(c, "worker", "v1", "i1", "") },
+		func() error { return UpdateEnvVariables(c, "worker", "v1", map[string]string{"A": "b"}, nil) },
+		func() error { return StopAllVersionsExcept(c, "worker", "v2") },
+		func() error { return StartAllVersions(c, "worker") },
+		func() error { _, err := AllVersions(c); return err },
+		func() error { _, err := ListIterator(c).all(); return err },
+		func() error { _, err := VersionsIterator(c, "worker").all(); return err },
+		func() error { ActiveBackend(); BackendSelection(); return nil },
+		func() error { SetCallObserver(func(CallInfo) {}); return nil },
+		func() error { SetLogger(&captureLogger{}); return nil },
+		func() error { InvalidateCache(); return nil },
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := calls[i%len(calls)](); err != nil {
+				t.Errorf("call %d: %v", i%len(calls), err)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,152 @@
+// Copyright 2026 Google LLC. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package module
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"google.golang.org/appengine/internal"
+)
+
+// runtimeDetection caches whether the app runs on a second-generation
+// runtime, which does not change during the life of the process.
+var runtimeDetection struct {
+	sync.Mutex
+	done   bool
+	secGen bool
+}
+
+// secondGenRuntime reports whether the app runs on a second-generation App
+// Engine standard runtime (Go 1.12 or later), where the legacy modules service
+// is not available.
+func secondGenRuntime() bool {
+	runtimeDetection.Lock()
+	defer runtimeDetection.Unlock()
+	if !runtimeDetection.done {
+		runtimeDetection.secGen = os.Getenv("GAE_ENV") == "standard" && isSecondGenGoRuntime(os.Getenv("GAE_RUNTIME"))
+		runtimeDetection.done = true
+	}
+	return runtimeDetection.secGen
+}
+
+// isSecondGenGoRuntime reports whether runtime, a GAE_RUNTIME value such as
+// "go111" or "go122", names a Go runtime of version 1.12 or later.
+func isSecondGenGoRuntime(runtime string) bool {
+	if !strings.HasPrefix(runtime, "go1") {
+		return false
+	}
+	minor, err := strconv.Atoi(runtime[len("go1"):])
+	return err == nil && minor >= 12
+}
+
+// envBool is internal.EnvBool, with the errors of this package.
+func envBool(name string) (bool, error) {
+	v, err := internal.EnvBool(name)
+	if err != nil {
+		return false, fmt.Errorf("module: %v", err)
+	}
+	return v, nil
+}
+
+// BackendChoice describes which backend the package uses for calls without a
+// Backend attached to their context, and why.
+type BackendChoice struct {
+	Backend string // "admin" or "legacy"
+	Reason  string // human-readable explanation of the choice
+
+	// Warning is set if the configuration is invalid, for example if
+	// MODULES_USE_ADMIN_API cannot be parsed as a boolean. The invalid setting
+	// is ignored.
+	Warning error
+}
+
+// invalidSettingWarned records which invalid MODULES_USE_ADMIN_API values
+// have been logged.
+var invalidSettingWarned struct {
+	sync.Mutex
+	values map[string]bool
+}
+
+// BackendSelection returns the backend that calls without a Backend attached
+// to their context currently use, with the reason it was chosen. It is meant
+// for debugging.
+//
+// The Admin API is used if MODULES_USE_ADMIN_API is set to a true value such
+// as "true", "1", "yes" or "on", or if it is unset and the app runs on a
+// second-generation runtime. Setting it to a false value forces the legacy
+// backend. An invalid value is ignored; it is reported in the Warning field
+// and logged once.
+func BackendSelection() BackendChoice {
+	if fallbackActive() {
+		return BackendChoice{Backend: "legacy", Reason: "fell back after the Admin API was found unavailable"}
+	}
+	var warning error
+	if os.Getenv("MODULES_USE_ADMIN_API") != "" {
+		v, err := envBool("MODULES_USE_ADMIN_API")
+		if err == nil {
+			if v {
+				return BackendChoice{Backend: "admin", Reason: "MODULES_USE_ADMIN_API is true"}
+			}
+			return BackendChoice{Backend: "legacy", Reason: "MODULES_USE_ADMIN_API is false"}
+		}
+		warning = err
+		warnInvalidSetting(err)
+	}
+	if secondGenRuntime() {
+		return BackendChoice{Backend: "admin", Reason: "second-generation runtime detected", Warning: warning}
+	}
+	return BackendChoice{Backend: "legacy", Reason: "default for first-generation runtimes", Warning: warning}
+}
+
+// warnInvalidSetting logs err the first time it is seen for the current
+// value of MODULES_USE_ADMIN_API.
+func warnInvalidSetting(err error) {
+	value := os.Getenv("MODULES_USE_ADMIN_API")
+	invalidSettingWarned.Lock()
+	warned := invalidSettingWarned.values[value]
+	if !warned {
+		if invalidSettingWarned.values == nil {
+			invalidSettingWarned.values = make(map[string]bool)
+		}
+		invalidSettingWarned.values[value] = true
+	}
+	invalidSettingWarned.Unlock()
+	if warned {
+		return
+	}
+	l := currentLogger()
+	if l == nil {
+		l = log.Default()
+	}
+	l.Printf("%v; ignoring it", err)
+}
+
+// ActiveBackend returns the backend that calls without a Backend attached to
+// their context currently use: "admin" for the App Engine Admin API or
+// "legacy" for the App Engine modules service. It is meant for debugging;
+// BackendSelection also explains the choice.
+func ActiveBackend() string {
+	return BackendSelection().Backend
+}
+
+// ReloadConfig discards the configuration decisions the package has
+// remembered, so that they are made again on the next call: the detection of
+// the runtime, and any fallback from the Admin API to the legacy backend.
+func ReloadConfig() {
+	runtimeDetection.Lock()
+	runtimeDetection.done = false
+	runtimeDetection.Unlock()
+	adminFallback.Lock()
+	adminFallback.active = false
+	adminFallback.Unlock()
+	invalidSettingWarned.Lock()
+	invalidSettingWarned.values = nil
+	invalidSettingWarned.Unlock()
+}
@@ -0,0 +1,82 @@
+// Copyright 2026 Google LLC. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package module
+
+import "testing"
+
+func TestActiveBackend(t *testing.T) {
+	tests := []struct {
+		name               string
+		gaeEnv, gaeRuntime string
+		useAdminAPI        string
+		want               string
+	}{
+		{"NotOnAppEngine", "", "", "", "legacy"},
+		{"FirstGeneration", "standard", "go111", "", "legacy"},
+		{"SecondGeneration", "standard", "go122", "", "admin"},
+		{"SecondGenerationGo112", "standard", "go112", "", "admin"},
+		{"Flexible", "flex", "go122", "", "legacy"},
+		{"ForceAdminOnFirstGeneration", "standard", "go111", "true", "admin"},
+		{"ForceLegacyOnSecondGeneration", "standard", "go122", "false", "legacy"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("GAE_ENV", tt.gaeEnv)
+			t.Setenv("GAE_RUNTIME", tt.gaeRuntime)
+			t.Setenv("MODULES_USE_ADMIN_API", tt.useAdminAPI)
+			ReloadConfig()
+			t.Cleanup(ReloadConfig)
+			if got := ActiveBackend(); got != tt.want {
+				t.Errorf("ActiveBackend() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRuntimeDetectionCached(t *testing.T) {
+	t.Setenv("GAE_ENV", "standard")
+	t.Setenv("GAE_RUNTIME", "go122")
+	t.Setenv("MODULES_USE_ADMIN_API", "")
+	ReloadConfig()
+	t.Cleanup(ReloadConfig)
+	if got := ActiveBackend(); got != "admin" {
+		t.Fatalf("ActiveBackend() = %q, want admin", got)
+	}
+	// The runtime does not change under a running process, so the detection
+	// is only redone after ReloadConfig.
+	t.Setenv("GAE_ENV", "")
+	if got := ActiveBackend(); got != "admin" {
+		t.Errorf("ActiveBackend() after env change = %q, want cached admin", got)
+	}
+	ReloadConfig()
+	if got := ActiveBackend(); got != "legacy" {
+		t.Errorf("ActiveBackend() after ReloadConfig = %q, want legacy", got)
+	}
+}
+
+func TestBackendSelectionInvalidValue(t *testing.T) {
+	l := &captureLogger{}
+	SetLogger(l)
+	defer SetLogger(nil)
+	t.Setenv("GAE_ENV", "")
+	t.Setenv("MODULES_USE_ADMIN_API", "enabled")
+	ReloadConfig()
+	t.Cleanup(ReloadConfig)
+
+	for i := 0; i < 3; i++ {
+		sel := BackendSelection()
+		if sel.Backend != "legacy" || sel.Warning == nil {
+			t.Errorf("BackendSelection() = %+v, want legacy with a warning", sel)
+		}
+	}
+	if got := len(l.lines); got != 1 {
+		t.Errorf("logged %d warnings, want 1: %q", got, l.lines)
+	}
+
+	t.Setenv("MODULES_USE_ADMIN_API", "yes")
+	if sel := BackendSelection(); sel.Backend != "admin" || sel.Warning != nil {
+		t.Errorf("BackendSelection() = %+v, want admin without a warning", sel)
+	}
+}
@@ -0,0 +1,59 @@
+// Copyright 2026 Google LLC. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package module
+
+import (
+	"context"
+
+	admin "google.golang.org/api/appengine/v1"
+)
+
+// UpdateEnvVariables changes the environment variables of the given
+// module.version. Variables in set are added or overwritten, and variables
+// named in remove are deleted; a name in both is removed. If either module or
+// version are the empty string it means the default.
+//
+// The Admin API replaces the whole environment in a single update, so
+// UpdateEnvVariables reads the current variables, merges the changes and
+// writes the result back. This is not atomic: a concurrent update of the same
+// version between the read and the write may be lost.
+//
+// It returns ErrVersionNotFound if the version does not exist.
+func UpdateEnvVariables(c context.Context, module, version string, set map[string]string, remove []string) (err error) {
+	c, done := startCall(c, "UpdateEnvVariables", module, version)
+	defer done(&err)
+	if err := validateNames(module, version); err != nil {
+		return err
+	}
+	if !useAdminAPI(c) {
+		return ErrNotSupported
+	}
+	module, version, err = defaultModuleVersion(c, module, version)
+	if err != nil {
+		return err
+	}
+	projectID := getProjectID(c)
+	b, err := newAdminBackend(c, "update_env_variables")
+	if err != nil {
+		return err
+	}
+	v, err := b.GetVersion(c, projectID, module, version, "FULL")
+	if err != nil {
+		return err
+	}
+	env := make(map[string]string, len(v.EnvVariables)+len(set))
+	for k, val := range v.EnvVariables {
+		env[k] = val
+	}
+	for k, val := range set {
+		env[k] = val
+	}
+	for _, k := range remove {
+		delete(env, k)
+	}
+	update := &admin.Version{EnvVariables: env}
+	_, err = patchVersion(c, b, projectID, module, version, update, []string{"envVariables"}, true)
+	return err
+}
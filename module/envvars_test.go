@@ -0,0 +1,71 @@
+// Copyright 2026 Google LLC. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package module
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+
+	admin "google.golang.org/api/appengine/v1"
+)
+
+func TestUpdateEnvVariables_AdminAPI(t *testing.T) {
+	current := map[string]string{"KEEP": "1", "FLAG": "off", "OLD": "x"}
+	tests := []struct {
+		name   string
+		set    map[string]string
+		remove []string
+		want   map[string]string
+	}{
+		{"Add", map[string]string{"NEW": "y"}, nil, map[string]string{"KEEP": "1", "FLAG": "off", "OLD": "x", "NEW": "y"}},
+		{"Overwrite", map[string]string{"FLAG": "on"}, nil, map[string]string{"KEEP": "1", "FLAG": "on", "OLD": "x"}},
+		{"Remove", nil, []string{"OLD", "MISSING"}, map[string]string{"KEEP": "1", "FLAG": "off"}},
+		{"All", map[string]string{"FLAG": "on", "OLD": "z"}, []string{"OLD"}, map[string]string{"KEEP": "1", "FLAG": "on"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			patched := false
+			newAdminTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+				switch r.Method {
+				case "GET":
+					if got := r.URL.Query().Get("view"); got != "FULL" {
+						t.Errorf("view = %q, want FULL", got)
+					}
+					json.NewEncoder(w).Encode(&admin.Version{Id: "v1", EnvVariables: current})
+				case "PATCH":
+					patched = true
+					if got := r.URL.Query().Get("updateMask"); got != "envVariables" {
+						t.Errorf("updateMask = %q, want envVariables", got)
+					}
+					var v admin.Version
+					json.NewDecoder(r.Body).Decode(&v)
+					if !reflect.DeepEqual(v.EnvVariables, tt.want) {
+						t.Errorf("envVariables = %v, want %v", v.EnvVariables, tt.want)
+					}
+					json.NewEncoder(w).Encode(&admin.Operation{Name: "apps/test-project/operations/op", Done: true})
+				}
+			})
+			if err := UpdateEnvVariables(context.Background(), "my-module", "v1", tt.set, tt.remove); err != nil {
+				t.Fatalf("UpdateEnvVariables: %v", err)
+			}
+			if !patched {
+				t.Error("no PATCH was issued")
+			}
+		})
+	}
+}
+
+func TestUpdateEnvVariables_NotFound(t *testing.T) {
+	newAdminTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error":{"code":404,"message":"not found"}}`, http.StatusNotFound)
+	})
+	err := UpdateEnvVariables(context.Background(), "my-module", "v9", map[string]string{"A": "b"}, nil)
+	if err != ErrVersionNotFound {
+		t.Errorf("UpdateEnvVariables = %v, want ErrVersionNotFound", err)
+	}
+}
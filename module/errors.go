@@ -0,0 +1,148 @@
+// Copyright 2026 Google LLC. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package module
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"google.golang.org/api/googleapi"
+	"google.golang.org/appengine/internal"
+	pb "google.golang.org/appengine/internal/modules"
+)
+
+var (
+	// ErrNotSupported is returned when the requested operation cannot be
+	// performed by the legacy modules API and requires the Admin API.
+	ErrNotSupported = errors.New("module: operation not supported by the legacy modules API; set MODULES_USE_ADMIN_API=true")
+
+	// ErrVersionNotFound is returned when the requested version does not exist.
+	ErrVersionNotFound = errors.New("module: version not found")
+
+	// ErrInstanceNotFound is returned when the requested instance does not exist.
+	ErrInstanceNotFound = errors.New("module: instance not found")
+)
+
+type rawErrorsContextKey struct{}
+
+// RawErrors returns a copy of c whose calls report errors as the underlying
+// API returned them, for callers that inspect HTTP status codes themselves:
+// the errors of the Admin API, typically *googleapi.Error values, the entries
+// of the appengine.MultiError returned by the batch helpers, and the errors of
+// the legacy modules API are all left untranslated, instead of becoming the
+// errors of this package such as ErrVersionNotFound or *QuotaError. Requests
+// rejected for exceeding a quota are still retried as usual.
+func RawErrors(c context.Context, raw bool) context.Context {
+	return context.WithValue(c, rawErrorsContextKey{}, raw)
+}
+
+// rawErrors reports whether errors of calls made with c must not be
+// translated; see RawErrors.
+func rawErrors(c context.Context) bool {
+	raw, _ := c.Value(rawErrorsContextKey{}).(bool)
+	return raw
+}
+
+// isNotFound reports whether err is an Admin API error for a missing resource.
+func isNotFound(err error) bool {
+	var gErr *googleapi.Error
+	return errors.As(err, &gErr) && gErr.Code == http.StatusNotFound
+}
+
+// QuotaError is returned when the Admin API rejects a request because a quota
+// or rate limit was exceeded (HTTP 429). It wraps the *googleapi.Error
+// returned by the API client. Requests rejected this way are retried a few
+// times before QuotaError is returned.
+type QuotaError struct {
+	RetryAfter time.Duration // delay the server asked for before retrying, or zero
+	Metric     string        // exceeded quota metric, e.g. "appengine.googleapis.com/...", if known
+	Err        error
+}
+
+func (e *QuotaError) Error() string {
+	msg := "module: Admin API quota exceeded"
+	if e.Metric != "" {
+		msg += " for " + e.Metric
+	}
+	if e.RetryAfter > 0 {
+		msg += fmt.Sprintf(" (retry after %v)", e.RetryAfter)
+	}
+	return msg + ": " + e.Err.Error()
+}
+
+func (e *QuotaError) Unwrap() error { return e.Err }
+
+// translateError converts the Admin API errors that this package gives a
+// type of their own. Other errors are returned unchanged.
+func translateError(err error) error {
+	var gErr *googleapi.Error
+	if !errors.As(err, &gErr) || gErr.Code != http.StatusTooManyRequests {
+		return err
+	}
+	return &QuotaError{
+		RetryAfter: parseRetryAfter(gErr.Header.Get("Retry-After"), time.Now()),
+		Metric:     quotaMetric(gErr.Details),
+		Err:        err,
+	}
+}
+
+// translateVersionError returns ErrVersionNotFound in place of err if it is
+// the Admin API error for a missing version, unless c asks for raw errors.
+// It applies to the requests for a single version, which guardedBackend makes.
+func translateVersionError(c context.Context, err error) error {
+	if isNotFound(err) && !rawErrors(c) {
+		return ErrVersionNotFound
+	}
+	return err
+}
+
+// translateLegacyError gives the errors of the legacy modules API the meaning
+// of their Admin API counterparts: INVALID_VERSION matches ErrVersionNotFound
+// with errors.Is. The *internal.APIError is still available with errors.As.
+func translateLegacyError(err error) error {
+	if errors.Is(err, &internal.APIError{Service: "modules", Code: int32(pb.ModulesServiceError_INVALID_VERSION)}) {
+		return fmt.Errorf("%w: %w", ErrVersionNotFound, err)
+	}
+	return err
+}
+
+// parseRetryAfter parses the value of a Retry-After header, which is either a
+// number of seconds or an HTTP date. It returns zero if s is empty or invalid.
+func parseRetryAfter(s string, now time.Time) time.Duration {
+	if s == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(s); err == nil {
+		if secs < 0 {
+			return 0
+		}
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(s); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
+}
+
+// quotaMetric returns the quota metric named by the google.rpc.ErrorInfo
+// entry of the details of an API error, if any.
+func quotaMetric(details []interface{}) string {
+	for _, d := range details {
+		m, ok := d.(map[string]interface{})
+		if !ok || m["@type"] != "type.googleapis.com/google.rpc.ErrorInfo" {
+			continue
+		}
+		if md, ok := m["metadata"].(map[string]interface{}); ok {
+			if metric, ok := md["quota_metric"].(string); ok {
+				return metric
+			}
+		}
+	}
+	return ""
+}
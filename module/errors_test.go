@@ -0,0 +1,219 @@
+// Copyright 2026 Google LLC. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package module
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"google.golang.org/api/googleapi"
+	"google.golang.org/appengine"
+	"google.golang.org/appengine/internal"
+	"google.golang.org/appengine/internal/aetesting"
+	pb "google.golang.org/appengine/internal/modules"
+)
+
+const quotaErrorBody = `{"error": {"code": 429, "message": "Quota exceeded", "status": "RESOURCE_EXHAUSTED",
+	"details": [{"@type": "type.googleapis.com/google.rpc.ErrorInfo", "reason": "RATE_LIMIT_EXCEEDED",
+		"metadata": {"quota_metric": "appengine.googleapis.com/read_requests"}}]}}`
+
+func TestQuotaError(t *testing.T) {
+	old := quotaRetryDelay
+	quotaRetryDelay = time.Millisecond
+	t.Cleanup(func() { quotaRetryDelay = old })
+	var requests int32
+	newAdminTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusTooManyRequests)
+		fmt.Fprint(w, quotaErrorBody)
+	})
+	_, err := NumInstances(context.Background(), "my-module", "v1")
+	var qErr *QuotaError
+	if !errors.As(err, &qErr) {
+		t.Fatalf("NumInstances = %v, want a QuotaError", err)
+	}
+	if qErr.Metric != "appengine.googleapis.com/read_requests" {
+		t.Errorf("Metric = %q", qErr.Metric)
+	}
+	var gErr *googleapi.Error
+	if !errors.As(err, &gErr) || gErr.Code != http.StatusTooManyRequests {
+		t.Errorf("error %v does not wrap the 429 googleapi.Error", err)
+	}
+	if got, want := atomic.LoadInt32(&requests), int32(1+maxQuotaRetries); got != want {
+		t.Errorf("got %d requests, want %d", got, want)
+	}
+}
+
+func TestQuotaError_RetryAfter(t *testing.T) {
+	calls := observeCalls(t)
+	var requests int32
+	newAdminTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusTooManyRequests)
+			fmt.Fprint(w, quotaErrorBody)
+			return
+		}
+		fmt.Fprint(w, `{"manualScaling": {"instances": 2}}`)
+	})
+	start := time.Now()
+	n, err := NumInstances(context.Background(), "my-module", "v1")
+	if err != nil || n != 2 {
+		t.Fatalf("NumInstances = %d, %v; want 2, nil", n, err)
+	}
+	if d := time.Since(start); d < time.Second {
+		t.Errorf("NumInstances returned after %v, want the retry to wait 1s", d)
+	}
+	if c := calls(); len(c) != 1 || c[0].Attempts != 2 {
+		t.Errorf("observed calls = %+v, want one call with 2 attempts", c)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	for in, want := range map[string]time.Duration{
+		"":                              0,
+		"30":                            30 * time.Second,
+		"-1":                            0,
+		"soon":                          0,
+		"Thu, 01 Jan 2026 12:00:10 GMT": 10 * time.Second,
+		"Thu, 01 Jan 2026 11:00:00 GMT": 0,
+	} {
+		if got := parseRetryAfter(in, now); got != want {
+			t.Errorf("parseRetryAfter(%q) = %v, want %v", in, got, want)
+		}
+	}
+}
+
+func TestRawErrors(t *testing.T) {
+	newAdminTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error": {"code": 404, "message": "no such version"}}`, http.StatusNotFound)
+	})
+	_, err := ServingStatus(context.Background(), "my-module", "v1")
+	if !errors.Is(err, ErrVersionNotFound) {
+		t.Errorf("ServingStatus = %v, want ErrVersionNotFound", err)
+	}
+	_, err = ServingStatus(RawErrors(context.Background(), true), "my-module", "v1")
+	gErr, ok := err.(*googleapi.Error)
+	if !ok || gErr.Code != http.StatusNotFound {
+		t.Errorf("ServingStatus with raw errors = %#v, want a 404 *googleapi.Error", err)
+	}
+}
+
+func TestVersionNotFound(t *testing.T) {
+	newAdminTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" && r.URL.Query().Get("fields") == "" {
+			t.Errorf("GET %s without a field mask", r.URL.Path)
+		}
+		http.Error(w, `{"error": {"code": 404, "message": "no such version"}}`, http.StatusNotFound)
+	})
+	calls := map[string]func(c context.Context) error{
+		"SetAutomaticScaling": func(c context.Context) error {
+			return SetAutomaticScaling(c, "my-module", "v1", AutomaticScalingSettings{MinIdleInstances: intPtr(1)})
+		},
+		"SetBasicScaling":  func(c context.Context) error { return SetBasicScaling(c, "my-module", "v1", 2, 0) },
+		"SetInstanceClass": func(c context.Context) error { return SetInstanceClass(c, "my-module", "v1", "F2") },
+		"NumInstances": func(c context.Context) error {
+			_, err := NumInstances(c, "my-module", "v1")
+			return err
+		},
+		"DeleteVersion": func(c context.Context) error { return DeleteVersion(c, "my-module", "v1") },
+	}
+	for name, call := range calls {
+		if err := call(context.Background()); err != ErrVersionNotFound {
+			t.Errorf("%s = %v, want ErrVersionNotFound", name, err)
+		}
+		err := call(RawErrors(context.Background(), true))
+		if gErr, ok := err.(*googleapi.Error); !ok || gErr.Code != http.StatusNotFound {
+			t.Errorf("%s with raw errors = %#v, want a 404 *googleapi.Error", name, err)
+		}
+	}
+}
+
+func TestRawErrors_Batch(t *testing.T) {
+	newAdminTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error": {"code": 404, "message": "no such version"}}`, http.StatusNotFound)
+	})
+	targets := []InstanceTarget{{Module: "my-module", Version: "v1", Instances: 2}}
+	err := SetNumInstancesBulk(context.Background(), targets)
+	if me, ok := err.(appengine.MultiError); !ok || len(me) != 1 {
+		t.Fatalf("SetNumInstancesBulk = %v, want a MultiError with one entry", err)
+	} else if _, ok := me[0].(*VersionError); !ok {
+		t.Errorf("entry = %#v, want a *VersionError", me[0])
+	}
+	err = SetNumInstancesBulk(RawErrors(context.Background(), true), targets)
+	if me, ok := err.(appengine.MultiError); !ok || len(me) != 1 {
+		t.Fatalf("SetNumInstancesBulk with raw errors = %v, want a MultiError with one entry", err)
+	} else if gErr, ok := me[0].(*googleapi.Error); !ok || gErr.Code != http.StatusNotFound {
+		t.Errorf("entry = %#v, want a 404 *googleapi.Error", me[0])
+	}
+}
+
+func TestRawErrors_Quota(t *testing.T) {
+	old := quotaRetryDelay
+	quotaRetryDelay = time.Millisecond
+	t.Cleanup(func() { quotaRetryDelay = old })
+	var requests int32
+	newAdminTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusTooManyRequests)
+		fmt.Fprint(w, quotaErrorBody)
+	})
+	_, err := NumInstances(RawErrors(context.Background(), true), "my-module", "v1")
+	if gErr, ok := err.(*googleapi.Error); !ok || gErr.Code != http.StatusTooManyRequests {
+		t.Errorf("NumInstances with raw errors = %#v, want a 429 *googleapi.Error", err)
+	}
+	if got, want := atomic.LoadInt32(&requests), int32(1+maxQuotaRetries); got != want {
+		t.Errorf("got %d requests, want %d", got, want)
+	}
+}
+
+func TestLegacyErrors(t *testing.T) {
+	invalidVersion := aetesting.APIError("modules", int32(pb.ModulesServiceError_INVALID_VERSION), "no such version")
+	transient := aetesting.APIError("modules", int32(pb.ModulesServiceError_TRANSIENT_ERROR), "try again")
+	tests := []struct {
+		name         string
+		apiErr       error
+		raw          bool
+		wantNotFound bool
+	}{
+		{"InvalidVersion", invalidVersion, false, true},
+		{"InvalidVersionRaw", invalidVersion, true, false},
+		{"Transient", transient, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := aetesting.FakeSingleContext(t, "modules", "StopModule", func(req *pb.StopModuleRequest, res *pb.StopModuleResponse) error {
+				return tt.apiErr
+			})
+			c = RawErrors(c, tt.raw)
+			err := StopLegacy(c, "worker", "v1")
+			if got := errors.Is(err, ErrVersionNotFound); got != tt.wantNotFound {
+				t.Errorf("StopLegacy error = %v; errors.Is(err, ErrVersionNotFound) = %t, want %t", err, got, tt.wantNotFound)
+			}
+			var apiErr *internal.APIError
+			if !errors.As(err, &apiErr) || apiErr.Code != tt.apiErr.(*internal.APIError).Code {
+				t.Errorf("StopLegacy error = %v, want it to wrap %v", err, tt.apiErr)
+			}
+		})
+	}
+}
+
+func TestLegacyErrors_Server(t *testing.T) {
+	c, _ := aetesting.FakeAPIServer(t, "modules", "GetNumInstances", func(req *pb.GetNumInstancesRequest, res *pb.GetNumInstancesResponse) error {
+		return aetesting.APIError("modules", int32(pb.ModulesServiceError_INVALID_VERSION), "no such version")
+	})
+	if _, err := NumInstancesLegacy(c, "worker", "v1"); !errors.Is(err, ErrVersionNotFound) {
+		t.Errorf("NumInstancesLegacy error = %v, want ErrVersionNotFound", err)
+	}
+}
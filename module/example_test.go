@@ -0,0 +1,40 @@
+// Copyright 2026 Google LLC. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package module_test
+
+import (
+	"context"
+	"fmt"
+
+	"google.golang.org/appengine/module"
+	"google.golang.org/appengine/module/modulefake"
+)
+
+func ExampleSetDefaultVersion() {
+	f := modulefake.New("my-project")
+	f.AddVersion("default", "v1")
+	f.AddVersion("default", "v2")
+	ctx := module.WithBackend(context.Background(), f.Backend())
+
+	if err := module.SetDefaultVersion(ctx, "default", "v2"); err != nil {
+		fmt.Println(err)
+		return
+	}
+	v, err := module.DefaultVersion(ctx, "default")
+	fmt.Println(v, err)
+	// Output: v2 <nil>
+}
+
+func ExampleVersionsFiltered() {
+	f := modulefake.New("my-project")
+	f.AddVersion("default", "v1", modulefake.ManualScaling(2))
+	f.AddVersion("default", "v2", modulefake.ManualScaling(2), modulefake.Stopped())
+	f.AddVersion("default", "v3")
+	ctx := module.WithBackend(context.Background(), f.Backend())
+
+	versions, err := module.VersionsFiltered(ctx, "default", module.OnlyServing(), module.WithScaling(module.ScalingManual))
+	fmt.Println(versions, err)
+	// Output: [v1] <nil>
+}
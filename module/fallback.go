@@ -0,0 +1,96 @@
+// Copyright 2026 Google LLC. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package module
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/url"
+	"sync"
+
+	"google.golang.org/api/googleapi"
+	"google.golang.org/appengine/internal"
+)
+
+// adminFallback records whether the package has fallen back from the Admin API
+// to the legacy backend because of MODULES_ADMIN_API_FALLBACK.
+var adminFallback struct {
+	sync.Mutex
+	active bool
+}
+
+// fallbackEnabled reports whether MODULES_ADMIN_API_FALLBACK is set.
+func fallbackEnabled() bool {
+	v, _ := envBool("MODULES_ADMIN_API_FALLBACK")
+	return v
+}
+
+// fallbackActive reports whether calls should go to the legacy backend because
+// the Admin API was found to be unavailable.
+func fallbackActive() bool {
+	if !fallbackEnabled() {
+		return false
+	}
+	adminFallback.Lock()
+	defer adminFallback.Unlock()
+	return adminFallback.active
+}
+
+// adminInitError is returned when the Admin API client cannot be created,
+// typically because no credentials are available.
+type adminInitError struct {
+	err error
+}
+
+func (e *adminInitError) Error() string {
+	return "module: could not create admin service: " + e.err.Error()
+}
+
+func (e *adminInitError) Unwrap() error { return e.err }
+
+// adminUnavailable reports whether err means that the Admin API cannot be used
+// at all, as opposed to an error about the resource requested.
+func adminUnavailable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var gErr *googleapi.Error
+	if errors.As(err, &gErr) {
+		return gErr.Code == 401 || gErr.Code == 403
+	}
+	var initErr *adminInitError
+	var urlErr *url.Error
+	return errors.As(err, &initErr) || errors.As(err, &urlErr)
+}
+
+// fallBack reports whether a call that failed with err on the Admin API should
+// be retried on the legacy backend. When it first decides to fall back, it
+// logs the reason and makes subsequent calls use the legacy backend directly,
+// until ReloadConfig is called.
+func fallBack(c context.Context, err error) bool {
+	if err == nil || !fallbackEnabled() || backendFromContext(c) != nil || projectFromContext(c) != "" || !adminUnavailable(err) {
+		return false
+	}
+	// Background contexts cannot make legacy calls.
+	if internal.BackgroundIdentityFromContext(c) != nil {
+		return false
+	}
+	adminFallback.Lock()
+	first := !adminFallback.active
+	adminFallback.active = true
+	adminFallback.Unlock()
+	if first {
+		l := currentLogger()
+		if l == nil {
+			l = log.Default()
+		}
+		l.Printf("module: Admin API unavailable, falling back to the legacy backend: %v", err)
+	}
+	if st := callStateFrom(c); st != nil {
+		st.fellBack()
+	}
+	return true
+}
@@ -0,0 +1,106 @@
+// Copyright 2026 Google LLC. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package module
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	admin "google.golang.org/api/appengine/v1"
+	"google.golang.org/api/googleapi"
+
+	"google.golang.org/appengine/internal/aetesting"
+	pb "google.golang.org/appengine/internal/modules"
+)
+
+func TestFallbackOnForbidden(t *testing.T) {
+	t.Cleanup(ReloadConfig)
+	adminCalls := 0
+	useStubBackend(t, &stubBackend{
+		listServices: func(pageToken string) (*admin.ListServicesResponse, error) {
+			adminCalls++
+			return nil, &googleapi.Error{Code: 403, Message: "Permission denied"}
+		},
+	})
+	t.Setenv("MODULES_ADMIN_API_FALLBACK", "true")
+	c := aetesting.FakeSingleContext(t, "modules", "GetModules", func(req *pb.GetModulesRequest, res *pb.GetModulesResponse) error {
+		res.Module = []string{"default", "legacy-mod"}
+		return nil
+	})
+	calls := observeCalls(t)
+
+	want := []string{"default", "legacy-mod"}
+	for i := 0; i < 2; i++ {
+		got, err := List(c)
+		if err != nil {
+			t.Fatalf("List #%d: %v", i, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("List #%d = %v, want %v", i, got, want)
+		}
+	}
+	// The second call goes straight to the legacy backend.
+	if adminCalls != 1 {
+		t.Errorf("Admin API called %d times, want 1", adminCalls)
+	}
+	got := calls()
+	if len(got) != 2 {
+		t.Fatalf("observed %d calls, want 2", len(got))
+	}
+	if info := got[0]; info.Backend != "legacy" || !info.Fallback || info.Attempts != 2 || info.Err != nil {
+		t.Errorf("first CallInfo = %+v, want a successful fallback to legacy after 2 attempts", info)
+	}
+	if info := got[1]; info.Backend != "legacy" || info.Fallback || info.Attempts != 1 {
+		t.Errorf("second CallInfo = %+v, want a direct legacy call", info)
+	}
+
+	ReloadConfig()
+	if _, err := List(c); err != nil {
+		t.Fatalf("List after ReloadConfig: %v", err)
+	}
+	if adminCalls != 2 {
+		t.Errorf("Admin API called %d times after ReloadConfig, want 2", adminCalls)
+	}
+}
+
+func TestNoFallbackOnNotFound(t *testing.T) {
+	t.Cleanup(ReloadConfig)
+	useStubBackend(t, &stubBackend{
+		getVersion: func(module, version string) (*admin.Version, error) {
+			return nil, &googleapi.Error{Code: 404, Message: "Not Found"}
+		},
+	})
+	t.Setenv("MODULES_ADMIN_API_FALLBACK", "true")
+	c := aetesting.FakeSingleContext(t, "modules", "GetNumInstances", func(req *pb.GetNumInstancesRequest, res *pb.GetNumInstancesResponse) error {
+		t.Error("legacy backend called for a missing version")
+		res.Instances = proto.Int64(1)
+		return nil
+	})
+
+	if _, err := NumInstances(c, "mod", "v1"); !isNotFound(err) {
+		t.Errorf("NumInstances error = %v, want the Admin API's not-found error", err)
+	}
+	if fallbackActive() {
+		t.Error("fallback is active after a not-found error")
+	}
+}
+
+func TestNoFallbackWhenDisabled(t *testing.T) {
+	t.Cleanup(ReloadConfig)
+	useStubBackend(t, &stubBackend{
+		getVersion: func(module, version string) (*admin.Version, error) {
+			return nil, &googleapi.Error{Code: 403, Message: "Permission denied"}
+		},
+	})
+	c := aetesting.FakeSingleContext(t, "modules", "GetNumInstances", func(req *pb.GetNumInstancesRequest, res *pb.GetNumInstancesResponse) error {
+		t.Error("legacy backend called without MODULES_ADMIN_API_FALLBACK")
+		return nil
+	})
+
+	if _, err := NumInstances(c, "mod", "v1"); err == nil {
+		t.Error("NumInstances succeeded, want the Admin API's permission error")
+	}
+}
@@ -0,0 +1,172 @@
+// Copyright 2026 Google LLC. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package module
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	admin "google.golang.org/api/appengine/v1"
+	"google.golang.org/api/googleapi"
+)
+
+// withGuards returns b wrapped so that its requests are bounded by the
+// default timeouts and the rate limit, their errors are translated, including
+// those for missing versions into ErrVersionNotFound, and requests rejected
+// for exceeding a quota are retried.
+func withGuards(b adminBackend) adminBackend {
+	return &guardedBackend{b}
+}
+
+// guardedBackend is an adminBackend that applies the package's request policy
+// to the requests it forwards to b.
+type guardedBackend struct {
+	b adminBackend
+}
+
+// maxQuotaRetries is the number of times a request rejected with a
+// *QuotaError is retried.
+const maxQuotaRetries = 3
+
+// quotaRetryDelay is the delay before the first retry of a request rejected
+// with a *QuotaError that does not say when to retry. It is a variable so that
+// tests can shorten it.
+var quotaRetryDelay = time.Second
+
+// quotaBackoff is the default policy for the retries of requests rejected with
+// a *QuotaError; see WithBackoff.
+func quotaBackoff() Backoff {
+	return Backoff{Initial: quotaRetryDelay, Max: time.Minute, Multiplier: 2, Jitter: 0.1}
+}
+
+// call runs f, which makes one request, with ctx bounded by the default read
+// or write timeout and after waiting for the rate limiter. If the request is
+// rejected with a *QuotaError, call waits for the delay the server asked for,
+// or else the next delay of the retry policy, and tries again, up to
+// maxQuotaRetries times. Retrying is safe even for writes, since a rejected
+// request has no effect. Errors are translated unless ctx asks for raw errors.
+func (g *guardedBackend) call(ctx context.Context, write bool, f func(ctx context.Context) error) error {
+	ctx, cancel := withDefaultTimeout(ctx, write)
+	defer cancel()
+	bo := backoffFor(ctx, quotaBackoff())
+	for attempt := 0; ; attempt++ {
+		if err := rateLimiter.wait(ctx); err != nil {
+			return err
+		}
+		raw := f(ctx)
+		err := translateError(raw)
+		var qErr *QuotaError
+		retry := attempt < maxQuotaRetries && errors.As(err, &qErr)
+		if rawErrors(ctx) {
+			err = raw
+		}
+		if !retry {
+			return err
+		}
+		wait := bo.Next()
+		if qErr.RetryAfter > 0 {
+			wait = qErr.RetryAfter
+		}
+		t := time.NewTimer(wait)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			return err
+		}
+		if st := callStateFrom(ctx); st != nil {
+			st.retried()
+		}
+	}
+}
+
+func (g *guardedBackend) GetApplication(ctx context.Context, project string, fields ...googleapi.Field) (r *admin.Application, err error) {
+	err = g.call(ctx, false, func(ctx context.Context) (err error) {
+		r, err = g.b.GetApplication(ctx, project, fields...)
+		return err
+	})
+	return r, err
+}
+
+func (g *guardedBackend) ListServices(ctx context.Context, project, pageToken string, fields ...googleapi.Field) (r *admin.ListServicesResponse, err error) {
+	err = g.call(ctx, false, func(ctx context.Context) (err error) {
+		r, err = g.b.ListServices(ctx, project, pageToken, fields...)
+		return err
+	})
+	return r, err
+}
+
+func (g *guardedBackend) GetService(ctx context.Context, project, module string, fields ...googleapi.Field) (r *admin.Service, err error) {
+	err = g.call(ctx, false, func(ctx context.Context) (err error) {
+		r, err = g.b.GetService(ctx, project, module, fields...)
+		return err
+	})
+	return r, err
+}
+
+func (g *guardedBackend) PatchService(ctx context.Context, project, module string, s *admin.Service, updateMask string, migrateTraffic bool) (r *admin.Operation, err error) {
+	err = g.call(ctx, true, func(ctx context.Context) (err error) {
+		r, err = g.b.PatchService(ctx, project, module, s, updateMask, migrateTraffic)
+		return err
+	})
+	return r, err
+}
+
+func (g *guardedBackend) ListVersions(ctx context.Context, project, module, pageToken, view string, fields ...googleapi.Field) (r *admin.ListVersionsResponse, err error) {
+	err = g.call(ctx, false, func(ctx context.Context) (err error) {
+		r, err = g.b.ListVersions(ctx, project, module, pageToken, view, fields...)
+		return err
+	})
+	return r, err
+}
+
+func (g *guardedBackend) GetVersion(ctx context.Context, project, module, version, view string, fields ...googleapi.Field) (r *admin.Version, err error) {
+	err = g.call(ctx, false, func(ctx context.Context) (err error) {
+		r, err = g.b.GetVersion(ctx, project, module, version, view, fields...)
+		return err
+	})
+	return r, translateVersionError(ctx, err)
+}
+
+func (g *guardedBackend) PatchVersion(ctx context.Context, project, module, version string, v *admin.Version, updateMask string) (r *admin.Operation, err error) {
+	err = g.call(ctx, true, func(ctx context.Context) (err error) {
+		r, err = g.b.PatchVersion(ctx, project, module, version, v, updateMask)
+		return err
+	})
+	return r, translateVersionError(ctx, err)
+}
+
+func (g *guardedBackend) DeleteVersion(ctx context.Context, project, module, version string) (r *admin.Operation, err error) {
+	err = g.call(ctx, true, func(ctx context.Context) (err error) {
+		r, err = g.b.DeleteVersion(ctx, project, module, version)
+		return err
+	})
+	return r, translateVersionError(ctx, err)
+}
+
+func (g *guardedBackend) ListInstances(ctx context.Context, project, module, version, pageToken string) (r *admin.ListInstancesResponse, err error) {
+	err = g.call(ctx, false, func(ctx context.Context) (err error) {
+		r, err = g.b.ListInstances(ctx, project, module, version, pageToken)
+		return err
+	})
+	return r, err
+}
+
+func (g *guardedBackend) DebugInstance(ctx context.Context, project, module, version, instance string, req *admin.DebugInstanceRequest) (r *admin.Operation, err error) {
+	err = g.call(ctx, true, func(ctx context.Context) (err error) {
+		r, err = g.b.DebugInstance(ctx, project, module, version, instance, req)
+		return err
+	})
+	return r, err
+}
+
+func (g *guardedBackend) GetOperation(ctx context.Context, project, operation string) (r *admin.Operation, err error) {
+	err = g.call(ctx, false, func(ctx context.Context) (err error) {
+		r, err = g.b.GetOperation(ctx, project, operation)
+		return err
+	})
+	return r, err
+}
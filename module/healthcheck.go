@@ -0,0 +1,212 @@
+// Copyright 2026 Google LLC. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package module
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	admin "google.golang.org/api/appengine/v1"
+)
+
+// ReadinessCheck configures the readiness check of a flexible environment
+// version, which decides whether an instance may receive traffic. Zero fields
+// use the server defaults.
+type ReadinessCheck struct {
+	Path             string // request path, e.g. "/readiness_check"
+	Host             string // Host header sent with the check
+	CheckInterval    time.Duration
+	Timeout          time.Duration
+	FailureThreshold int // consecutive failures before the instance is unready
+	SuccessThreshold int // consecutive successes before the instance is ready
+	AppStartTimeout  time.Duration
+}
+
+// LivenessCheck configures the liveness check of a flexible environment
+// version, which decides whether an instance must be restarted. Zero fields
+// use the server defaults.
+type LivenessCheck struct {
+	Path             string // request path, e.g. "/liveness_check"
+	Host             string // Host header sent with the check
+	CheckInterval    time.Duration
+	Timeout          time.Duration
+	FailureThreshold int // consecutive failures before the instance is restarted
+	SuccessThreshold int // consecutive successes before the instance is healthy
+	InitialDelay     time.Duration
+}
+
+// ErrWrongEnvironment is matched by errors.Is for every *EnvironmentError.
+var ErrWrongEnvironment = errors.New("module: wrong environment")
+
+// EnvironmentError is returned when an operation is only available for
+// versions of another App Engine environment.
+type EnvironmentError struct {
+	Module, Version string
+	Env             string // environment of the version, e.g. "standard"
+	Want            string // environment the operation requires
+}
+
+func (e *EnvironmentError) Error() string {
+	return fmt.Sprintf("module: version %s of module %s runs in the %s environment, want %s", e.Version, e.Module, e.Env, e.Want)
+}
+
+// Is reports whether target is ErrWrongEnvironment.
+func (e *EnvironmentError) Is(target error) bool {
+	return target == ErrWrongEnvironment
+}
+
+// isFlexible reports whether env, the env field of a version, names the
+// flexible environment.
+func isFlexible(env string) bool {
+	return env == "flex" || env == "flexible"
+}
+
+// parseDuration parses a duration in the Admin API's syntax, e.g. "300s". An
+// empty or malformed duration is zero.
+func parseDuration(s string) time.Duration {
+	d, _ := time.ParseDuration(s)
+	return d
+}
+
+// HealthChecks returns the readiness and liveness checks of the given
+// module.version. A check that the version does not configure is returned as
+// nil. If either module or version are the empty string it means the
+// default. HealthChecks returns ErrNotSupported on the legacy backend.
+func HealthChecks(c context.Context, module, version string) (_ *ReadinessCheck, _ *LivenessCheck, err error) {
+	c, done := startCall(c, "HealthChecks", module, version)
+	defer done(&err)
+	if err := validateNames(module, version); err != nil {
+		return nil, nil, err
+	}
+	if !useAdminAPI(c) {
+		return nil, nil, ErrNotSupported
+	}
+	module, version, err = defaultModuleVersion(c, module, version)
+	if err != nil {
+		return nil, nil, err
+	}
+	projectID := getProjectID(c)
+	b, err := newAdminBackend(c, "get_health_checks")
+	if err != nil {
+		return nil, nil, err
+	}
+	v, err := b.GetVersion(c, projectID, module, version, "", "readinessCheck,livenessCheck")
+	if err != nil {
+		return nil, nil, err
+	}
+	var readiness *ReadinessCheck
+	if r := v.ReadinessCheck; r != nil {
+		readiness = &ReadinessCheck{
+			Path:             r.Path,
+			Host:             r.Host,
+			CheckInterval:    parseDuration(r.CheckInterval),
+			Timeout:          parseDuration(r.Timeout),
+			FailureThreshold: int(r.FailureThreshold),
+			SuccessThreshold: int(r.SuccessThreshold),
+			AppStartTimeout:  parseDuration(r.AppStartTimeout),
+		}
+	}
+	var liveness *LivenessCheck
+	if l := v.LivenessCheck; l != nil {
+		liveness = &LivenessCheck{
+			Path:             l.Path,
+			Host:             l.Host,
+			CheckInterval:    parseDuration(l.CheckInterval),
+			Timeout:          parseDuration(l.Timeout),
+			FailureThreshold: int(l.FailureThreshold),
+			SuccessThreshold: int(l.SuccessThreshold),
+			InitialDelay:     parseDuration(l.InitialDelay),
+		}
+	}
+	return readiness, liveness, nil
+}
+
+// formatOptionalDuration is formatDuration for settings where zero means the
+// server default.
+func formatOptionalDuration(d time.Duration) string {
+	if d <= 0 {
+		return ""
+	}
+	return formatDuration(d)
+}
+
+// SetReadinessCheck replaces the readiness check of the given flexible
+// environment module.version with check, and waits for the change to take
+// effect. If either module or version are the empty string it means the
+// default.
+//
+// It returns an *EnvironmentError for a standard environment version, and
+// ErrNotSupported on the legacy backend.
+func SetReadinessCheck(c context.Context, module, version string, check ReadinessCheck) (err error) {
+	c, done := startCall(c, "SetReadinessCheck", module, version)
+	defer done(&err)
+	update := &admin.Version{ReadinessCheck: &admin.ReadinessCheck{
+		Path:             check.Path,
+		Host:             check.Host,
+		CheckInterval:    formatOptionalDuration(check.CheckInterval),
+		Timeout:          formatOptionalDuration(check.Timeout),
+		FailureThreshold: int64(check.FailureThreshold),
+		SuccessThreshold: int64(check.SuccessThreshold),
+		AppStartTimeout:  formatOptionalDuration(check.AppStartTimeout),
+	}}
+	return setHealthCheck(c, module, version, update, "readinessCheck")
+}
+
+// SetLivenessCheck replaces the liveness check of the given flexible
+// environment module.version with check, and waits for the change to take
+// effect. If either module or version are the empty string it means the
+// default.
+//
+// It returns an *EnvironmentError for a standard environment version, and
+// ErrNotSupported on the legacy backend.
+func SetLivenessCheck(c context.Context, module, version string, check LivenessCheck) (err error) {
+	c, done := startCall(c, "SetLivenessCheck", module, version)
+	defer done(&err)
+	update := &admin.Version{LivenessCheck: &admin.LivenessCheck{
+		Path:             check.Path,
+		Host:             check.Host,
+		CheckInterval:    formatOptionalDuration(check.CheckInterval),
+		Timeout:          formatOptionalDuration(check.Timeout),
+		FailureThreshold: int64(check.FailureThreshold),
+		SuccessThreshold: int64(check.SuccessThreshold),
+		InitialDelay:     formatOptionalDuration(check.InitialDelay),
+	}}
+	return setHealthCheck(c, module, version, update, "livenessCheck")
+}
+
+// setHealthCheck applies update, which sets the health check named by mask,
+// after checking that module.version runs in the flexible environment.
+func setHealthCheck(c context.Context, module, version string, update *admin.Version, mask string) error {
+	if err := validateNames(module, version); err != nil {
+		return err
+	}
+	if !useAdminAPI(c) {
+		return ErrNotSupported
+	}
+	module, version, err := defaultModuleVersion(c, module, version)
+	if err != nil {
+		return err
+	}
+	projectID := getProjectID(c)
+	b, err := newAdminBackend(c, "set_health_check")
+	if err != nil {
+		return err
+	}
+	v, err := b.GetVersion(c, projectID, module, version, "", "env")
+	if err != nil {
+		return err
+	}
+	if !isFlexible(v.Env) {
+		env := v.Env
+		if env == "" {
+			env = "standard"
+		}
+		return &EnvironmentError{Module: module, Version: version, Env: env, Want: "flexible"}
+	}
+	_, err = patchVersion(c, b, projectID, module, version, update, []string{mask}, true)
+	return err
+}
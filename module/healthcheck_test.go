@@ -0,0 +1,101 @@
+// Copyright 2026 Google LLC. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package module
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	admin "google.golang.org/api/appengine/v1"
+	"google.golang.org/appengine/internal/aetesting"
+)
+
+func TestHealthChecks(t *testing.T) {
+	ctx := aetesting.FakeAdminContext(t, func(req *aetesting.AdminRequest) (int, interface{}) {
+		req.Expect(t, "GET", "/v1/apps/test-project/services/my-module/versions/v1", "")
+		if got, want := req.Query.Get("fields"), "readinessCheck,livenessCheck"; got != want {
+			t.Errorf("fields = %q, want %q", got, want)
+		}
+		return http.StatusOK, &admin.Version{
+			ReadinessCheck: &admin.ReadinessCheck{Path: "/ready", CheckInterval: "5s", FailureThreshold: 2, AppStartTimeout: "300s"},
+			LivenessCheck:  &admin.LivenessCheck{Path: "/live", Timeout: "4.5s", InitialDelay: "300s"},
+		}
+	})
+	readiness, liveness, err := HealthChecks(ctx, "my-module", "v1")
+	if err != nil {
+		t.Fatalf("HealthChecks: %v", err)
+	}
+	wantReadiness := &ReadinessCheck{Path: "/ready", CheckInterval: 5 * time.Second, FailureThreshold: 2, AppStartTimeout: 5 * time.Minute}
+	if !reflect.DeepEqual(readiness, wantReadiness) {
+		t.Errorf("readiness = %+v, want %+v", readiness, wantReadiness)
+	}
+	wantLiveness := &LivenessCheck{Path: "/live", Timeout: 4500 * time.Millisecond, InitialDelay: 5 * time.Minute}
+	if !reflect.DeepEqual(liveness, wantLiveness) {
+		t.Errorf("liveness = %+v, want %+v", liveness, wantLiveness)
+	}
+}
+
+// healthCheckContext serves a version running in env and records the PATCH
+// requests it receives.
+func healthCheckContext(t *testing.T, env string) (context.Context, *[]*aetesting.AdminRequest) {
+	var patches []*aetesting.AdminRequest
+	ctx := aetesting.FakeAdminContext(t, func(req *aetesting.AdminRequest) (int, interface{}) {
+		if req.Method == "PATCH" {
+			patches = append(patches, req)
+			return http.StatusOK, &admin.Operation{Name: "apps/test-project/operations/op", Done: true}
+		}
+		req.Expect(t, "GET", "/v1/apps/test-project/services/my-module/versions/v1", "")
+		if got := req.Query.Get("fields"); got != "env" {
+			t.Errorf("fields = %q, want env", got)
+		}
+		return http.StatusOK, &admin.Version{Env: env}
+	})
+	return ctx, &patches
+}
+
+func TestSetHealthChecks(t *testing.T) {
+	ctx, patches := healthCheckContext(t, "flex")
+	if err := SetReadinessCheck(ctx, "my-module", "v1", ReadinessCheck{Path: "/ready", CheckInterval: 5 * time.Second, FailureThreshold: 3}); err != nil {
+		t.Fatalf("SetReadinessCheck: %v", err)
+	}
+	if err := SetLivenessCheck(ctx, "my-module", "v1", LivenessCheck{Path: "/live", InitialDelay: 2 * time.Minute}); err != nil {
+		t.Fatalf("SetLivenessCheck: %v", err)
+	}
+	want := []struct{ mask, body string }{
+		{"readinessCheck", `{"readinessCheck":{"checkInterval":"5s","failureThreshold":3,"path":"/ready"}}`},
+		{"livenessCheck", `{"livenessCheck":{"initialDelay":"120s","path":"/live"}}`},
+	}
+	if len(*patches) != len(want) {
+		t.Fatalf("got %d PATCH requests, want %d", len(*patches), len(want))
+	}
+	for i, req := range *patches {
+		req.Expect(t, "PATCH", "/v1/apps/test-project/services/my-module/versions/v1", want[i].mask)
+		if got := strings.TrimSpace(string(req.Body)); got != want[i].body {
+			t.Errorf("PATCH body = %s, want %s", got, want[i].body)
+		}
+	}
+}
+
+func TestSetHealthChecks_StandardEnvironment(t *testing.T) {
+	for _, env := range []string{"standard", ""} {
+		ctx, patches := healthCheckContext(t, env)
+		err := SetReadinessCheck(ctx, "my-module", "v1", ReadinessCheck{Path: "/ready"})
+		var ee *EnvironmentError
+		if !errors.Is(err, ErrWrongEnvironment) || !errors.As(err, &ee) || ee.Env != "standard" {
+			t.Errorf("SetReadinessCheck on env %q = %v, want a standard environment EnvironmentError", env, err)
+		}
+		if err := SetLivenessCheck(ctx, "my-module", "v1", LivenessCheck{}); !errors.Is(err, ErrWrongEnvironment) {
+			t.Errorf("SetLivenessCheck on env %q = %v, want ErrWrongEnvironment", env, err)
+		}
+		if len(*patches) != 0 {
+			t.Errorf("%d PATCH requests sent to a standard environment version", len(*patches))
+		}
+	}
+}
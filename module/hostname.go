@@ -0,0 +1,145 @@
+// Copyright 2026 Google LLC. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package module
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"google.golang.org/appengine"
+	"google.golang.org/appengine/internal"
+	pb "google.golang.org/appengine/internal/modules"
+)
+
+// Hostname returns a hostname that routes requests to the given instance of
+// module.version. If instance is the empty string, the hostname routes to any
+// instance of the version. If either module or version are the empty string
+// it means the default.
+func Hostname(c context.Context, module, version, instance string) (_ string, err error) {
+	c, done := startCall(c, "Hostname", module, version)
+	defer done(&err)
+	if err := validateNames(module, version); err != nil {
+		return "", err
+	}
+	if !useAdminAPI(c) {
+		return HostnameLegacy(c, module, version, instance)
+	}
+	host, err := hostnameAdmin(c, module, version, instance)
+	if fallBack(c, err) {
+		return HostnameLegacy(c, module, version, instance)
+	}
+	return host, err
+}
+
+// hostnameAdmin builds the hostname from the application's default hostname,
+// following the "instance-dot-version-dot-module" routing scheme. The default
+// hostname comes from the Admin API or, if the API is unreachable, is guessed
+// from the environment of the running app.
+func hostnameAdmin(c context.Context, module, version, instance string) (string, error) {
+	module, version, err := defaultModuleVersion(c, module, version)
+	if err != nil {
+		return "", err
+	}
+	host, err := DefaultVersionHostname(c)
+	if err != nil {
+		return "", err
+	}
+	labels := []string{version, module, host}
+	if instance != "" {
+		labels = append([]string{instance}, labels...)
+	}
+	return strings.Join(labels, "-dot-"), nil
+}
+
+func init() {
+	internal.DefaultVersionHostnameFallback = func(c context.Context) string {
+		if !useAdminAPI(c) {
+			return ""
+		}
+		host, _ := DefaultVersionHostname(c)
+		return host
+	}
+}
+
+// DefaultVersionHostname returns the hostname of the default version of the
+// application, such as "my-project.appspot.com". It comes from the Admin API
+// and is remembered like the rest of AppInfo, or from the incoming request on
+// the legacy backend. If the API is unreachable, the API error is returned:
+// the region ID in the hostnames of most apps, as in
+// "my-project.uc.r.appspot.com", cannot be derived from the environment. Apps
+// whose hostname has no region ID, as for some apps created before 2020, may
+// set MODULES_DEFAULT_HOSTNAME_NO_REGION to true, and the hostname is then
+// guessed as "PROJECT.appspot.com" for those in the "s~" partition.
+//
+// appengine.DefaultVersionHostname uses this function on second-generation
+// runtimes, where requests do not carry the hostname.
+func DefaultVersionHostname(c context.Context) (string, error) {
+	if !useAdminAPI(c) {
+		if host := appengine.DefaultVersionHostname(c); host != "" {
+			return host, nil
+		}
+	}
+	app, err := AppInfo(c)
+	if err != nil {
+		if guess, ok := guessDefaultHostname(c); ok && adminUnavailable(err) {
+			return guess, nil
+		}
+		return "", err
+	}
+	if app.DefaultHostname == "" {
+		return "", fmt.Errorf("module: application %s has no default hostname", app.ID)
+	}
+	return app.DefaultHostname, nil
+}
+
+// guessDefaultHostname derives the default hostname of the running app from
+// its environment, for use when the Admin API is unreachable. The region ID
+// of a hostname depends on the region and creation date of the app, and the
+// partition prefix of GAE_APPLICATION, such as "s~" or "e~", does not
+// determine it: US apps created since 2020 are "s~" apps with hostnames such
+// as "my-project.uc.r.appspot.com". So the guess is only made for "s~" apps
+// that MODULES_DEFAULT_HOSTNAME_NO_REGION marks as having no region ID. It
+// reports false otherwise, or if c targets another project.
+func guessDefaultHostname(c context.Context) (string, bool) {
+	if backendFromContext(c) != nil || projectFromContext(c) != "" {
+		return "", false
+	}
+	if noRegion, _ := envBool("MODULES_DEFAULT_HOSTNAME_NO_REGION"); !noRegion {
+		return "", false
+	}
+	_, partition := appengine.ParseFullAppID(os.Getenv("GAE_APPLICATION"))
+	project := getProjectID(c)
+	// The hostnames of custom domain deployments have another form.
+	if partition != "s" || project == "" || strings.Contains(project, ":") {
+		return "", false
+	}
+	return project + ".appspot.com", true
+}
+
+// HostnameLegacy is like Hostname, but always uses the legacy modules API.
+func HostnameLegacy(c context.Context, module, version, instance string) (_ string, err error) {
+	c, done := startLegacyCall(c, "HostnameLegacy", module, version)
+	defer done(&err)
+	if err := validateNames(module, version); err != nil {
+		return "", err
+	}
+	req := &pb.GetHostnameRequest{}
+	if module != "" {
+		req.Module = &module
+	}
+	if version != "" {
+		req.Version = &version
+	}
+	if instance != "" {
+		req.Instance = &instance
+	}
+	res := &pb.GetHostnameResponse{}
+	if err := legacyCall(c, "GetHostname", false, req, res); err != nil {
+		return "", err
+	}
+	return res.GetHostname(), nil
+}
@@ -0,0 +1,170 @@
+// Copyright 2026 Google LLC. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package module
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	admin "google.golang.org/api/appengine/v1"
+	"google.golang.org/appengine"
+	"google.golang.org/appengine/internal"
+	"google.golang.org/appengine/internal/aetesting"
+	pb "google.golang.org/appengine/internal/modules"
+)
+
+func TestHostnameLegacy(t *testing.T) {
+	c := aetesting.FakeSingleContext(t, "modules", "GetHostname", func(req *pb.GetHostnameRequest, res *pb.GetHostnameResponse) error {
+		if req.GetModule() != module || req.GetVersion() != version || req.GetInstance() != "2" {
+			t.Errorf("request = %v, want module %q, version %q, instance 2", req, module, version)
+		}
+		res.Hostname = proto.String("2.test-version.test-module.example.appspot.com")
+		return nil
+	})
+	got, err := Hostname(c, module, version, "2")
+	if err != nil {
+		t.Fatalf("Hostname: %v", err)
+	}
+	if want := "2.test-version.test-module.example.appspot.com"; got != want {
+		t.Errorf("Hostname = %q, want %q", got, want)
+	}
+}
+
+func TestHostnameLegacy_Defaults(t *testing.T) {
+	c := aetesting.FakeSingleContext(t, "modules", "GetHostname", func(req *pb.GetHostnameRequest, res *pb.GetHostnameResponse) error {
+		if req.Module != nil || req.Version != nil || req.Instance != nil {
+			t.Errorf("request = %v, want no fields set", req)
+		}
+		res.Hostname = proto.String("example.appspot.com")
+		return nil
+	})
+	if _, err := HostnameLegacy(c, "", "", ""); err != nil {
+		t.Fatalf("HostnameLegacy: %v", err)
+	}
+}
+
+func TestHostname_AdminAPI(t *testing.T) {
+	InvalidateCache()
+	t.Cleanup(InvalidateCache)
+	ctx := aetesting.FakeAdminContext(t, func(req *aetesting.AdminRequest) (int, interface{}) {
+		req.Expect(t, "GET", "/v1/apps/test-project", "")
+		return http.StatusOK, &admin.Application{DefaultHostname: "test-project.appspot.com"}
+	})
+	tests := []struct {
+		instance, want string
+	}{
+		{"", "v1-dot-worker-dot-test-project.appspot.com"},
+		{"abc", "abc-dot-v1-dot-worker-dot-test-project.appspot.com"},
+	}
+	for _, tt := range tests {
+		got, err := Hostname(ctx, "worker", "v1", tt.instance)
+		if err != nil {
+			t.Fatalf("Hostname: %v", err)
+		}
+		if got != tt.want {
+			t.Errorf("Hostname(%q) = %q, want %q", tt.instance, got, tt.want)
+		}
+	}
+}
+
+func TestHostname_RegionGuess(t *testing.T) {
+	tests := []struct {
+		name, app string
+		noRegion  string // value of MODULES_DEFAULT_HOSTNAME_NO_REGION
+		apiHost   string // default hostname served by the Admin API; empty if it is unreachable
+		want      string // empty if Hostname must fail
+	}{
+		{"NoRegion", "s~my-project", "true", "", "v1-dot-worker-dot-my-project.appspot.com"},
+		// US apps created since 2020 are in the "s~" partition too.
+		{"RegionUnknown", "s~my-project", "", "", ""},
+		{"Europe", "e~my-project", "", "", ""},
+		{"EuropeNoRegion", "e~my-project", "true", "", ""},
+		{"APIPreferred", "s~my-project", "true", "my-project.uc.r.appspot.com", "v1-dot-worker-dot-my-project.uc.r.appspot.com"},
+		{"UnknownPartition", "x~my-project", "", "my-project.xy.r.appspot.com", "v1-dot-worker-dot-my-project.xy.r.appspot.com"},
+		{"UnknownPartitionOffline", "x~my-project", "true", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			InvalidateCache()
+			t.Cleanup(InvalidateCache)
+			var lookups int
+			ctx := aetesting.FakeAdminContext(t, func(req *aetesting.AdminRequest) (int, interface{}) {
+				req.Expect(t, "GET", "/v1/apps/my-project", "")
+				lookups++
+				if tt.apiHost == "" {
+					return http.StatusForbidden, "permission denied"
+				}
+				return http.StatusOK, &admin.Application{Id: "my-project", DefaultHostname: tt.apiHost}
+			})
+			t.Setenv("GOOGLE_CLOUD_PROJECT", "")
+			t.Setenv("GAE_APPLICATION", tt.app)
+			t.Setenv("MODULES_DEFAULT_HOSTNAME_NO_REGION", tt.noRegion)
+			got, err := Hostname(ctx, "worker", "v1", "")
+			if tt.want == "" {
+				if err == nil {
+					t.Errorf("Hostname = %q, want an error", got)
+				}
+			} else if err != nil || got != tt.want {
+				t.Errorf("Hostname = %q, %v; want %q", got, err, tt.want)
+			}
+			if lookups != 1 {
+				t.Errorf("Admin API looked up %d times, want once", lookups)
+			}
+		})
+	}
+}
+
+func TestDefaultVersionHostname(t *testing.T) {
+	t.Run("Legacy", func(t *testing.T) {
+		t.Setenv("MODULES_USE_ADMIN_API", "false")
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("X-AppEngine-Default-Version-Hostname", "legacy-app.appspot.com")
+		c := internal.ContextForTesting(req)
+		if got, want := appengine.DefaultVersionHostname(c), "legacy-app.appspot.com"; got != want {
+			t.Errorf("appengine.DefaultVersionHostname = %q, want %q", got, want)
+		}
+		if got, err := DefaultVersionHostname(c); err != nil || got != "legacy-app.appspot.com" {
+			t.Errorf("DefaultVersionHostname = %q, %v; want legacy-app.appspot.com", got, err)
+		}
+	})
+	for _, tt := range []struct {
+		name    string
+		apiHost string // default hostname served by the Admin API; empty if it is unreachable
+		want    string
+	}{
+		{"AdminAPI", "my-project.uc.r.appspot.com", "my-project.uc.r.appspot.com"},
+		{"OfflineGuess", "", "my-project.appspot.com"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			InvalidateCache()
+			t.Cleanup(InvalidateCache)
+			var lookups int
+			ctx := aetesting.FakeAdminContext(t, func(req *aetesting.AdminRequest) (int, interface{}) {
+				req.Expect(t, "GET", "/v1/apps/my-project", "")
+				lookups++
+				if tt.apiHost == "" {
+					return http.StatusForbidden, "permission denied"
+				}
+				return http.StatusOK, &admin.Application{Id: "my-project", DefaultHostname: tt.apiHost}
+			})
+			t.Setenv("GOOGLE_CLOUD_PROJECT", "")
+			t.Setenv("GAE_APPLICATION", "s~my-project")
+			t.Setenv("MODULES_DEFAULT_HOSTNAME_NO_REGION", "true")
+			for i := 0; i < 2; i++ {
+				if got := appengine.DefaultVersionHostname(ctx); got != tt.want {
+					t.Errorf("appengine.DefaultVersionHostname = %q, want %q", got, tt.want)
+				}
+			}
+			if got, err := DefaultVersionHostname(ctx); err != nil || got != tt.want {
+				t.Errorf("DefaultVersionHostname = %q, %v; want %q", got, err, tt.want)
+			}
+			if tt.apiHost != "" && lookups != 1 {
+				t.Errorf("Admin API looked up %d times, want once", lookups)
+			}
+		})
+	}
+}
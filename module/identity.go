@@ -0,0 +1,65 @@
+// Copyright 2026 Google LLC. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package module
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"google.golang.org/appengine"
+)
+
+// ErrNoIdentity is returned when the module, version or instance of the
+// running app cannot be determined.
+var ErrNoIdentity = errors.New("module: identity of the running app is not available")
+
+// IdentityError is returned by CurrentModule, CurrentVersion and
+// CurrentInstance when neither the environment nor the legacy runtime provide
+// the requested value. It matches ErrNoIdentity with errors.Is.
+type IdentityError struct {
+	What string // "module", "version" or "instance"
+	Env  string // environment variable that was consulted
+}
+
+func (e *IdentityError) Error() string {
+	return fmt.Sprintf("module: cannot determine the current %s: %s is not set and the legacy runtime is not available", e.What, e.Env)
+}
+
+// Is reports whether target is ErrNoIdentity.
+func (e *IdentityError) Is(target error) bool {
+	return target == ErrNoIdentity
+}
+
+// CurrentModule returns the name of the module of the running app, as
+// reported by appengine.ModuleNameErr.
+func CurrentModule(c context.Context) (string, error) {
+	m, err := appengine.ModuleNameErr(c)
+	if err != nil {
+		return "", &IdentityError{What: "module", Env: "GAE_SERVICE"}
+	}
+	return m, nil
+}
+
+// CurrentVersion returns the ID of the version of the running app, as
+// reported by appengine.VersionIDErr but without the minor version suffix, as
+// the Admin API expects it.
+func CurrentVersion(c context.Context) (string, error) {
+	v, err := appengine.VersionIDErr(c)
+	if err != nil {
+		return "", &IdentityError{What: "version", Env: "GAE_VERSION"}
+	}
+	return majorVersion(v), nil
+}
+
+// CurrentInstance returns the ID of the instance running the app, as reported
+// by appengine.InstanceIDErr.
+func CurrentInstance(c context.Context) (string, error) {
+	i, err := appengine.InstanceIDErr()
+	if err != nil {
+		return "", &IdentityError{What: "instance", Env: "GAE_INSTANCE"}
+	}
+	return i, nil
+}
@@ -0,0 +1,79 @@
+// Copyright 2026 Google LLC. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package module
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// clearIdentityEnv unsets the environment variables that report the identity
+// of the running app for the duration of the test.
+func clearIdentityEnv(t *testing.T) {
+	for _, env := range []string{
+		"GAE_ENV", "GAE_SERVICE", "GAE_VERSION", "GAE_INSTANCE", "GAE_DEPLOYMENT_ID",
+		"GAE_MODULE_NAME", "GAE_MODULE_VERSION", "GAE_MINOR_VERSION", "GAE_MODULE_INSTANCE",
+	} {
+		t.Setenv(env, "")
+	}
+}
+
+func currentIdentity(t *testing.T) (module, version, instance string) {
+	t.Helper()
+	c := context.Background()
+	var err error
+	if module, err = CurrentModule(c); err != nil {
+		t.Errorf("CurrentModule: %v", err)
+	}
+	if version, err = CurrentVersion(c); err != nil {
+		t.Errorf("CurrentVersion: %v", err)
+	}
+	if instance, err = CurrentInstance(c); err != nil {
+		t.Errorf("CurrentInstance: %v", err)
+	}
+	return module, version, instance
+}
+
+func TestCurrentIdentity_Env(t *testing.T) {
+	clearIdentityEnv(t)
+	t.Setenv("GAE_SERVICE", "worker")
+	t.Setenv("GAE_VERSION", "v2")
+	t.Setenv("GAE_INSTANCE", "inst-1")
+	// The second-generation variables take precedence.
+	t.Setenv("GAE_MODULE_NAME", "legacy")
+	m, v, i := currentIdentity(t)
+	if m != "worker" || v != "v2" || i != "inst-1" {
+		t.Errorf("identity = %q, %q, %q; want worker, v2, inst-1", m, v, i)
+	}
+}
+
+func TestCurrentIdentity_Legacy(t *testing.T) {
+	clearIdentityEnv(t)
+	t.Setenv("GAE_MODULE_NAME", "backend")
+	t.Setenv("GAE_MODULE_VERSION", "20240101t120000")
+	t.Setenv("GAE_MINOR_VERSION", "4567890")
+	t.Setenv("GAE_MODULE_INSTANCE", "3")
+	m, v, i := currentIdentity(t)
+	if m != "backend" || v != "20240101t120000" || i != "3" {
+		t.Errorf("identity = %q, %q, %q; want backend, 20240101t120000, 3", m, v, i)
+	}
+}
+
+func TestCurrentIdentity_Unavailable(t *testing.T) {
+	clearIdentityEnv(t)
+	c := context.Background()
+	for name, f := range map[string]func(context.Context) (string, error){
+		"CurrentModule":   CurrentModule,
+		"CurrentVersion":  CurrentVersion,
+		"CurrentInstance": CurrentInstance,
+	} {
+		_, err := f(c)
+		var ie *IdentityError
+		if !errors.Is(err, ErrNoIdentity) || !errors.As(err, &ie) {
+			t.Errorf("%s error = %v, want an IdentityError", name, err)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+// Copyright 2026 Google LLC. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package module
+
+import (
+	"context"
+	"fmt"
+
+	admin "google.golang.org/api/appengine/v1"
+)
+
+// DebugInstance enables debug mode on an instance of the given module.version,
+// allowing SSH access to it. If sshKey is not empty, it is a public SSH key in
+// the format "[USERNAME]:ssh-rsa [KEY_VALUE] [USERNAME]" to add to the
+// instance. If either module or version are the empty string it means the
+// default.
+//
+// Not every environment supports debug mode. When the Admin API rejects the
+// request, its error is returned annotated with the instance being debugged.
+// DebugInstance returns ErrInstanceNotFound if the instance does not exist.
+func DebugInstance(c context.Context, module, version, instanceID, sshKey string) (err error) {
+	c, done := startCall(c, "DebugInstance", module, version)
+	defer done(&err)
+	if err := validateNames(module, version); err != nil {
+		return err
+	}
+	if !useAdminAPI(c) {
+		return ErrNotSupported
+	}
+	if instanceID == "" {
+		return fmt.Errorf("module: instance ID must not be empty")
+	}
+	module, version, err = defaultModuleVersion(c, module, version)
+	if err != nil {
+		return err
+	}
+	projectID := getProjectID(c)
+	b, err := newAdminBackend(c, "debug_instance")
+	if err != nil {
+		return err
+	}
+	req := &admin.DebugInstanceRequest{SshKey: sshKey}
+	op, err := b.DebugInstance(c, projectID, module, version, instanceID, req)
+	if err != nil {
+		if isNotFound(err) && !rawErrors(c) {
+			return ErrInstanceNotFound
+		}
+		if rawErrors(c) {
+			return err
+		}
+		return fmt.Errorf("module: could not debug instance %s of %s.%s: %w", instanceID, version, module, err)
+	}
+	return waitOperation(c, b, projectID, op)
+}
@@ -0,0 +1,77 @@
+// Copyright 2026 Google LLC. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package module
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	admin "google.golang.org/api/appengine/v1"
+	"google.golang.org/api/googleapi"
+)
+
+func TestDebugInstance_AdminAPI(t *testing.T) {
+	const sshKey = "me:ssh-rsa AAAA me"
+	var debugged, polled bool
+	newAdminTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "POST" && r.URL.Path == "/v1/apps/test-project/services/my-module/versions/v1/instances/i1:debug":
+			debugged = true
+			var req admin.DebugInstanceRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				t.Errorf("decoding body: %v", err)
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if req.SshKey != sshKey {
+				t.Errorf("sshKey = %q, want %q", req.SshKey, sshKey)
+			}
+			json.NewEncoder(w).Encode(&admin.Operation{Name: "apps/test-project/operations/dbg"})
+		case r.Method == "GET" && r.URL.Path == "/v1/apps/test-project/operations/dbg":
+			polled = true
+			json.NewEncoder(w).Encode(&admin.Operation{Name: "apps/test-project/operations/dbg", Done: true})
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			http.Error(w, "unexpected", http.StatusBadRequest)
+		}
+	})
+	if err := DebugInstance(context.Background(), "my-module", "v1", "i1", sshKey); err != nil {
+		t.Fatalf("DebugInstance: %v", err)
+	}
+	if !debugged || !polled {
+		t.Errorf("debugged = %v, polled = %v; want both true", debugged, polled)
+	}
+}
+
+func TestDebugInstance_Errors(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		check  func(error) bool
+	}{
+		{"NotFound", http.StatusNotFound, func(err error) bool { return err == ErrInstanceNotFound }},
+		{"Rejected", http.StatusBadRequest, func(err error) bool {
+			var gErr *googleapi.Error
+			return errors.As(err, &gErr) && gErr.Code == http.StatusBadRequest && strings.Contains(err.Error(), "i1")
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newAdminTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(tt.status)
+				fmt.Fprintf(w, `{"error":{"code":%d,"message":"debug not allowed"}}`, tt.status)
+			})
+			if err := DebugInstance(context.Background(), "my-module", "v1", "i1", ""); !tt.check(err) {
+				t.Errorf("DebugInstance = %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+// Copyright 2026 Google LLC. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package module_test
+
+// The tests in this file run against a real application; see package
+// moduletest for how to enable them.
+
+import (
+	"testing"
+	"time"
+
+	"google.golang.org/appengine/module"
+	"google.golang.org/appengine/module/internal/moduletest"
+)
+
+func TestIntegration_Read(t *testing.T) {
+	env := moduletest.Setup(t)
+	c := env.Context
+
+	modules, err := module.List(c)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	moduletest.Contains(t, "List", modules, env.Service)
+
+	versions, err := module.Versions(c, env.Service)
+	if err != nil {
+		t.Fatalf("Versions: %v", err)
+	}
+	if len(versions) == 0 {
+		t.Fatalf("service %s has no versions", env.Service)
+	}
+	def, err := module.DefaultVersion(c, env.Service)
+	if err != nil {
+		t.Fatalf("DefaultVersion: %v", err)
+	}
+	moduletest.Contains(t, "Versions", versions, def)
+}
+
+func TestIntegration_SetNumInstances(t *testing.T) {
+	env := moduletest.Setup(t)
+	c := env.Context
+	v := env.PickVersion(t, module.WithScaling(module.ScalingManual))
+	n := env.RestoreNumInstances(t, v)
+
+	want := n + 1
+	if want > 3 {
+		want = 1
+	}
+	prev, changed, err := module.SetNumInstancesResult(c, env.Service, v, want)
+	if err != nil {
+		t.Fatalf("SetNumInstancesResult: %v", err)
+	}
+	if prev != n || !changed {
+		t.Errorf("SetNumInstancesResult = %d, %t; want %d, true", prev, changed, n)
+	}
+	moduletest.Eventually(t, 2*time.Minute, func() error {
+		got, err := module.NumInstances(c, env.Service, v)
+		if err != nil {
+			return err
+		}
+		return moduletest.Equal("NumInstances", got, want)
+	})
+}
+
+func TestIntegration_StartStop(t *testing.T) {
+	env := moduletest.Setup(t)
+	c := env.Context
+	v := env.PickVersion(t, module.WithScaling(module.ScalingManual))
+	def, err := module.DefaultVersion(c, env.Service)
+	if err != nil {
+		t.Fatalf("DefaultVersion: %v", err)
+	}
+	if v == def {
+		t.Skipf("version %s receives the traffic of service %s", v, env.Service)
+	}
+	env.RestoreServingStatus(t, v)
+
+	for _, step := range []struct {
+		f    func() error
+		want string
+	}{
+		{func() error { return module.Stop(c, env.Service, v) }, "STOPPED"},
+		{func() error { return module.Start(c, env.Service, v) }, "SERVING"},
+	} {
+		// Start and Stop wait for their operation to complete.
+		if err := step.f(); err != nil {
+			t.Fatalf("changing version %s to %s: %v", v, step.want, err)
+		}
+		got, err := module.ServingStatus(c, env.Service, v)
+		if err != nil {
+			t.Fatalf("ServingStatus: %v", err)
+		}
+		if got != step.want {
+			t.Errorf("ServingStatus = %s, want %s", got, step.want)
+		}
+	}
+}
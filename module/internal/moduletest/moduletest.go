@@ -0,0 +1,171 @@
+// Copyright 2026 Google LLC. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+// Package moduletest supports the integration tests of package module, which
+// run against the Admin API of a real App Engine application.
+//
+// The tests are opt-in: they are skipped unless TEST_APPENGINE_PROJECT names
+// the project to use and application default credentials are available. They
+// operate on a disposable service of that project, named by
+// TEST_APPENGINE_SERVICE ("integration" by default), which must already have
+// at least one version deployed. The tests change the serving status and the
+// number of instances of its versions, and restore them when they finish;
+// they never change traffic splits, and refuse to use the "default" service.
+package moduletest // import "google.golang.org/appengine/module/internal/moduletest"
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"os"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2/google"
+
+	"google.golang.org/appengine/module"
+)
+
+// DefaultService is the service used when TEST_APPENGINE_SERVICE is not set.
+const DefaultService = "integration"
+
+// Env is the environment of an integration test.
+type Env struct {
+	Project string
+	Service string
+
+	// Context carries a module.Backend for Project; calls of package module
+	// made with it use the Admin API.
+	Context context.Context
+}
+
+// Setup returns the environment of an integration test, or skips t if the
+// test is not configured. The context of the environment is canceled when t
+// finishes.
+func Setup(t testing.TB) *Env {
+	t.Helper()
+	project := os.Getenv("TEST_APPENGINE_PROJECT")
+	if project == "" {
+		t.Skip("TEST_APPENGINE_PROJECT is not set")
+	}
+	service := os.Getenv("TEST_APPENGINE_SERVICE")
+	if service == "" {
+		service = DefaultService
+	}
+	if service == "default" {
+		t.Fatal("TEST_APPENGINE_SERVICE must name a disposable service, not the default service")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	t.Cleanup(cancel)
+	if _, err := google.FindDefaultCredentials(ctx, "https://www.googleapis.com/auth/cloud-platform"); err != nil {
+		t.Skipf("no application default credentials: %v", err)
+	}
+	return &Env{
+		Project: project,
+		Service: service,
+		Context: module.WithBackend(ctx, module.NewBackend(project)),
+	}
+}
+
+// PickVersion returns a randomly chosen version of the service that matches
+// filters, so that repeated runs spread over the versions deployed for the
+// tests. It skips t if no version matches.
+func (e *Env) PickVersion(t testing.TB, filters ...module.VersionFilter) string {
+	t.Helper()
+	versions, err := module.VersionsFiltered(e.Context, e.Service, filters...)
+	if err != nil {
+		t.Fatalf("VersionsFiltered(%q): %v", e.Service, err)
+	}
+	if len(versions) == 0 {
+		t.Skipf("service %s has no version suitable for this test", e.Service)
+	}
+	v := versions[rand.Intn(len(versions))]
+	t.Logf("using version %s of service %s", v, e.Service)
+	return v
+}
+
+// RestoreServingStatus records the serving status of version and restores it
+// when t finishes.
+func (e *Env) RestoreServingStatus(t testing.TB, version string) {
+	t.Helper()
+	status, err := module.ServingStatus(e.Context, e.Service, version)
+	if err != nil {
+		t.Fatalf("ServingStatus(%q, %q): %v", e.Service, version, err)
+	}
+	t.Cleanup(func() {
+		// The test context may be done by now.
+		ctx, cancel := e.cleanupContext()
+		defer cancel()
+		var err error
+		if status == "SERVING" {
+			err = module.Start(ctx, e.Service, version)
+		} else {
+			err = module.Stop(ctx, e.Service, version)
+		}
+		if err != nil {
+			t.Errorf("restoring serving status %s of version %s: %v", status, version, err)
+		}
+	})
+}
+
+// RestoreNumInstances records the number of instances of version, which must
+// use manual scaling, and restores it when t finishes.
+func (e *Env) RestoreNumInstances(t testing.TB, version string) int {
+	t.Helper()
+	n, err := module.NumInstances(e.Context, e.Service, version)
+	if err != nil {
+		t.Fatalf("NumInstances(%q, %q): %v", e.Service, version, err)
+	}
+	t.Cleanup(func() {
+		ctx, cancel := e.cleanupContext()
+		defer cancel()
+		if _, _, err := module.SetNumInstancesResult(ctx, e.Service, version, n, module.SkipIfUnchanged(true)); err != nil {
+			t.Errorf("restoring %d instances of version %s: %v", n, version, err)
+		}
+	})
+	return n
+}
+
+// cleanupContext returns a context for restoring state after a test.
+func (e *Env) cleanupContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	return module.WithBackend(ctx, module.NewBackend(e.Project)), cancel
+}
+
+// Eventually calls f until it succeeds or timeout elapses, waiting a few
+// seconds between calls, and fails t with the last error in the latter case.
+// It absorbs the delay with which the Admin API reflects some changes.
+func Eventually(t testing.TB, timeout time.Duration, f func() error) {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for {
+		err := f()
+		if err == nil {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("condition not met after %v: %v", timeout, err)
+		}
+		time.Sleep(5 * time.Second)
+	}
+}
+
+// Contains fails t unless list contains s.
+func Contains(t testing.TB, what string, list []string, s string) {
+	t.Helper()
+	for _, e := range list {
+		if e == s {
+			return
+		}
+	}
+	t.Errorf("%s = %v, want it to contain %q", what, list, s)
+}
+
+// Equal returns an error unless got equals want.
+func Equal(what string, got, want interface{}) error {
+	if got != want {
+		return fmt.Errorf("%s = %v, want %v", what, got, want)
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+// Copyright 2026 Google LLC. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package module
+
+import (
+	"context"
+	"errors"
+)
+
+// Done is returned by Iterator.Next when the iteration is complete.
+var Done = errors.New("module: no more items in iterator")
+
+// Iterator iterates over the names of modules or versions. Pages of results
+// are fetched from the backend lazily, as Next needs them. An Iterator must not
+// be used by multiple goroutines at once.
+type Iterator struct {
+	// fetch returns the page of results identified by token, along with the
+	// token of the following page, which is empty for the last page.
+	fetch func(token string) (items []string, next string, err error)
+
+	buf   []string
+	token string
+	done  bool
+	err   error
+}
+
+// Next returns the next result. Its second return value is Done if there are
+// no more results. Once Next returns a non-nil error, all subsequent calls
+// return the same error.
+func (it *Iterator) Next() (string, error) {
+	for len(it.buf) == 0 {
+		if it.err != nil {
+			return "", it.err
+		}
+		if it.done {
+			return "", Done
+		}
+		items, next, err := it.fetch(it.token)
+		if err != nil {
+			it.err = err
+			return "", err
+		}
+		it.buf, it.token = items, next
+		it.done = next == ""
+	}
+	s := it.buf[0]
+	it.buf = it.buf[1:]
+	return s, nil
+}
+
+// all drains the iterator and returns the remaining results.
+func (it *Iterator) all() ([]string, error) {
+	var items []string
+	for {
+		s, err := it.Next()
+		if err == Done {
+			return items, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, s)
+	}
+}
+
+// legacyIterator returns an Iterator over the single result of f.
+func legacyIterator(f func() ([]string, error)) *Iterator {
+	return &Iterator{fetch: func(string) ([]string, string, error) {
+		items, err := f()
+		return items, "", err
+	}}
+}
+
+// ListIterator returns an iterator over the names of modules belonging to
+// this application.
+func ListIterator(c context.Context) *Iterator {
+	if !useAdminAPI(c) {
+		return legacyIterator(func() ([]string, error) { return ListLegacy(c) })
+	}
+	projectID := getProjectID(c)
+	var b adminBackend
+	return &Iterator{fetch: func(token string) (_ []string, _ string, err error) {
+		c, done := startCall(c, "ListIterator", "", "")
+		defer done(&err)
+		if err := c.Err(); err != nil {
+			return nil, "", err
+		}
+		if b == nil {
+			var err error
+			if b, err = newAdminBackend(c, "get_modules"); err != nil {
+				return nil, "", err
+			}
+		}
+		resp, err := b.ListServices(c, projectID, token, "services/id", "nextPageToken")
+		if err != nil {
+			return nil, "", err
+		}
+		var modules []string
+		for _, s := range resp.Services {
+			modules = append(modules, s.Id)
+		}
+		return modules, resp.NextPageToken, nil
+	}}
+}
+
+// VersionsIterator returns an iterator over the names of the versions that
+// belong to the specified module. If module is the empty string, it means the
+// default module.
+func VersionsIterator(c context.Context, module string) *Iterator {
+	if err := validateNames(module, ""); err != nil {
+		return &Iterator{err: err}
+	}
+	if !useAdminAPI(c) {
+		return legacyIterator(func() ([]string, error) { return VersionsLegacy(c, module) })
+	}
+	if module == "" {
+		module = getModuleorDefault(c)
+	}
+	projectID := getProjectID(c)
+	var b adminBackend
+	return &Iterator{fetch: func(token string) (_ []string, _ string, err error) {
+		c, done := startCall(c, "VersionsIterator", module, "")
+		defer done(&err)
+		if err := c.Err(); err != nil {
+			return nil, "", err
+		}
+		if b == nil {
+			var err error
+			if b, err = newAdminBackend(c, "get_versions"); err != nil {
+				return nil, "", err
+			}
+		}
+		resp, err := b.ListVersions(c, projectID, module, token, "", "versions/id", "nextPageToken")
+		if err != nil {
+			return nil, "", err
+		}
+		var versions []string
+		for _, v := range resp.Versions {
+			versions = append(versions, v.Id)
+		}
+		return versions, resp.NextPageToken, nil
+	}}
+}
@@ -0,0 +1,127 @@
+// Copyright 2026 Google LLC. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package module
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+
+	admin "google.golang.org/api/appengine/v1"
+	"google.golang.org/appengine/internal/aetesting"
+	pb "google.golang.org/appengine/internal/modules"
+)
+
+// pagedVersionsServer serves the versions of "backend" in pages of two and
+// returns a function reporting the page tokens requested so far.
+func pagedVersionsServer(t *testing.T) func() []string {
+	var tokens []string
+	pages := map[string]*admin.ListVersionsResponse{
+		"":   {Versions: []*admin.Version{{Id: "v1"}, {Id: "v2"}}, NextPageToken: "p2"},
+		"p2": {Versions: []*admin.Version{{Id: "v3"}, {Id: "v4"}}, NextPageToken: "p3"},
+		"p3": {Versions: []*admin.Version{{Id: "v5"}}},
+	}
+	newAdminTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		token := r.URL.Query().Get("pageToken")
+		tokens = append(tokens, token)
+		json.NewEncoder(w).Encode(pages[token])
+	})
+	return func() []string { return tokens }
+}
+
+func TestVersionsIterator_Lazy(t *testing.T) {
+	tokens := pagedVersionsServer(t)
+	it := VersionsIterator(context.Background(), "backend")
+	if len(tokens()) != 0 {
+		t.Fatalf("requests issued before Next: %q", tokens())
+	}
+	var got []string
+	for i := 0; i < 2; i++ {
+		v, err := it.Next()
+		if err != nil {
+			t.Fatalf("Next: %v", err)
+		}
+		got = append(got, v)
+	}
+	if want := []string{""}; !reflect.DeepEqual(tokens(), want) {
+		t.Errorf("after first page, tokens = %q, want %q", tokens(), want)
+	}
+	v, err := it.Next()
+	if err != nil {
+		t.Fatalf("Next: %v", err)
+	}
+	got = append(got, v)
+	if want := []string{"", "p2"}; !reflect.DeepEqual(tokens(), want) {
+		t.Errorf("after second page, tokens = %q, want %q", tokens(), want)
+	}
+	for {
+		v, err := it.Next()
+		if err == Done {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Next: %v", err)
+		}
+		got = append(got, v)
+	}
+	if want := []string{"v1", "v2", "v3", "v4", "v5"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("versions = %v, want %v", got, want)
+	}
+	if _, err := it.Next(); err != Done {
+		t.Errorf("Next after end = %v, want Done", err)
+	}
+}
+
+func TestVersionsIterator_EarlyStop(t *testing.T) {
+	tokens := pagedVersionsServer(t)
+	it := VersionsIterator(context.Background(), "backend")
+	if v, err := it.Next(); err != nil || v != "v1" {
+		t.Fatalf("Next = %q, %v; want v1", v, err)
+	}
+	if want := []string{""}; !reflect.DeepEqual(tokens(), want) {
+		t.Errorf("tokens = %q, want %q", tokens(), want)
+	}
+}
+
+func TestListIterator_AdminAPI(t *testing.T) {
+	newAdminTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("pageToken") == "" {
+			json.NewEncoder(w).Encode(&admin.ListServicesResponse{Services: []*admin.Service{{Id: "default"}}, NextPageToken: "x"})
+			return
+		}
+		json.NewEncoder(w).Encode(&admin.ListServicesResponse{Services: []*admin.Service{{Id: "api"}}})
+	})
+	got, err := ListIterator(context.Background()).all()
+	if err != nil {
+		t.Fatalf("ListIterator: %v", err)
+	}
+	if want := []string{"default", "api"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("modules = %v, want %v", got, want)
+	}
+}
+
+func TestListIterator_Legacy(t *testing.T) {
+	c := aetesting.FakeSingleContext(t, "modules", "GetModules", func(req *pb.GetModulesRequest, res *pb.GetModulesResponse) error {
+		res.Module = []string{"default", "mod1"}
+		return nil
+	})
+	it := ListIterator(c)
+	var got []string
+	for {
+		m, err := it.Next()
+		if err == Done {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Next: %v", err)
+		}
+		got = append(got, m)
+	}
+	if want := []string{"default", "mod1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("modules = %v, want %v", got, want)
+	}
+}
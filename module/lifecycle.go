@@ -0,0 +1,141 @@
+// Copyright 2026 Google LLC. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package module
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Lifecycle is the lifecycle state of the running instance, as recorded by
+// the handlers installed with RegisterLifecycleHandlers.
+type Lifecycle string
+
+const (
+	LifecycleUnstarted Lifecycle = "unstarted"  // no lifecycle request was received yet
+	LifecycleWarmingUp Lifecycle = "warming up" // the warmup hook is running
+	LifecycleRunning   Lifecycle = "running"    // the instance was started or warmed up
+	LifecycleStopping  Lifecycle = "stopping"   // the drain hook is running
+	LifecycleStopped   Lifecycle = "stopped"    // the drain hook returned
+)
+
+// stopBudget is the time the platform allows the /_ah/stop handler to
+// respond in, less a margin for writing the response.
+var stopBudget = 28 * time.Second
+
+// LifecycleHooks are the callbacks of the handlers installed by
+// RegisterLifecycleHandlers. Each may be nil.
+type LifecycleHooks struct {
+	// Start is called for /_ah/start, which manual and basic scaling
+	// instances receive when they start. An error fails the request, and the
+	// platform then stops the instance.
+	Start func(r *http.Request) error
+
+	// Warmup is called for /_ah/warmup, which instances receive before
+	// serving traffic if warmup requests are enabled. An error fails the
+	// request and leaves the state as it was before the warmup, so an
+	// instance started by /_ah/start stays LifecycleRunning.
+	Warmup func(r *http.Request) error
+
+	// Drain is called for /_ah/stop, which manual and basic scaling instances
+	// receive before they are stopped. Its context expires after
+	// DrainTimeout.
+	Drain func(ctx context.Context)
+
+	// DrainTimeout bounds the time Drain is given. If zero or longer than the
+	// platform's 30 second budget for /_ah/stop, the handler responds by the
+	// end of that budget.
+	DrainTimeout time.Duration
+}
+
+var lifecycle struct {
+	sync.Mutex
+	state Lifecycle
+}
+
+// LifecycleState returns the lifecycle state of the running instance, as
+// recorded by the handlers installed with RegisterLifecycleHandlers.
+func LifecycleState() Lifecycle {
+	lifecycle.Lock()
+	defer lifecycle.Unlock()
+	if lifecycle.state == "" {
+		return LifecycleUnstarted
+	}
+	return lifecycle.state
+}
+
+func setLifecycleState(s Lifecycle) {
+	lifecycle.Lock()
+	lifecycle.state = s
+	lifecycle.Unlock()
+}
+
+// RegisterLifecycleHandlers installs handlers for the /_ah/start, /_ah/stop
+// and /_ah/warmup requests of App Engine on mux. They call the hooks, record
+// the lifecycle state reported by LifecycleState and respond with 200 unless
+// a hook fails.
+//
+// The /_ah/stop handler responds within the platform's budget even if Drain
+// overruns DrainTimeout; the overrun is logged and the state remains
+// LifecycleStopping until Drain returns.
+func RegisterLifecycleHandlers(mux *http.ServeMux, hooks LifecycleHooks) {
+	mux.HandleFunc("/_ah/start", func(w http.ResponseWriter, r *http.Request) {
+		if hooks.Start != nil {
+			if err := hooks.Start(r); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+		setLifecycleState(LifecycleRunning)
+	})
+	mux.HandleFunc("/_ah/warmup", func(w http.ResponseWriter, r *http.Request) {
+		if hooks.Warmup != nil {
+			// An instance that /_ah/start has already started stays
+			// running, even if the warmup fails.
+			prev := LifecycleState()
+			if prev == LifecycleUnstarted {
+				setLifecycleState(LifecycleWarmingUp)
+			}
+			if err := hooks.Warmup(r); err != nil {
+				setLifecycleState(prev)
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+		setLifecycleState(LifecycleRunning)
+	})
+	mux.HandleFunc("/_ah/stop", func(w http.ResponseWriter, r *http.Request) {
+		setLifecycleState(LifecycleStopping)
+		if hooks.Drain == nil {
+			setLifecycleState(LifecycleStopped)
+			return
+		}
+		timeout := hooks.DrainTimeout
+		if timeout <= 0 || timeout > stopBudget {
+			timeout = stopBudget
+		}
+		// The drain outlives the request if it overruns.
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			hooks.Drain(ctx)
+			setLifecycleState(LifecycleStopped)
+		}()
+		select {
+		case <-done:
+		case <-ctx.Done():
+			l := currentLogger()
+			if l == nil {
+				l = log.Default()
+			}
+			l.Printf("module: drain hook overran its %v deadline; responding to /_ah/stop", timeout)
+		}
+	})
+}
@@ -0,0 +1,164 @@
+// Copyright 2026 Google LLC. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package module
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// resetLifecycle clears the recorded lifecycle state for the duration of a
+// test.
+func resetLifecycle(t *testing.T) {
+	setLifecycleState("")
+	t.Cleanup(func() { setLifecycleState("") })
+}
+
+func serveLifecycle(mux *http.ServeMux, path string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+	return w
+}
+
+func TestLifecycleHandlers(t *testing.T) {
+	resetLifecycle(t)
+	var drained bool
+	mux := http.NewServeMux()
+	RegisterLifecycleHandlers(mux, LifecycleHooks{
+		Drain: func(ctx context.Context) {
+			if _, ok := ctx.Deadline(); !ok {
+				t.Error("drain context has no deadline")
+			}
+			drained = true
+		},
+	})
+
+	if got := LifecycleState(); got != LifecycleUnstarted {
+		t.Errorf("initial LifecycleState = %q, want %q", got, LifecycleUnstarted)
+	}
+	if w := serveLifecycle(mux, "/_ah/start"); w.Code != http.StatusOK {
+		t.Errorf("/_ah/start returned %d, want 200", w.Code)
+	}
+	if got := LifecycleState(); got != LifecycleRunning {
+		t.Errorf("LifecycleState after start = %q, want %q", got, LifecycleRunning)
+	}
+	if w := serveLifecycle(mux, "/_ah/stop"); w.Code != http.StatusOK {
+		t.Errorf("/_ah/stop returned %d, want 200", w.Code)
+	}
+	if !drained {
+		t.Error("drain hook did not run")
+	}
+	if got := LifecycleState(); got != LifecycleStopped {
+		t.Errorf("LifecycleState after stop = %q, want %q", got, LifecycleStopped)
+	}
+}
+
+func TestLifecycleHandlers_Warmup(t *testing.T) {
+	resetLifecycle(t)
+	fail := true
+	mux := http.NewServeMux()
+	RegisterLifecycleHandlers(mux, LifecycleHooks{
+		Warmup: func(*http.Request) error {
+			if got := LifecycleState(); got != LifecycleWarmingUp {
+				t.Errorf("LifecycleState during warmup = %q, want %q", got, LifecycleWarmingUp)
+			}
+			if fail {
+				return errors.New("cache not loaded")
+			}
+			return nil
+		},
+	})
+	if w := serveLifecycle(mux, "/_ah/warmup"); w.Code != http.StatusInternalServerError || !strings.Contains(w.Body.String(), "cache not loaded") {
+		t.Errorf("failed /_ah/warmup returned %d %q, want 500 with the hook's error", w.Code, w.Body)
+	}
+	if got := LifecycleState(); got != LifecycleUnstarted {
+		t.Errorf("LifecycleState after failed warmup = %q, want %q", got, LifecycleUnstarted)
+	}
+	fail = false
+	if w := serveLifecycle(mux, "/_ah/warmup"); w.Code != http.StatusOK {
+		t.Errorf("/_ah/warmup returned %d, want 200", w.Code)
+	}
+	if got := LifecycleState(); got != LifecycleRunning {
+		t.Errorf("LifecycleState after warmup = %q, want %q", got, LifecycleRunning)
+	}
+}
+
+func TestLifecycleHandlers_WarmupAfterStart(t *testing.T) {
+	resetLifecycle(t)
+	var starts int
+	mux := http.NewServeMux()
+	RegisterLifecycleHandlers(mux, LifecycleHooks{
+		Start: func(*http.Request) error {
+			starts++
+			return nil
+		},
+		Warmup: func(*http.Request) error {
+			if got := LifecycleState(); got != LifecycleRunning {
+				t.Errorf("LifecycleState during warmup after start = %q, want %q", got, LifecycleRunning)
+			}
+			return errors.New("cache not loaded")
+		},
+	})
+	if w := serveLifecycle(mux, "/_ah/start"); w.Code != http.StatusOK {
+		t.Errorf("/_ah/start returned %d, want 200", w.Code)
+	}
+	if w := serveLifecycle(mux, "/_ah/warmup"); w.Code != http.StatusInternalServerError {
+		t.Errorf("failed /_ah/warmup returned %d, want 500", w.Code)
+	}
+	if got := LifecycleState(); got != LifecycleRunning {
+		t.Errorf("LifecycleState after failed warmup after start = %q, want %q", got, LifecycleRunning)
+	}
+	if starts != 1 {
+		t.Errorf("start hook ran %d times, want once", starts)
+	}
+}
+
+func TestLifecycleHandlers_DrainOverrun(t *testing.T) {
+	resetLifecycle(t)
+	defer func(d time.Duration) { stopBudget = d }(stopBudget)
+	stopBudget = 100 * time.Millisecond
+	l := &captureLogger{}
+	SetLogger(l)
+	defer SetLogger(nil)
+
+	release := make(chan bool)
+	drained := make(chan bool)
+	mux := http.NewServeMux()
+	RegisterLifecycleHandlers(mux, LifecycleHooks{
+		// Longer than the budget: the budget applies.
+		DrainTimeout: time.Hour,
+		Drain: func(ctx context.Context) {
+			<-ctx.Done()
+			<-release
+			close(drained)
+		},
+	})
+	start := time.Now()
+	if w := serveLifecycle(mux, "/_ah/stop"); w.Code != http.StatusOK {
+		t.Errorf("/_ah/stop returned %d, want 200", w.Code)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("/_ah/stop responded after %v, want about %v", elapsed, stopBudget)
+	}
+	if got := l.String(); !strings.Contains(got, "overran") {
+		t.Errorf("log = %q, want the overrun logged", got)
+	}
+	if got := LifecycleState(); got != LifecycleStopping {
+		t.Errorf("LifecycleState during overrun = %q, want %q", got, LifecycleStopping)
+	}
+	close(release)
+	<-drained
+	for i := 0; i < 100 && LifecycleState() != LifecycleStopped; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := LifecycleState(); got != LifecycleStopped {
+		t.Errorf("LifecycleState after drain = %q, want %q", got, LifecycleStopped)
+	}
+}
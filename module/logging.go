@@ -0,0 +1,188 @@
+// Copyright 2026 Google LLC. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package module
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+
+	admin "google.golang.org/api/appengine/v1"
+	"google.golang.org/api/googleapi"
+)
+
+// Logger is the interface used for debug logging of Admin API requests.
+// *log.Logger implements it.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+var debugLogger struct {
+	sync.Mutex
+	l Logger
+}
+
+// SetLogger makes the package log every Admin API request it makes to l: the
+// API method, resource path, update mask, request body, response status and
+// the name of any operation started. Authorization headers and the values of
+// environment variables are never logged. Passing nil disables logging.
+//
+// If no logger is set and the MODULES_DEBUG environment variable is true,
+// requests are logged with the standard library's log package.
+func SetLogger(l Logger) {
+	debugLogger.Lock()
+	debugLogger.l = l
+	debugLogger.Unlock()
+}
+
+// currentLogger returns the logger to use, or nil if logging is disabled.
+func currentLogger() Logger {
+	debugLogger.Lock()
+	l := debugLogger.l
+	debugLogger.Unlock()
+	if debug, _ := envBool("MODULES_DEBUG"); l == nil && debug {
+		return log.Default()
+	}
+	return l
+}
+
+// withLogging returns b wrapped so that its requests are logged, if logging
+// is enabled.
+func withLogging(b adminBackend) adminBackend {
+	l := currentLogger()
+	if l == nil {
+		return b
+	}
+	return &loggingBackend{b: b, l: l}
+}
+
+// loggingBackend is an adminBackend that logs the requests it forwards to b.
+type loggingBackend struct {
+	b adminBackend
+	l Logger
+}
+
+// log logs a request for the resource at path. body is the request body, if
+// any, and op the operation it started, if any.
+func (l *loggingBackend) log(method, path, updateMask string, body interface{}, op *admin.Operation, err error) {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "module: Admin API %s %s", method, path)
+	if updateMask != "" {
+		fmt.Fprintf(&sb, " updateMask=%s", updateMask)
+	}
+	if body != nil {
+		b, jerr := json.Marshal(body)
+		if jerr != nil {
+			b = []byte("<unencodable>")
+		}
+		fmt.Fprintf(&sb, " body=%s", b)
+	}
+	var gErr *googleapi.Error
+	switch {
+	case err == nil:
+		fmt.Fprintf(&sb, " status=%d", http.StatusOK)
+	case errors.As(err, &gErr):
+		fmt.Fprintf(&sb, " status=%d", gErr.Code)
+	default:
+		fmt.Fprintf(&sb, " error=%q", err.Error())
+	}
+	if op != nil && op.Name != "" {
+		fmt.Fprintf(&sb, " operation=%s", op.Name)
+	}
+	l.l.Printf("%s", sb.String())
+}
+
+// sanitizeVersion returns a copy of v that is safe to log.
+func sanitizeVersion(v *admin.Version) *admin.Version {
+	if v == nil || len(v.EnvVariables) == 0 {
+		return v
+	}
+	cp := *v
+	cp.EnvVariables = make(map[string]string, len(v.EnvVariables))
+	for k := range v.EnvVariables {
+		cp.EnvVariables[k] = "REDACTED"
+	}
+	return &cp
+}
+
+func servicePath(project, module string) string {
+	return "apps/" + project + "/services/" + module
+}
+
+func versionPath(project, module, version string) string {
+	return servicePath(project, module) + "/versions/" + version
+}
+
+func (l *loggingBackend) GetApplication(ctx context.Context, project string, fields ...googleapi.Field) (*admin.Application, error) {
+	a, err := l.b.GetApplication(ctx, project, fields...)
+	l.log("GET", "apps/"+project, "", nil, nil, err)
+	return a, err
+}
+
+func (l *loggingBackend) ListServices(ctx context.Context, project, pageToken string, fields ...googleapi.Field) (*admin.ListServicesResponse, error) {
+	r, err := l.b.ListServices(ctx, project, pageToken, fields...)
+	l.log("GET", "apps/"+project+"/services", "", nil, nil, err)
+	return r, err
+}
+
+func (l *loggingBackend) GetService(ctx context.Context, project, module string, fields ...googleapi.Field) (*admin.Service, error) {
+	s, err := l.b.GetService(ctx, project, module, fields...)
+	l.log("GET", servicePath(project, module), "", nil, nil, err)
+	return s, err
+}
+
+func (l *loggingBackend) PatchService(ctx context.Context, project, module string, s *admin.Service, updateMask string, migrateTraffic bool) (*admin.Operation, error) {
+	op, err := l.b.PatchService(ctx, project, module, s, updateMask, migrateTraffic)
+	l.log("PATCH", servicePath(project, module), updateMask, s, op, err)
+	return op, err
+}
+
+func (l *loggingBackend) ListVersions(ctx context.Context, project, module, pageToken, view string, fields ...googleapi.Field) (*admin.ListVersionsResponse, error) {
+	r, err := l.b.ListVersions(ctx, project, module, pageToken, view, fields...)
+	l.log("GET", servicePath(project, module)+"/versions", "", nil, nil, err)
+	return r, err
+}
+
+func (l *loggingBackend) GetVersion(ctx context.Context, project, module, version, view string, fields ...googleapi.Field) (*admin.Version, error) {
+	v, err := l.b.GetVersion(ctx, project, module, version, view, fields...)
+	l.log("GET", versionPath(project, module, version), "", nil, nil, err)
+	return v, err
+}
+
+func (l *loggingBackend) PatchVersion(ctx context.Context, project, module, version string, v *admin.Version, updateMask string) (*admin.Operation, error) {
+	op, err := l.b.PatchVersion(ctx, project, module, version, v, updateMask)
+	l.log("PATCH", versionPath(project, module, version), updateMask, sanitizeVersion(v), op, err)
+	return op, err
+}
+
+func (l *loggingBackend) DeleteVersion(ctx context.Context, project, module, version string) (*admin.Operation, error) {
+	op, err := l.b.DeleteVersion(ctx, project, module, version)
+	l.log("DELETE", versionPath(project, module, version), "", nil, op, err)
+	return op, err
+}
+
+func (l *loggingBackend) ListInstances(ctx context.Context, project, module, version, pageToken string) (*admin.ListInstancesResponse, error) {
+	r, err := l.b.ListInstances(ctx, project, module, version, pageToken)
+	l.log("GET", versionPath(project, module, version)+"/instances", "", nil, nil, err)
+	return r, err
+}
+
+func (l *loggingBackend) DebugInstance(ctx context.Context, project, module, version, instance string, req *admin.DebugInstanceRequest) (*admin.Operation, error) {
+	op, err := l.b.DebugInstance(ctx, project, module, version, instance, req)
+	// The request only carries an SSH key, which is not logged.
+	l.log("POST", versionPath(project, module, version)+"/instances/"+instance+":debug", "", nil, op, err)
+	return op, err
+}
+
+func (l *loggingBackend) GetOperation(ctx context.Context, project, operation string) (*admin.Operation, error) {
+	op, err := l.b.GetOperation(ctx, project, operation)
+	l.log("GET", "apps/"+project+"/operations/"+operation, "", nil, op, err)
+	return op, err
+}
@@ -0,0 +1,120 @@
+// Copyright 2026 Google LLC. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package module
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	admin "google.golang.org/api/appengine/v1"
+	"google.golang.org/api/option"
+)
+
+type captureLogger struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+func (l *captureLogger) Printf(format string, v ...interface{}) {
+	l.mu.Lock()
+	l.lines = append(l.lines, fmt.Sprintf(format, v...))
+	l.mu.Unlock()
+}
+
+func (l *captureLogger) String() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return strings.Join(l.lines, "\n")
+}
+
+func TestLoggerRedactsEnvVariables(t *testing.T) {
+	l := &captureLogger{}
+	SetLogger(l)
+	defer SetLogger(nil)
+
+	c := WithBackend(context.Background(), &Backend{
+		project: "test-project",
+		client: &stubBackend{
+			getVersion: func(module, version string) (*admin.Version, error) {
+				return &admin.Version{EnvVariables: map[string]string{"API_KEY": "s3cret-old"}}, nil
+			},
+			patchVersion: func(module, version string, v *admin.Version, updateMask string) (*admin.Operation, error) {
+				return &admin.Operation{Name: "apps/test-project/operations/op1", Done: true}, nil
+			},
+		},
+	})
+	if err := UpdateEnvVariables(c, "mod", "v1", map[string]string{"DB_PASSWORD": "s3cret-new"}, nil); err != nil {
+		t.Fatalf("UpdateEnvVariables: %v", err)
+	}
+
+	got := l.String()
+	for _, secret := range []string{"s3cret-old", "s3cret-new"} {
+		if strings.Contains(got, secret) {
+			t.Errorf("log contains env variable value %q:\n%s", secret, got)
+		}
+	}
+	for _, want := range []string{
+		"GET apps/test-project/services/mod/versions/v1 status=200",
+		"PATCH apps/test-project/services/mod/versions/v1 updateMask=envVariables",
+		`"DB_PASSWORD":"REDACTED"`,
+		"operation=apps/test-project/operations/op1",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("log does not contain %q:\n%s", want, got)
+		}
+	}
+}
+
+// bearerTransport adds an Authorization header to every request.
+type bearerTransport struct{}
+
+func (bearerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer s3cret-token")
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestLoggerNoAuthorization(t *testing.T) {
+	l := &captureLogger{}
+	SetLogger(l)
+	defer SetLogger(nil)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "" {
+			t.Error("request has no Authorization header")
+		}
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"error": {"code": 404, "message": "Not Found"}}`)
+	}))
+	defer srv.Close()
+	c := WithBackend(context.Background(), NewBackend("test-project",
+		option.WithEndpoint(srv.URL+"/"), option.WithHTTPClient(&http.Client{Transport: bearerTransport{}})))
+	NumInstances(c, "mod", "v1")
+
+	got := l.String()
+	if !strings.Contains(got, "GET apps/test-project/services/mod/versions/v1 status=404") {
+		t.Errorf("log = %q, want the failed request with its status", got)
+	}
+	if strings.Contains(got, "s3cret-token") || strings.Contains(strings.ToLower(got), "authorization") {
+		t.Errorf("log contains credentials: %q", got)
+	}
+}
+
+func TestLoggerDisabled(t *testing.T) {
+	SetLogger(nil)
+	t.Setenv("MODULES_DEBUG", "")
+	if l := currentLogger(); l != nil {
+		t.Errorf("currentLogger() = %v, want nil", l)
+	}
+	t.Setenv("MODULES_DEBUG", "true")
+	if l := currentLogger(); l == nil {
+		t.Error("currentLogger() = nil with MODULES_DEBUG=true, want the standard logger")
+	}
+}
@@ -7,13 +7,22 @@ Package module provides functions for interacting with modules.
 
 The appengine package contains functions that report the identity of the app,
 including the module name.
+
+The functions of this package are safe for concurrent use by multiple
+goroutines.
+
+For development and tests away from App Engine, package modulefake provides
+an in-memory application that can be attached to a context with WithBackend.
 */
 package module // import "google.golang.org/appengine/module"
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
 	"os"
+	"sort"
 	"strings"
 
 	"github.com/golang/protobuf/proto"
@@ -26,97 +35,164 @@ import (
 	admin "google.golang.org/api/appengine/v1"
 )
 
-func getProjectID() string {
-	projectID := os.Getenv("GOOGLE_CLOUD_PROJECT")
-	
-	if (projectID == "") {
-		appID := os.Getenv("GAE_APPLICATION")
-		if appID == ""{
-			appID = os.Getenv("APPLICATION_ID")
-		}
-		if i := strings.Index(appID, "~"); i != -1 {
-			projectID = appID[i+1:]
-		}
-		// Strip domain prefix (e.g., "google.com:project-id" -> "project-id")
-		if i := strings.Index(projectID, ":"); i != -1 {
-			projectID = projectID[i+1:]
-		}
-		return projectID
+// adminServiceOptions holds extra client options used when constructing the
+// Admin API service. Tests use it to point the service at a local server.
+var adminServiceOptions []option.ClientOption
+
+// getProjectID returns the project set by WithProjectContext or the project
+// of the Backend attached to c, if any, or else the project of the running
+// application.
+func getProjectID(c context.Context) string {
+	if p := projectFromContext(c); p != "" {
+		return p
 	}
-	
+	if b := backendFromContext(c); b != nil && b.project != "" {
+		return b.project
+	}
+	if id := internal.BackgroundIdentityFromContext(c); id != nil && id.ProjectID != "" {
+		return id.ProjectID
+	}
+	if p := os.Getenv("GOOGLE_CLOUD_PROJECT"); p != "" {
+		return p
+	}
+	appID := os.Getenv("GAE_APPLICATION")
+	if appID == "" {
+		appID = os.Getenv("APPLICATION_ID")
+	}
+	projectID, _ := appengine.ParseFullAppID(appID)
 	return projectID
 }
 
-func getModuleorDefault() string {
-	module := os.Getenv("GAE_SERVICE")
+func getModuleorDefault(c context.Context) string {
+	return appengine.ModuleName(c)
+}
+
+// defaultModuleVersion substitutes the defaults for an empty module or
+// version. As in the legacy API, an empty version means the version of the
+// running app for the current module, and the default version of any other
+// module. The "latest" alias is resolved if c enables it; see ResolveAliases.
+func defaultModuleVersion(c context.Context, module, version string) (string, string, error) {
+	current := getModuleorDefault(c)
 	if module == "" {
-	    module = "default"
+		module = current
+	}
+	if version == latestAlias && aliasesEnabled(c) {
+		version, err := LatestVersion(c, module)
+		if err != nil {
+			return "", "", err
+		}
+		return module, version, nil
 	}
-	return module
+	if version != "" {
+		return module, version, nil
+	}
+	if module == current {
+		version, err := CurrentVersion(c)
+		if err != nil {
+			return "", "", err
+		}
+		return module, version, nil
+	}
+	version, err := DefaultVersion(c, module)
+	if err != nil {
+		return "", "", err
+	}
+	return module, version, nil
+}
+
+// majorVersion strips the minor version from a version ID of the form
+// "major.minor", such as "20240101t120000.4567890".
+func majorVersion(version string) string {
+	if i := strings.Index(version, "."); i != -1 {
+		return version[:i]
+	}
+	return version
 }
 
-// useAdminAPI checks if the Admin API implementation is enabled via environment variable.
-func useAdminAPI() bool {
-	return strings.ToLower(os.Getenv("MODULES_USE_ADMIN_API")) == "true"
+// useAdminAPI checks if the Admin API implementation is enabled, either by a
+// Backend or project attached to c, via environment variable, or because the
+// app runs on a second-generation runtime.
+func useAdminAPI(c context.Context) bool {
+	if backendFromContext(c) != nil || projectFromContext(c) != "" {
+		return true
+	}
+	// Background contexts cannot make legacy calls.
+	if internal.BackgroundIdentityFromContext(c) != nil {
+		return true
+	}
+	return BackendSelection().Backend == "admin"
 }
 
 // getService initializes the App Engine Admin API service.
-func getAdminService(ctx context.Context, methodName string) (*admin.APIService, error) {
+func getAdminService(ctx context.Context, methodName string, extra ...option.ClientOption) (*admin.APIService, error) {
 	userAgent := "appengine-modules-api-go-client/" + methodName
-	svc, err := admin.NewService(ctx, option.WithUserAgent(userAgent))
+	opts := append([]option.ClientOption{option.WithUserAgent(userAgent)}, adminServiceOptions...)
+	opts = append(opts, extra...)
+	if endpoint, ok := internal.AdminEndpointOverride(ctx); ok {
+		opts = append(opts, option.WithEndpoint(endpoint), option.WithoutAuthentication())
+	}
+	svc, err := admin.NewService(ctx, opts...)
 	if err != nil {
-		return nil, fmt.Errorf("module: could not create admin service: %v", err)
+		return nil, &adminInitError{err}
 	}
 	
 	return svc, nil
 }
 
 // List returns the names of modules belonging to this application.
-func List(c context.Context) ([]string, error) {
-	if (!useAdminAPI()) {
-		return ListLegacy(c)
-	}
-	projectID := getProjectID()
-	svc, err := getAdminService(c, "get_modules")
-	if err != nil {
-		return nil, err
-	}
-	resp, err := svc.Apps.Services.List(projectID).Do()
-	if err != nil {
-		return nil, err
-	}
-	var modules []string
-	for _, s := range resp.Services {
-		modules = append(modules, s.Id)
-	}
-	return modules, nil
+func List(c context.Context) (_ []string, err error) {
+	c, done := startCall(c, "List", "", "")
+	defer done(&err)
+	return cachedStrings(newCacheKey(c, "list", ""), func() ([]string, error) {
+		if (!useAdminAPI(c)) {
+			return ListLegacy(c)
+		}
+		modules, err := ListIterator(c).all()
+		if fallBack(c, err) {
+			return ListLegacy(c)
+		}
+		return modules, err
+	})
 }
 
-func ListLegacy(c context.Context) ([]string, error) {
+func ListLegacy(c context.Context) (_ []string, err error) {
+	c, done := startLegacyCall(c, "ListLegacy", "", "")
+	defer done(&err)
 	req := &pb.GetModulesRequest{}
 	res := &pb.GetModulesResponse{}
-	err := internal.Call(c, "modules", "GetModules", req, res)
+	err = legacyCall(c, "GetModules", false, req, res)
 	return res.Module, err
 }
 
 // NumInstances returns the number of instances of the given module/version.
 // If either argument is the empty string it means the default.
-func NumInstances(c context.Context, module, version string) (int, error) {
-	if (!useAdminAPI()) {
+func NumInstances(c context.Context, module, version string) (_ int, err error) {
+	c, done := startCall(c, "NumInstances", module, version)
+	defer done(&err)
+	if err := validateNames(module, version); err != nil {
+		return 0, err
+	}
+	if (!useAdminAPI(c)) {
 		return NumInstancesLegacy(c, module, version)
 	}
-	if module == "" {
-		module = getModuleorDefault()
+	n, err := numInstancesAdmin(c, module, version)
+	if fallBack(c, err) {
+		return NumInstancesLegacy(c, module, version)
 	}
-	if version == "" {
-		version = appengine.VersionID(c)
+	return n, err
+}
+
+func numInstancesAdmin(c context.Context, module, version string) (int, error) {
+	module, version, err := defaultModuleVersion(c, module, version)
+	if err != nil {
+		return 0, err
 	}
-	projectID := getProjectID()
-	svc, err := getAdminService(c, "get_num_instances")
+	projectID := getProjectID(c)
+	b, err := newAdminBackend(c, "get_num_instances")
 	if err != nil {
 		return 0, err
 	}
-	v, err := svc.Apps.Services.Versions.Get(projectID, module, version).Do()
+	v, err := b.GetVersion(c, projectID, module, version, "", "manualScaling")
 	if err != nil {
 		return 0, err
 	}
@@ -126,7 +202,12 @@ func NumInstances(c context.Context, module, version string) (int, error) {
 	return 0, fmt.Errorf("module: version %s is not using manual scaling", version)
 }
 
-func NumInstancesLegacy(c context.Context, module, version string) (int, error) {
+func NumInstancesLegacy(c context.Context, module, version string) (_ int, err error) {
+	c, done := startLegacyCall(c, "NumInstancesLegacy", module, version)
+	defer done(&err)
+	if err := validateNames(module, version); err != nil {
+		return 0, err
+	}
 	req := &pb.GetNumInstancesRequest{}
 	if module != "" {
 		req.Module = &module
@@ -136,7 +217,7 @@ func NumInstancesLegacy(c context.Context, module, version string) (int, error)
 	}
 	res := &pb.GetNumInstancesResponse{}
 
-	if err := internal.Call(c, "modules", "GetNumInstances", req, res); err != nil {
+	if err := legacyCall(c, "GetNumInstances", false, req, res); err != nil {
 		return 0, err
 	}
 	return int(*res.Instances), nil
@@ -145,18 +226,38 @@ func NumInstancesLegacy(c context.Context, module, version string) (int, error)
 // SetNumInstances sets the number of instances of the given module.version to the
 // specified value. If either module or version are the empty string it means the
 // default.
-func SetNumInstances(c context.Context, module, version string, instances int) error {
-	if (!useAdminAPI()) {
+func SetNumInstances(c context.Context, module, version string, instances int) (err error) {
+	c, done := startCall(c, "SetNumInstances", module, version)
+	defer done(&err)
+	if err := validateNames(module, version); err != nil {
+		return err
+	}
+	return setNumInstances(c, module, version, instances, false)
+}
+
+// setNumInstances sets the number of instances of module.version on the
+// selected backend. On the Admin API, it waits for the change to take effect
+// if wait is set.
+func setNumInstances(c context.Context, module, version string, instances int, wait bool) error {
+	if !useAdminAPI(c) {
 		return SetNumInstancesLegacy(c, module, version, instances)
 	}
-	if module == "" {
-		module = getModuleorDefault()
+	err := setNumInstancesAdmin(c, module, version, instances, wait)
+	if fallBack(c, err) {
+		return SetNumInstancesLegacy(c, module, version, instances)
 	}
-	if version == "" {
-		version = appengine.VersionID(c)
+	return err
+}
+
+// setNumInstancesAdmin patches the number of instances of module.version and,
+// if wait is set, waits for the change to take effect.
+func setNumInstancesAdmin(c context.Context, module, version string, instances int, wait bool) error {
+	module, version, err := defaultModuleVersion(c, module, version)
+	if err != nil {
+		return err
 	}
-	projectID := getProjectID()
-	svc, err1 := getAdminService(c, "set_num_instances")
+	projectID := getProjectID(c)
+	b, err1 := newAdminBackend(c, "set_num_instances")
 	if err1 != nil {
 		return err1
 	}
@@ -165,12 +266,16 @@ func SetNumInstances(c context.Context, module, version string, instances int) e
 			Instances: int64(instances),
 		},
 	}
-	_, err2 := svc.Apps.Services.Versions.Patch(projectID, module, version, update).
-		UpdateMask("manualScaling.instances").Do()
-	return err2
+	_, err = patchVersion(c, b, projectID, module, version, update, []string{"manualScaling.instances"}, wait)
+	return err
 }
 
-func SetNumInstancesLegacy(c context.Context, module, version string, instances int) error {
+func SetNumInstancesLegacy(c context.Context, module, version string, instances int) (err error) {
+	c, done := startLegacyCall(c, "SetNumInstancesLegacy", module, version)
+	defer done(&err)
+	if err := validateNames(module, version); err != nil {
+		return err
+	}
 	req := &pb.SetNumInstancesRequest{}
 	if module != "" {
 		req.Module = &module
@@ -180,59 +285,76 @@ func SetNumInstancesLegacy(c context.Context, module, version string, instances
 	}
 	req.Instances = proto.Int64(int64(instances))
 	res := &pb.SetNumInstancesResponse{}
-	return internal.Call(c, "modules", "SetNumInstances", req, res)
+	return legacyCall(c, "SetNumInstances", true, req, res)
 }
 
 // Versions returns the names of the versions that belong to the specified module.
 // If module is the empty string, it means the default module.
-func Versions(c context.Context, module string) ([]string, error) {
-	if (!useAdminAPI()) {
-		return VersionsLegacy(c, module)
-	}
-	if module == "" {
-		module = getModuleorDefault()
-	}
-	projectID := getProjectID()
-	svc, err := getAdminService(c, "get_versions")
-	if err != nil {
+func Versions(c context.Context, module string) (_ []string, err error) {
+	c, done := startCall(c, "Versions", module, "")
+	defer done(&err)
+	if err := validateNames(module, ""); err != nil {
 		return nil, err
 	}
-	resp, err := svc.Apps.Services.Versions.List(projectID, module).Do()
-	if err != nil {
-		return nil, err
-	}
-	var versions []string
-	for _, v := range resp.Versions {
-		versions = append(versions, v.Id)
-	}
-	return versions, nil
+	return cachedStrings(newCacheKey(c, "versions", module), func() ([]string, error) {
+		if (!useAdminAPI(c)) {
+			return VersionsLegacy(c, module)
+		}
+		versions, err := VersionsIterator(c, module).all()
+		if fallBack(c, err) {
+			return VersionsLegacy(c, module)
+		}
+		return versions, err
+	})
 }
 
-func VersionsLegacy(c context.Context, module string) ([]string, error) {
+func VersionsLegacy(c context.Context, module string) (_ []string, err error) {
+	c, done := startLegacyCall(c, "VersionsLegacy", module, "")
+	defer done(&err)
+	if err := validateNames(module, ""); err != nil {
+		return nil, err
+	}
 	req := &pb.GetVersionsRequest{}
 	if module != "" {
 		req.Module = &module
 	}
 	res := &pb.GetVersionsResponse{}
-	err := internal.Call(c, "modules", "GetVersions", req, res)
+	err = legacyCall(c, "GetVersions", false, req, res)
 	return res.GetVersion(), err
 }
 
 // DefaultVersion returns the default version of the specified module.
 // If module is the empty string, it means the default module.
-func DefaultVersion(c context.Context, module string) (string, error) {
-	if (!useAdminAPI()) {
-		return DefaultVersionLegacy(c, module)
+func DefaultVersion(c context.Context, module string) (_ string, err error) {
+	c, done := startCall(c, "DefaultVersion", module, "")
+	defer done(&err)
+	if err := validateNames(module, ""); err != nil {
+		return "", err
 	}
+	v, err := cached(newCacheKey(c, "defaultVersion", module), func() (interface{}, error) {
+		if (!useAdminAPI(c)) {
+			return DefaultVersionLegacy(c, module)
+		}
+		v, err := defaultVersionAdmin(c, module)
+		if fallBack(c, err) {
+			return DefaultVersionLegacy(c, module)
+		}
+		return v, err
+	})
+	s, _ := v.(string)
+	return s, err
+}
+
+func defaultVersionAdmin(c context.Context, module string) (string, error) {
 	if module == "" {
-		module = getModuleorDefault()
+		module = getModuleorDefault(c)
 	}
-	projectID := getProjectID()
-	svc, err := getAdminService(c, "get_default_version")
+	projectID := getProjectID(c)
+	b, err := newAdminBackend(c, "get_default_version")
 	if err != nil {
 		return "", err
 	}
-	service, err := svc.Apps.Services.Get(projectID, module).Context(c).Do()
+	service, err := b.GetService(c, projectID, module, "split/allocations")
 	if err != nil {
 		if gErr, ok := err.(*googleapi.Error); ok && gErr.Code == 404 {
 			return "", fmt.Errorf("module: Module '%s' not found", module)
@@ -240,31 +362,9 @@ func DefaultVersion(c context.Context, module string) (string, error) {
 		return "", err
 	}
 
-	// Logic to determine default version from traffic split allocations
 	var retVersion string
-	maxAlloc := -1.0
-
-	if service.Split != nil && service.Split.Allocations != nil {
-		allocations := service.Split.Allocations // map[string]float64
-
-		for version, allocation := range allocations {
-			// If a version has 100% traffic, it is the default
-			if allocation == 1.0 {
-				retVersion = version
-				break
-			}
-
-			// Find version with maximum allocation
-			if allocation > maxAlloc {
-				retVersion = version
-				maxAlloc = allocation
-			} else if allocation == maxAlloc {
-				// Tie-breaker: lexicographically smaller version name
-				if version < retVersion {
-					retVersion = version
-				}
-			}
-		}
+	if service.Split != nil {
+		retVersion = defaultFromAllocations(service.Split.Allocations)
 	}
 
 	// Equivalent to if retVersion is None: raise InvalidVersionError
@@ -275,26 +375,88 @@ func DefaultVersion(c context.Context, module string) (string, error) {
 	return retVersion, nil
 }
 
-func DefaultVersionLegacy(c context.Context, module string) (string, error) {
+// defaultFromAllocations returns the version with the largest traffic
+// allocation, or the empty string if there are no allocations. Versions with
+// equal allocations are ordered by name, so that the result does not depend
+// on map iteration order.
+func defaultFromAllocations(allocations map[string]float64) string {
+	versions := make([]string, 0, len(allocations))
+	for version := range allocations {
+		versions = append(versions, version)
+	}
+	sort.Strings(versions)
+
+	var retVersion string
+	maxAlloc := -1.0
+	for _, version := range versions {
+		allocation := allocations[version]
+		// If a version has 100% traffic, it is the default
+		if allocation == 1.0 {
+			return version
+		}
+		// Strictly greater, so that the first of tied versions wins
+		if allocation > maxAlloc {
+			retVersion = version
+			maxAlloc = allocation
+		}
+	}
+	return retVersion
+}
+
+func DefaultVersionLegacy(c context.Context, module string) (_ string, err error) {
+	c, done := startLegacyCall(c, "DefaultVersionLegacy", module, "")
+	defer done(&err)
+	if err := validateNames(module, ""); err != nil {
+		return "", err
+	}
 	req := &pb.GetDefaultVersionRequest{}
 	if module != "" {
 		req.Module = &module
 	}
 	res := &pb.GetDefaultVersionResponse{}
-	err := internal.Call(c, "modules", "GetDefaultVersion", req, res)
+	err = legacyCall(c, "GetDefaultVersion", false, req, res)
 	return res.GetVersion(), err
 }
 
+// ServingOption configures a call to Start or Stop.
+type ServingOption func(*servingOptions)
+
+type servingOptions struct {
+	strict bool
+}
+
+// StrictState reports whether Start and Stop should fail when the version is
+// already in the requested state. By default, starting a serving version or
+// stopping a stopped one succeeds without doing anything, as it did with the
+// legacy modules API.
+func StrictState(strict bool) ServingOption {
+	return func(o *servingOptions) { o.strict = strict }
+}
+
 // Start starts the specified version of the specified module.
 // If either module or version are the empty string, it means the default.
-func Start(c context.Context, module, version string) error {
-	if (!useAdminAPI()) {
+func Start(c context.Context, module, version string, opts ...ServingOption) (err error) {
+	c, done := startCall(c, "Start", module, version)
+	defer done(&err)
+	if err := validateNames(module, version); err != nil {
+		return err
+	}
+	if (!useAdminAPI(c)) {
+		return StartLegacy(c, module, version)
+	}
+	err = setServingStatus(c, module, version, "SERVING", opts)
+	if fallBack(c, err) {
 		return StartLegacy(c, module, version)
 	}
-	return setServingStatus(c, module, version, "SERVING")
+	return err
 }
 
-func StartLegacy(c context.Context, module, version string) error {
+func StartLegacy(c context.Context, module, version string) (err error) {
+	c, done := startLegacyCall(c, "StartLegacy", module, version)
+	defer done(&err)
+	if err := validateNames(module, version); err != nil {
+		return err
+	}
 	req := &pb.StartModuleRequest{}
 	if module != "" {
 		req.Module = &module
@@ -303,19 +465,33 @@ func StartLegacy(c context.Context, module, version string) error {
 		req.Version = &version
 	}
 	res := &pb.StartModuleResponse{}
-	return internal.Call(c, "modules", "StartModule", req, res)
+	return legacyCall(c, "StartModule", true, req, res)
 }
 
 // Stop stops the specified version of the specified module.
 // If either module or version are the empty string, it means the default.
-func Stop(c context.Context, module, version string) error {
-	if (!useAdminAPI()) {
+func Stop(c context.Context, module, version string, opts ...ServingOption) (err error) {
+	c, done := startCall(c, "Stop", module, version)
+	defer done(&err)
+	if err := validateNames(module, version); err != nil {
+		return err
+	}
+	if (!useAdminAPI(c)) {
+		return StopLegacy(c, module, version)
+	}
+	err = setServingStatus(c, module, version, "STOPPED", opts)
+	if fallBack(c, err) {
 		return StopLegacy(c, module, version)
 	}
-	return setServingStatus(c, module, version, "STOPPED")
+	return err
 }
 
-func StopLegacy(c context.Context, module, version string) error {
+func StopLegacy(c context.Context, module, version string) (err error) {
+	c, done := startLegacyCall(c, "StopLegacy", module, version)
+	defer done(&err)
+	if err := validateNames(module, version); err != nil {
+		return err
+	}
 	req := &pb.StopModuleRequest{}
 	if module != "" {
 		req.Module = &module
@@ -324,31 +500,79 @@ func StopLegacy(c context.Context, module, version string) error {
 		req.Version = &version
 	}
 	res := &pb.StopModuleResponse{}
-	return internal.Call(c, "modules", "StopModule", req, res)
+	return legacyCall(c, "StopModule", true, req, res)
+}
+
+// ServingStatus returns the serving status of the specified version of the
+// specified module, "SERVING" or "STOPPED". If either module or version are
+// the empty string, it means the default. ServingStatus returns
+// ErrVersionNotFound if the version does not exist.
+//
+// The legacy modules API does not report serving status, so ServingStatus
+// returns ErrNotSupported on the legacy backend.
+func ServingStatus(c context.Context, module, version string) (_ string, err error) {
+	c, done := startCall(c, "ServingStatus", module, version)
+	defer done(&err)
+	if err := validateNames(module, version); err != nil {
+		return "", err
+	}
+	if !useAdminAPI(c) {
+		return "", ErrNotSupported
+	}
+	module, version, err = defaultModuleVersion(c, module, version)
+	if err != nil {
+		return "", err
+	}
+	projectID := getProjectID(c)
+	b, err := newAdminBackend(c, "get_serving_status")
+	if err != nil {
+		return "", err
+	}
+	v, err := b.GetVersion(c, projectID, module, version, "", "servingStatus")
+	if err != nil {
+		return "", err
+	}
+	return v.ServingStatus, nil
 }
 
-func setServingStatus(c context.Context, module, version, status string) error {
-	projectID := getProjectID()
+func setServingStatus(c context.Context, module, version, status string, opts []ServingOption) error {
+	var o servingOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	projectID := getProjectID(c)
 	methodName := ""
 	if status == "SERVING" {
 		methodName = "start_version"
 	} else if status == "STOPPED" {
 		methodName = "stop_version"
 	}
-	svc, err := getAdminService(c, methodName)
+	b, err := newAdminBackend(c, methodName)
 	if err != nil {
 		return err
 	}
-	if module == "" {
-		module = getModuleorDefault()
-	}
-	if version == "" {
-		version = appengine.VersionID(c)
+	module, version, err = defaultModuleVersion(c, module, version)
+	if err != nil {
+		return err
 	}
 	update := &admin.Version{
 		ServingStatus: status,
 	}
-	_, err = svc.Apps.Services.Versions.Patch(projectID, module, version, update).
-		UpdateMask("servingStatus").Do()
+	op, err := patchVersion(c, b, projectID, module, version, update, []string{"servingStatus"}, true)
+	if err != nil && op == nil && !o.strict && alreadyInState(c, b, projectID, module, version, status, err) {
+		return nil
+	}
 	return err
 }
+
+// alreadyInState reports whether err, returned by a servingStatus update, is
+// the Admin API's FAILED_PRECONDITION error for a version that already has the
+// requested status.
+func alreadyInState(c context.Context, b adminBackend, projectID, module, version, status string, err error) bool {
+	var gErr *googleapi.Error
+	if !errors.As(err, &gErr) || gErr.Code != http.StatusBadRequest {
+		return false
+	}
+	v, err := b.GetVersion(c, projectID, module, version, "", "servingStatus")
+	return err == nil && v.ServingStatus == status
+}
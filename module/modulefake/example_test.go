@@ -0,0 +1,27 @@
+// Copyright 2026 Google LLC. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package modulefake_test
+
+import (
+	"context"
+	"fmt"
+
+	"google.golang.org/appengine/module"
+	"google.golang.org/appengine/module/modulefake"
+)
+
+func Example() {
+	f := modulefake.New("my-project")
+	f.AddVersion("default", "v1", modulefake.ManualScaling(3))
+	ctx := module.WithBackend(context.Background(), f.Backend())
+
+	if err := module.SetNumInstances(ctx, "default", "v1", 5); err != nil {
+		fmt.Println(err)
+		return
+	}
+	n, err := module.NumInstances(ctx, "default", "v1")
+	fmt.Println(n, err)
+	// Output: 5 <nil>
+}
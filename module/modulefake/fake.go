@@ -0,0 +1,644 @@
+// Copyright 2026 Google LLC. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+/*
+Package modulefake provides an in-memory App Engine Admin API for use with
+the module package, for development and tests without an App Engine runtime,
+credentials or network access.
+
+A Fake holds the services and versions of one application. It is seeded
+programmatically and attached to a context with module.WithBackend:
+
+	f := modulefake.New("my-project")
+	f.AddVersion("default", "v1", modulefake.ManualScaling(3))
+	ctx = module.WithBackend(ctx, f.Backend())
+	n, err := module.NumInstances(ctx, "default", "v1") // 3
+
+Every call of the module package made with ctx is then served by f, which
+validates requests the way the Admin API does: unknown services and versions
+are not found, manual scaling settings can only be changed on versions that
+use manual scaling, and so on. Changes complete immediately.
+*/
+package modulefake // import "google.golang.org/appengine/module/modulefake"
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	admin "google.golang.org/api/appengine/v1"
+	"google.golang.org/api/option"
+
+	"google.golang.org/appengine/module"
+)
+
+// endpoint is the base URL of the Admin API clients of a Fake. Requests to it
+// never leave the process.
+const endpoint = "http://modulefake.invalid/"
+
+// epoch is the creation time of the first version added without CreatedAt.
+var epoch = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// Fake is an in-memory App Engine application. It is safe for concurrent use.
+type Fake struct {
+	project string
+
+	mu         sync.Mutex
+	services   map[string]*serviceState
+	operations map[string]*admin.Operation
+	created    int // number of versions added without CreatedAt
+}
+
+type serviceState struct {
+	versions map[string]*versionState
+	split    map[string]float64
+	shardBy  string
+}
+
+type versionState struct {
+	v     *admin.Version
+	debug map[string]bool // instances in debug mode
+}
+
+// New returns an empty application with the given project ID.
+func New(project string) *Fake {
+	return &Fake{
+		project:    project,
+		services:   make(map[string]*serviceState),
+		operations: make(map[string]*admin.Operation),
+	}
+}
+
+// Backend returns a module.Backend whose calls are served by f. Attach it to
+// a context with module.WithBackend.
+func (f *Fake) Backend() *module.Backend {
+	client := &http.Client{Transport: transport{f}}
+	return module.NewBackend(f.project, option.WithHTTPClient(client), option.WithEndpoint(endpoint))
+}
+
+// VersionOption configures a version added by AddVersion.
+type VersionOption func(*admin.Version)
+
+// ManualScaling makes the version use manual scaling with the given number of
+// instances.
+func ManualScaling(instances int) VersionOption {
+	return func(v *admin.Version) {
+		v.AutomaticScaling, v.BasicScaling = nil, nil
+		v.ManualScaling = &admin.ManualScaling{Instances: int64(instances)}
+	}
+}
+
+// BasicScaling makes the version use basic scaling with the given maximum
+// number of instances and idle timeout.
+func BasicScaling(maxInstances int, idleTimeout time.Duration) VersionOption {
+	return func(v *admin.Version) {
+		v.AutomaticScaling, v.ManualScaling = nil, nil
+		v.BasicScaling = &admin.BasicScaling{MaxInstances: int64(maxInstances)}
+		if idleTimeout > 0 {
+			v.BasicScaling.IdleTimeout = fmt.Sprintf("%ds", int64(idleTimeout/time.Second))
+		}
+	}
+}
+
+// Stopped makes the version stopped instead of serving.
+func Stopped() VersionOption {
+	return func(v *admin.Version) { v.ServingStatus = "STOPPED" }
+}
+
+// Flexible makes the version run in the flexible environment instead of the
+// standard environment.
+func Flexible() VersionOption {
+	return func(v *admin.Version) { v.Env = "flexible" }
+}
+
+// CreatedAt sets the creation time of the version. Versions added without it
+// are created one minute apart, in the order they are added, starting on
+// January 1, 2026.
+func CreatedAt(t time.Time) VersionOption {
+	return func(v *admin.Version) { v.CreateTime = t.UTC().Format(time.RFC3339) }
+}
+
+// InstanceClass sets the instance class of the version, e.g. "F2".
+func InstanceClass(class string) VersionOption {
+	return func(v *admin.Version) { v.InstanceClass = class }
+}
+
+// AddVersion adds a serving version of the given service, creating the
+// service if necessary. Versions use automatic scaling in the standard
+// environment unless configured otherwise by opts. The first version of a
+// service receives all of its traffic. Adding an existing version replaces
+// it.
+func (f *Fake) AddVersion(service, version string, opts ...VersionOption) {
+	v := &admin.Version{
+		Id:               version,
+		Name:             fmt.Sprintf("apps/%s/services/%s/versions/%s", f.project, service, version),
+		Env:              "standard",
+		Runtime:          "go122",
+		ServingStatus:    "SERVING",
+		AutomaticScaling: &admin.AutomaticScaling{},
+	}
+	for _, opt := range opts {
+		opt(v)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if v.CreateTime == "" {
+		v.CreateTime = epoch.Add(time.Duration(f.created) * time.Minute).Format(time.RFC3339)
+		f.created++
+	}
+	s := f.services[service]
+	if s == nil {
+		s = &serviceState{versions: make(map[string]*versionState), split: map[string]float64{version: 1}}
+		f.services[service] = s
+	}
+	s.versions[version] = &versionState{v: v, debug: make(map[string]bool)}
+}
+
+// SetTraffic replaces the traffic split of the given service. It panics if
+// the service does not exist.
+func (f *Fake) SetTraffic(service string, allocations map[string]float64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	s := f.services[service]
+	if s == nil {
+		panic("modulefake: unknown service " + service)
+	}
+	s.split = make(map[string]float64, len(allocations))
+	for v, a := range allocations {
+		s.split[v] = a
+	}
+}
+
+// Traffic returns the traffic split of the given service, or nil if the
+// service does not exist.
+func (f *Fake) Traffic(service string) map[string]float64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	s := f.services[service]
+	if s == nil {
+		return nil
+	}
+	split := make(map[string]float64, len(s.split))
+	for v, a := range s.split {
+		split[v] = a
+	}
+	return split
+}
+
+// Version returns a copy of the current state of the given version, and
+// whether it exists.
+func (f *Fake) Version(service, version string) (*admin.Version, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	vs, err := f.version(service, version)
+	if err != nil {
+		return nil, false
+	}
+	return copyVersion(vs.v), true
+}
+
+// transport is an http.RoundTripper that serves requests with a Fake.
+type transport struct {
+	f *Fake
+}
+
+func (t transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		defer req.Body.Close()
+	}
+	w := httptest.NewRecorder()
+	t.f.serveHTTP(w, req)
+	resp := w.Result()
+	resp.Request = req
+	return resp, nil
+}
+
+// apiError is an error response of the Admin API.
+type apiError struct {
+	code int
+	msg  string
+}
+
+func (e *apiError) Error() string { return e.msg }
+
+func errorf(code int, format string, args ...interface{}) error {
+	return &apiError{code: code, msg: fmt.Sprintf(format, args...)}
+}
+
+// statuses maps the HTTP status codes used by Fake to canonical error codes.
+var statuses = map[int]string{
+	http.StatusBadRequest:       "INVALID_ARGUMENT",
+	http.StatusNotFound:         "NOT_FOUND",
+	http.StatusMethodNotAllowed: "UNIMPLEMENTED",
+}
+
+func (f *Fake) serveHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	resp, err := f.route(req)
+	if err != nil {
+		e, ok := err.(*apiError)
+		if !ok {
+			e = &apiError{code: http.StatusBadRequest, msg: err.Error()}
+		}
+		w.WriteHeader(e.code)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": map[string]interface{}{"code": e.code, "message": e.msg, "status": statuses[e.code]},
+		})
+		return
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// route dispatches req, an Admin API request of the form
+// "/v1/apps/{app}/...", to the method that serves it.
+func (f *Fake) route(req *http.Request) (interface{}, error) {
+	path := strings.TrimPrefix(req.URL.Path, "/v1/apps/")
+	if path == req.URL.Path {
+		return nil, errorf(http.StatusNotFound, "unknown path %s", req.URL.Path)
+	}
+	p := strings.Split(path, "/")
+	if p[0] != f.project {
+		return nil, errorf(http.StatusNotFound, "Application %s not found", p[0])
+	}
+	var body []byte
+	if req.Body != nil {
+		var err error
+		if body, err = ioutil.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+	}
+	q := req.URL.Query()
+	mask := q.Get("updateMask")
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch {
+	case len(p) == 1 && req.Method == "GET":
+		return f.application(), nil
+	case len(p) == 3 && p[1] == "operations" && req.Method == "GET":
+		if op := f.operations[p[2]]; op != nil {
+			return op, nil
+		}
+		return nil, errorf(http.StatusNotFound, "Operation %s not found", p[2])
+	case len(p) == 2 && p[1] == "services" && req.Method == "GET":
+		return f.listServices(), nil
+	case len(p) == 3 && p[1] == "services" && req.Method == "GET":
+		return f.getService(p[2])
+	case len(p) == 3 && p[1] == "services" && req.Method == "PATCH":
+		return f.patchService(p[2], body, mask, q.Get("migrateTraffic") == "true")
+	case len(p) == 4 && p[1] == "services" && p[3] == "versions" && req.Method == "GET":
+		return f.listVersions(p[2])
+	case len(p) == 5 && p[1] == "services" && p[3] == "versions":
+		switch req.Method {
+		case "GET":
+			vs, err := f.version(p[2], p[4])
+			if err != nil {
+				return nil, err
+			}
+			return vs.v, nil
+		case "PATCH":
+			return f.patchVersion(p[2], p[4], body, mask)
+		case "DELETE":
+			return f.deleteVersion(p[2], p[4])
+		}
+	case len(p) == 6 && p[1] == "services" && p[3] == "versions" && p[5] == "instances" && req.Method == "GET":
+		return f.listInstances(p[2], p[4])
+	case len(p) == 7 && p[1] == "services" && p[3] == "versions" && p[5] == "instances" &&
+		strings.HasSuffix(p[6], ":debug") && req.Method == "POST":
+		return f.debugInstance(p[2], p[4], strings.TrimSuffix(p[6], ":debug"))
+	}
+	return nil, errorf(http.StatusMethodNotAllowed, "%s %s is not supported", req.Method, req.URL.Path)
+}
+
+func (f *Fake) application() *admin.Application {
+	return &admin.Application{
+		Id:              f.project,
+		Name:            "apps/" + f.project,
+		LocationId:      "us-central",
+		DefaultHostname: f.project + ".appspot.com",
+		DefaultBucket:   f.project + ".appspot.com",
+		ServingStatus:   "SERVING",
+		DatabaseType:    "CLOUD_DATASTORE_COMPATIBILITY",
+	}
+}
+
+// done records a completed operation and returns it.
+func (f *Fake) done() *admin.Operation {
+	id := fmt.Sprintf("op-%d", len(f.operations)+1)
+	op := &admin.Operation{Name: fmt.Sprintf("apps/%s/operations/%s", f.project, id), Done: true}
+	f.operations[id] = op
+	return op
+}
+
+func (f *Fake) service(service string) (*serviceState, error) {
+	s := f.services[service]
+	if s == nil {
+		return nil, errorf(http.StatusNotFound, "Service %s not found", service)
+	}
+	return s, nil
+}
+
+func (f *Fake) version(service, version string) (*versionState, error) {
+	s, err := f.service(service)
+	if err != nil {
+		return nil, err
+	}
+	vs := s.versions[version]
+	if vs == nil {
+		return nil, errorf(http.StatusNotFound, "Version %s of service %s not found", version, service)
+	}
+	return vs, nil
+}
+
+func (f *Fake) serviceResource(id string, s *serviceState) *admin.Service {
+	return &admin.Service{
+		Id:    id,
+		Name:  fmt.Sprintf("apps/%s/services/%s", f.project, id),
+		Split: &admin.TrafficSplit{Allocations: s.split, ShardBy: s.shardBy},
+	}
+}
+
+func (f *Fake) listServices() *admin.ListServicesResponse {
+	res := &admin.ListServicesResponse{}
+	for _, id := range f.serviceIDs() {
+		res.Services = append(res.Services, f.serviceResource(id, f.services[id]))
+	}
+	return res
+}
+
+func (f *Fake) getService(service string) (*admin.Service, error) {
+	s, err := f.service(service)
+	if err != nil {
+		return nil, err
+	}
+	return f.serviceResource(service, s), nil
+}
+
+func (f *Fake) patchService(service string, body []byte, mask string, migrate bool) (*admin.Operation, error) {
+	s, err := f.service(service)
+	if err != nil {
+		return nil, err
+	}
+	var setSplit, setShardBy bool
+	for _, p := range strings.Split(mask, ",") {
+		switch p {
+		case "split":
+			setSplit, setShardBy = true, true
+		case "split.allocations":
+			setSplit = true
+		case "split.shardBy":
+			setShardBy = true
+		default:
+			return nil, errorf(http.StatusBadRequest, "Unsupported update mask %q", mask)
+		}
+	}
+	var update admin.Service
+	if err := json.Unmarshal(body, &update); err != nil {
+		return nil, errorf(http.StatusBadRequest, "Invalid service: %v", err)
+	}
+	if update.Split == nil {
+		update.Split = &admin.TrafficSplit{}
+	}
+	if setSplit {
+		if err := validateSplit(s, service, update.Split.Allocations, migrate); err != nil {
+			return nil, err
+		}
+		s.split = update.Split.Allocations
+	}
+	if setShardBy {
+		s.shardBy = update.Split.ShardBy
+	}
+	return f.done(), nil
+}
+
+// validateSplit checks that allocations is a valid traffic split of s.
+func validateSplit(s *serviceState, service string, allocations map[string]float64, migrate bool) error {
+	if len(allocations) == 0 {
+		return errorf(http.StatusBadRequest, "Traffic split must allocate traffic to at least one version")
+	}
+	if migrate && len(allocations) != 1 {
+		return errorf(http.StatusBadRequest, "Traffic migration requires a split to a single version")
+	}
+	var sum float64
+	for v, a := range allocations {
+		if s.versions[v] == nil {
+			return errorf(http.StatusBadRequest, "Version %s of service %s not found", v, service)
+		}
+		if a < 0 {
+			return errorf(http.StatusBadRequest, "Allocation of version %s must not be negative", v)
+		}
+		sum += a
+	}
+	if math.Abs(sum-1) > 1e-6 {
+		return errorf(http.StatusBadRequest, "Traffic allocations must sum to 1, got %g", sum)
+	}
+	return nil
+}
+
+func (f *Fake) listVersions(service string) (*admin.ListVersionsResponse, error) {
+	s, err := f.service(service)
+	if err != nil {
+		return nil, err
+	}
+	res := &admin.ListVersionsResponse{}
+	for _, id := range s.versionIDs() {
+		res.Versions = append(res.Versions, s.versions[id].v)
+	}
+	return res, nil
+}
+
+// scalingFields are the mutually exclusive scaling settings of a version.
+var scalingFields = map[string]func(*admin.Version) bool{
+	"automaticScaling": func(v *admin.Version) bool { return v.AutomaticScaling != nil },
+	"basicScaling":     func(v *admin.Version) bool { return v.BasicScaling != nil },
+	"manualScaling":    func(v *admin.Version) bool { return v.ManualScaling != nil },
+}
+
+func (f *Fake) patchVersion(service, version string, body []byte, mask string) (*admin.Operation, error) {
+	vs, err := f.version(service, version)
+	if err != nil {
+		return nil, err
+	}
+	if mask == "" {
+		return nil, errorf(http.StatusBadRequest, "Update mask must not be empty")
+	}
+	var update admin.Version
+	if err := json.Unmarshal(body, &update); err != nil {
+		return nil, errorf(http.StatusBadRequest, "Invalid version: %v", err)
+	}
+	paths := strings.Split(mask, ",")
+	for _, p := range paths {
+		field := strings.SplitN(p, ".", 2)[0]
+		if uses, ok := scalingFields[field]; ok && !uses(vs.v) {
+			return nil, errorf(http.StatusBadRequest, "Version %s of service %s does not use %s; the scaling type of a version cannot be changed", version, service, field)
+		}
+		switch p {
+		case "servingStatus":
+			if update.ServingStatus != "SERVING" && update.ServingStatus != "STOPPED" {
+				return nil, errorf(http.StatusBadRequest, "Invalid serving status %q", update.ServingStatus)
+			}
+		case "manualScaling.instances":
+			if update.ManualScaling == nil || update.ManualScaling.Instances < 1 {
+				return nil, errorf(http.StatusBadRequest, "Manual scaling requires at least one instance")
+			}
+		}
+	}
+	v, err := applyMask(vs.v, &update, paths)
+	if err != nil {
+		return nil, err
+	}
+	vs.v = v
+	return f.done(), nil
+}
+
+func (f *Fake) deleteVersion(service, version string) (*admin.Operation, error) {
+	s, err := f.service(service)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := f.version(service, version); err != nil {
+		return nil, err
+	}
+	if s.split[version] > 0 {
+		return nil, errorf(http.StatusBadRequest, "Cannot delete version %s of service %s because it receives traffic", version, service)
+	}
+	delete(s.versions, version)
+	return f.done(), nil
+}
+
+// instanceIDs returns the IDs of the running instances of a version: the
+// configured number for a serving version that uses manual scaling, none
+// otherwise.
+func instanceIDs(v *admin.Version) []string {
+	if v.ManualScaling == nil || v.ServingStatus != "SERVING" {
+		return nil
+	}
+	ids := make([]string, v.ManualScaling.Instances)
+	for i := range ids {
+		ids[i] = fmt.Sprintf("instance-%d", i)
+	}
+	return ids
+}
+
+func (f *Fake) listInstances(service, version string) (*admin.ListInstancesResponse, error) {
+	vs, err := f.version(service, version)
+	if err != nil {
+		return nil, err
+	}
+	res := &admin.ListInstancesResponse{}
+	for _, id := range instanceIDs(vs.v) {
+		res.Instances = append(res.Instances, &admin.Instance{
+			Id:             id,
+			Name:           vs.v.Name + "/instances/" + id,
+			VmDebugEnabled: vs.debug[id],
+		})
+	}
+	return res, nil
+}
+
+func (f *Fake) debugInstance(service, version, instance string) (*admin.Operation, error) {
+	vs, err := f.version(service, version)
+	if err != nil {
+		return nil, err
+	}
+	found := false
+	for _, id := range instanceIDs(vs.v) {
+		found = found || id == instance
+	}
+	if !found {
+		return nil, errorf(http.StatusNotFound, "Instance %s of version %s not found", instance, version)
+	}
+	if vs.v.Env != "flexible" {
+		return nil, errorf(http.StatusBadRequest, "Debug mode is only available for flexible environment instances")
+	}
+	vs.debug[instance] = true
+	return f.done(), nil
+}
+
+// applyMask returns a copy of v with the fields named by the update mask
+// paths replaced by those of update. A field that is unset in update is
+// cleared.
+func applyMask(v, update *admin.Version, paths []string) (*admin.Version, error) {
+	dst, src := toMap(v), toMap(update)
+	for _, p := range paths {
+		keys := strings.Split(p, ".")
+		m := dst
+		for _, k := range keys[:len(keys)-1] {
+			next, ok := m[k].(map[string]interface{})
+			if !ok {
+				next = make(map[string]interface{})
+				m[k] = next
+			}
+			m = next
+		}
+		last := keys[len(keys)-1]
+		if val, ok := lookup(src, keys); ok {
+			m[last] = val
+		} else {
+			delete(m, last)
+		}
+	}
+	b, err := json.Marshal(dst)
+	if err != nil {
+		return nil, err
+	}
+	res := &admin.Version{}
+	if err := json.Unmarshal(b, res); err != nil {
+		return nil, errorf(http.StatusBadRequest, "Invalid version: %v", err)
+	}
+	return res, nil
+}
+
+func lookup(m map[string]interface{}, keys []string) (interface{}, bool) {
+	var val interface{} = m
+	for _, k := range keys {
+		m, ok := val.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if val, ok = m[k]; !ok {
+			return nil, false
+		}
+	}
+	return val, true
+}
+
+func toMap(v *admin.Version) map[string]interface{} {
+	b, _ := json.Marshal(v)
+	m := make(map[string]interface{})
+	json.Unmarshal(b, &m)
+	return m
+}
+
+func copyVersion(v *admin.Version) *admin.Version {
+	b, _ := json.Marshal(v)
+	cp := &admin.Version{}
+	json.Unmarshal(b, cp)
+	return cp
+}
+
+func (f *Fake) serviceIDs() []string {
+	ids := make([]string, 0, len(f.services))
+	for id := range f.services {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func (s *serviceState) versionIDs() []string {
+	ids := make([]string, 0, len(s.versions))
+	for id := range s.versions {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
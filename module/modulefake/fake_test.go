@@ -0,0 +1,131 @@
+// Copyright 2026 Google LLC. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package modulefake
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"google.golang.org/appengine/module"
+)
+
+func newTestContext(t *testing.T) (context.Context, *Fake) {
+	t.Helper()
+	f := New("fake-project")
+	f.AddVersion("default", "v1", ManualScaling(3))
+	f.AddVersion("default", "v2", BasicScaling(5, 0))
+	f.AddVersion("worker", "w1", Stopped())
+	return module.WithBackend(context.Background(), f.Backend()), f
+}
+
+func TestListAndVersions(t *testing.T) {
+	c, _ := newTestContext(t)
+	modules, err := module.List(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"default", "worker"}; !reflect.DeepEqual(modules, want) {
+		t.Errorf("List = %v, want %v", modules, want)
+	}
+	versions, err := module.Versions(c, "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"v1", "v2"}; !reflect.DeepEqual(versions, want) {
+		t.Errorf("Versions = %v, want %v", versions, want)
+	}
+	if _, err := module.Versions(c, "missing"); err == nil {
+		t.Error("Versions of an unknown module succeeded")
+	}
+}
+
+func TestNumInstances(t *testing.T) {
+	c, f := newTestContext(t)
+	if err := module.SetNumInstances(c, "default", "v1", 5); err != nil {
+		t.Fatal(err)
+	}
+	n, err := module.NumInstances(c, "default", "v1")
+	if err != nil || n != 5 {
+		t.Errorf("NumInstances = %d, %v; want 5, nil", n, err)
+	}
+	if v, _ := f.Version("default", "v1"); v.ManualScaling.Instances != 5 {
+		t.Errorf("fake holds %d instances, want 5", v.ManualScaling.Instances)
+	}
+	if err := module.SetNumInstances(c, "default", "v2", 2); err == nil {
+		t.Error("SetNumInstances on a basic scaling version succeeded")
+	}
+	if _, err := module.ServingStatus(c, "default", "missing"); !errors.Is(err, module.ErrVersionNotFound) {
+		t.Errorf("ServingStatus of an unknown version = %v, want ErrVersionNotFound", err)
+	}
+}
+
+func TestStartStop(t *testing.T) {
+	c, _ := newTestContext(t)
+	if err := module.Start(c, "worker", "w1"); err != nil {
+		t.Fatal(err)
+	}
+	if s, err := module.ServingStatus(c, "worker", "w1"); err != nil || s != "SERVING" {
+		t.Errorf("ServingStatus after Start = %q, %v; want SERVING", s, err)
+	}
+	if err := module.Stop(c, "worker", "w1"); err != nil {
+		t.Fatal(err)
+	}
+	if s, err := module.ServingStatus(c, "worker", "w1"); err != nil || s != "STOPPED" {
+		t.Errorf("ServingStatus after Stop = %q, %v; want STOPPED", s, err)
+	}
+}
+
+func TestTraffic(t *testing.T) {
+	c, f := newTestContext(t)
+	if v, err := module.DefaultVersion(c, "default"); err != nil || v != "v1" {
+		t.Errorf("DefaultVersion = %q, %v; want v1", v, err)
+	}
+	if err := module.SetDefaultVersion(c, "default", "v2"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := f.Traffic("default"), map[string]float64{"v2": 1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Traffic = %v, want %v", got, want)
+	}
+	if err := module.DeleteVersion(c, "default", "v2"); err == nil {
+		t.Error("DeleteVersion of the version receiving traffic succeeded")
+	}
+	if err := module.DeleteVersion(c, "default", "v1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := f.Version("default", "v1"); ok {
+		t.Error("deleted version still exists")
+	}
+}
+
+func TestLatestVersion(t *testing.T) {
+	c, _ := newTestContext(t)
+	if v, err := module.LatestVersion(c, "default"); err != nil || v != "v2" {
+		t.Errorf("LatestVersion = %q, %v; want v2", v, err)
+	}
+}
+
+func TestHostname(t *testing.T) {
+	c, _ := newTestContext(t)
+	module.InvalidateCache()
+	t.Cleanup(module.InvalidateCache)
+	h, err := module.Hostname(c, "default", "v1", "")
+	if want := "v1-dot-default-dot-fake-project.appspot.com"; err != nil || h != want {
+		t.Errorf("Hostname = %q, %v; want %q", h, err, want)
+	}
+}
+
+func TestDebugInstance(t *testing.T) {
+	f := New("fake-project")
+	f.AddVersion("default", "v1", Flexible(), ManualScaling(1))
+	c := module.WithBackend(context.Background(), f.Backend())
+	if err := module.DebugInstance(c, "default", "v1", "instance-0", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := module.DebugInstance(c, "default", "v1", "instance-1", ""); !errors.Is(err, module.ErrInstanceNotFound) {
+		t.Errorf("DebugInstance of an unknown instance = %v, want ErrInstanceNotFound", err)
+	}
+}
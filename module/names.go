@@ -0,0 +1,69 @@
+// Copyright 2026 Google LLC. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package module
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// maxNameLength is the maximum length of a module or version ID.
+const maxNameLength = 63
+
+// ErrInvalidName is matched by errors.Is for every *NameError.
+var ErrInvalidName = errors.New("module: invalid name")
+
+// NameError is returned when a module or version argument is not a valid App
+// Engine identifier. It is returned before any request is made.
+type NameError struct {
+	Arg    string // "module" or "version"
+	Name   string
+	Reason string
+}
+
+func (e *NameError) Error() string {
+	return fmt.Sprintf("module: invalid %s name %q: %s", e.Arg, e.Name, e.Reason)
+}
+
+// Is reports whether target is ErrInvalidName.
+func (e *NameError) Is(target error) bool {
+	return target == ErrInvalidName
+}
+
+// validateNames checks module and version against App Engine's naming rules.
+// Empty names are valid, since they mean the default.
+func validateNames(module, version string) error {
+	if err := validateName("module", module); err != nil {
+		return err
+	}
+	return validateName("version", version)
+}
+
+func validateName(arg, name string) error {
+	if name == "" {
+		return nil
+	}
+	reason := ""
+	switch {
+	case len(name) > maxNameLength:
+		reason = fmt.Sprintf("longer than %d characters", maxNameLength)
+	case name[0] == '-' || name[len(name)-1] == '-':
+		reason = "must not start or end with a hyphen"
+	case arg == "version" && strings.HasPrefix(name, "ah-"):
+		reason = `must not start with "ah-"`
+	default:
+		for _, r := range name {
+			if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-') {
+				reason = fmt.Sprintf("contains %q; only lowercase letters, digits and hyphens are allowed", r)
+				break
+			}
+		}
+	}
+	if reason != "" {
+		return &NameError{Arg: arg, Name: name, Reason: reason}
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+// Copyright 2026 Google LLC. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package module
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"google.golang.org/appengine/internal/aetesting"
+	pb "google.golang.org/appengine/internal/modules"
+)
+
+var invalidNames = []struct {
+	name, module, version, wantArg string
+}{
+	{"ModuleSlash", "a/b", "v1", "module"},
+	{"ModuleSpace", "my module", "v1", "module"},
+	{"ModuleUppercase", "MyModule", "v1", "module"},
+	{"ModuleTooLong", strings.Repeat("m", 64), "v1", "module"},
+	{"ModuleLeadingHyphen", "-mod", "v1", "module"},
+	{"VersionSlash", "mod", "v1/../v2", "version"},
+	{"VersionUppercase", "mod", "V1", "version"},
+	{"VersionTooLong", "mod", strings.Repeat("v", 64), "version"},
+	{"VersionLeadingHyphen", "mod", "-v1", "version"},
+	{"VersionTrailingHyphen", "mod", "v1-", "version"},
+	{"VersionReservedPrefix", "mod", "ah-builtin", "version"},
+}
+
+func TestValidateNames(t *testing.T) {
+	for _, tt := range invalidNames {
+		err := validateNames(tt.module, tt.version)
+		var nameErr *NameError
+		if !errors.As(err, &nameErr) || !errors.Is(err, ErrInvalidName) {
+			t.Errorf("%s: validateNames(%q, %q) = %v, want a *NameError", tt.name, tt.module, tt.version, err)
+			continue
+		}
+		if nameErr.Arg != tt.wantArg || nameErr.Reason == "" {
+			t.Errorf("%s: NameError = %+v, want Arg %q and a reason", tt.name, nameErr, tt.wantArg)
+		}
+	}
+	for _, valid := range [][2]string{
+		{"", ""},
+		{"default", ""},
+		{"backend-api", "20240101t120000"},
+		{strings.Repeat("m", 63), strings.Repeat("v", 63)},
+		{"a1", "1"},
+	} {
+		if err := validateNames(valid[0], valid[1]); err != nil {
+			t.Errorf("validateNames(%q, %q) = %v, want nil", valid[0], valid[1], err)
+		}
+	}
+}
+
+func TestInvalidNamesAdminAPI(t *testing.T) {
+	// The stub panics if any request is made.
+	useStubBackend(t, &stubBackend{})
+	for _, tt := range invalidNames {
+		if _, err := NumInstances(context.Background(), tt.module, tt.version); !errors.Is(err, ErrInvalidName) {
+			t.Errorf("%s: NumInstances error = %v, want ErrInvalidName", tt.name, err)
+		}
+		if err := Start(context.Background(), tt.module, tt.version); !errors.Is(err, ErrInvalidName) {
+			t.Errorf("%s: Start error = %v, want ErrInvalidName", tt.name, err)
+		}
+		if err := SetDefaultVersion(context.Background(), tt.module, tt.version); !errors.Is(err, ErrInvalidName) {
+			t.Errorf("%s: SetDefaultVersion error = %v, want ErrInvalidName", tt.name, err)
+		}
+	}
+	if err := SetTraffic(context.Background(), "mod", map[string]float64{"Bad_Version": 1}); !errors.Is(err, ErrInvalidName) {
+		t.Errorf("SetTraffic error = %v, want ErrInvalidName", err)
+	}
+	if _, err := VersionsIterator(context.Background(), "Bad/Module").Next(); !errors.Is(err, ErrInvalidName) {
+		t.Errorf("VersionsIterator.Next error = %v, want ErrInvalidName", err)
+	}
+}
+
+func TestInvalidNamesLegacy(t *testing.T) {
+	c := aetesting.FakeSingleContext(t, "modules", "SetNumInstances", func(req *pb.SetNumInstancesRequest, res *pb.SetNumInstancesResponse) error {
+		t.Error("legacy backend called with an invalid name")
+		return nil
+	})
+	for _, tt := range invalidNames {
+		if err := SetNumInstances(c, tt.module, tt.version, 1); !errors.Is(err, ErrInvalidName) {
+			t.Errorf("%s: SetNumInstances error = %v, want ErrInvalidName", tt.name, err)
+		}
+	}
+	if _, err := Versions(c, "Bad Module"); !errors.Is(err, ErrInvalidName) {
+		t.Errorf("Versions error = %v, want ErrInvalidName", err)
+	}
+}
@@ -0,0 +1,68 @@
+// Copyright 2026 Google LLC. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package module
+
+import (
+	"context"
+)
+
+// Network holds the network settings of a flexible environment version.
+type Network struct {
+	Name           string   // Compute Engine network, e.g. "default"
+	Subnetwork     string   // sub-network the instances run in, if any
+	InstanceTag    string   // tag applied to the instances for firewall rules
+	ForwardedPorts []string // ports forwarded from the instances, e.g. "8080/tcp"
+}
+
+// VPCAccessConnector holds the Serverless VPC Access connector settings of a
+// version.
+type VPCAccessConnector struct {
+	Name          string // "projects/{project}/locations/{region}/connectors/{id}"
+	EgressSetting string // "ALL_TRAFFIC" or "PRIVATE_IP_RANGES"
+}
+
+// NetworkConfig returns the network settings and the VPC Access connector of
+// the given module.version. Either is nil if the version does not configure
+// it. If either module or version are the empty string it means the default.
+// NetworkConfig returns ErrVersionNotFound if the version does not exist, and
+// ErrNotSupported on the legacy backend.
+func NetworkConfig(c context.Context, module, version string) (_ *Network, _ *VPCAccessConnector, err error) {
+	c, done := startCall(c, "NetworkConfig", module, version)
+	defer done(&err)
+	if err := validateNames(module, version); err != nil {
+		return nil, nil, err
+	}
+	if !useAdminAPI(c) {
+		return nil, nil, ErrNotSupported
+	}
+	module, version, err = defaultModuleVersion(c, module, version)
+	if err != nil {
+		return nil, nil, err
+	}
+	projectID := getProjectID(c)
+	b, err := newAdminBackend(c, "get_network_config")
+	if err != nil {
+		return nil, nil, err
+	}
+	// The network settings are only returned in the FULL view.
+	v, err := b.GetVersion(c, projectID, module, version, "FULL", "network,vpcAccessConnector")
+	if err != nil {
+		return nil, nil, err
+	}
+	var network *Network
+	if n := v.Network; n != nil {
+		network = &Network{
+			Name:           n.Name,
+			Subnetwork:     n.SubnetworkName,
+			InstanceTag:    n.InstanceTag,
+			ForwardedPorts: n.ForwardedPorts,
+		}
+	}
+	var connector *VPCAccessConnector
+	if vc := v.VpcAccessConnector; vc != nil {
+		connector = &VPCAccessConnector{Name: vc.Name, EgressSetting: vc.EgressSetting}
+	}
+	return network, connector, nil
+}
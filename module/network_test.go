@@ -0,0 +1,56 @@
+// Copyright 2026 Google LLC. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package module
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	admin "google.golang.org/api/appengine/v1"
+	"google.golang.org/appengine/internal/aetesting"
+)
+
+func TestNetworkConfig(t *testing.T) {
+	const connector = "projects/test-project/locations/us-central1/connectors/c1"
+	versions := map[string]*admin.Version{
+		"with-connector": {
+			Network:            &admin.Network{Name: "default", SubnetworkName: "sub", InstanceTag: "web", ForwardedPorts: []string{"8080/tcp"}},
+			VpcAccessConnector: &admin.VpcAccessConnector{Name: connector, EgressSetting: "PRIVATE_IP_RANGES"},
+		},
+		"without-connector": {},
+	}
+	ctx := aetesting.FakeAdminContext(t, func(req *aetesting.AdminRequest) (int, interface{}) {
+		if got := req.Query.Get("view"); got != "FULL" {
+			t.Errorf("view = %q, want FULL", got)
+		}
+		id := req.Path[len("/v1/apps/test-project/services/my-module/versions/"):]
+		if v, ok := versions[id]; ok {
+			return http.StatusOK, v
+		}
+		return http.StatusNotFound, "version not found"
+	})
+
+	network, vpc, err := NetworkConfig(ctx, "my-module", "with-connector")
+	if err != nil {
+		t.Fatalf("NetworkConfig: %v", err)
+	}
+	wantNetwork := &Network{Name: "default", Subnetwork: "sub", InstanceTag: "web", ForwardedPorts: []string{"8080/tcp"}}
+	if !reflect.DeepEqual(network, wantNetwork) {
+		t.Errorf("network = %+v, want %+v", network, wantNetwork)
+	}
+	if want := (&VPCAccessConnector{Name: connector, EgressSetting: "PRIVATE_IP_RANGES"}); !reflect.DeepEqual(vpc, want) {
+		t.Errorf("connector = %+v, want %+v", vpc, want)
+	}
+
+	network, vpc, err = NetworkConfig(ctx, "my-module", "without-connector")
+	if err != nil || network != nil || vpc != nil {
+		t.Errorf("NetworkConfig = %v, %v, %v; want nil, nil, nil", network, vpc, err)
+	}
+
+	if _, _, err := NetworkConfig(ctx, "my-module", "missing"); err != ErrVersionNotFound {
+		t.Errorf("NetworkConfig of a missing version = %v, want ErrVersionNotFound", err)
+	}
+}
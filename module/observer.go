@@ -0,0 +1,130 @@
+// Copyright 2026 Google LLC. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package module
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"google.golang.org/appengine/internal"
+)
+
+// CallInfo describes a completed call to one of the functions of this package
+// that reach the modules backend.
+type CallInfo struct {
+	Backend   string        // "admin" or "legacy"
+	Method    string        // name of the function called, e.g. "SetNumInstances"
+	Module    string        // module argument as given by the caller, if any
+	Version   string        // version argument as given by the caller, if any
+	Namespace string        // namespace of the caller's context, as set by appengine.Namespace
+	Duration  time.Duration // total time spent in the call
+	Attempts  int           // number of times the operation was attempted
+	Fallback  bool          // whether the call fell back from the Admin API to the legacy backend
+	Err       error         // error returned to the caller, or nil
+}
+
+var callObserver struct {
+	sync.Mutex
+	f func(CallInfo)
+}
+
+// SetCallObserver registers f to be called after every call to a function of
+// this package that reaches the backend, such as List or SetNumInstances.
+// Calls made internally on behalf of another function, like the Stop calls of
+// StopAllVersionsExcept, are reported as part of the outer call only.
+//
+// f is called synchronously on the caller's goroutine, after the call has
+// completed, so it should return quickly. Passing nil removes the observer.
+// SetCallObserver may be called at any time, including concurrently with
+// calls being observed.
+func SetCallObserver(f func(CallInfo)) {
+	callObserver.Lock()
+	callObserver.f = f
+	callObserver.Unlock()
+}
+
+// callState is the state of an observed call, shared by everything done on
+// its behalf.
+type callState struct {
+	mu       sync.Mutex
+	backend  string
+	attempts int
+	fallback bool
+}
+
+// fellBack records that the call is being retried on the legacy backend.
+func (st *callState) fellBack() {
+	st.mu.Lock()
+	st.backend = "legacy"
+	st.attempts++
+	st.fallback = true
+	st.mu.Unlock()
+}
+
+// retried records that a request of the call is being retried.
+func (st *callState) retried() {
+	st.mu.Lock()
+	st.attempts++
+	st.mu.Unlock()
+}
+
+type callStateKey struct{}
+
+// callStateFrom returns the state of the observed call that c belongs to, or
+// nil.
+func callStateFrom(c context.Context) *callState {
+	st, _ := c.Value(callStateKey{}).(*callState)
+	return st
+}
+
+// startCall marks the beginning of a call to the named function. The returned
+// context must be used for the rest of the call, and the returned function must
+// be called with a pointer to the call's error result when it returns.
+func startCall(c context.Context, method, module, version string) (context.Context, func(*error)) {
+	backend := "legacy"
+	if useAdminAPI(c) {
+		backend = "admin"
+	}
+	return startCallOn(c, backend, method, module, version)
+}
+
+// startLegacyCall is like startCall for functions that always use the legacy
+// backend.
+func startLegacyCall(c context.Context, method, module, version string) (context.Context, func(*error)) {
+	return startCallOn(c, "legacy", method, module, version)
+}
+
+func startCallOn(c context.Context, backend, method, module, version string) (context.Context, func(*error)) {
+	if callStateFrom(c) != nil {
+		// Nested call; it is reported as part of the outer one.
+		return c, func(*error) {}
+	}
+	st := &callState{backend: backend, attempts: 1}
+	c = context.WithValue(c, callStateKey{}, st)
+	start := time.Now()
+	return c, func(errp *error) {
+		callObserver.Lock()
+		f := callObserver.f
+		callObserver.Unlock()
+		if f == nil {
+			return
+		}
+		st.mu.Lock()
+		info := CallInfo{
+			Backend:   st.backend,
+			Method:    method,
+			Module:    module,
+			Version:   version,
+			Namespace: internal.NamespaceFromContext(c),
+			Duration:  time.Since(start),
+			Attempts:  st.attempts,
+			Fallback:  st.fallback,
+			Err:       *errp,
+		}
+		st.mu.Unlock()
+		f(info)
+	}
+}
@@ -0,0 +1,243 @@
+// Copyright 2026 Google LLC. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package module
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	admin "google.golang.org/api/appengine/v1"
+	"google.golang.org/api/googleapi"
+
+	"google.golang.org/appengine"
+	"google.golang.org/appengine/internal"
+	"google.golang.org/appengine/internal/aetesting"
+	pb "google.golang.org/appengine/internal/modules"
+)
+
+// observeCalls registers an observer for the duration of the test and returns
+// a function reporting the calls observed so far.
+func observeCalls(t *testing.T) func() []CallInfo {
+	var mu sync.Mutex
+	var calls []CallInfo
+	SetCallObserver(func(info CallInfo) {
+		mu.Lock()
+		calls = append(calls, info)
+		mu.Unlock()
+	})
+	t.Cleanup(func() { SetCallObserver(nil) })
+	return func() []CallInfo {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]CallInfo(nil), calls...)
+	}
+}
+
+func TestCallObserver(t *testing.T) {
+	tests := []struct {
+		name     string
+		apiErr   error
+		wantCode int // HTTP status of the observed error; 0 means success
+	}{
+		{name: "Success"},
+		{name: "Retryable", apiErr: &googleapi.Error{Code: 503, Message: "Service Unavailable"}, wantCode: 503},
+		{name: "NotFound", apiErr: &googleapi.Error{Code: 404, Message: "Not Found"}, wantCode: 404},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useStubBackend(t, &stubBackend{
+				getVersion: func(module, version string) (*admin.Version, error) {
+					if tt.apiErr != nil {
+						return nil, tt.apiErr
+					}
+					return &admin.Version{ManualScaling: &admin.ManualScaling{Instances: 2}}, nil
+				},
+			})
+			calls := observeCalls(t)
+
+			NumInstances(context.Background(), "mod", "v1")
+
+			got := calls()
+			if len(got) != 1 {
+				t.Fatalf("observed %d calls, want 1: %+v", len(got), got)
+			}
+			info := got[0]
+			if info.Backend != "admin" || info.Method != "NumInstances" || info.Module != "mod" || info.Version != "v1" || info.Attempts != 1 {
+				t.Errorf("CallInfo = %+v, want admin NumInstances of mod.v1 with 1 attempt", info)
+			}
+			var gErr *googleapi.Error
+			switch {
+			case tt.wantCode == 0 && info.Err != nil:
+				t.Errorf("CallInfo.Err = %v, want nil", info.Err)
+			case tt.wantCode != 0 && (!errors.As(info.Err, &gErr) || gErr.Code != tt.wantCode):
+				t.Errorf("CallInfo.Err = %v, want an error with code %d", info.Err, tt.wantCode)
+			}
+			if tt.wantCode == 404 && !isNotFound(info.Err) {
+				t.Errorf("CallInfo.Err = %v, want a not-found error", info.Err)
+			}
+		})
+	}
+}
+
+func TestCallObserverNested(t *testing.T) {
+	useStubBackend(t, &stubBackend{
+		listVersions: func(module, pageToken string) (*admin.ListVersionsResponse, error) {
+			return &admin.ListVersionsResponse{Versions: []*admin.Version{
+				{Id: "v1", ServingStatus: "SERVING"},
+				{Id: "v2", ServingStatus: "SERVING"},
+				{Id: "v3", ServingStatus: "SERVING"},
+			}}, nil
+		},
+		patchVersion: func(module, version string, v *admin.Version, updateMask string) (*admin.Operation, error) {
+			return &admin.Operation{Done: true}, nil
+		},
+	})
+	calls := observeCalls(t)
+
+	if err := StopAllVersionsExcept(context.Background(), "mod", "v1"); err != nil {
+		t.Fatalf("StopAllVersionsExcept: %v", err)
+	}
+	got := calls()
+	if len(got) != 1 || got[0].Method != "StopAllVersionsExcept" {
+		t.Errorf("observed %+v, want a single StopAllVersionsExcept call", got)
+	}
+}
+
+func TestCallObserverLegacy(t *testing.T) {
+	c := aetesting.FakeSingleContext(t, "modules", "GetModules", func(req *pb.GetModulesRequest, res *pb.GetModulesResponse) error {
+		res.Module = []string{"default"}
+		return nil
+	})
+	calls := observeCalls(t)
+
+	if _, err := List(c); err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	got := calls()
+	if len(got) != 1 || got[0].Backend != "legacy" || got[0].Method != "List" || got[0].Err != nil {
+		t.Errorf("observed %+v, want a single successful legacy List call", got)
+	}
+}
+
+// hookedCall is an API call reported to the hook set with internal.SetCallHook.
+type hookedCall struct {
+	service, method string
+	d               time.Duration
+	err             error
+}
+
+func TestCallHookLegacy(t *testing.T) {
+	var mu sync.Mutex
+	var hooked []hookedCall
+	internal.SetCallHook(func(service, method string, d time.Duration, err error) {
+		mu.Lock()
+		hooked = append(hooked, hookedCall{service, method, d, err})
+		mu.Unlock()
+	})
+	t.Cleanup(func() { internal.SetCallHook(nil) })
+	calls := observeCalls(t)
+
+	c := aetesting.FakeSingleContext(t, "modules", "GetModules", func(req *pb.GetModulesRequest, res *pb.GetModulesResponse) error {
+		time.Sleep(time.Millisecond)
+		res.Module = []string{"default"}
+		return nil
+	})
+	if _, err := ListLegacy(c); err != nil {
+		t.Fatalf("ListLegacy: %v", err)
+	}
+	c = aetesting.FakeSingleContext(t, "modules", "SetNumInstances", func(req *pb.SetNumInstancesRequest, res *pb.SetNumInstancesResponse) error {
+		time.Sleep(time.Millisecond)
+		return nil
+	})
+	if err := SetNumInstancesLegacy(c, "worker", "v1", 2); err != nil {
+		t.Fatalf("SetNumInstancesLegacy: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	observed := calls()
+	wantMethods := [][2]string{{"GetModules", "ListLegacy"}, {"SetNumInstances", "SetNumInstancesLegacy"}}
+	if len(hooked) != len(wantMethods) || len(observed) != len(wantMethods) {
+		t.Fatalf("hooked %+v and observed %+v, want %d calls each", hooked, observed, len(wantMethods))
+	}
+	for i, want := range wantMethods {
+		h, o := hooked[i], observed[i]
+		if h.service != "modules" || h.method != want[0] || h.err != nil {
+			t.Errorf("hooked call %d = %+v, want a successful modules.%s", i, h, want[0])
+		}
+		if o.Backend != "legacy" || o.Method != want[1] {
+			t.Errorf("observed call %d = %+v, want legacy %s", i, o, want[1])
+		}
+		if h.d < time.Millisecond || h.d > o.Duration {
+			t.Errorf("hooked call %d took %v, want at least 1ms and at most the observed %v", i, h.d, o.Duration)
+		}
+	}
+}
+
+func TestCallObserverNil(t *testing.T) {
+	SetCallObserver(nil)
+	c := aetesting.FakeSingleContext(t, "modules", "GetModules", func(req *pb.GetModulesRequest, res *pb.GetModulesResponse) error {
+		return nil
+	})
+	if _, err := List(c); err != nil {
+		t.Fatalf("List: %v", err)
+	}
+}
+
+func TestCallObserverNamespace(t *testing.T) {
+	t.Run("Admin", func(t *testing.T) {
+		old, oldInterval := quotaRetryDelay, operationPollInterval
+		quotaRetryDelay, operationPollInterval = time.Millisecond, time.Millisecond
+		t.Cleanup(func() { quotaRetryDelay, operationPollInterval = old, oldInterval })
+		var patches int
+		ctx := aetesting.FakeAdminContext(t, func(req *aetesting.AdminRequest) (int, interface{}) {
+			if req.Method == "GET" {
+				req.Expect(t, "GET", "/v1/apps/test-project/operations/123", "")
+				return http.StatusOK, &admin.Operation{Name: "apps/test-project/operations/123", Done: true}
+			}
+			req.Expect(t, "PATCH", "/v1/apps/test-project/services/mod/versions/v1", "manualScaling.instances")
+			if patches++; patches == 1 {
+				return http.StatusTooManyRequests, "Quota exceeded"
+			}
+			return http.StatusOK, &admin.Operation{Name: "apps/test-project/operations/123"}
+		})
+		ctx, err := appengine.Namespace(ctx, "tenant-a")
+		if err != nil {
+			t.Fatal(err)
+		}
+		calls := observeCalls(t)
+
+		// The call is retried and waits for its operation.
+		if err := SetNumInstances(ctx, "mod", "v1", 2); err != nil {
+			t.Fatalf("SetNumInstances: %v", err)
+		}
+		got := calls()
+		if len(got) != 1 || got[0].Namespace != "tenant-a" || got[0].Attempts != 2 {
+			t.Errorf("observed %+v, want one call in namespace tenant-a with 2 attempts", got)
+		}
+	})
+	t.Run("Legacy", func(t *testing.T) {
+		c := aetesting.FakeSingleContext(t, "modules", "GetModules", func(req *pb.GetModulesRequest, res *pb.GetModulesResponse) error {
+			res.Module = []string{"default"}
+			return nil
+		})
+		c, err := appengine.Namespace(c, "tenant-b")
+		if err != nil {
+			t.Fatal(err)
+		}
+		calls := observeCalls(t)
+
+		if _, err := List(c); err != nil {
+			t.Fatalf("List: %v", err)
+		}
+		if got := calls(); len(got) != 1 || got[0].Backend != "legacy" || got[0].Namespace != "tenant-b" {
+			t.Errorf("observed %+v, want one legacy call in namespace tenant-b", got)
+		}
+	})
+}
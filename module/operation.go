@@ -0,0 +1,109 @@
+// Copyright 2026 Google LLC. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package module
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	admin "google.golang.org/api/appengine/v1"
+)
+
+// operationPollInterval is the delay before the first poll of a pending Admin
+// API operation. It is a variable so that tests can shorten it.
+var operationPollInterval = time.Second
+
+// pollBackoff is the default policy for the delays between polls of a pending
+// operation; see WithBackoff.
+func pollBackoff() Backoff {
+	return Backoff{Initial: operationPollInterval, Max: 10 * time.Second, Multiplier: 1.5, Jitter: 0.1}
+}
+
+// waitOperation blocks until the long-running operation op has completed,
+// polling the Admin API as necessary. It returns an error if the operation
+// finished with an error or if c is done first. If c has no deadline, the
+// wait is bounded by the default write timeout; see SetDefaultTimeout.
+func waitOperation(c context.Context, b adminBackend, projectID string, op *admin.Operation) error {
+	_, err := awaitOperation(c, b, projectID, op)
+	return err
+}
+
+// awaitOperation is like waitOperation, but also returns the last state of
+// the operation that it saw.
+func awaitOperation(c context.Context, b adminBackend, projectID string, op *admin.Operation) (*admin.Operation, error) {
+	c, cancel := withDefaultTimeout(c, true)
+	defer cancel()
+	bo := backoffFor(c, pollBackoff())
+	for op != nil && !op.Done {
+		if err := bo.Sleep(c); err != nil {
+			return op, err
+		}
+		next, err := b.GetOperation(c, projectID, operationID(op.Name))
+		if err != nil {
+			return op, err
+		}
+		op = next
+	}
+	if err := operationError(op); err != nil {
+		return op, err
+	}
+	return op, nil
+}
+
+// operationError returns the error a completed operation finished with, or
+// nil.
+func operationError(op *admin.Operation) error {
+	if op != nil && op.Error != nil {
+		return fmt.Errorf("module: operation %s failed: %s", op.Name, op.Error.Message)
+	}
+	return nil
+}
+
+// operationID returns the final component of an operation resource name of the
+// form "apps/{app}/operations/{id}".
+func operationID(name string) string {
+	if i := strings.LastIndex(name, "/"); i != -1 {
+		return name[i+1:]
+	}
+	return name
+}
+
+// Operation is a long-running Admin API operation, such as the rollout of a
+// change made by PatchVersion.
+type Operation struct {
+	Name string // resource name of the form "apps/{app}/operations/{id}"
+	Done bool   // whether the operation has completed
+	Err  error  // error the operation finished with, if it is done
+}
+
+func newOperation(op *admin.Operation) *Operation {
+	if op == nil {
+		return &Operation{Done: true}
+	}
+	return &Operation{Name: op.Name, Done: op.Done, Err: operationError(op)}
+}
+
+// Wait blocks until the operation has completed and returns the error it
+// finished with, if any. It updates op to its final state.
+func (op *Operation) Wait(c context.Context) error {
+	if op.Done {
+		return op.Err
+	}
+	projectID := getProjectID(c)
+	if parts := strings.Split(op.Name, "/"); len(parts) == 4 && parts[0] == "apps" {
+		projectID = parts[1]
+	}
+	b, err := newAdminBackend(c, "wait_operation")
+	if err != nil {
+		return err
+	}
+	final, err := awaitOperation(c, b, projectID, &admin.Operation{Name: op.Name})
+	if final != nil && final.Done {
+		*op = *newOperation(final)
+	}
+	return err
+}
@@ -0,0 +1,84 @@
+// Copyright 2026 Google LLC. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package module
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	admin "google.golang.org/api/appengine/v1"
+)
+
+// CallOption configures a call to PatchVersion.
+type CallOption func(*callOptions)
+
+type callOptions struct {
+	wait bool
+}
+
+// Wait reports whether PatchVersion should wait for the operation it starts
+// to complete before returning.
+func Wait(wait bool) CallOption {
+	return func(o *callOptions) { o.wait = wait }
+}
+
+// PatchVersion updates the fields of module.version named by updateMask, such
+// as "vpcAccessConnector" or "inboundServices", to their values in v. It is an
+// escape hatch for the Version fields that this package does not otherwise
+// manage; see the Admin API documentation for the fields that can be updated.
+// If either module or version are the empty string it means the default.
+//
+// PatchVersion returns the operation that applies the change. With the Wait
+// option, it returns once the operation has completed. PatchVersion requires
+// the Admin API and returns ErrNotSupported on the legacy backend.
+func PatchVersion(c context.Context, module, version string, v *admin.Version, updateMask []string, opts ...CallOption) (_ *Operation, err error) {
+	c, done := startCall(c, "PatchVersion", module, version)
+	defer done(&err)
+	if err := validateNames(module, version); err != nil {
+		return nil, err
+	}
+	if !useAdminAPI(c) {
+		return nil, ErrNotSupported
+	}
+	if len(updateMask) == 0 {
+		return nil, errEmptyUpdateMask
+	}
+	var o callOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	module, version, err = defaultModuleVersion(c, module, version)
+	if err != nil {
+		return nil, err
+	}
+	projectID := getProjectID(c)
+	b, err := newAdminBackend(c, "patch_version")
+	if err != nil {
+		return nil, err
+	}
+	op, err := patchVersion(c, b, projectID, module, version, v, updateMask, o.wait)
+	if op == nil {
+		return nil, err
+	}
+	// If only the wait failed, the operation is returned along with the error.
+	return newOperation(op), err
+}
+
+var errEmptyUpdateMask = errors.New("module: update mask must not be empty")
+
+// patchVersion updates the fields of module.version named by updateMask to
+// their values in v and, if wait is set, waits for the resulting operation to
+// complete. Every version update of this package goes through it.
+func patchVersion(c context.Context, b adminBackend, projectID, module, version string, v *admin.Version, updateMask []string, wait bool) (*admin.Operation, error) {
+	if len(updateMask) == 0 {
+		return nil, errEmptyUpdateMask
+	}
+	op, err := b.PatchVersion(c, projectID, module, version, v, strings.Join(updateMask, ","))
+	if err != nil || !wait {
+		return op, err
+	}
+	return awaitOperation(c, b, projectID, op)
+}
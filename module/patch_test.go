@@ -0,0 +1,83 @@
+// Copyright 2026 Google LLC. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package module
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	admin "google.golang.org/api/appengine/v1"
+	"google.golang.org/appengine/internal/aetesting"
+)
+
+func TestPatchVersion(t *testing.T) {
+	const opName = "apps/test-project/operations/op1"
+	polled := 0
+	ctx := aetesting.FakeAdminContext(t, func(req *aetesting.AdminRequest) (int, interface{}) {
+		if req.Method == "GET" {
+			req.Expect(t, "GET", "/v1/"+opName, "")
+			polled++
+			return http.StatusOK, &admin.Operation{Name: opName, Done: true}
+		}
+		req.Expect(t, "PATCH", "/v1/apps/test-project/services/worker/versions/v1", "vpcAccessConnector.name,inboundServices")
+		var v admin.Version
+		req.Decode(&v)
+		want := admin.Version{
+			VpcAccessConnector: &admin.VpcAccessConnector{Name: "projects/test-project/locations/us-central1/connectors/c1"},
+			InboundServices:    []string{"INBOUND_SERVICE_WARMUP"},
+		}
+		if !reflect.DeepEqual(v, want) {
+			t.Errorf("PATCH body = %+v, want %+v", v, want)
+		}
+		return http.StatusOK, &admin.Operation{Name: opName}
+	})
+	oldInterval := operationPollInterval
+	operationPollInterval = time.Millisecond
+	t.Cleanup(func() { operationPollInterval = oldInterval })
+
+	v := &admin.Version{
+		VpcAccessConnector: &admin.VpcAccessConnector{Name: "projects/test-project/locations/us-central1/connectors/c1"},
+		InboundServices:    []string{"INBOUND_SERVICE_WARMUP"},
+	}
+	mask := []string{"vpcAccessConnector.name", "inboundServices"}
+	op, err := PatchVersion(ctx, "worker", "v1", v, mask)
+	if err != nil {
+		t.Fatalf("PatchVersion: %v", err)
+	}
+	if op.Name != opName || op.Done || polled != 0 {
+		t.Errorf("PatchVersion = %+v after %d polls, want pending %s without polling", op, polled, opName)
+	}
+	if err := op.Wait(ctx); err != nil || !op.Done {
+		t.Errorf("Wait = %v with operation %+v, want done", err, op)
+	}
+
+	op, err = PatchVersion(ctx, "worker", "v1", v, mask, Wait(true))
+	if err != nil {
+		t.Fatalf("PatchVersion with Wait: %v", err)
+	}
+	if !op.Done {
+		t.Errorf("PatchVersion with Wait = %+v, want done", op)
+	}
+}
+
+func TestPatchVersion_EmptyMask(t *testing.T) {
+	ctx := aetesting.FakeAdminContext(t, func(req *aetesting.AdminRequest) (int, interface{}) {
+		t.Errorf("unexpected request %s %s", req.Method, req.Path)
+		return http.StatusBadRequest, nil
+	})
+	if _, err := PatchVersion(ctx, "worker", "v1", &admin.Version{}, nil); err == nil {
+		t.Error("PatchVersion with an empty mask succeeded, want error")
+	}
+}
+
+func TestPatchVersion_Legacy(t *testing.T) {
+	t.Setenv("MODULES_USE_ADMIN_API", "false")
+	if _, err := PatchVersion(context.Background(), "worker", "v1", &admin.Version{}, []string{"inboundServices"}); err != ErrNotSupported {
+		t.Errorf("PatchVersion = %v, want ErrNotSupported", err)
+	}
+}
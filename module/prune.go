@@ -0,0 +1,124 @@
+// Copyright 2026 Google LLC. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package module
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+)
+
+// PruneOption configures a call to PruneVersions.
+type PruneOption func(*pruneOptions)
+
+type pruneOptions struct {
+	dryRun bool
+	minAge time.Duration
+}
+
+// DryRun reports whether PruneVersions should only return the versions it
+// would delete, without deleting them.
+func DryRun(dryRun bool) PruneOption {
+	return func(o *pruneOptions) { o.dryRun = dryRun }
+}
+
+// MinAge makes PruneVersions keep every version created less than d ago.
+func MinAge(d time.Duration) PruneOption {
+	return func(o *pruneOptions) { o.minAge = d }
+}
+
+// PruneVersions deletes the old versions of the specified module, and returns
+// the names of the versions it deleted. Versions that receive traffic are
+// never deleted. Of the remaining versions, the keep most recently created
+// ones, and those younger than the MinAge option, are kept as well. If module
+// is the empty string, it means the default module.
+//
+// The deletions are issued concurrently. Failures do not prevent the remaining
+// versions from being deleted; they are reported together as an
+// appengine.MultiError with a *VersionError for each version that failed,
+// along with the versions that were deleted. PruneVersions returns
+// ErrNotSupported on the legacy backend.
+func PruneVersions(c context.Context, module string, keep int, opts ...PruneOption) (_ []string, err error) {
+	c, done := startCall(c, "PruneVersions", module, "")
+	defer done(&err)
+	if err := validateNames(module, ""); err != nil {
+		return nil, err
+	}
+	if !useAdminAPI(c) {
+		return nil, ErrNotSupported
+	}
+	if keep < 0 {
+		return nil, errors.New("module: keep must not be negative")
+	}
+	var o pruneOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if module == "" {
+		module = getModuleorDefault(c)
+	}
+	allocations, err := trafficAllocations(c, module)
+	if err != nil {
+		return nil, err
+	}
+	projectID := getProjectID(c)
+	b, err := newAdminBackend(c, "prune_versions")
+	if err != nil {
+		return nil, err
+	}
+	versions, err := listVersions(c, b, projectID, module, []string{"id", "createTime"})
+	if err != nil {
+		return nil, err
+	}
+
+	type candidate struct {
+		id      string
+		created time.Time
+	}
+	var candidates []candidate
+	for _, v := range versions {
+		if allocations[v.Id] > 0 {
+			continue
+		}
+		created, err := time.Parse(time.RFC3339Nano, v.CreateTime)
+		if err != nil {
+			// Without a creation time the version's age is unknown; keep it.
+			continue
+		}
+		candidates = append(candidates, candidate{v.Id, created})
+	}
+	// Newest first, with the same tie-break as LatestVersion.
+	sort.Slice(candidates, func(i, j int) bool {
+		if !candidates[i].created.Equal(candidates[j].created) {
+			return candidates[i].created.After(candidates[j].created)
+		}
+		return candidates[i].id > candidates[j].id
+	})
+	var targets []string
+	now := time.Now()
+	for i, cand := range candidates {
+		if i < keep || now.Sub(cand.created) < o.minAge {
+			continue
+		}
+		targets = append(targets, cand.id)
+	}
+	if o.dryRun {
+		return targets, nil
+	}
+
+	errs := forEach(c, len(targets), batchConcurrency, func(i int) error {
+		return DeleteVersion(c, module, targets[i])
+	})
+	var deleted []string
+	for i, err := range errs {
+		if err == nil {
+			deleted = append(deleted, targets[i])
+		}
+	}
+	return deleted, batchError(c, errs, func(i int, err error) error {
+		return &VersionError{Module: module, Version: targets[i], Err: err}
+	})
+}
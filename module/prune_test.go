@@ -0,0 +1,118 @@
+// Copyright 2026 Google LLC. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package module
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	admin "google.golang.org/api/appengine/v1"
+	"google.golang.org/appengine"
+	"google.golang.org/appengine/internal/aetesting"
+)
+
+// pruneContext serves a module whose versions were created the given number
+// of hours ago, with the given traffic allocations. Deleting fail is
+// rejected. The returned function reports the versions deleted.
+func pruneContext(t *testing.T, ages map[string]int, allocations map[string]float64, fail string) (context.Context, func() []string) {
+	const prefix = "/v1/apps/test-project/services/my-module"
+	var mu sync.Mutex
+	var deleted []string
+	now := time.Now()
+	ctx := aetesting.FakeAdminContext(t, func(req *aetesting.AdminRequest) (int, interface{}) {
+		switch {
+		case req.Method == "GET" && req.Path == prefix:
+			return http.StatusOK, &admin.Service{Split: &admin.TrafficSplit{Allocations: allocations}}
+		case req.Method == "GET" && req.Path == prefix+"/versions":
+			if got, want := req.Query.Get("fields"), "versions(id,createTime),nextPageToken"; got != want {
+				t.Errorf("fields = %q, want %q", got, want)
+			}
+			resp := &admin.ListVersionsResponse{}
+			for id, hours := range ages {
+				created := now.Add(-time.Duration(hours) * time.Hour).Format(time.RFC3339)
+				resp.Versions = append(resp.Versions, &admin.Version{Id: id, CreateTime: created})
+			}
+			return http.StatusOK, resp
+		case req.Method == "DELETE" && strings.HasPrefix(req.Path, prefix+"/versions/"):
+			id := strings.TrimPrefix(req.Path, prefix+"/versions/")
+			if id == fail {
+				return http.StatusBadRequest, "cannot delete"
+			}
+			mu.Lock()
+			deleted = append(deleted, id)
+			mu.Unlock()
+			return http.StatusOK, &admin.Operation{Name: "apps/test-project/operations/" + id, Done: true}
+		}
+		t.Errorf("unexpected request %s %s", req.Method, req.Path)
+		return http.StatusBadRequest, nil
+	})
+	return ctx, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		sort.Strings(deleted)
+		return deleted
+	}
+}
+
+var pruneAges = map[string]int{
+	"v1": 500, // oldest, but serves all traffic
+	"v2": 400,
+	"v3": 300,
+	"v4": 200,
+	"v5": 100,
+	"v6": 1, // newest
+}
+
+func TestPruneVersions(t *testing.T) {
+	ctx, deleted := pruneContext(t, pruneAges, map[string]float64{"v1": 0.9, "v2": 0.1}, "")
+	got, err := PruneVersions(ctx, "my-module", 2)
+	if err != nil {
+		t.Fatalf("PruneVersions: %v", err)
+	}
+	sort.Strings(got)
+	// v1 and v2 receive traffic, and v5 and v6 are the two newest others.
+	if want := []string{"v3", "v4"}; !reflect.DeepEqual(got, want) || !reflect.DeepEqual(deleted(), want) {
+		t.Errorf("PruneVersions = %v, deleted %v; want %v", got, deleted(), want)
+	}
+}
+
+func TestPruneVersions_DryRunMinAge(t *testing.T) {
+	ctx, deleted := pruneContext(t, pruneAges, map[string]float64{"v1": 1}, "")
+	got, err := PruneVersions(ctx, "my-module", 0, DryRun(true), MinAge(150*time.Hour))
+	if err != nil {
+		t.Fatalf("PruneVersions: %v", err)
+	}
+	if want := []string{"v4", "v3", "v2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("PruneVersions = %v, want %v", got, want)
+	}
+	if d := deleted(); len(d) != 0 {
+		t.Errorf("dry run deleted %v", d)
+	}
+}
+
+func TestPruneVersions_PartialFailure(t *testing.T) {
+	ctx, deleted := pruneContext(t, pruneAges, map[string]float64{"v1": 1}, "v3")
+	got, err := PruneVersions(ctx, "my-module", 1)
+	failed, ok := IsPartialFailure(err)
+	if !ok || len(failed) != 1 {
+		t.Fatalf("PruneVersions error = %v, want a partial failure of one version", err)
+	}
+	var ve *VersionError
+	if e := err.(appengine.MultiError)[failed[0]]; !errors.As(e, &ve) || ve.Version != "v3" {
+		t.Errorf("error = %v, want a VersionError for v3", e)
+	}
+	want := []string{"v2", "v4", "v5"}
+	sort.Strings(got)
+	if !reflect.DeepEqual(got, want) || !reflect.DeepEqual(deleted(), want) {
+		t.Errorf("PruneVersions = %v, deleted %v; want %v", got, deleted(), want)
+	}
+}
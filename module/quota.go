@@ -0,0 +1,43 @@
+// Copyright 2026 Google LLC. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package module
+
+import (
+	"context"
+	"net/http"
+	"os"
+)
+
+const userProjectHeader = "X-Goog-User-Project"
+
+type quotaProjectContextKey struct{}
+
+// WithQuotaProject returns a copy of ctx whose Admin API calls bill their
+// quota to project, which may differ from the project the calls operate on.
+// This is needed with user credentials, or when administering another project
+// through WithBackend, if the Admin API is not enabled on the project of the
+// credentials. An empty project restores the default, which is the value of
+// the MODULES_QUOTA_PROJECT environment variable, or else the project of the
+// credentials.
+func WithQuotaProject(ctx context.Context, project string) context.Context {
+	return context.WithValue(ctx, quotaProjectContextKey{}, project)
+}
+
+// quotaProject returns the project that Admin API calls made with ctx bill
+// their quota to, or "" for the project of the credentials.
+func quotaProject(ctx context.Context) string {
+	if p, _ := ctx.Value(quotaProjectContextKey{}).(string); p != "" {
+		return p
+	}
+	return os.Getenv("MODULES_QUOTA_PROJECT")
+}
+
+// setQuotaProjectHeader adds the quota project of ctx, if any, to the headers
+// h of an outgoing Admin API request.
+func setQuotaProjectHeader(ctx context.Context, h http.Header) {
+	if p := quotaProject(ctx); p != "" {
+		h.Set(userProjectHeader, p)
+	}
+}
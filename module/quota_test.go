@@ -0,0 +1,64 @@
+// Copyright 2026 Google LLC. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package module
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"google.golang.org/api/option"
+)
+
+func TestQuotaProject(t *testing.T) {
+	tests := []struct {
+		name, env, ctx, want string
+	}{
+		{"Unset", "", "", ""},
+		{"Env", "billing-project", "", "billing-project"},
+		{"Context", "", "ctx-project", "ctx-project"},
+		{"ContextOverridesEnv", "billing-project", "ctx-project", "ctx-project"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("MODULES_QUOTA_PROJECT", tt.env)
+			got := traceServer(t)
+			c := context.Background()
+			if tt.ctx != "" {
+				c = WithQuotaProject(c, tt.ctx)
+			}
+			if _, err := List(c); err != nil {
+				t.Fatalf("List: %v", err)
+			}
+			if v, ok := (*got)[userProjectHeader]; tt.want == "" && ok {
+				t.Errorf("outgoing %s header = %q, want none", userProjectHeader, v)
+			}
+			if v := got.Get(userProjectHeader); v != tt.want {
+				t.Errorf("outgoing %s header = %q, want %q", userProjectHeader, v, tt.want)
+			}
+		})
+	}
+}
+
+func TestQuotaProject_WithBackend(t *testing.T) {
+	t.Setenv("MODULES_QUOTA_PROJECT", "")
+	var path, quota string
+	srv := newAdminTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		path, quota = r.URL.Path, r.Header.Get(userProjectHeader)
+		fmt.Fprint(w, `{"services": [{"id": "default"}]}`)
+	})
+	b := NewBackend("other-project", option.WithEndpoint(srv.URL+"/"), option.WithoutAuthentication())
+	c := WithQuotaProject(WithBackend(context.Background(), b), "billing-project")
+	if _, err := List(c); err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if want := "/v1/apps/other-project/services"; path != want {
+		t.Errorf("request path = %q, want %q", path, want)
+	}
+	if quota != "billing-project" {
+		t.Errorf("outgoing %s header = %q, want %q", userProjectHeader, quota, "billing-project")
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright 2026 Google LLC. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package module
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// rateLimiter limits the rate of the Admin API requests of the process.
+var rateLimiter tokenBucket
+
+// SetRateLimit limits the Admin API requests made by this package to rps per
+// second on average, with bursts of up to burst requests. The limit is shared
+// by all goroutines of the process. A request that has to wait for the limit
+// gives up when its context is done. An rps of zero or less removes the limit,
+// which is the default.
+func SetRateLimit(rps float64, burst int) {
+	rateLimiter.set(rps, burst)
+}
+
+// tokenBucket is a token bucket rate limiter. Its zero value allows every
+// request.
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64 // tokens added per second; zero means unlimited
+	burst  float64 // capacity of the bucket
+	tokens float64 // tokens available at time last
+	last   time.Time
+}
+
+func (b *tokenBucket) set(rps float64, burst int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if rps <= 0 {
+		b.rate = 0
+		return
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	b.rate, b.burst = rps, float64(burst)
+	b.tokens, b.last = b.burst, time.Now()
+}
+
+// wait takes a token from the bucket, waiting for one to become available if
+// necessary. It returns c's error if c is done before then.
+func (b *tokenBucket) wait(c context.Context) error {
+	b.mu.Lock()
+	if b.rate == 0 {
+		b.mu.Unlock()
+		return nil
+	}
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+	// Taking the token now, even if it is only available later, reserves it
+	// for this request so that waiting requests are served in order.
+	b.tokens--
+	delay := time.Duration(-b.tokens / b.rate * float64(time.Second))
+	b.mu.Unlock()
+	if delay <= 0 {
+		return nil
+	}
+	t := time.NewTimer(delay)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-c.Done():
+		// Give the reserved token back.
+		b.mu.Lock()
+		b.tokens++
+		b.mu.Unlock()
+		return c.Err()
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright 2026 Google LLC. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package module
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	admin "google.golang.org/api/appengine/v1"
+	"google.golang.org/appengine/internal/aetesting"
+)
+
+func TestSetRateLimit(t *testing.T) {
+	SetRateLimit(20, 1)
+	t.Cleanup(func() { SetRateLimit(0, 0) })
+	var mu sync.Mutex
+	var times []time.Time
+	ctx := aetesting.FakeAdminContext(t, func(req *aetesting.AdminRequest) (int, interface{}) {
+		mu.Lock()
+		times = append(times, time.Now())
+		mu.Unlock()
+		return http.StatusOK, &admin.Version{ManualScaling: &admin.ManualScaling{Instances: 1}}
+	})
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := NumInstances(ctx, "my-module", "v1"); err != nil {
+				t.Errorf("NumInstances: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if len(times) != 5 {
+		t.Fatalf("got %d requests, want 5", len(times))
+	}
+	// At 20 requests per second with no burst, five requests span at
+	// least four intervals of 50ms.
+	first, last := times[0], times[0]
+	for _, tm := range times {
+		if tm.Before(first) {
+			first = tm
+		}
+		if tm.After(last) {
+			last = tm
+		}
+	}
+	if d := last.Sub(first); d < 180*time.Millisecond {
+		t.Errorf("five requests took %v, want at least 200ms", d)
+	}
+}
+
+func TestSetRateLimit_Canceled(t *testing.T) {
+	SetRateLimit(0.1, 1)
+	t.Cleanup(func() { SetRateLimit(0, 0) })
+	ctx := aetesting.FakeAdminContext(t, func(req *aetesting.AdminRequest) (int, interface{}) {
+		return http.StatusOK, &admin.Version{ManualScaling: &admin.ManualScaling{Instances: 1}}
+	})
+	// The first request takes the only token.
+	if _, err := NumInstances(ctx, "my-module", "v1"); err != nil {
+		t.Fatalf("NumInstances: %v", err)
+	}
+	c, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := NumInstances(c, "my-module", "v1"); err != context.DeadlineExceeded {
+		t.Errorf("NumInstances = %v, want DeadlineExceeded", err)
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("canceled wait took %v", d)
+	}
+}
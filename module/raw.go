@@ -0,0 +1,71 @@
+// Copyright 2026 Google LLC. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package module
+
+import (
+	"context"
+	"fmt"
+
+	admin "google.golang.org/api/appengine/v1"
+)
+
+// GetVersionRaw returns the given module.version as the Admin API reports it,
+// including fields that this package has no accessor for. view is "BASIC",
+// "FULL" or the empty string for the server's default, which is "BASIC". If
+// either module or version are the empty string it means the default.
+//
+// The request is made like the other calls of this package, with the same
+// project, credentials, timeouts and retries. GetVersionRaw returns
+// ErrNotSupported on the legacy backend.
+func GetVersionRaw(c context.Context, module, version, view string) (_ *admin.Version, err error) {
+	c, done := startCall(c, "GetVersionRaw", module, version)
+	defer done(&err)
+	if err := validateNames(module, version); err != nil {
+		return nil, err
+	}
+	if view != "" && view != "BASIC" && view != "FULL" {
+		return nil, fmt.Errorf("module: invalid version view %q, want BASIC or FULL", view)
+	}
+	if !useAdminAPI(c) {
+		return nil, ErrNotSupported
+	}
+	module, version, err = defaultModuleVersion(c, module, version)
+	if err != nil {
+		return nil, err
+	}
+	projectID := getProjectID(c)
+	b, err := newAdminBackend(c, "get_version")
+	if err != nil {
+		return nil, err
+	}
+	v, err := b.GetVersion(c, projectID, module, version, view)
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// GetServiceRaw returns the given module as the Admin API reports it. If
+// module is the empty string, it means the default module. Like
+// GetVersionRaw, it returns ErrNotSupported on the legacy backend.
+func GetServiceRaw(c context.Context, module string) (_ *admin.Service, err error) {
+	c, done := startCall(c, "GetServiceRaw", module, "")
+	defer done(&err)
+	if err := validateNames(module, ""); err != nil {
+		return nil, err
+	}
+	if !useAdminAPI(c) {
+		return nil, ErrNotSupported
+	}
+	if module == "" {
+		module = getModuleorDefault(c)
+	}
+	projectID := getProjectID(c)
+	b, err := newAdminBackend(c, "get_service")
+	if err != nil {
+		return nil, err
+	}
+	return b.GetService(c, projectID, module)
+}
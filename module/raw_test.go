@@ -0,0 +1,80 @@
+// Copyright 2026 Google LLC. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package module
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestGetVersionRaw(t *testing.T) {
+	for _, view := range []string{"", "BASIC", "FULL"} {
+		t.Run("View"+view, func(t *testing.T) {
+			newAdminTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+				if want := "/v1/apps/test-project/services/my-module/versions/v1"; r.URL.Path != want {
+					t.Errorf("request path = %q, want %q", r.URL.Path, want)
+				}
+				if got := r.URL.Query().Get("view"); got != view {
+					t.Errorf("view = %q, want %q", got, view)
+				}
+				fmt.Fprint(w, `{"id": "v1", "entrypoint": {"shell": "./server"}, "runtime": "go122"}`)
+			})
+			v, err := GetVersionRaw(context.Background(), "my-module", "v1", view)
+			if err != nil {
+				t.Fatalf("GetVersionRaw: %v", err)
+			}
+			if v.Entrypoint == nil || v.Entrypoint.Shell != "./server" || v.Runtime != "go122" {
+				t.Errorf("GetVersionRaw = %+v, want the entrypoint and runtime of the response", v)
+			}
+		})
+	}
+}
+
+func TestGetVersionRaw_InvalidView(t *testing.T) {
+	newAdminTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request %s", r.URL.Path)
+	})
+	if _, err := GetVersionRaw(context.Background(), "my-module", "v1", "full"); err == nil {
+		t.Error("GetVersionRaw with an invalid view succeeded")
+	}
+}
+
+func TestGetVersionRaw_NotFound(t *testing.T) {
+	newAdminTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error": {"code": 404, "message": "not found"}}`, http.StatusNotFound)
+	})
+	if _, err := GetVersionRaw(context.Background(), "my-module", "v1", ""); !errors.Is(err, ErrVersionNotFound) {
+		t.Errorf("GetVersionRaw = %v, want ErrVersionNotFound", err)
+	}
+}
+
+func TestGetServiceRaw(t *testing.T) {
+	newAdminTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if want := "/v1/apps/test-project/services/my-module"; r.URL.Path != want {
+			t.Errorf("request path = %q, want %q", r.URL.Path, want)
+		}
+		fmt.Fprint(w, `{"id": "my-module", "labels": {"team": "infra"}, "split": {"allocations": {"v1": 1}}}`)
+	})
+	s, err := GetServiceRaw(context.Background(), "my-module")
+	if err != nil {
+		t.Fatalf("GetServiceRaw: %v", err)
+	}
+	if s.Labels["team"] != "infra" || s.Split.Allocations["v1"] != 1 {
+		t.Errorf("GetServiceRaw = %+v, want the labels and split of the response", s)
+	}
+}
+
+func TestGetRaw_Legacy(t *testing.T) {
+	t.Setenv("MODULES_USE_ADMIN_API", "false")
+	if _, err := GetVersionRaw(context.Background(), "my-module", "v1", ""); err != ErrNotSupported {
+		t.Errorf("GetVersionRaw = %v, want ErrNotSupported", err)
+	}
+	if _, err := GetServiceRaw(context.Background(), "my-module"); err != ErrNotSupported {
+		t.Errorf("GetServiceRaw = %v, want ErrNotSupported", err)
+	}
+}
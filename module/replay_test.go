@@ -0,0 +1,112 @@
+// Copyright 2026 Google LLC. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package module
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	admin "google.golang.org/api/appengine/v1"
+	"google.golang.org/api/option"
+	htransport "google.golang.org/api/transport/http"
+
+	"google.golang.org/appengine/internal/aetesting"
+)
+
+// The replay tests run against the fixtures in testdata by default. With
+// MODULES_ADMIN_RECORD=true they run against the real Admin API for the project
+// in GOOGLE_CLOUD_PROJECT, using Application Default Credentials, and rewrite
+// the fixtures. That project must have a module named replayModule whose
+// version replayVersion uses manual scaling.
+const (
+	replayModule  = "worker"
+	replayVersion = "v1"
+)
+
+// replayContext returns a context whose Admin API calls are replayed from, or
+// recorded to, the named fixture in testdata.
+func replayContext(t *testing.T, fixture string) context.Context {
+	path := filepath.Join("testdata", fixture+".json")
+	ctx := context.Background()
+	var rt http.RoundTripper
+	project := aetesting.AdminProject
+	if record, _ := envBool("MODULES_ADMIN_RECORD"); record {
+		project = os.Getenv("GOOGLE_CLOUD_PROJECT")
+		if project == "" {
+			t.Skip("MODULES_ADMIN_RECORD requires GOOGLE_CLOUD_PROJECT")
+		}
+		var err error
+		rt, err = htransport.NewTransport(ctx, aetesting.RecordTransport(t, path, http.DefaultTransport, project),
+			option.WithScopes(admin.CloudPlatformScope))
+		if err != nil {
+			t.Skipf("no credentials for recording: %v", err)
+		}
+	} else {
+		rt = aetesting.ReplayTransport(t, path)
+		oldInterval := operationPollInterval
+		operationPollInterval = 0
+		t.Cleanup(func() { operationPollInterval = oldInterval })
+	}
+	return WithBackend(ctx, NewBackend(project, option.WithHTTPClient(&http.Client{Transport: rt})))
+}
+
+func TestReplayList(t *testing.T) {
+	c := replayContext(t, "list")
+	got, err := List(c)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if !contains(got, replayModule) {
+		t.Errorf("List = %v, want it to contain %q", got, replayModule)
+	}
+}
+
+func TestReplayVersions(t *testing.T) {
+	c := replayContext(t, "versions")
+	got, err := Versions(c, replayModule)
+	if err != nil {
+		t.Fatalf("Versions: %v", err)
+	}
+	if !contains(got, replayVersion) {
+		t.Errorf("Versions = %v, want it to contain %q", got, replayVersion)
+	}
+}
+
+func TestReplayDefaultVersion(t *testing.T) {
+	c := replayContext(t, "default_version")
+	got, err := DefaultVersion(c, replayModule)
+	if err != nil {
+		t.Fatalf("DefaultVersion: %v", err)
+	}
+	if got != replayVersion {
+		t.Errorf("DefaultVersion = %q, want %q", got, replayVersion)
+	}
+}
+
+func TestReplaySetNumInstances(t *testing.T) {
+	c := replayContext(t, "set_num_instances")
+	if err := SetNumInstances(c, replayModule, replayVersion, 2); err != nil {
+		t.Fatalf("SetNumInstances: %v", err)
+	}
+	got, err := NumInstances(c, replayModule, replayVersion)
+	if err != nil {
+		t.Fatalf("NumInstances: %v", err)
+	}
+	if got != 2 {
+		t.Errorf("NumInstances = %d, want 2", got)
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
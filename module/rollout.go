@@ -0,0 +1,199 @@
+// Copyright 2026 Google LLC. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package module
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+
+	admin "google.golang.org/api/appengine/v1"
+)
+
+// RolloutOption configures a call to RolloutTraffic.
+type RolloutOption func(*rolloutOptions)
+
+type rolloutOptions struct {
+	rollback bool
+}
+
+// Rollback reports whether RolloutTraffic should restore the module's
+// original traffic split when a check fails or the context is canceled.
+func Rollback(rollback bool) RolloutOption {
+	return func(o *rolloutOptions) { o.rollback = rollback }
+}
+
+// RolloutTraffic gradually shifts traffic of the specified module to
+// targetVersion. Each entry of steps is the fraction of traffic targetVersion
+// should receive at that step; the entries must be increasing and in (0, 1].
+// The remaining traffic is shared by the versions that were serving before the
+// rollout, in proportion to their original allocations. The allocations are
+// rounded to the precision that the Admin API accepts, 0.01 or 0.001 with
+// cookie sharding, and the rounding remainder goes to the other version with
+// the largest allocation, so that each split sums to exactly 1.
+//
+// After each step, check is called with the allocation just applied. If check
+// returns an error, or c is done, the rollout stops and that error is returned.
+// With the Rollback option, the original traffic split is restored first.
+// If module is the empty string, it means the default module.
+func RolloutTraffic(c context.Context, module, targetVersion string, steps []float64, check func(ctx context.Context, allocation float64) error, opts ...RolloutOption) (err error) {
+	// check gets the caller's context, so that calls it makes are observed
+	// in their own right.
+	checkCtx := c
+	c, done := startCall(c, "RolloutTraffic", module, targetVersion)
+	defer done(&err)
+	if err := validateNames(module, targetVersion); err != nil {
+		return err
+	}
+	if !useAdminAPI(c) {
+		return ErrNotSupported
+	}
+	var o rolloutOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if targetVersion == "" {
+		return fmt.Errorf("module: target version must not be empty")
+	}
+	prev := 0.0
+	for _, s := range steps {
+		if s <= prev || s > 1 {
+			return fmt.Errorf("module: rollout steps must be increasing and in (0, 1], got %v", steps)
+		}
+		prev = s
+	}
+	if module == "" {
+		module = getModuleorDefault(c)
+	}
+	split, err := trafficSplit(c, module)
+	if err != nil {
+		return err
+	}
+	original := split.Allocations
+	units := allocationUnits(split.ShardBy)
+	var others float64
+	for v, alloc := range original {
+		if v != targetVersion {
+			others += alloc
+		}
+	}
+	// The splits are computed upfront, so that a step that cannot be applied
+	// fails the rollout before it starts.
+	splits := make([]map[string]float64, len(steps))
+	for i, s := range steps {
+		if s < 1 && others == 0 {
+			return fmt.Errorf("module: no versions other than %q are serving traffic in module %q", targetVersion, module)
+		}
+		if splits[i], err = rolloutSplit(original, targetVersion, s, units); err != nil {
+			return err
+		}
+	}
+
+	for i, s := range steps {
+		if err := c.Err(); err != nil {
+			return rollbackTraffic(c, module, original, o.rollback, err)
+		}
+		if err := SetTraffic(c, module, splits[i]); err != nil {
+			return rollbackTraffic(c, module, original, o.rollback, err)
+		}
+		if check == nil {
+			continue
+		}
+		if err := check(checkCtx, s); err != nil {
+			return rollbackTraffic(c, module, original, o.rollback, err)
+		}
+	}
+	return nil
+}
+
+// rollbackTraffic restores the original traffic split of module if rollback
+// is set, and returns cause annotated with the outcome of the rollback.
+func rollbackTraffic(c context.Context, module string, original map[string]float64, rollback bool, cause error) error {
+	if !rollback {
+		return cause
+	}
+	// The rollback must happen even if c has been canceled.
+	if err := SetTraffic(context.WithoutCancel(c), module, original); err != nil {
+		return fmt.Errorf("module: rollout failed: %v; rollback also failed: %v", cause, err)
+	}
+	return fmt.Errorf("module: rollout failed and was rolled back: %w", cause)
+}
+
+// allocationUnits returns the number of parts that the Admin API divides the
+// traffic of a split sharded by shardBy into: allocations may have 3 decimal
+// places with cookie sharding, and 2 otherwise.
+func allocationUnits(shardBy string) int {
+	if shardBy == "COOKIE" {
+		return 1000
+	}
+	return 100
+}
+
+// rolloutSplit returns the split of the rollout step that gives the share s
+// of traffic to target, and the rest to the other versions of original in
+// proportion to their allocations, in whole parts of 1/units. The rounding
+// remainder goes to the other version with the largest allocation, the first
+// by name on ties.
+func rolloutSplit(original map[string]float64, target string, s float64, units int) (map[string]float64, error) {
+	targetParts := int(math.Round(s * float64(units)))
+	if targetParts == 0 {
+		return nil, fmt.Errorf("module: rollout step %v is below the precision of traffic allocations, %v", s, 1/float64(units))
+	}
+	parts := map[string]int{target: targetParts}
+	if rest := units - targetParts; rest > 0 {
+		var versions []string
+		var others float64
+		for v, alloc := range original {
+			if v != target && alloc > 0 {
+				versions = append(versions, v)
+				others += alloc
+			}
+		}
+		sort.Strings(versions)
+		largest, assigned := versions[0], 0
+		for _, v := range versions {
+			if original[v] > original[largest] {
+				largest = v
+			}
+			parts[v] = int(float64(rest) * original[v] / others)
+			assigned += parts[v]
+		}
+		parts[largest] += rest - assigned
+	}
+	split := make(map[string]float64, len(parts))
+	for v, n := range parts {
+		if n > 0 {
+			split[v] = float64(n) / float64(units)
+		}
+	}
+	return split, nil
+}
+
+// trafficSplit returns the current traffic split of module.
+func trafficSplit(c context.Context, module string) (*admin.TrafficSplit, error) {
+	projectID := getProjectID(c)
+	b, err := newAdminBackend(c, "get_traffic_split")
+	if err != nil {
+		return nil, err
+	}
+	service, err := b.GetService(c, projectID, module, "split/allocations,split/shardBy")
+	if err != nil {
+		return nil, err
+	}
+	if service.Split == nil || len(service.Split.Allocations) == 0 {
+		return nil, fmt.Errorf("module: module '%s' has no traffic split", module)
+	}
+	return service.Split, nil
+}
+
+// trafficAllocations returns the current traffic allocations of module.
+func trafficAllocations(c context.Context, module string) (map[string]float64, error) {
+	split, err := trafficSplit(c, module)
+	if err != nil {
+		return nil, err
+	}
+	return split.Allocations, nil
+}
@@ -0,0 +1,210 @@
+// Copyright 2026 Google LLC. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package module
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"sync"
+	"testing"
+
+	admin "google.golang.org/api/appengine/v1"
+)
+
+// rolloutServer serves a module whose split starts at original and records
+// the allocations of every PATCH it receives.
+type rolloutServer struct {
+	mu      sync.Mutex
+	patches []map[string]float64
+}
+
+func newRolloutServer(t *testing.T, original map[string]float64) *rolloutServer {
+	return newShardedRolloutServer(t, original, "")
+}
+
+// newShardedRolloutServer is like newRolloutServer, for a split sharded by
+// shardBy.
+func newShardedRolloutServer(t *testing.T, original map[string]float64, shardBy string) *rolloutServer {
+	rs := &rolloutServer{}
+	newAdminTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			json.NewEncoder(w).Encode(&admin.Service{
+				Id:    "my-module",
+				Split: &admin.TrafficSplit{Allocations: original, ShardBy: shardBy},
+			})
+		case "PATCH":
+			var s admin.Service
+			if err := json.NewDecoder(r.Body).Decode(&s); err != nil {
+				t.Errorf("decoding body: %v", err)
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			rs.mu.Lock()
+			rs.patches = append(rs.patches, s.Split.Allocations)
+			rs.mu.Unlock()
+			json.NewEncoder(w).Encode(&admin.Operation{Name: "apps/test-project/operations/op", Done: true})
+		}
+	})
+	return rs
+}
+
+func sameAllocations(a, b map[string]float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if math.Abs(b[k]-v) > 1e-9 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestRolloutTraffic_CheckFailsWithRollback(t *testing.T) {
+	original := map[string]float64{"v1": 0.5, "v0": 0.5}
+	rs := newRolloutServer(t, original)
+
+	errUnhealthy := errors.New("unhealthy")
+	var checked []float64
+	check := func(ctx context.Context, alloc float64) error {
+		checked = append(checked, alloc)
+		if len(checked) == 2 {
+			return errUnhealthy
+		}
+		return nil
+	}
+	err := RolloutTraffic(context.Background(), "my-module", "v2", []float64{0.1, 0.5, 1}, check, Rollback(true))
+	if !errors.Is(err, errUnhealthy) {
+		t.Fatalf("RolloutTraffic = %v, want wrapped %v", err, errUnhealthy)
+	}
+	want := []map[string]float64{
+		{"v2": 0.1, "v1": 0.45, "v0": 0.45},
+		{"v2": 0.5, "v1": 0.25, "v0": 0.25},
+		original,
+	}
+	if len(rs.patches) != len(want) {
+		t.Fatalf("got %d PATCHes %v, want %d", len(rs.patches), rs.patches, len(want))
+	}
+	for i := range want {
+		if !sameAllocations(rs.patches[i], want[i]) {
+			t.Errorf("PATCH %d = %v, want %v", i, rs.patches[i], want[i])
+		}
+	}
+}
+
+func TestRolloutTraffic_CheckFailsWithoutRollback(t *testing.T) {
+	rs := newRolloutServer(t, map[string]float64{"v1": 1})
+	step := 0
+	check := func(ctx context.Context, alloc float64) error {
+		step++
+		if step == 2 {
+			return errors.New("unhealthy")
+		}
+		return nil
+	}
+	if err := RolloutTraffic(context.Background(), "my-module", "v2", []float64{0.05, 0.25, 1}, check); err == nil {
+		t.Fatal("RolloutTraffic succeeded, want error")
+	}
+	if len(rs.patches) != 2 {
+		t.Errorf("got %d PATCHes %v, want 2", len(rs.patches), rs.patches)
+	}
+}
+
+func TestRolloutTraffic_Success(t *testing.T) {
+	rs := newRolloutServer(t, map[string]float64{"v1": 1})
+	steps := []float64{0.05, 0.25, 0.5, 1}
+	if err := RolloutTraffic(context.Background(), "my-module", "v2", steps, nil); err != nil {
+		t.Fatalf("RolloutTraffic: %v", err)
+	}
+	if len(rs.patches) != len(steps) {
+		t.Fatalf("got %d PATCHes, want %d", len(rs.patches), len(steps))
+	}
+	if last := rs.patches[len(steps)-1]; !sameAllocations(last, map[string]float64{"v2": 1}) {
+		t.Errorf("final split = %v, want all traffic on v2", last)
+	}
+}
+
+func TestRolloutTraffic_Rounding(t *testing.T) {
+	original := map[string]float64{"v1": 0.5, "v2": 0.3, "v3": 0.2}
+	steps := []float64{0.1, 1.0 / 3, 0.5, 1}
+	tests := []struct {
+		shardBy string
+		units   float64
+		third   map[string]float64 // split of the step 1/3
+	}{
+		{"", 100, map[string]float64{"v4": 0.33, "v1": 0.34, "v2": 0.2, "v3": 0.13}},
+		{"IP", 100, map[string]float64{"v4": 0.33, "v1": 0.34, "v2": 0.2, "v3": 0.13}},
+		{"COOKIE", 1000, map[string]float64{"v4": 0.333, "v1": 0.334, "v2": 0.2, "v3": 0.133}},
+	}
+	for _, tt := range tests {
+		t.Run("shardBy="+tt.shardBy, func(t *testing.T) {
+			rs := newShardedRolloutServer(t, original, tt.shardBy)
+			if err := RolloutTraffic(context.Background(), "my-module", "v4", steps, nil); err != nil {
+				t.Fatalf("RolloutTraffic: %v", err)
+			}
+			if len(rs.patches) != len(steps) {
+				t.Fatalf("got %d PATCHes, want %d", len(rs.patches), len(steps))
+			}
+			for i, split := range rs.patches {
+				var parts float64
+				for v, alloc := range split {
+					p := alloc * tt.units
+					if math.Abs(p-math.Round(p)) > 1e-9 {
+						t.Errorf("step %v: allocation %v of %s has more than %v decimal places", steps[i], alloc, v, math.Log10(tt.units))
+					}
+					parts += math.Round(p)
+				}
+				if parts != tt.units {
+					t.Errorf("step %v: split %v sums to %v/%v, want exactly 1", steps[i], split, parts, tt.units)
+				}
+			}
+			if got := rs.patches[1]; !sameAllocations(got, tt.third) {
+				t.Errorf("split of step 1/3 = %v, want %v", got, tt.third)
+			}
+		})
+	}
+
+	// A step that rounds to no traffic is rejected before any change.
+	rs := newRolloutServer(t, original)
+	if err := RolloutTraffic(context.Background(), "my-module", "v4", []float64{0.001, 1}, nil); err == nil {
+		t.Error("RolloutTraffic with a step below the precision succeeded, want an error")
+	}
+	if len(rs.patches) != 0 {
+		t.Errorf("got %d PATCHes, want none", len(rs.patches))
+	}
+}
+
+func TestRolloutTraffic_CanceledRollsBack(t *testing.T) {
+	original := map[string]float64{"v1": 1}
+	rs := newRolloutServer(t, original)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	check := func(ctx context.Context, alloc float64) error {
+		cancel()
+		return nil
+	}
+	err := RolloutTraffic(ctx, "my-module", "v2", []float64{0.5, 1}, check, Rollback(true))
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("RolloutTraffic = %v, want context.Canceled", err)
+	}
+	if len(rs.patches) != 2 || !sameAllocations(rs.patches[1], original) {
+		t.Errorf("PATCHes = %v, want step followed by restore of %v", rs.patches, original)
+	}
+}
+
+func TestRolloutTraffic_InvalidSteps(t *testing.T) {
+	newAdminTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+	})
+	for _, steps := range [][]float64{{0.5, 0.25}, {0}, {0.5, 1.5}} {
+		if err := RolloutTraffic(context.Background(), "my-module", "v2", steps, nil); err == nil {
+			t.Errorf("RolloutTraffic(%v) succeeded, want error", steps)
+		}
+	}
+}
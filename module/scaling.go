@@ -0,0 +1,339 @@
+// Copyright 2026 Google LLC. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package module
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	admin "google.golang.org/api/appengine/v1"
+)
+
+// ScalingType identifies how the instances of a version are scaled.
+type ScalingType string
+
+const (
+	ScalingAutomatic ScalingType = "automatic"
+	ScalingBasic     ScalingType = "basic"
+	ScalingManual    ScalingType = "manual"
+)
+
+// ErrWrongScalingType is matched by errors.Is for every *ScalingTypeError.
+var ErrWrongScalingType = errors.New("module: wrong scaling type")
+
+// ScalingTypeError is returned when an operation requires a version to use a
+// different scaling type than the one it is deployed with.
+type ScalingTypeError struct {
+	Module, Version string
+	Want, Got       ScalingType
+}
+
+func (e *ScalingTypeError) Error() string {
+	return fmt.Sprintf("module: version %s of module %s uses %s scaling, want %s scaling", e.Version, e.Module, e.Got, e.Want)
+}
+
+// Is reports whether target is ErrWrongScalingType.
+func (e *ScalingTypeError) Is(target error) bool {
+	return target == ErrWrongScalingType
+}
+
+// scalingFields are the fields of a version that scalingType reads.
+const scalingFields = "automaticScaling,basicScaling,manualScaling"
+
+// scalingType returns the scaling type of v. Versions without a scaling block
+// use automatic scaling.
+func scalingType(v *admin.Version) ScalingType {
+	switch {
+	case v.BasicScaling != nil:
+		return ScalingBasic
+	case v.ManualScaling != nil:
+		return ScalingManual
+	}
+	return ScalingAutomatic
+}
+
+// AutomaticScalingSettings holds the automatic scaling parameters of a version.
+// Only non-nil fields are changed by SetAutomaticScaling.
+type AutomaticScalingSettings struct {
+	MinIdleInstances      *int
+	MaxIdleInstances      *int
+	MinTotalInstances     *int     // standardSchedulerSettings.minInstances
+	MaxTotalInstances     *int     // standardSchedulerSettings.maxInstances
+	TargetCPUUtilization  *float64 // standardSchedulerSettings.targetCpuUtilization
+	MaxConcurrentRequests *int
+}
+
+// update returns the version update and update mask that apply s.
+func (s AutomaticScalingSettings) update() (*admin.AutomaticScaling, []string) {
+	as := &admin.AutomaticScaling{}
+	ss := &admin.StandardSchedulerSettings{}
+	var mask []string
+	if s.MinIdleInstances != nil {
+		as.MinIdleInstances = int64(*s.MinIdleInstances)
+		as.ForceSendFields = append(as.ForceSendFields, "MinIdleInstances")
+		mask = append(mask, "automaticScaling.minIdleInstances")
+	}
+	if s.MaxIdleInstances != nil {
+		as.MaxIdleInstances = int64(*s.MaxIdleInstances)
+		as.ForceSendFields = append(as.ForceSendFields, "MaxIdleInstances")
+		mask = append(mask, "automaticScaling.maxIdleInstances")
+	}
+	if s.MaxConcurrentRequests != nil {
+		as.MaxConcurrentRequests = int64(*s.MaxConcurrentRequests)
+		as.ForceSendFields = append(as.ForceSendFields, "MaxConcurrentRequests")
+		mask = append(mask, "automaticScaling.maxConcurrentRequests")
+	}
+	if s.MinTotalInstances != nil {
+		ss.MinInstances = int64(*s.MinTotalInstances)
+		ss.ForceSendFields = append(ss.ForceSendFields, "MinInstances")
+		mask = append(mask, "automaticScaling.standardSchedulerSettings.minInstances")
+	}
+	if s.MaxTotalInstances != nil {
+		ss.MaxInstances = int64(*s.MaxTotalInstances)
+		ss.ForceSendFields = append(ss.ForceSendFields, "MaxInstances")
+		mask = append(mask, "automaticScaling.standardSchedulerSettings.maxInstances")
+	}
+	if s.TargetCPUUtilization != nil {
+		ss.TargetCpuUtilization = *s.TargetCPUUtilization
+		ss.ForceSendFields = append(ss.ForceSendFields, "TargetCpuUtilization")
+		mask = append(mask, "automaticScaling.standardSchedulerSettings.targetCpuUtilization")
+	}
+	if len(ss.ForceSendFields) > 0 {
+		as.StandardSchedulerSettings = ss
+	}
+	return as, mask
+}
+
+// SetAutomaticScaling updates the automatic scaling parameters of the given
+// module.version. Only the fields of s that are set are changed. If either
+// module or version are the empty string it means the default.
+//
+// It returns ErrVersionNotFound if the version does not exist, a
+// *ScalingTypeError if it does not use automatic scaling, and ErrNotSupported
+// on the legacy backend.
+func SetAutomaticScaling(c context.Context, module, version string, s AutomaticScalingSettings) (err error) {
+	c, done := startCall(c, "SetAutomaticScaling", module, version)
+	defer done(&err)
+	if err := validateNames(module, version); err != nil {
+		return err
+	}
+	if !useAdminAPI(c) {
+		return ErrNotSupported
+	}
+	as, mask := s.update()
+	if len(mask) == 0 {
+		return fmt.Errorf("module: no automatic scaling settings given")
+	}
+	module, version, err = defaultModuleVersion(c, module, version)
+	if err != nil {
+		return err
+	}
+	projectID := getProjectID(c)
+	b, err := newAdminBackend(c, "set_automatic_scaling")
+	if err != nil {
+		return err
+	}
+	v, err := b.GetVersion(c, projectID, module, version, "", scalingFields)
+	if err != nil {
+		return err
+	}
+	if got := scalingType(v); got != ScalingAutomatic {
+		return &ScalingTypeError{Module: module, Version: version, Want: ScalingAutomatic, Got: got}
+	}
+	update := &admin.Version{AutomaticScaling: as}
+	_, err = patchVersion(c, b, projectID, module, version, update, mask, true)
+	return err
+}
+
+// SetBasicScaling updates the basic scaling parameters of the given
+// module.version. A zero maxInstances or idleTimeout leaves the corresponding
+// setting unchanged. If either module or version are the empty string it means
+// the default.
+//
+// It returns ErrVersionNotFound if the version does not exist, a
+// *ScalingTypeError if it does not use basic scaling, and ErrNotSupported on
+// the legacy backend.
+func SetBasicScaling(c context.Context, module, version string, maxInstances int, idleTimeout time.Duration) (err error) {
+	c, done := startCall(c, "SetBasicScaling", module, version)
+	defer done(&err)
+	if err := validateNames(module, version); err != nil {
+		return err
+	}
+	if !useAdminAPI(c) {
+		return ErrNotSupported
+	}
+	if maxInstances < 0 || idleTimeout < 0 {
+		return fmt.Errorf("module: basic scaling settings must not be negative")
+	}
+	bs := &admin.BasicScaling{}
+	var mask []string
+	if maxInstances > 0 {
+		bs.MaxInstances = int64(maxInstances)
+		mask = append(mask, "basicScaling.maxInstances")
+	}
+	if idleTimeout > 0 {
+		bs.IdleTimeout = formatDuration(idleTimeout)
+		mask = append(mask, "basicScaling.idleTimeout")
+	}
+	if len(mask) == 0 {
+		return fmt.Errorf("module: no basic scaling settings given")
+	}
+	module, version, err = defaultModuleVersion(c, module, version)
+	if err != nil {
+		return err
+	}
+	projectID := getProjectID(c)
+	b, err := newAdminBackend(c, "set_basic_scaling")
+	if err != nil {
+		return err
+	}
+	v, err := b.GetVersion(c, projectID, module, version, "", scalingFields)
+	if err != nil {
+		return err
+	}
+	if got := scalingType(v); got != ScalingBasic {
+		return &ScalingTypeError{Module: module, Version: version, Want: ScalingBasic, Got: got}
+	}
+	update := &admin.Version{BasicScaling: bs}
+	_, err = patchVersion(c, b, projectID, module, version, update, mask, true)
+	return err
+}
+
+// formatDuration formats d in the Admin API's duration syntax, e.g. "600s".
+func formatDuration(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', -1, 64) + "s"
+}
+
+// Instance classes of the standard environment. F classes are for automatic
+// scaling; B classes are for basic and manual scaling.
+var (
+	frontendClasses = map[string]bool{"F1": true, "F2": true, "F4": true, "F4_1G": true}
+	backendClasses  = map[string]bool{"B1": true, "B2": true, "B4": true, "B4_1G": true, "B8": true}
+)
+
+// InstanceClassError is returned by SetInstanceClass when the instance class
+// cannot be used with the scaling type of the version.
+type InstanceClassError struct {
+	Module, Version string
+	Class           string
+	Scaling         ScalingType
+}
+
+func (e *InstanceClassError) Error() string {
+	return fmt.Sprintf("module: instance class %s cannot be used with %s scaling (version %s of module %s)", e.Class, e.Scaling, e.Version, e.Module)
+}
+
+// SetInstanceClass changes the instance class of the given module.version,
+// e.g. from "F1" to "F4". If either module or version are the empty string it
+// means the default.
+//
+// F classes may only be used by automatic scaling versions, and B classes by
+// manual or basic scaling versions; SetInstanceClass returns an
+// *InstanceClassError rather than sending a mismatched class to the server.
+func SetInstanceClass(c context.Context, module, version, class string) (err error) {
+	c, done := startCall(c, "SetInstanceClass", module, version)
+	defer done(&err)
+	if err := validateNames(module, version); err != nil {
+		return err
+	}
+	if !useAdminAPI(c) {
+		return ErrNotSupported
+	}
+	if !frontendClasses[class] && !backendClasses[class] {
+		return fmt.Errorf("module: unknown instance class %q", class)
+	}
+	module, version, err = defaultModuleVersion(c, module, version)
+	if err != nil {
+		return err
+	}
+	projectID := getProjectID(c)
+	b, err := newAdminBackend(c, "set_instance_class")
+	if err != nil {
+		return err
+	}
+	v, err := b.GetVersion(c, projectID, module, version, "", scalingFields)
+	if err != nil {
+		return err
+	}
+	st := scalingType(v)
+	if (st == ScalingAutomatic) != frontendClasses[class] {
+		return &InstanceClassError{Module: module, Version: version, Class: class, Scaling: st}
+	}
+	update := &admin.Version{InstanceClass: class}
+	_, err = patchVersion(c, b, projectID, module, version, update, []string{"instanceClass"}, true)
+	return err
+}
+
+// InstancesOption configures a call to SetNumInstancesResult.
+type InstancesOption func(*instancesOptions)
+
+type instancesOptions struct {
+	skipUnchanged bool
+}
+
+// SkipIfUnchanged reports whether SetNumInstancesResult should leave a
+// version alone if it already has the requested number of instances, instead
+// of issuing an update that restarts its instances.
+func SkipIfUnchanged(skip bool) InstancesOption {
+	return func(o *instancesOptions) { o.skipUnchanged = skip }
+}
+
+// SetNumInstancesResult is like SetNumInstances, but first reads the current
+// number of instances of the manual scaling version, which it returns as
+// previous, and reports whether the number changed. With the SkipIfUnchanged
+// option, no update is made if the version already has the requested number
+// of instances. On the Admin API, SetNumInstancesResult waits for the change
+// to take effect before returning.
+func SetNumInstancesResult(c context.Context, module, version string, instances int, opts ...InstancesOption) (previous int, changed bool, err error) {
+	c, done := startCall(c, "SetNumInstancesResult", module, version)
+	defer done(&err)
+	if err := validateNames(module, version); err != nil {
+		return 0, false, err
+	}
+	var o instancesOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	previous, err = NumInstances(c, module, version)
+	if err != nil {
+		return 0, false, err
+	}
+	changed = previous != instances
+	if !changed && o.skipUnchanged {
+		return previous, false, nil
+	}
+	if err := setNumInstances(c, module, version, instances, true); err != nil {
+		return previous, false, err
+	}
+	return previous, changed, nil
+}
+
+// EnsureMinInstances makes sure that the manual scaling version module.version
+// has at least min instances, raising its number of instances to min if it
+// has fewer. It never lowers the number of instances. It reports whether a
+// change was made; on the Admin API, it waits for the change to take effect
+// before returning. If either module or version are the empty string it means
+// the default.
+func EnsureMinInstances(c context.Context, module, version string, min int) (scaled bool, err error) {
+	c, done := startCall(c, "EnsureMinInstances", module, version)
+	defer done(&err)
+	if err := validateNames(module, version); err != nil {
+		return false, err
+	}
+	n, err := NumInstances(c, module, version)
+	if err != nil {
+		return false, err
+	}
+	if n >= min {
+		return false, nil
+	}
+	if err := setNumInstances(c, module, version, min, true); err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,412 @@
+// Copyright 2026 Google LLC. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package module
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	admin "google.golang.org/api/appengine/v1"
+)
+
+func intPtr(n int) *int           { return &n }
+func floatPtr(f float64) *float64 { return &f }
+
+func TestSetAutomaticScaling_AdminAPI(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings AutomaticScalingSettings
+		wantMask string
+		wantBody string
+	}{
+		{
+			name:     "MinIdleOnly",
+			settings: AutomaticScalingSettings{MinIdleInstances: intPtr(2)},
+			wantMask: "automaticScaling.minIdleInstances",
+			wantBody: `{"automaticScaling":{"minIdleInstances":2}}`,
+		},
+		{
+			name:     "ZeroIsSent",
+			settings: AutomaticScalingSettings{MaxIdleInstances: intPtr(0)},
+			wantMask: "automaticScaling.maxIdleInstances",
+			wantBody: `{"automaticScaling":{"maxIdleInstances":0}}`,
+		},
+		{
+			name: "SchedulerSettings",
+			settings: AutomaticScalingSettings{
+				MinTotalInstances:    intPtr(1),
+				MaxTotalInstances:    intPtr(20),
+				TargetCPUUtilization: floatPtr(0.65),
+			},
+			wantMask: "automaticScaling.standardSchedulerSettings.minInstances," +
+				"automaticScaling.standardSchedulerSettings.maxInstances," +
+				"automaticScaling.standardSchedulerSettings.targetCpuUtilization",
+			wantBody: `{"automaticScaling":{"standardSchedulerSettings":{"maxInstances":20,"minInstances":1,"targetCpuUtilization":0.65}}}`,
+		},
+		{
+			name: "Everything",
+			settings: AutomaticScalingSettings{
+				MinIdleInstances:      intPtr(1),
+				MaxIdleInstances:      intPtr(3),
+				MinTotalInstances:     intPtr(2),
+				MaxTotalInstances:     intPtr(10),
+				TargetCPUUtilization:  floatPtr(0.5),
+				MaxConcurrentRequests: intPtr(40),
+			},
+			wantMask: "automaticScaling.minIdleInstances," +
+				"automaticScaling.maxIdleInstances," +
+				"automaticScaling.maxConcurrentRequests," +
+				"automaticScaling.standardSchedulerSettings.minInstances," +
+				"automaticScaling.standardSchedulerSettings.maxInstances," +
+				"automaticScaling.standardSchedulerSettings.targetCpuUtilization",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			patched := false
+			newAdminTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/v1/apps/test-project/services/my-module/versions/v1" {
+					t.Errorf("Path = %s", r.URL.Path)
+				}
+				switch r.Method {
+				case "GET":
+					json.NewEncoder(w).Encode(&admin.Version{Id: "v1", AutomaticScaling: &admin.AutomaticScaling{}})
+				case "PATCH":
+					patched = true
+					if got := r.URL.Query().Get("updateMask"); got != tt.wantMask {
+						t.Errorf("updateMask = %q, want %q", got, tt.wantMask)
+					}
+					if tt.wantBody != "" {
+						var got, want interface{}
+						json.NewDecoder(r.Body).Decode(&got)
+						json.Unmarshal([]byte(tt.wantBody), &want)
+						gb, _ := json.Marshal(got)
+						wb, _ := json.Marshal(want)
+						if string(gb) != string(wb) {
+							t.Errorf("body = %s, want %s", gb, wb)
+						}
+					}
+					json.NewEncoder(w).Encode(&admin.Operation{Name: "apps/test-project/operations/op", Done: true})
+				}
+			})
+			if err := SetAutomaticScaling(context.Background(), "my-module", "v1", tt.settings); err != nil {
+				t.Fatalf("SetAutomaticScaling: %v", err)
+			}
+			if !patched {
+				t.Error("no PATCH was issued")
+			}
+		})
+	}
+}
+
+func TestSetAutomaticScaling_WrongScalingType(t *testing.T) {
+	newAdminTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			t.Errorf("unexpected %s request", r.Method)
+		}
+		json.NewEncoder(w).Encode(&admin.Version{Id: "v1", ManualScaling: &admin.ManualScaling{Instances: 2}})
+	})
+	err := SetAutomaticScaling(context.Background(), "my-module", "v1", AutomaticScalingSettings{MinIdleInstances: intPtr(1)})
+	if !errors.Is(err, ErrWrongScalingType) {
+		t.Fatalf("SetAutomaticScaling = %v, want ErrWrongScalingType", err)
+	}
+	var se *ScalingTypeError
+	if !errors.As(err, &se) || se.Got != ScalingManual || se.Want != ScalingAutomatic {
+		t.Errorf("error = %#v, want manual vs automatic ScalingTypeError", err)
+	}
+}
+
+func TestSetAutomaticScaling_NoScalingBlock(t *testing.T) {
+	patched := false
+	newAdminTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			json.NewEncoder(w).Encode(&admin.Version{Id: "v1"})
+		case "PATCH":
+			patched = true
+			json.NewEncoder(w).Encode(&admin.Operation{Name: "apps/test-project/operations/op", Done: true})
+		}
+	})
+	// Versions without a scaling block use automatic scaling, as for
+	// SetInstanceClass.
+	if err := SetAutomaticScaling(context.Background(), "my-module", "v1", AutomaticScalingSettings{MinIdleInstances: intPtr(1)}); err != nil {
+		t.Fatalf("SetAutomaticScaling: %v", err)
+	}
+	if !patched {
+		t.Error("no PATCH was issued")
+	}
+}
+
+func TestSetAutomaticScaling_NoSettings(t *testing.T) {
+	newAdminTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+	})
+	if err := SetAutomaticScaling(context.Background(), "my-module", "v1", AutomaticScalingSettings{}); err == nil {
+		t.Error("SetAutomaticScaling succeeded, want error")
+	}
+}
+
+func TestSetBasicScaling_AdminAPI(t *testing.T) {
+	tests := []struct {
+		name         string
+		maxInstances int
+		idleTimeout  time.Duration
+		wantMask     string
+		want         admin.BasicScaling
+	}{
+		{"Both", 5, 10 * time.Minute, "basicScaling.maxInstances,basicScaling.idleTimeout", admin.BasicScaling{MaxInstances: 5, IdleTimeout: "600s"}},
+		{"MaxOnly", 3, 0, "basicScaling.maxInstances", admin.BasicScaling{MaxInstances: 3}},
+		{"IdleOnly", 0, 1500 * time.Millisecond, "basicScaling.idleTimeout", admin.BasicScaling{IdleTimeout: "1.5s"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var patched, polled bool
+			newAdminTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+				switch {
+				case r.Method == "GET" && r.URL.Path == "/v1/apps/test-project/operations/op":
+					polled = true
+					json.NewEncoder(w).Encode(&admin.Operation{Name: "apps/test-project/operations/op", Done: true})
+				case r.Method == "GET":
+					json.NewEncoder(w).Encode(&admin.Version{Id: "v1", BasicScaling: &admin.BasicScaling{MaxInstances: 1}})
+				case r.Method == "PATCH":
+					patched = true
+					if got := r.URL.Query().Get("updateMask"); got != tt.wantMask {
+						t.Errorf("updateMask = %q, want %q", got, tt.wantMask)
+					}
+					var v admin.Version
+					json.NewDecoder(r.Body).Decode(&v)
+					if v.BasicScaling == nil || v.BasicScaling.MaxInstances != tt.want.MaxInstances || v.BasicScaling.IdleTimeout != tt.want.IdleTimeout {
+						t.Errorf("basicScaling = %+v, want %+v", v.BasicScaling, tt.want)
+					}
+					json.NewEncoder(w).Encode(&admin.Operation{Name: "apps/test-project/operations/op"})
+				}
+			})
+			if err := SetBasicScaling(context.Background(), "my-module", "v1", tt.maxInstances, tt.idleTimeout); err != nil {
+				t.Fatalf("SetBasicScaling: %v", err)
+			}
+			if !patched || !polled {
+				t.Errorf("patched = %v, polled = %v; want both true", patched, polled)
+			}
+		})
+	}
+}
+
+func TestSetBasicScaling_WrongScalingType(t *testing.T) {
+	for _, v := range []*admin.Version{
+		{Id: "v1", ManualScaling: &admin.ManualScaling{Instances: 1}},
+		{Id: "v1", AutomaticScaling: &admin.AutomaticScaling{}},
+		{Id: "v1"},
+	} {
+		newAdminTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != "GET" {
+				t.Errorf("unexpected %s request", r.Method)
+			}
+			json.NewEncoder(w).Encode(v)
+		})
+		err := SetBasicScaling(context.Background(), "my-module", "v1", 2, 0)
+		if !errors.Is(err, ErrWrongScalingType) {
+			t.Errorf("SetBasicScaling on %s version = %v, want ErrWrongScalingType", scalingType(v), err)
+		}
+	}
+}
+
+func TestSetBasicScaling_NoSettings(t *testing.T) {
+	newAdminTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+	})
+	if err := SetBasicScaling(context.Background(), "my-module", "v1", 0, 0); err == nil {
+		t.Error("SetBasicScaling succeeded, want error")
+	}
+}
+
+func TestSetInstanceClass_AdminAPI(t *testing.T) {
+	tests := []struct {
+		name    string
+		version *admin.Version
+		class   string
+		wantErr bool
+	}{
+		{"AutomaticToF4", &admin.Version{Id: "v1", InstanceClass: "F1", AutomaticScaling: &admin.AutomaticScaling{}}, "F4", false},
+		{"ManualToB4", &admin.Version{Id: "v1", InstanceClass: "B1", ManualScaling: &admin.ManualScaling{}}, "B4", false},
+		{"AutomaticWithBClass", &admin.Version{Id: "v1", AutomaticScaling: &admin.AutomaticScaling{}}, "B2", true},
+		{"BasicWithFClass", &admin.Version{Id: "v1", BasicScaling: &admin.BasicScaling{}}, "F2", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			patched := false
+			newAdminTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+				switch r.Method {
+				case "GET":
+					json.NewEncoder(w).Encode(tt.version)
+				case "PATCH":
+					patched = true
+					if got := r.URL.Query().Get("updateMask"); got != "instanceClass" {
+						t.Errorf("updateMask = %q, want instanceClass", got)
+					}
+					var v admin.Version
+					json.NewDecoder(r.Body).Decode(&v)
+					if v.InstanceClass != tt.class {
+						t.Errorf("instanceClass = %q, want %q", v.InstanceClass, tt.class)
+					}
+					json.NewEncoder(w).Encode(&admin.Operation{Name: "apps/test-project/operations/op", Done: true})
+				}
+			})
+			err := SetInstanceClass(context.Background(), "my-module", "v1", tt.class)
+			if tt.wantErr {
+				var ice *InstanceClassError
+				if !errors.As(err, &ice) || ice.Class != tt.class {
+					t.Errorf("SetInstanceClass = %v, want InstanceClassError", err)
+				}
+				if patched {
+					t.Error("PATCH issued for mismatched instance class")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("SetInstanceClass: %v", err)
+			}
+			if !patched {
+				t.Error("no PATCH was issued")
+			}
+		})
+	}
+}
+
+func TestSetInstanceClass_UnknownClass(t *testing.T) {
+	newAdminTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+	})
+	if err := SetInstanceClass(context.Background(), "my-module", "v1", "F3"); err == nil {
+		t.Error("SetInstanceClass succeeded, want error")
+	}
+}
+
+func TestSetNumInstancesResult(t *testing.T) {
+	tests := []struct {
+		name        string
+		current     int64
+		target      int
+		opts        []InstancesOption
+		wantChanged bool
+		wantPatch   bool
+	}{
+		{name: "Unchanged", current: 3, target: 3, opts: []InstancesOption{SkipIfUnchanged(true)}},
+		{name: "UnchangedNoSkip", current: 3, target: 3, wantPatch: true},
+		{name: "Changed", current: 2, target: 5, opts: []InstancesOption{SkipIfUnchanged(true)}, wantChanged: true, wantPatch: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			patched := false
+			newAdminTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+				switch r.Method {
+				case "GET":
+					if got := r.URL.Query().Get("fields"); got != "manualScaling" {
+						t.Errorf("fields = %q, want manualScaling", got)
+					}
+					json.NewEncoder(w).Encode(&admin.Version{ManualScaling: &admin.ManualScaling{Instances: tt.current}})
+				case "PATCH":
+					patched = true
+					var v admin.Version
+					json.NewDecoder(r.Body).Decode(&v)
+					if v.ManualScaling == nil || v.ManualScaling.Instances != int64(tt.target) {
+						t.Errorf("PATCH body = %+v, want %d instances", v.ManualScaling, tt.target)
+					}
+					json.NewEncoder(w).Encode(&admin.Operation{Name: "apps/test-project/operations/op1", Done: true})
+				default:
+					t.Errorf("unexpected %s request", r.Method)
+				}
+			})
+			previous, changed, err := SetNumInstancesResult(context.Background(), "my-module", "v1", tt.target, tt.opts...)
+			if err != nil {
+				t.Fatalf("SetNumInstancesResult: %v", err)
+			}
+			if previous != int(tt.current) || changed != tt.wantChanged {
+				t.Errorf("SetNumInstancesResult = (%d, %v), want (%d, %v)", previous, changed, tt.current, tt.wantChanged)
+			}
+			if patched != tt.wantPatch {
+				t.Errorf("PATCH issued = %v, want %v", patched, tt.wantPatch)
+			}
+		})
+	}
+}
+
+func TestSetNumInstancesResult_NotManualScaling(t *testing.T) {
+	newAdminTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			t.Errorf("unexpected %s request", r.Method)
+		}
+		json.NewEncoder(w).Encode(&admin.Version{})
+	})
+	if _, _, err := SetNumInstancesResult(context.Background(), "my-module", "v1", 2, SkipIfUnchanged(true)); err == nil {
+		t.Error("SetNumInstancesResult succeeded, want error")
+	}
+}
+
+func TestEnsureMinInstances(t *testing.T) {
+	tests := []struct {
+		name       string
+		current    int64
+		wantScaled bool
+	}{
+		{name: "BelowMin", current: 1, wantScaled: true},
+		{name: "AtMin", current: 3},
+		{name: "AboveMin", current: 6},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var patched, polled bool
+			newAdminTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+				switch {
+				case r.Method == "GET" && r.URL.Path == "/v1/apps/test-project/services/my-module/versions/v1":
+					json.NewEncoder(w).Encode(&admin.Version{ManualScaling: &admin.ManualScaling{Instances: tt.current}})
+				case r.Method == "PATCH":
+					patched = true
+					var v admin.Version
+					json.NewDecoder(r.Body).Decode(&v)
+					if v.ManualScaling == nil || v.ManualScaling.Instances != 3 {
+						t.Errorf("PATCH body = %+v, want 3 instances", v.ManualScaling)
+					}
+					json.NewEncoder(w).Encode(&admin.Operation{Name: "apps/test-project/operations/op1"})
+				case r.Method == "GET" && r.URL.Path == "/v1/apps/test-project/operations/op1":
+					polled = true
+					json.NewEncoder(w).Encode(&admin.Operation{Name: "apps/test-project/operations/op1", Done: true})
+				default:
+					t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+				}
+			})
+			oldInterval := operationPollInterval
+			operationPollInterval = time.Millisecond
+			t.Cleanup(func() { operationPollInterval = oldInterval })
+			scaled, err := EnsureMinInstances(context.Background(), "my-module", "v1", 3)
+			if err != nil {
+				t.Fatalf("EnsureMinInstances: %v", err)
+			}
+			if scaled != tt.wantScaled || patched != tt.wantScaled {
+				t.Errorf("EnsureMinInstances = %v with PATCH issued %v, want %v", scaled, patched, tt.wantScaled)
+			}
+			if patched && !polled {
+				t.Error("EnsureMinInstances returned before the operation completed")
+			}
+		})
+	}
+}
+
+func TestEnsureMinInstances_NotManualScaling(t *testing.T) {
+	newAdminTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			t.Errorf("unexpected %s request", r.Method)
+		}
+		json.NewEncoder(w).Encode(&admin.Version{})
+	})
+	_, want := NumInstances(context.Background(), "my-module", "v1")
+	scaled, err := EnsureMinInstances(context.Background(), "my-module", "v1", 2)
+	if scaled || err == nil || err.Error() != want.Error() {
+		t.Errorf("EnsureMinInstances = %v, %v; want false, %v", scaled, err, want)
+	}
+}
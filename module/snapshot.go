@@ -0,0 +1,178 @@
+// Copyright 2026 Google LLC. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package module
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// AppSnapshot is the topology of an application at one point in time, as
+// returned by Snapshot. It is meant to be serialized, for example with
+// encoding/json.
+type AppSnapshot struct {
+	App      *Application       `json:"app,omitempty"`
+	Services []*ServiceSnapshot `json:"services"`
+	// Error is set if the application details could not be fetched; App is
+	// then nil.
+	Error string `json:"error,omitempty"`
+}
+
+// ServiceSnapshot describes a module and its versions in an AppSnapshot.
+type ServiceSnapshot struct {
+	Name     string             `json:"name"`
+	Traffic  map[string]float64 `json:"traffic,omitempty"` // fraction of traffic by version
+	ShardBy  string             `json:"shardBy,omitempty"`
+	Versions []*VersionSnapshot `json:"versions,omitempty"`
+	// Error is set if the versions of the module could not be fetched.
+	Error string `json:"error,omitempty"`
+}
+
+// VersionSnapshot describes a version in an AppSnapshot.
+type VersionSnapshot struct {
+	ID            string      `json:"id"`
+	ServingStatus string      `json:"servingStatus"`
+	Env           string      `json:"env,omitempty"`
+	Scaling       ScalingType `json:"scaling,omitempty"`
+	Instances     int         `json:"instances,omitempty"`    // manual scaling only
+	MaxInstances  int         `json:"maxInstances,omitempty"` // basic scaling only
+	InstanceClass string      `json:"instanceClass,omitempty"`
+	CreateTime    time.Time   `json:"createTime"`
+}
+
+// SnapshotOption configures a call to Snapshot.
+type SnapshotOption func(*snapshotOptions)
+
+type snapshotOptions struct {
+	strict      bool
+	concurrency int
+}
+
+// StrictSnapshot reports whether Snapshot should fail as a whole if any part
+// of the application cannot be fetched, instead of recording the failure in
+// the Error field of the part concerned.
+func StrictSnapshot(strict bool) SnapshotOption {
+	return func(o *snapshotOptions) { o.strict = strict }
+}
+
+// SnapshotConcurrency sets the maximum number of concurrent requests issued by
+// Snapshot. Values less than one mean one. The default is 8.
+func SnapshotConcurrency(n int) SnapshotOption {
+	return func(o *snapshotOptions) {
+		if n < 1 {
+			n = 1
+		}
+		o.concurrency = n
+	}
+}
+
+// snapshotVersionFields are the version fields that Snapshot fetches.
+var snapshotVersionFields = []string{"id", "servingStatus", "env", "instanceClass", "createTime",
+	"automaticScaling", "basicScaling", "manualScaling"}
+
+// Snapshot returns the topology of the application: its details, and every
+// module with its traffic split and versions. The versions of different
+// modules are fetched concurrently, see SnapshotConcurrency. Modules and
+// versions are sorted by name.
+//
+// Unless StrictSnapshot is used, a part of the application that cannot be
+// fetched is reported in its Error field and the rest of the snapshot is
+// returned. Only a failure to list the modules fails the whole snapshot.
+// With StrictSnapshot, Snapshot fails instead: with the error of AppInfo, or
+// with an appengine.MultiError of *ModuleError values indexed like the sorted
+// modules. Snapshot returns ErrNotSupported on the legacy backend.
+func Snapshot(c context.Context, opts ...SnapshotOption) (_ *AppSnapshot, err error) {
+	c, done := startCall(c, "Snapshot", "", "")
+	defer done(&err)
+	o := snapshotOptions{concurrency: batchConcurrency}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if !useAdminAPI(c) {
+		return nil, ErrNotSupported
+	}
+	projectID := getProjectID(c)
+	b, err := newAdminBackend(c, "snapshot")
+	if err != nil {
+		return nil, err
+	}
+
+	snap := &AppSnapshot{Services: []*ServiceSnapshot{}}
+	for token := ""; ; {
+		resp, err := b.ListServices(c, projectID, token, "services(id,split),nextPageToken")
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range resp.Services {
+			ss := &ServiceSnapshot{Name: s.Id}
+			if s.Split != nil {
+				ss.Traffic, ss.ShardBy = s.Split.Allocations, s.Split.ShardBy
+			}
+			snap.Services = append(snap.Services, ss)
+		}
+		if token = resp.NextPageToken; token == "" {
+			break
+		}
+	}
+	sort.Slice(snap.Services, func(i, j int) bool { return snap.Services[i].Name < snap.Services[j].Name })
+
+	var wg sync.WaitGroup
+	var appErr error
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		snap.App, appErr = AppInfo(c)
+	}()
+	errs := forEach(c, len(snap.Services), o.concurrency, func(i int) error {
+		ss := snap.Services[i]
+		versions, err := listVersions(c, b, projectID, ss.Name, snapshotVersionFields)
+		if err != nil {
+			return err
+		}
+		for _, v := range versions {
+			vs := &VersionSnapshot{
+				ID:            v.Id,
+				ServingStatus: v.ServingStatus,
+				Env:           v.Env,
+				Scaling:       scalingType(v),
+				InstanceClass: v.InstanceClass,
+			}
+			vs.CreateTime, _ = time.Parse(time.RFC3339Nano, v.CreateTime)
+			if v.ManualScaling != nil {
+				vs.Instances = int(v.ManualScaling.Instances)
+			}
+			if v.BasicScaling != nil {
+				vs.MaxInstances = int(v.BasicScaling.MaxInstances)
+			}
+			ss.Versions = append(ss.Versions, vs)
+		}
+		sort.Slice(ss.Versions, func(i, j int) bool { return ss.Versions[i].ID < ss.Versions[j].ID })
+		return nil
+	})
+	wg.Wait()
+
+	if o.strict {
+		if appErr != nil {
+			return nil, appErr
+		}
+		if err := batchError(c, errs, func(i int, err error) error {
+			return &ModuleError{Module: snap.Services[i].Name, Err: err}
+		}); err != nil {
+			return nil, err
+		}
+		return snap, nil
+	}
+	if appErr != nil {
+		snap.Error = appErr.Error()
+	}
+	for i, err := range errs {
+		if err != nil {
+			snap.Services[i].Error = err.Error()
+		}
+	}
+	return snap, nil
+}
@@ -0,0 +1,90 @@
+// Copyright 2026 Google LLC. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package module
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"google.golang.org/appengine"
+)
+
+// snapshotServer serves an application with three modules, the versions of
+// one of which cannot be listed.
+func snapshotServer(t *testing.T) {
+	InvalidateCache()
+	t.Cleanup(InvalidateCache)
+	newAdminTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		const prefix = "/v1/apps/test-project"
+		switch r.URL.Path {
+		case prefix:
+			fmt.Fprint(w, `{"id": "test-project", "locationId": "us-central", "defaultHostname": "test-project.appspot.com", "servingStatus": "SERVING"}`)
+		case prefix + "/services":
+			fmt.Fprint(w, `{"services": [
+				{"id": "worker", "split": {"allocations": {"w1": 1}}},
+				{"id": "default", "split": {"allocations": {"v1": 0.75, "v2": 0.25}, "shardBy": "COOKIE"}},
+				{"id": "broken"}]}`)
+		case prefix + "/services/default/versions":
+			fmt.Fprint(w, `{"versions": [
+				{"id": "v2", "servingStatus": "SERVING", "env": "standard", "manualScaling": {"instances": 3}, "instanceClass": "B2", "createTime": "2026-02-01T10:00:00Z"},
+				{"id": "v1", "servingStatus": "SERVING", "env": "standard", "automaticScaling": {}, "createTime": "2026-01-01T10:00:00Z"}]}`)
+		case prefix + "/services/worker/versions":
+			fmt.Fprint(w, `{"versions": [
+				{"id": "w1", "servingStatus": "STOPPED", "env": "flexible", "basicScaling": {"maxInstances": 5}, "createTime": "2026-03-01T10:00:00.5Z"}]}`)
+		case prefix + "/services/broken/versions":
+			http.Error(w, `{"error": {"code": 400, "message": "boom"}}`, http.StatusBadRequest)
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			http.NotFound(w, r)
+		}
+	})
+}
+
+func TestSnapshot(t *testing.T) {
+	snapshotServer(t)
+	snap, err := Snapshot(context.Background(), SnapshotConcurrency(2))
+	if err != nil {
+		t.Fatalf("Snapshot: %v", err)
+	}
+	got, err := json.MarshalIndent(snap, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := ioutil.ReadFile("testdata/snapshot.golden")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got)+"\n" != string(want) {
+		t.Errorf("Snapshot serialized as\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestSnapshot_Strict(t *testing.T) {
+	snapshotServer(t)
+	snap, err := Snapshot(context.Background(), StrictSnapshot(true))
+	if snap != nil {
+		t.Errorf("Snapshot returned %+v along with its error", snap)
+	}
+	failed, ok := IsPartialFailure(err)
+	if !ok || len(failed) != 1 {
+		t.Fatalf("Snapshot error = %v, want a failure of one module", err)
+	}
+	var modErr *ModuleError
+	if e := err.(appengine.MultiError)[failed[0]]; !errors.As(e, &modErr) || modErr.Module != "broken" {
+		t.Errorf("Snapshot error = %v, want a ModuleError for broken", e)
+	}
+}
+
+func TestSnapshot_Legacy(t *testing.T) {
+	t.Setenv("MODULES_USE_ADMIN_API", "false")
+	if _, err := Snapshot(context.Background()); err != ErrNotSupported {
+		t.Errorf("Snapshot = %v, want ErrNotSupported", err)
+	}
+}
@@ -0,0 +1,71 @@
+// Copyright 2026 Google LLC. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package module
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/appengine/internal"
+)
+
+var defaultTimeout = struct {
+	sync.Mutex
+	read, write time.Duration
+}{read: 30 * time.Second, write: 60 * time.Second}
+
+// SetDefaultTimeout sets the timeouts applied to Admin API calls made with a
+// context that has no deadline. read bounds each request that only reads
+// state; write bounds each request that changes state, and separately the wait
+// for the change to take effect. A zero duration disables the corresponding
+// timeout. The defaults are 30 seconds for reads and 60 seconds for writes.
+//
+// The timeouts apply to the calls of the legacy modules API too. Contexts that
+// carry a deadline are used as they are.
+func SetDefaultTimeout(read, write time.Duration) {
+	defaultTimeout.Lock()
+	defaultTimeout.read, defaultTimeout.write = read, write
+	defaultTimeout.Unlock()
+}
+
+// withDefaultTimeout returns c bounded by the default read or write timeout,
+// unless c already has a deadline or the timeout is disabled.
+func withDefaultTimeout(c context.Context, write bool) (context.Context, context.CancelFunc) {
+	if _, ok := c.Deadline(); ok {
+		return c, func() {}
+	}
+	d := defaultTimeoutFor(write)
+	if d <= 0 {
+		return c, func() {}
+	}
+	return context.WithTimeout(c, d)
+}
+
+func defaultTimeoutFor(write bool) time.Duration {
+	defaultTimeout.Lock()
+	defer defaultTimeout.Unlock()
+	if write {
+		return defaultTimeout.write
+	}
+	return defaultTimeout.read
+}
+
+// legacyCall calls method of the legacy modules API. As on the Admin API, the
+// default read or write timeout bounds the call if c has no deadline, and the
+// error is translated unless c asks for raw errors.
+func legacyCall(c context.Context, method string, write bool, in, out proto.Message) error {
+	if _, ok := c.Deadline(); !ok {
+		if d := defaultTimeoutFor(write); d > 0 {
+			c = internal.WithCallTimeout(c, d)
+		}
+	}
+	err := internal.Call(c, "modules", method, in, out)
+	if err != nil && !rawErrors(c) {
+		err = translateLegacyError(err)
+	}
+	return err
+}
@@ -0,0 +1,84 @@
+// Copyright 2026 Google LLC. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package module
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	admin "google.golang.org/api/appengine/v1"
+)
+
+// setDefaultTimeout sets the default timeouts for the duration of the test.
+func setDefaultTimeout(t *testing.T, read, write time.Duration) {
+	defaultTimeout.Lock()
+	oldRead, oldWrite := defaultTimeout.read, defaultTimeout.write
+	defaultTimeout.Unlock()
+	SetDefaultTimeout(read, write)
+	t.Cleanup(func() { SetDefaultTimeout(oldRead, oldWrite) })
+}
+
+// slowServer serves the Admin API after a delay, or when the test ends.
+func slowServer(t *testing.T, delay time.Duration) {
+	stop := make(chan struct{})
+	newAdminTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(delay):
+		case <-stop:
+		}
+		json.NewEncoder(w).Encode(&admin.Version{ManualScaling: &admin.ManualScaling{Instances: 1}})
+	})
+	// Cleanups run last-in first-out, so this runs before the server is
+	// closed, which waits for outstanding requests.
+	t.Cleanup(func() { close(stop) })
+}
+
+func TestDefaultTimeout_Read(t *testing.T) {
+	setDefaultTimeout(t, 50*time.Millisecond, time.Minute)
+	slowServer(t, time.Minute)
+	start := time.Now()
+	_, err := NumInstances(context.Background(), "my-module", "v1")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("NumInstances = %v, want DeadlineExceeded", err)
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("NumInstances took %v, want about 50ms", d)
+	}
+}
+
+func TestDefaultTimeout_Write(t *testing.T) {
+	setDefaultTimeout(t, time.Minute, 50*time.Millisecond)
+	slowServer(t, time.Minute)
+	start := time.Now()
+	err := SetNumInstances(context.Background(), "my-module", "v1", 2)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("SetNumInstances = %v, want DeadlineExceeded", err)
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("SetNumInstances took %v, want about 50ms", d)
+	}
+}
+
+func TestDefaultTimeout_CallerDeadline(t *testing.T) {
+	setDefaultTimeout(t, 10*time.Millisecond, 10*time.Millisecond)
+	slowServer(t, 100*time.Millisecond)
+	c, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if _, err := NumInstances(c, "my-module", "v1"); err != nil {
+		t.Errorf("NumInstances with caller deadline: %v", err)
+	}
+}
+
+func TestDefaultTimeout_Disabled(t *testing.T) {
+	setDefaultTimeout(t, 0, 0)
+	slowServer(t, 100*time.Millisecond)
+	if _, err := NumInstances(context.Background(), "my-module", "v1"); err != nil {
+		t.Errorf("NumInstances without timeout: %v", err)
+	}
+}
@@ -0,0 +1,63 @@
+// Copyright 2026 Google LLC. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package module
+
+import (
+	"context"
+	"net/http"
+	"sync"
+
+	"google.golang.org/appengine/internal"
+)
+
+const (
+	cloudTraceHeader  = "X-Cloud-Trace-Context"
+	traceParentHeader = "Traceparent"
+)
+
+var traceExtractor struct {
+	sync.Mutex
+	f func(ctx context.Context) (header, value string)
+}
+
+// SetTraceHeaderExtractor sets the function used to find the trace context of
+// an Admin API call. f returns the name and value of the header to send with
+// the request, or an empty header name if the call is not part of a trace.
+// Passing nil restores the default, which propagates the X-Cloud-Trace-Context
+// or traceparent header of the incoming App Engine request that ctx belongs to.
+//
+// Callers that do not run on App Engine can use it to plug in their own
+// propagation, for example with OpenTelemetry.
+func SetTraceHeaderExtractor(f func(ctx context.Context) (header, value string)) {
+	traceExtractor.Lock()
+	traceExtractor.f = f
+	traceExtractor.Unlock()
+}
+
+// incomingTraceHeader is the default trace header extractor.
+func incomingTraceHeader(ctx context.Context) (header, value string) {
+	h := internal.IncomingHeaders(ctx)
+	if v := h.Get(cloudTraceHeader); v != "" {
+		return cloudTraceHeader, v
+	}
+	if v := h.Get(traceParentHeader); v != "" {
+		return traceParentHeader, v
+	}
+	return "", ""
+}
+
+// setTraceHeader adds the trace context of ctx, if any, to the headers h of an
+// outgoing Admin API request.
+func setTraceHeader(ctx context.Context, h http.Header) {
+	traceExtractor.Lock()
+	f := traceExtractor.f
+	traceExtractor.Unlock()
+	if f == nil {
+		f = incomingTraceHeader
+	}
+	if header, value := f(ctx); header != "" && value != "" {
+		h.Set(header, value)
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright 2026 Google LLC. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package module
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"google.golang.org/appengine/internal"
+)
+
+// traceServer starts a fake Admin API that serves List and records the
+// headers of the requests it receives.
+func traceServer(t *testing.T) *http.Header {
+	var got http.Header
+	newAdminTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+		fmt.Fprint(w, `{"services": [{"id": "default"}]}`)
+	})
+	return &got
+}
+
+func TestTraceHeaderPropagation(t *testing.T) {
+	tests := []struct {
+		name, header, value string
+	}{
+		{"CloudTrace", "X-Cloud-Trace-Context", "105445aa7843bc8bf206b12000100000/1;o=1"},
+		{"TraceParent", "Traceparent", "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := traceServer(t)
+			req, _ := http.NewRequest("GET", "/", nil)
+			req.Header.Set(tt.header, tt.value)
+			if _, err := List(internal.ContextForTesting(req)); err != nil {
+				t.Fatalf("List: %v", err)
+			}
+			if v := got.Get(tt.header); v != tt.value {
+				t.Errorf("outgoing %s header = %q, want %q", tt.header, v, tt.value)
+			}
+		})
+	}
+}
+
+func TestTraceHeaderAbsent(t *testing.T) {
+	got := traceServer(t)
+	if _, err := List(context.Background()); err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if v := got.Get("X-Cloud-Trace-Context"); v != "" {
+		t.Errorf("outgoing X-Cloud-Trace-Context header = %q, want none", v)
+	}
+}
+
+func TestSetTraceHeaderExtractor(t *testing.T) {
+	type traceKey struct{}
+	SetTraceHeaderExtractor(func(ctx context.Context) (string, string) {
+		v, _ := ctx.Value(traceKey{}).(string)
+		return "Traceparent", v
+	})
+	defer SetTraceHeaderExtractor(nil)
+
+	got := traceServer(t)
+	want := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	if _, err := List(context.WithValue(context.Background(), traceKey{}, want)); err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if v := got.Get("Traceparent"); v != want {
+		t.Errorf("outgoing traceparent header = %q, want %q", v, want)
+	}
+}
@@ -0,0 +1,134 @@
+// Copyright 2026 Google LLC. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package module
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	admin "google.golang.org/api/appengine/v1"
+)
+
+// allocationTolerance is the maximum amount by which the sum of a traffic
+// split's allocations may differ from 1.0.
+const allocationTolerance = 1e-6
+
+// TrafficOption configures a call to SetTraffic.
+type TrafficOption func(*trafficOptions)
+
+type trafficOptions struct {
+	migrate bool
+	shardBy string
+}
+
+// MigrateTraffic reports whether traffic should be moved gradually to the new
+// split, warming up instances of the new versions before they receive
+// requests. Gradual migration is only supported for a single target version.
+func MigrateTraffic(migrate bool) TrafficOption {
+	return func(o *trafficOptions) { o.migrate = migrate }
+}
+
+// ShardBy sets the mechanism used to determine which version a request is
+// sent to. It must be one of "COOKIE", "IP" or "RANDOM". If ShardBy is not
+// given, the service's existing sharding mechanism is left untouched.
+func ShardBy(shardBy string) TrafficOption {
+	return func(o *trafficOptions) { o.shardBy = shardBy }
+}
+
+// SetTraffic sets the traffic split of the specified module. Allocations map
+// version IDs to the fraction of traffic they should receive, and must sum to
+// 1.0. If module is the empty string, it means the default module.
+//
+// SetTraffic waits for the change to take effect before returning. It requires
+// the Admin API; on the legacy backend it returns ErrNotSupported.
+func SetTraffic(c context.Context, module string, allocations map[string]float64, opts ...TrafficOption) (err error) {
+	c, done := startCall(c, "SetTraffic", module, "")
+	defer done(&err)
+	if err := validateNames(module, ""); err != nil {
+		return err
+	}
+	if !useAdminAPI(c) {
+		return ErrNotSupported
+	}
+	var o trafficOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if err := validateAllocations(allocations); err != nil {
+		return err
+	}
+	switch o.shardBy {
+	case "", "COOKIE", "IP", "RANDOM":
+	default:
+		return fmt.Errorf("module: invalid shardBy %q; want COOKIE, IP or RANDOM", o.shardBy)
+	}
+	if module == "" {
+		module = getModuleorDefault(c)
+	}
+	projectID := getProjectID(c)
+	b, err := newAdminBackend(c, "set_traffic_split")
+	if err != nil {
+		return err
+	}
+	update := &admin.Service{
+		Split: &admin.TrafficSplit{
+			Allocations: allocations,
+			ShardBy:     o.shardBy,
+		},
+	}
+	// Only replace the whole split when the sharding mechanism changes, so
+	// that an existing shardBy setting survives an allocation-only update.
+	mask := "split.allocations"
+	if o.shardBy != "" {
+		mask = "split"
+	}
+	// Invalidate once the change has taken effect, so that a concurrent
+	// DefaultVersion call cannot cache the old value in the meantime.
+	defer invalidateCache(c, "defaultVersion", module)
+	op, err := b.PatchService(c, projectID, module, update, mask, o.migrate)
+	if err != nil {
+		return err
+	}
+	return waitOperation(c, b, projectID, op)
+}
+
+// SetDefaultVersion routes all traffic of the specified module to version.
+// If module is the empty string, it means the default module.
+func SetDefaultVersion(c context.Context, module, version string) (err error) {
+	c, done := startCall(c, "SetDefaultVersion", module, version)
+	defer done(&err)
+	if err := validateNames(module, version); err != nil {
+		return err
+	}
+	if version == "" {
+		return fmt.Errorf("module: version must not be empty")
+	}
+	return SetTraffic(c, module, map[string]float64{version: 1})
+}
+
+// validateAllocations checks that allocations describe a valid traffic split.
+func validateAllocations(allocations map[string]float64) error {
+	if len(allocations) == 0 {
+		return fmt.Errorf("module: traffic split has no allocations")
+	}
+	var sum float64
+	for version, alloc := range allocations {
+		if version == "" {
+			return fmt.Errorf("module: traffic split contains an empty version")
+		}
+		if err := validateName("version", version); err != nil {
+			return err
+		}
+		if alloc < 0 || alloc > 1 {
+			return fmt.Errorf("module: allocation %v for version %q is out of range [0, 1]", alloc, version)
+		}
+		sum += alloc
+	}
+	if math.Abs(sum-1) > allocationTolerance {
+		return fmt.Errorf("module: traffic allocations sum to %v, want 1.0", sum)
+	}
+	return nil
+}
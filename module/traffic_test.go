@@ -0,0 +1,136 @@
+// Copyright 2026 Google LLC. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package module
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+
+	admin "google.golang.org/api/appengine/v1"
+)
+
+func TestSetTraffic_AdminAPI(t *testing.T) {
+	tests := []struct {
+		name        string
+		allocations map[string]float64
+		opts        []TrafficOption
+		wantMask    string
+		wantMigrate string
+		wantShardBy string
+	}{
+		{
+			name:        "SplitOnly",
+			allocations: map[string]float64{"v1": 0.25, "v2": 0.75},
+			wantMask:    "split.allocations",
+		},
+		{
+			name:        "ShardByCookie",
+			allocations: map[string]float64{"v1": 0.1, "v2": 0.2, "v3": 0.7},
+			opts:        []TrafficOption{ShardBy("COOKIE")},
+			wantMask:    "split",
+			wantShardBy: "COOKIE",
+		},
+		{
+			name:        "Migrate",
+			allocations: map[string]float64{"v2": 1},
+			opts:        []TrafficOption{MigrateTraffic(true)},
+			wantMask:    "split.allocations",
+			wantMigrate: "true",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var patched, polled bool
+			newAdminTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+				switch {
+				case r.Method == "PATCH" && r.URL.Path == "/v1/apps/test-project/services/my-module":
+					patched = true
+					q := r.URL.Query()
+					if got := q.Get("updateMask"); got != tt.wantMask {
+						t.Errorf("updateMask = %q, want %q", got, tt.wantMask)
+					}
+					if got := q.Get("migrateTraffic"); got != tt.wantMigrate {
+						t.Errorf("migrateTraffic = %q, want %q", got, tt.wantMigrate)
+					}
+					var s admin.Service
+					if err := json.NewDecoder(r.Body).Decode(&s); err != nil {
+						t.Errorf("decoding body: %v", err)
+						http.Error(w, err.Error(), http.StatusBadRequest)
+						return
+					}
+					if s.Split == nil || !reflect.DeepEqual(s.Split.Allocations, tt.allocations) {
+						t.Errorf("split = %+v, want allocations %v", s.Split, tt.allocations)
+					} else if s.Split.ShardBy != tt.wantShardBy {
+						t.Errorf("shardBy = %q, want %q", s.Split.ShardBy, tt.wantShardBy)
+					}
+					json.NewEncoder(w).Encode(&admin.Operation{Name: "apps/test-project/operations/op1"})
+				case r.Method == "GET" && r.URL.Path == "/v1/apps/test-project/operations/op1":
+					polled = true
+					json.NewEncoder(w).Encode(&admin.Operation{Name: "apps/test-project/operations/op1", Done: true})
+				default:
+					t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+					http.Error(w, "unexpected", http.StatusBadRequest)
+				}
+			})
+
+			if err := SetTraffic(context.Background(), "my-module", tt.allocations, tt.opts...); err != nil {
+				t.Fatalf("SetTraffic: %v", err)
+			}
+			if !patched || !polled {
+				t.Errorf("patched = %v, polled = %v; want both true", patched, polled)
+			}
+		})
+	}
+}
+
+func TestSetTraffic_InvalidSplit(t *testing.T) {
+	newAdminTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+	})
+	tests := []struct {
+		name        string
+		allocations map[string]float64
+		opts        []TrafficOption
+	}{
+		{"Empty", map[string]float64{}, nil},
+		{"SumTooLow", map[string]float64{"v1": 0.5, "v2": 0.4}, nil},
+		{"SumTooHigh", map[string]float64{"v1": 0.5, "v2": 0.6}, nil},
+		{"Negative", map[string]float64{"v1": 1.5, "v2": -0.5}, nil},
+		{"BadShardBy", map[string]float64{"v1": 1}, []TrafficOption{ShardBy("HEADER")}},
+	}
+	for _, tt := range tests {
+		if err := SetTraffic(context.Background(), "my-module", tt.allocations, tt.opts...); err == nil {
+			t.Errorf("%s: SetTraffic succeeded, want error", tt.name)
+		}
+	}
+}
+
+func TestSetDefaultVersion_AdminAPI(t *testing.T) {
+	newAdminTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "PATCH" {
+			t.Errorf("Method = %s, want PATCH", r.Method)
+		}
+		var s admin.Service
+		json.NewDecoder(r.Body).Decode(&s)
+		want := map[string]float64{"v2": 1}
+		if s.Split == nil || !reflect.DeepEqual(s.Split.Allocations, want) {
+			t.Errorf("split = %+v, want allocations %v", s.Split, want)
+		}
+		json.NewEncoder(w).Encode(&admin.Operation{Name: "apps/test-project/operations/op2", Done: true})
+	})
+	if err := SetDefaultVersion(context.Background(), "my-module", "v2"); err != nil {
+		t.Fatalf("SetDefaultVersion: %v", err)
+	}
+}
+
+func TestSetTraffic_Legacy(t *testing.T) {
+	err := SetTraffic(context.Background(), "my-module", map[string]float64{"v1": 1})
+	if err != ErrNotSupported {
+		t.Errorf("SetTraffic = %v, want ErrNotSupported", err)
+	}
+}
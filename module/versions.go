@@ -0,0 +1,235 @@
+// Copyright 2026 Google LLC. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package module
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	admin "google.golang.org/api/appengine/v1"
+	"google.golang.org/api/googleapi"
+)
+
+// VersionFilter restricts the versions returned by VersionsFiltered.
+type VersionFilter func(*versionFilter)
+
+type versionFilter struct {
+	serving      bool
+	scaling      ScalingType
+	createdAfter time.Time
+}
+
+// OnlyServing makes VersionsFiltered return only the versions that are
+// serving.
+func OnlyServing() VersionFilter {
+	return func(f *versionFilter) { f.serving = true }
+}
+
+// WithScaling makes VersionsFiltered return only the versions that use the
+// given scaling type. Versions deployed without a scaling block use automatic
+// scaling.
+func WithScaling(t ScalingType) VersionFilter {
+	return func(f *versionFilter) { f.scaling = t }
+}
+
+// CreatedAfter makes VersionsFiltered return only the versions created after
+// t.
+func CreatedAfter(t time.Time) VersionFilter {
+	return func(f *versionFilter) { f.createdAfter = t }
+}
+
+// fields returns the version fields the filter needs.
+func (f *versionFilter) fields() []string {
+	fields := []string{"id"}
+	if f.serving {
+		fields = append(fields, "servingStatus")
+	}
+	if f.scaling != "" {
+		fields = append(fields, "automaticScaling", "basicScaling", "manualScaling")
+	}
+	if !f.createdAfter.IsZero() {
+		fields = append(fields, "createTime")
+	}
+	return fields
+}
+
+func (f *versionFilter) match(v *admin.Version) bool {
+	if f.serving && v.ServingStatus != "SERVING" {
+		return false
+	}
+	if f.scaling != "" {
+		if scalingType(v) != f.scaling {
+			return false
+		}
+	}
+	if !f.createdAfter.IsZero() {
+		created, err := time.Parse(time.RFC3339Nano, v.CreateTime)
+		if err != nil || !created.After(f.createdAfter) {
+			return false
+		}
+	}
+	return true
+}
+
+// VersionsFiltered returns the names of the versions of the specified module
+// that match all of the given filters. If module is the empty string, it means
+// the default module. The Admin API cannot filter versions, so
+// VersionsFiltered lists them all, requesting only the fields the filters
+// need, and filters them locally.
+//
+// Without filters, VersionsFiltered is equivalent to Versions. With filters,
+// it requires the Admin API and returns ErrNotSupported on the legacy backend.
+func VersionsFiltered(c context.Context, module string, filters ...VersionFilter) (_ []string, err error) {
+	c, done := startCall(c, "VersionsFiltered", module, "")
+	defer done(&err)
+	if err := validateNames(module, ""); err != nil {
+		return nil, err
+	}
+	if len(filters) == 0 {
+		return Versions(c, module)
+	}
+	if !useAdminAPI(c) {
+		return nil, ErrNotSupported
+	}
+	var f versionFilter
+	for _, filter := range filters {
+		filter(&f)
+	}
+	if module == "" {
+		module = getModuleorDefault(c)
+	}
+	projectID := getProjectID(c)
+	b, err := newAdminBackend(c, "get_versions")
+	if err != nil {
+		return nil, err
+	}
+	all, err := listVersions(c, b, projectID, module, f.fields())
+	if err != nil {
+		return nil, err
+	}
+	var versions []string
+	for _, v := range all {
+		if f.match(v) {
+			versions = append(versions, v.Id)
+		}
+	}
+	return versions, nil
+}
+
+// listVersions returns every version of module, following pagination, with
+// only the given fields set.
+func listVersions(c context.Context, b adminBackend, projectID, module string, fields []string) ([]*admin.Version, error) {
+	mask := googleapi.Field("versions(" + strings.Join(fields, ",") + ")")
+	var versions []*admin.Version
+	for token := ""; ; {
+		resp, err := b.ListVersions(c, projectID, module, token, "", mask, "nextPageToken")
+		if err != nil {
+			return nil, err
+		}
+		versions = append(versions, resp.Versions...)
+		if token = resp.NextPageToken; token == "" {
+			return versions, nil
+		}
+	}
+}
+
+// ErrNoVersions is returned by LatestVersion for a module without versions.
+var ErrNoVersions = errors.New("module: module has no versions")
+
+// LatestVersion returns the most recently created version of the specified
+// module. Versions created at the same time are ordered by name, and the
+// greatest name wins. If module is the empty string, it means the default
+// module. The legacy modules API does not report creation times, so
+// LatestVersion returns ErrNotSupported on the legacy backend.
+func LatestVersion(c context.Context, module string) (_ string, err error) {
+	c, done := startCall(c, "LatestVersion", module, "")
+	defer done(&err)
+	if err := validateNames(module, ""); err != nil {
+		return "", err
+	}
+	if !useAdminAPI(c) {
+		return "", ErrNotSupported
+	}
+	if module == "" {
+		module = getModuleorDefault(c)
+	}
+	projectID := getProjectID(c)
+	b, err := newAdminBackend(c, "get_versions")
+	if err != nil {
+		return "", err
+	}
+	versions, err := listVersions(c, b, projectID, module, []string{"id", "createTime"})
+	if err != nil {
+		return "", err
+	}
+	var latest *admin.Version
+	var latestTime time.Time
+	for _, v := range versions {
+		created, err := time.Parse(time.RFC3339Nano, v.CreateTime)
+		if err != nil {
+			continue
+		}
+		if latest == nil || created.After(latestTime) || created.Equal(latestTime) && v.Id > latest.Id {
+			latest, latestTime = v, created
+		}
+	}
+	if latest == nil {
+		return "", ErrNoVersions
+	}
+	return latest.Id, nil
+}
+
+// latestAlias is the version alias resolved by LatestVersion.
+const latestAlias = "latest"
+
+type aliasesContextKey struct{}
+
+// ResolveAliases returns a copy of c in which the functions of this package
+// that take a version treat the version "latest" as an alias for the result
+// of LatestVersion, if resolve is true. Aliases are only resolved on the Admin
+// API. They are disabled by default, so that a version actually named "latest"
+// can still be addressed.
+func ResolveAliases(c context.Context, resolve bool) context.Context {
+	return context.WithValue(c, aliasesContextKey{}, resolve)
+}
+
+func aliasesEnabled(c context.Context) bool {
+	resolve, _ := c.Value(aliasesContextKey{}).(bool)
+	return resolve
+}
+
+// DeleteVersion deletes the specified version of the specified module and
+// waits for the deletion to complete. The Admin API refuses to delete a
+// version that receives traffic. DeleteVersion returns ErrVersionNotFound if
+// the version does not exist, and ErrNotSupported on the legacy backend.
+func DeleteVersion(c context.Context, module, version string) (err error) {
+	c, done := startCall(c, "DeleteVersion", module, version)
+	defer done(&err)
+	if err := validateNames(module, version); err != nil {
+		return err
+	}
+	if !useAdminAPI(c) {
+		return ErrNotSupported
+	}
+	if version == "" {
+		return errors.New("module: version must not be empty")
+	}
+	module, version, err = defaultModuleVersion(c, module, version)
+	if err != nil {
+		return err
+	}
+	projectID := getProjectID(c)
+	b, err := newAdminBackend(c, "delete_version")
+	if err != nil {
+		return err
+	}
+	op, err := b.DeleteVersion(c, projectID, module, version)
+	if err != nil {
+		return err
+	}
+	return waitOperation(c, b, projectID, op)
+}
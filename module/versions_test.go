@@ -0,0 +1,146 @@
+// Copyright 2026 Google LLC. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package module
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	admin "google.golang.org/api/appengine/v1"
+	"google.golang.org/appengine/internal/aetesting"
+)
+
+func TestVersionsFiltered(t *testing.T) {
+	pages := map[string]*admin.ListVersionsResponse{
+		"": {
+			Versions: []*admin.Version{
+				{Id: "v1", ServingStatus: "SERVING", ManualScaling: &admin.ManualScaling{Instances: 1}},
+				{Id: "v2", ServingStatus: "STOPPED", ManualScaling: &admin.ManualScaling{Instances: 1}},
+				{Id: "v3", ServingStatus: "SERVING", AutomaticScaling: &admin.AutomaticScaling{}},
+			},
+			NextPageToken: "page2",
+		},
+		"page2": {
+			Versions: []*admin.Version{
+				{Id: "v4", ServingStatus: "SERVING", ManualScaling: &admin.ManualScaling{Instances: 2}},
+				{Id: "v5", ServingStatus: "SERVING"},
+			},
+		},
+	}
+	ctx := aetesting.FakeAdminContext(t, func(req *aetesting.AdminRequest) (int, interface{}) {
+		req.Expect(t, "GET", "/v1/apps/test-project/services/my-module/versions", "")
+		want := "versions(id,servingStatus,automaticScaling,basicScaling,manualScaling),nextPageToken"
+		if got := req.Query.Get("fields"); got != want {
+			t.Errorf("fields = %q, want %q", got, want)
+		}
+		return http.StatusOK, pages[req.Query.Get("pageToken")]
+	})
+
+	got, err := VersionsFiltered(ctx, "my-module", OnlyServing(), WithScaling(ScalingManual))
+	if err != nil {
+		t.Fatalf("VersionsFiltered: %v", err)
+	}
+	if want := []string{"v1", "v4"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("VersionsFiltered = %v, want %v", got, want)
+	}
+}
+
+func TestVersionsFiltered_CreatedAfter(t *testing.T) {
+	ctx := aetesting.FakeAdminContext(t, func(req *aetesting.AdminRequest) (int, interface{}) {
+		if got, want := req.Query.Get("fields"), "versions(id,servingStatus,createTime),nextPageToken"; got != want {
+			t.Errorf("fields = %q, want %q", got, want)
+		}
+		return http.StatusOK, &admin.ListVersionsResponse{Versions: []*admin.Version{
+			{Id: "old", ServingStatus: "SERVING", CreateTime: "2026-01-01T00:00:00Z"},
+			{Id: "new", ServingStatus: "SERVING", CreateTime: "2026-03-01T12:00:00.5Z"},
+			{Id: "stopped", ServingStatus: "STOPPED", CreateTime: "2026-03-02T00:00:00Z"},
+		}}
+	})
+	after := time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)
+	got, err := VersionsFiltered(ctx, "my-module", CreatedAfter(after), OnlyServing())
+	if err != nil {
+		t.Fatalf("VersionsFiltered: %v", err)
+	}
+	if want := []string{"new"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("VersionsFiltered = %v, want %v", got, want)
+	}
+}
+
+func TestLatestVersion(t *testing.T) {
+	tests := []struct {
+		name     string
+		versions []*admin.Version
+		want     string
+	}{
+		{
+			name: "NewestWins",
+			versions: []*admin.Version{
+				{Id: "b", CreateTime: "2026-01-02T00:00:00Z"},
+				{Id: "c", CreateTime: "2026-03-01T00:00:00Z"},
+				{Id: "a", CreateTime: "2026-02-01T00:00:00Z"},
+			},
+			want: "c",
+		},
+		{
+			name: "TieBrokenByName",
+			versions: []*admin.Version{
+				{Id: "v2", CreateTime: "2026-03-01T00:00:00Z"},
+				{Id: "v10", CreateTime: "2026-03-01T00:00:00Z"},
+				{Id: "v1", CreateTime: "2026-01-01T00:00:00Z"},
+			},
+			want: "v2",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := aetesting.FakeAdminContext(t, func(req *aetesting.AdminRequest) (int, interface{}) {
+				req.Expect(t, "GET", "/v1/apps/test-project/services/my-module/versions", "")
+				if got, want := req.Query.Get("fields"), "versions(id,createTime),nextPageToken"; got != want {
+					t.Errorf("fields = %q, want %q", got, want)
+				}
+				return http.StatusOK, &admin.ListVersionsResponse{Versions: tt.versions}
+			})
+			got, err := LatestVersion(ctx, "my-module")
+			if err != nil {
+				t.Fatalf("LatestVersion: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("LatestVersion = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLatestVersion_Legacy(t *testing.T) {
+	t.Setenv("MODULES_USE_ADMIN_API", "false")
+	if _, err := LatestVersion(context.Background(), "my-module"); err != ErrNotSupported {
+		t.Errorf("LatestVersion = %v, want ErrNotSupported", err)
+	}
+}
+
+func TestStart_ResolvesLatest(t *testing.T) {
+	for _, resolve := range []bool{true, false} {
+		want := "/v1/apps/test-project/services/my-module/versions/latest"
+		if resolve {
+			want = "/v1/apps/test-project/services/my-module/versions/v3"
+		}
+		ctx := aetesting.FakeAdminContext(t, func(req *aetesting.AdminRequest) (int, interface{}) {
+			if req.Method == "GET" {
+				return http.StatusOK, &admin.ListVersionsResponse{Versions: []*admin.Version{
+					{Id: "v3", CreateTime: "2026-03-01T00:00:00Z"},
+					{Id: "v2", CreateTime: "2026-02-01T00:00:00Z"},
+				}}
+			}
+			req.Expect(t, "PATCH", want, "servingStatus")
+			return http.StatusOK, &admin.Operation{Name: "apps/test-project/operations/op", Done: true}
+		})
+		if err := Start(ResolveAliases(ctx, resolve), "my-module", "latest"); err != nil {
+			t.Errorf("Start(latest) with ResolveAliases(%v): %v", resolve, err)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+// Copyright 2026 Google LLC. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package module
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"time"
+)
+
+// EventType is the kind of change reported by a ModuleEvent.
+type EventType string
+
+const (
+	VersionAdded   EventType = "VersionAdded"   // a version was deployed
+	VersionRemoved EventType = "VersionRemoved" // a version was deleted
+	StatusChanged  EventType = "StatusChanged"  // a version was started or stopped
+	TrafficChanged EventType = "TrafficChanged" // the traffic split changed
+	WatchError     EventType = "WatchError"     // the module could not be polled
+)
+
+// ModuleEvent is a change of a module observed by Watch.
+type ModuleEvent struct {
+	Type    EventType
+	Module  string
+	Version string // the version concerned; empty for TrafficChanged and WatchError
+
+	// Status is the serving status of the version, "SERVING" or "STOPPED", for
+	// VersionAdded and StatusChanged events. PreviousStatus is the status it
+	// changed from, for StatusChanged events.
+	Status, PreviousStatus string
+
+	// Traffic is the new traffic split of the module, from version to the
+	// fraction of traffic it receives, for TrafficChanged events.
+	Traffic map[string]float64
+
+	// Err is the error polling failed with, for WatchError events.
+	Err error
+}
+
+// maxWatchBackoff bounds the delay between polls after consecutive failures,
+// unless the interval is longer.
+const maxWatchBackoff = 5 * time.Minute
+
+// Watch polls the versions and traffic split of the specified module every
+// interval and reports their changes on the returned channel. If module is
+// the empty string, it means the default module. The first poll reports every
+// existing version as added and the initial traffic split as changed, so that
+// the events describe the complete state of the module.
+//
+// A failed poll is reported as a WatchError event and the watch goes on. By
+// default, it waits twice the interval before the first retry and twice as
+// long before each further retry while the failures continue; WithBackoff
+// sets another policy. The channel is closed once c is done. Watch returns
+// ErrNotSupported on the legacy backend.
+func Watch(c context.Context, module string, interval time.Duration) (_ <-chan ModuleEvent, err error) {
+	parent := c
+	c, done := startCall(c, "Watch", module, "")
+	defer done(&err)
+	if err := validateNames(module, ""); err != nil {
+		return nil, err
+	}
+	if !useAdminAPI(c) {
+		return nil, ErrNotSupported
+	}
+	if interval <= 0 {
+		return nil, errors.New("module: watch interval must be positive")
+	}
+	if module == "" {
+		module = getModuleorDefault(c)
+	}
+	projectID := getProjectID(c)
+	b, err := newAdminBackend(c, "watch")
+	if err != nil {
+		return nil, err
+	}
+	w := &watcher{b: b, projectID: projectID, module: module}
+	ch := make(chan ModuleEvent)
+	go w.run(parent, interval, ch)
+	return ch, nil
+}
+
+// watcher holds the state of a module last seen by Watch.
+type watcher struct {
+	b         adminBackend
+	projectID string
+	module    string
+
+	statuses map[string]string // serving status by version; nil before the first poll
+	traffic  map[string]float64
+}
+
+func (w *watcher) run(c context.Context, interval time.Duration, ch chan<- ModuleEvent) {
+	defer close(ch)
+	limit := maxWatchBackoff
+	if limit < interval {
+		limit = interval
+	}
+	def := Backoff{Initial: 2 * interval, Max: limit, Multiplier: 2}
+	bo := backoffFor(c, def)
+	for {
+		delay := interval
+		events, err := w.poll(c)
+		if err != nil {
+			if c.Err() != nil {
+				return
+			}
+			events = []ModuleEvent{{Type: WatchError, Module: w.module, Err: err}}
+			delay = bo.Next()
+		} else {
+			bo = backoffFor(c, def)
+		}
+		for _, e := range events {
+			select {
+			case ch <- e:
+			case <-c.Done():
+				return
+			}
+		}
+		select {
+		case <-time.After(delay):
+		case <-c.Done():
+			return
+		}
+	}
+}
+
+// poll fetches the current state of the module and returns the events that
+// describe how it differs from the previous one.
+func (w *watcher) poll(c context.Context) ([]ModuleEvent, error) {
+	versions, err := listVersions(c, w.b, w.projectID, w.module, []string{"id", "servingStatus"})
+	if err != nil {
+		return nil, err
+	}
+	service, err := w.b.GetService(c, w.projectID, w.module, "split/allocations")
+	if err != nil {
+		return nil, err
+	}
+	statuses := make(map[string]string, len(versions))
+	for _, v := range versions {
+		statuses[v.Id] = v.ServingStatus
+	}
+	traffic := map[string]float64{}
+	if service.Split != nil {
+		traffic = service.Split.Allocations
+	}
+
+	var events []ModuleEvent
+	for _, id := range sortedVersionIDs(statuses) {
+		old, ok := w.statuses[id]
+		switch {
+		case !ok:
+			events = append(events, ModuleEvent{Type: VersionAdded, Module: w.module, Version: id, Status: statuses[id]})
+		case old != statuses[id]:
+			events = append(events, ModuleEvent{Type: StatusChanged, Module: w.module, Version: id, Status: statuses[id], PreviousStatus: old})
+		}
+	}
+	for _, id := range sortedVersionIDs(w.statuses) {
+		if _, ok := statuses[id]; !ok {
+			events = append(events, ModuleEvent{Type: VersionRemoved, Module: w.module, Version: id})
+		}
+	}
+	if w.statuses == nil || !reflect.DeepEqual(traffic, w.traffic) {
+		events = append(events, ModuleEvent{Type: TrafficChanged, Module: w.module, Traffic: copyAllocations(traffic)})
+	}
+	w.statuses, w.traffic = statuses, traffic
+	return events, nil
+}
+
+func sortedVersionIDs(statuses map[string]string) []string {
+	ids := make([]string, 0, len(statuses))
+	for id := range statuses {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func copyAllocations(m map[string]float64) map[string]float64 {
+	cp := make(map[string]float64, len(m))
+	for v, a := range m {
+		cp[v] = a
+	}
+	return cp
+}
@@ -0,0 +1,97 @@
+// Copyright 2026 Google LLC. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package module
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWatch(t *testing.T) {
+	// Each state is served by one poll: the versions list followed by the
+	// traffic split. An empty versions response makes the poll fail.
+	states := []struct{ versions, split string }{
+		{`{"id": "v1", "servingStatus": "SERVING"}`, `{"v1": 1}`},
+		{`{"id": "v1", "servingStatus": "SERVING"}, {"id": "v2", "servingStatus": "SERVING"}`, `{"v1": 1}`},
+		{"", ""},
+		{`{"id": "v1", "servingStatus": "STOPPED"}, {"id": "v2", "servingStatus": "SERVING"}`, `{"v2": 1}`},
+		{`{"id": "v2", "servingStatus": "SERVING"}`, `{"v2": 1}`},
+	}
+	var mu sync.Mutex
+	poll := -1
+	newAdminTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if strings.HasSuffix(r.URL.Path, "/versions") {
+			poll++
+		}
+		state := states[len(states)-1]
+		if poll < len(states) {
+			state = states[poll]
+		}
+		switch {
+		case state.versions == "":
+			http.Error(w, `{"error": {"code": 400, "message": "boom"}}`, http.StatusBadRequest)
+		case r.URL.Path == "/v1/apps/test-project/services/default/versions":
+			fmt.Fprintf(w, `{"versions": [%s]}`, state.versions)
+		case r.URL.Path == "/v1/apps/test-project/services/default":
+			fmt.Fprintf(w, `{"split": {"allocations": %s}}`, state.split)
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+	})
+
+	c, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, err := Watch(c, "default", time.Millisecond)
+	if err != nil {
+		t.Fatalf("Watch: %v", err)
+	}
+	want := []ModuleEvent{
+		{Type: VersionAdded, Module: "default", Version: "v1", Status: "SERVING"},
+		{Type: TrafficChanged, Module: "default", Traffic: map[string]float64{"v1": 1}},
+		{Type: VersionAdded, Module: "default", Version: "v2", Status: "SERVING"},
+		{Type: WatchError, Module: "default"},
+		{Type: StatusChanged, Module: "default", Version: "v1", Status: "STOPPED", PreviousStatus: "SERVING"},
+		{Type: TrafficChanged, Module: "default", Traffic: map[string]float64{"v2": 1}},
+		{Type: VersionRemoved, Module: "default", Version: "v1"},
+	}
+	var got []ModuleEvent
+	for len(got) < len(want) {
+		select {
+		case e := <-events:
+			if e.Type == WatchError {
+				if e.Err == nil || !strings.Contains(e.Err.Error(), "boom") {
+					t.Errorf("WatchError event has error %v, want the poll's error", e.Err)
+				}
+				e.Err = nil
+			}
+			got = append(got, e)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out after events %+v", got)
+		}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("events =\n%+v\nwant\n%+v", got, want)
+	}
+
+	cancel()
+	for range events {
+		// Drain events sent before the watch saw the cancellation.
+	}
+}
+
+func TestWatch_Interval(t *testing.T) {
+	t.Setenv("MODULES_USE_ADMIN_API", "true")
+	if _, err := Watch(context.Background(), "default", 0); err == nil {
+		t.Error("Watch with a zero interval succeeded")
+	}
+}
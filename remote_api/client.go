@@ -10,6 +10,7 @@ package remote_api
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"log"
@@ -19,9 +20,12 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/golang/protobuf/proto"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
 
 	"google.golang.org/appengine/internal"
 	pb "google.golang.org/appengine/internal/remote_api"
@@ -29,14 +33,34 @@ import (
 
 // Client is a connection to the production APIs for an application.
 type Client struct {
-	hc    *http.Client
-	url   string
-	appID string
+	hc      *http.Client
+	url     string
+	appID   string
+	retries int
+}
+
+// defaultRetries is the number of times an API call is retried by default.
+const defaultRetries = 3
+
+// retryDelay is the delay before the first retry of an API call, which
+// doubles for each further retry.
+var retryDelay = 200 * time.Millisecond
+
+// A ClientOption configures a Client created by NewClient.
+type ClientOption func(*Client)
+
+// Retries sets the number of times an API call is retried after a connection
+// error or a 5xx response, which is 3 by default. API calls are idempotent at
+// this layer, so retrying them is safe.
+func Retries(n int) ClientOption {
+	return func(c *Client) {
+		c.retries = n
+	}
 }
 
 // NewClient returns a client for the given host. All communication will
 // be performed over SSL unless the host is localhost.
-func NewClient(host string, client *http.Client) (*Client, error) {
+func NewClient(host string, client *http.Client, opts ...ClientOption) (*Client, error) {
 	// Add an appcfg header to outgoing requests.
 	wrapClient := new(http.Client)
 	*wrapClient = *client
@@ -59,11 +83,16 @@ func NewClient(host string, client *http.Client) (*Client, error) {
 	if err != nil {
 		return nil, fmt.Errorf("unable to contact server: %v", err)
 	}
-	return &Client{
-		hc:    wrapClient,
-		url:   u,
-		appID: appID,
-	}, nil
+	c := &Client{
+		hc:      wrapClient,
+		url:     u,
+		appID:   appID,
+		retries: defaultRetries,
+	}
+	for _, o := range opts {
+		o(c)
+	}
+	return c, nil
 }
 
 // NewContext returns a copy of parent that will cause App Engine API
@@ -78,14 +107,68 @@ func (c *Client) NewContext(parent context.Context) context.Context {
 // NewRemoteContext returns a context that gives access to the production
 // APIs for the application at the given host. All communication will be
 // performed over SSL unless the host is localhost.
-func NewRemoteContext(host string, client *http.Client) (context.Context, error) {
-	c, err := NewClient(host, client)
+func NewRemoteContext(host string, client *http.Client, opts ...ClientOption) (context.Context, error) {
+	c, err := NewClient(host, client, opts...)
 	if err != nil {
 		return nil, err
 	}
 	return c.NewContext(context.Background()), nil
 }
 
+// defaultScopes are the OAuth scopes of the tokens used by
+// NewDefaultRemoteContext. The remote_api handler identifies the caller as an
+// administrator of the application by their email address.
+var defaultScopes = []string{
+	"https://www.googleapis.com/auth/cloud-platform",
+	"https://www.googleapis.com/auth/userinfo.email",
+}
+
+// NewRemoteContextWithTokenSource returns a copy of ctx that gives access to
+// the production APIs for the application at the given host, authenticating
+// with OAuth bearer tokens from ts. All communication will be performed over
+// SSL unless the host is localhost.
+//
+// Tokens from ts are reused until they expire. If the host rejects a token
+// with 401 Unauthorized, the request is retried once with a new token from ts,
+// so ts should fetch a new token on every call rather than cache them: the
+// sources of golang.org/x/oauth2, such as those of oauth2.ReuseTokenSource,
+// hand out the rejected token again until it expires.
+func NewRemoteContextWithTokenSource(ctx context.Context, host string, ts oauth2.TokenSource, opts ...ClientOption) (context.Context, error) {
+	hc := &http.Client{
+		Transport: newBearerRoundTripper(ts, http.DefaultTransport),
+	}
+	c, err := NewClient(host, hc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return c.NewContext(ctx), nil
+}
+
+// NewDefaultRemoteContext is like NewRemoteContextWithTokenSource, with
+// tokens for the Application Default Credentials, such as those of
+// "gcloud auth application-default login".
+func NewDefaultRemoteContext(ctx context.Context, host string, opts ...ClientOption) (context.Context, error) {
+	if _, err := google.FindDefaultCredentials(ctx, defaultScopes...); err != nil {
+		return nil, fmt.Errorf("remote_api: finding default credentials: %v", err)
+	}
+	return NewRemoteContextWithTokenSource(ctx, host, defaultCredentialsSource{ctx}, opts...)
+}
+
+// defaultCredentialsSource fetches a new token for the Application Default
+// Credentials on every call. The sources of golang.org/x/oauth2/google cache
+// their token until it expires, so a new one is made for each token.
+type defaultCredentialsSource struct {
+	ctx context.Context
+}
+
+func (s defaultCredentialsSource) Token() (*oauth2.Token, error) {
+	ts, err := google.DefaultTokenSource(s.ctx, defaultScopes...)
+	if err != nil {
+		return nil, err
+	}
+	return ts.Token()
+}
+
 var logLevels = map[int64]string{
 	0: "DEBUG",
 	1: "INFO",
@@ -116,19 +199,9 @@ func (c *Client) call(ctx context.Context, service, method string, in, out proto
 		return fmt.Errorf("proto.Marshal: %v", err)
 	}
 
-	// TODO(djd): Respect ctx.Deadline()?
-	resp, err := c.hc.Post(c.url, "application/octet-stream", bytes.NewReader(req))
+	body, err := c.post(ctx, service, method, req)
 	if err != nil {
-		return fmt.Errorf("error sending request: %v", err)
-	}
-	defer resp.Body.Close()
-
-	body, err := ioutil.ReadAll(resp.Body)
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("bad response %d; body: %q", resp.StatusCode, body)
-	}
-	if err != nil {
-		return fmt.Errorf("failed reading response: %v", err)
+		return err
 	}
 	remResp := &pb.Response{}
 	if err := proto.Unmarshal(body, remResp); err != nil {
@@ -140,6 +213,15 @@ func (c *Client) call(ctx context.Context, service, method string, in, out proto
 			Code:    ae.GetCode(),
 			Detail:  ae.GetDetail(),
 			Service: service,
+			Method:  method,
+		}
+	}
+	if re := remResp.GetRpcError(); re != nil {
+		return &internal.CallError{
+			Service: service,
+			Method:  method,
+			Code:    re.GetCode(),
+			Detail:  re.GetDetail(),
 		}
 	}
 
@@ -150,6 +232,81 @@ func (c *Client) call(ctx context.Context, service, method string, in, out proto
 	return proto.Unmarshal(remResp.Response, out)
 }
 
+// post sends the remote_api request req for a call to service.method and
+// returns the body of the response. It stops at the deadline of ctx, and
+// retries after connection errors and 5xx responses. Its errors are
+// *internal.CallError values.
+func (c *Client) post(ctx context.Context, service, method string, req []byte) ([]byte, error) {
+	delay := retryDelay
+	for attempt := 0; ; attempt++ {
+		body, retry, err := c.postOnce(ctx, req)
+		if err == nil {
+			return body, nil
+		}
+		if ctx.Err() != nil {
+			return nil, contextCallError(ctx, service, method)
+		}
+		if !retry || attempt >= c.retries {
+			return nil, &internal.CallError{
+				Service: service,
+				Method:  method,
+				Detail:  err.Error(),
+				Code:    int32(pb.RpcError_UNKNOWN),
+				Err:     err,
+			}
+		}
+		t := time.NewTimer(delay)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			return nil, contextCallError(ctx, service, method)
+		}
+		delay *= 2
+	}
+}
+
+// postOnce sends req once. It reports whether a failure may be retried.
+func (c *Client) postOnce(ctx context.Context, req []byte) (body []byte, retry bool, err error) {
+	hreq, err := http.NewRequestWithContext(ctx, "POST", c.url, bytes.NewReader(req))
+	if err != nil {
+		return nil, false, err
+	}
+	hreq.Header.Set("Content-Type", "application/octet-stream")
+	resp, err := c.hc.Do(hreq)
+	if err != nil {
+		return nil, true, fmt.Errorf("error sending request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err = ioutil.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return nil, resp.StatusCode >= 500, fmt.Errorf("bad response %d; body: %q", resp.StatusCode, body)
+	}
+	if err != nil {
+		return nil, true, fmt.Errorf("failed reading response: %v", err)
+	}
+	return body, false, nil
+}
+
+// contextCallError returns the error of a call to service.method that was
+// stopped because ctx is done.
+func contextCallError(ctx context.Context, service, method string) error {
+	timeout := errors.Is(ctx.Err(), context.DeadlineExceeded)
+	detail := ctx.Err().Error()
+	if timeout {
+		detail = "Deadline exceeded"
+	}
+	return &internal.CallError{
+		Service: service,
+		Method:  method,
+		Detail:  detail,
+		Code:    int32(pb.RpcError_CANCELLED),
+		Timeout: timeout,
+		Err:     ctx.Err(),
+	}
+}
+
 // This is a forgiving regexp designed to parse the app ID from YAML.
 var appIDRE = regexp.MustCompile(`app_id["']?\s*:\s*['"]?([-a-z0-9.:~]+)`)
 
@@ -192,3 +349,72 @@ func (t *headerAddingRoundTripper) RoundTrip(r *http.Request) (*http.Response, e
 	r.Header.Set("X-Appcfg-Api-Version", "1")
 	return t.Wrapped.RoundTrip(r)
 }
+
+// bearerRoundTripper authenticates requests with tokens from base, which are
+// reused until they expire, and retries a request rejected with 401
+// Unauthorized once with a new token.
+type bearerRoundTripper struct {
+	base    oauth2.TokenSource
+	Wrapped http.RoundTripper
+
+	mu  sync.Mutex
+	ts  oauth2.TokenSource // reuses the tokens of base, replaced when one is rejected
+	tok *oauth2.Token      // the last token from ts
+}
+
+func newBearerRoundTripper(base oauth2.TokenSource, wrapped http.RoundTripper) *bearerRoundTripper {
+	return &bearerRoundTripper{base: base, Wrapped: wrapped, ts: oauth2.ReuseTokenSource(nil, base)}
+}
+
+// token returns the token to send. If rejected is not nil, it is the token of
+// a rejected request, and unless another request already replaced it, a new
+// token is fetched from base.
+func (t *bearerRoundTripper) token(rejected *oauth2.Token) (*oauth2.Token, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if rejected != nil && t.tok != nil && t.tok.AccessToken == rejected.AccessToken {
+		t.ts = oauth2.ReuseTokenSource(nil, t.base)
+	}
+	tok, err := t.ts.Token()
+	if err != nil {
+		return nil, err
+	}
+	t.tok = tok
+	return tok, nil
+}
+
+func (t *bearerRoundTripper) RoundTrip(r *http.Request) (*http.Response, error) {
+	tok, err := t.token(nil)
+	if err != nil {
+		return nil, fmt.Errorf("remote_api: fetching token: %v", err)
+	}
+	resp, err := t.send(r, tok)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+	if r.Body != nil && r.GetBody == nil {
+		// The body cannot be sent again.
+		return resp, nil
+	}
+	resp.Body.Close()
+	tok, err = t.token(tok)
+	if err != nil {
+		return nil, fmt.Errorf("remote_api: refreshing token: %v", err)
+	}
+	if r.GetBody != nil {
+		body, err := r.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		r = r.Clone(r.Context())
+		r.Body = body
+	}
+	return t.send(r, tok)
+}
+
+func (t *bearerRoundTripper) send(r *http.Request, tok *oauth2.Token) (*http.Response, error) {
+	// A RoundTripper must not modify the request it is given.
+	r = r.Clone(r.Context())
+	tok.SetAuthHeader(r)
+	return t.Wrapped.RoundTrip(r)
+}
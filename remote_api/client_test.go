@@ -6,11 +6,24 @@ package remote_api
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
 	"log"
 	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"golang.org/x/oauth2"
 
 	"google.golang.org/appengine/datastore"
+	"google.golang.org/appengine/internal"
+	basepb "google.golang.org/appengine/internal/base"
+	pb "google.golang.org/appengine/internal/remote_api"
 )
 
 func TestAppIDRE(t *testing.T) {
@@ -28,6 +41,284 @@ func TestAppIDRE(t *testing.T) {
 	}
 }
 
+// fakeRemote serves the remote_api handshake, and answers each API call with
+// call, which is given the request and returns the response to reply with.
+func fakeRemote(t *testing.T, call func(w http.ResponseWriter, r *http.Request, req *pb.Request) *pb.Response) (host string, cleanup func()) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/_ah/remote_api" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method == "GET" {
+			fmt.Fprintf(w, "{rtok: %s, app_id: s~test-app}", r.FormValue("rtok"))
+			return
+		}
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("reading request: %v", err)
+			return
+		}
+		req := &pb.Request{}
+		if err := proto.Unmarshal(body, req); err != nil {
+			t.Errorf("unmarshalling request: %v", err)
+			return
+		}
+		resp := call(w, r, req)
+		if resp == nil {
+			return
+		}
+		b, err := proto.Marshal(resp)
+		if err != nil {
+			t.Errorf("marshalling response: %v", err)
+			return
+		}
+		w.Write(b)
+	}))
+	// NewClient speaks plain HTTP to localhost only.
+	return "localhost:" + srv.URL[strings.LastIndex(srv.URL, ":")+1:], srv.Close
+}
+
+// echoResponse returns a response to a call with a StringProto request, with
+// the same StringProto.
+func echoResponse(req *pb.Request) *pb.Response {
+	return &pb.Response{Response: req.Request}
+}
+
+func callEcho(ctx context.Context, v string) (string, error) {
+	out := &basepb.StringProto{}
+	err := internal.Call(ctx, "echo", "Echo", &basepb.StringProto{Value: proto.String(v)}, out)
+	return out.GetValue(), err
+}
+
+// countingTokenSource returns the tokens "token-1", "token-2", and so on.
+type countingTokenSource struct{ n int }
+
+func (s *countingTokenSource) Token() (*oauth2.Token, error) {
+	s.n++
+	return &oauth2.Token{AccessToken: fmt.Sprintf("token-%d", s.n)}, nil
+}
+
+func TestTokenSourceAuth(t *testing.T) {
+	var auths []string
+	host, cleanup := fakeRemote(t, func(w http.ResponseWriter, r *http.Request, req *pb.Request) *pb.Response {
+		auths = append(auths, r.Header.Get("Authorization"))
+		return echoResponse(req)
+	})
+	defer cleanup()
+
+	ts := &countingTokenSource{}
+	ctx, err := NewRemoteContextWithTokenSource(context.Background(), host, ts)
+	if err != nil {
+		t.Fatalf("NewRemoteContextWithTokenSource: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if got, err := callEcho(ctx, "hi"); err != nil || got != "hi" {
+			t.Fatalf("Echo = %q, %v; want hi", got, err)
+		}
+	}
+	if want := []string{"Bearer token-1", "Bearer token-1"}; fmt.Sprint(auths) != fmt.Sprint(want) {
+		t.Errorf("Authorization headers = %q, want %q", auths, want)
+	}
+	if got := internal.FullyQualifiedAppID(ctx); got != "s~test-app" {
+		t.Errorf("app ID = %q, want s~test-app", got)
+	}
+}
+
+func TestTokenSourceRefreshOn401(t *testing.T) {
+	var auths []string
+	host, cleanup := fakeRemote(t, func(w http.ResponseWriter, r *http.Request, req *pb.Request) *pb.Response {
+		auths = append(auths, r.Header.Get("Authorization"))
+		if r.Header.Get("Authorization") == "Bearer token-1" {
+			http.Error(w, "token expired", http.StatusUnauthorized)
+			return nil
+		}
+		return echoResponse(req)
+	})
+	defer cleanup()
+
+	ts := &countingTokenSource{}
+	ctx, err := NewRemoteContextWithTokenSource(context.Background(), host, ts)
+	if err != nil {
+		t.Fatalf("NewRemoteContextWithTokenSource: %v", err)
+	}
+	if got, err := callEcho(ctx, "hi"); err != nil || got != "hi" {
+		t.Fatalf("Echo = %q, %v; want hi", got, err)
+	}
+	if want := []string{"Bearer token-1", "Bearer token-2"}; fmt.Sprint(auths) != fmt.Sprint(want) {
+		t.Errorf("Authorization headers = %q, want %q", auths, want)
+	}
+}
+
+func TestDefaultRemoteContextRefreshOn401(t *testing.T) {
+	// The Application Default Credentials are those of a user, whose tokens
+	// come from a fake token endpoint through the caching sources of
+	// golang.org/x/oauth2/google.
+	var issued int
+	tokens := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		issued++
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token": "token-%d", "token_type": "Bearer", "expires_in": 3600}`, issued)
+	}))
+	defer tokens.Close()
+	creds := filepath.Join(t.TempDir(), "credentials.json")
+	err := ioutil.WriteFile(creds, []byte(fmt.Sprintf(`{"type": "authorized_user", "client_id": "id", "client_secret": "secret",
+		"refresh_token": "refresh", "token_uri": %q}`, tokens.URL)), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", creds)
+
+	var auths []string
+	host, cleanup := fakeRemote(t, func(w http.ResponseWriter, r *http.Request, req *pb.Request) *pb.Response {
+		auths = append(auths, r.Header.Get("Authorization"))
+		if r.Header.Get("Authorization") == "Bearer token-1" {
+			http.Error(w, "token revoked", http.StatusUnauthorized)
+			return nil
+		}
+		return echoResponse(req)
+	})
+	defer cleanup()
+
+	ctx, err := NewDefaultRemoteContext(context.Background(), host)
+	if err != nil {
+		t.Fatalf("NewDefaultRemoteContext: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if got, err := callEcho(ctx, "hi"); err != nil || got != "hi" {
+			t.Fatalf("Echo = %q, %v; want hi", got, err)
+		}
+	}
+	if want := []string{"Bearer token-1", "Bearer token-2", "Bearer token-2"}; fmt.Sprint(auths) != fmt.Sprint(want) {
+		t.Errorf("Authorization headers = %q, want %q", auths, want)
+	}
+	if issued != 2 {
+		t.Errorf("issued %d tokens, want 2", issued)
+	}
+}
+
+func TestTokenSourceRejected(t *testing.T) {
+	calls := 0
+	host, cleanup := fakeRemote(t, func(w http.ResponseWriter, r *http.Request, req *pb.Request) *pb.Response {
+		calls++
+		http.Error(w, "not an administrator", http.StatusUnauthorized)
+		return nil
+	})
+	defer cleanup()
+
+	ctx, err := NewRemoteContextWithTokenSource(context.Background(), host, &countingTokenSource{})
+	if err != nil {
+		t.Fatalf("NewRemoteContextWithTokenSource: %v", err)
+	}
+	if _, err := callEcho(ctx, "hi"); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("Echo error = %v, want a 401", err)
+	}
+	if calls != 2 {
+		t.Errorf("got %d requests, want 2", calls)
+	}
+}
+
+func TestCallDeadline(t *testing.T) {
+	host, cleanup := fakeRemote(t, func(w http.ResponseWriter, r *http.Request, req *pb.Request) *pb.Response {
+		<-r.Context().Done()
+		return nil
+	})
+	defer cleanup()
+
+	c, err := NewClient(host, http.DefaultClient)
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(c.NewContext(context.Background()), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err = callEcho(ctx, "hi")
+	if !internal.IsTimeout(err) {
+		t.Errorf("Echo error = %v, want a timeout", err)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Echo error = %v, want it to wrap context.DeadlineExceeded", err)
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("Echo took %v, want it to stop at the deadline", d)
+	}
+}
+
+func TestCallRetries(t *testing.T) {
+	defer func(d time.Duration) { retryDelay = d }(retryDelay)
+	retryDelay = time.Millisecond
+
+	tests := []struct {
+		desc     string
+		retries  int
+		statuses []int // of the attempts that fail
+		wantErr  bool
+		wantN    int
+	}{
+		{"retry then success", 3, []int{503, 500}, false, 3},
+		{"retries exhausted", 1, []int{500, 502, 503}, true, 2},
+		{"client error", 3, []int{403}, true, 1},
+	}
+	for _, tc := range tests {
+		n := 0
+		host, cleanup := fakeRemote(t, func(w http.ResponseWriter, r *http.Request, req *pb.Request) *pb.Response {
+			n++
+			if n <= len(tc.statuses) {
+				http.Error(w, "unavailable", tc.statuses[n-1])
+				return nil
+			}
+			return echoResponse(req)
+		})
+		c, err := NewClient(host, http.DefaultClient, Retries(tc.retries))
+		if err != nil {
+			t.Fatalf("%s: NewClient: %v", tc.desc, err)
+		}
+		got, err := callEcho(c.NewContext(context.Background()), "hi")
+		cleanup()
+		if tc.wantErr {
+			var ce *internal.CallError
+			if !errors.As(err, &ce) || ce.Service != "echo" || ce.Method != "Echo" {
+				t.Errorf("%s: Echo error = %#v, want a CallError for echo.Echo", tc.desc, err)
+			}
+		} else if err != nil || got != "hi" {
+			t.Errorf("%s: Echo = %q, %v; want hi", tc.desc, got, err)
+		}
+		if n != tc.wantN {
+			t.Errorf("%s: got %d requests, want %d", tc.desc, n, tc.wantN)
+		}
+	}
+}
+
+func TestCallRemoteErrors(t *testing.T) {
+	host, cleanup := fakeRemote(t, func(w http.ResponseWriter, r *http.Request, req *pb.Request) *pb.Response {
+		switch req.GetMethod() {
+		case "AppError":
+			return &pb.Response{ApplicationError: &pb.ApplicationError{Code: proto.Int32(5), Detail: proto.String("bad version")}}
+		default:
+			return &pb.Response{RpcError: &pb.RpcError{Code: proto.Int32(int32(pb.RpcError_OVER_QUOTA)), Detail: proto.String("no more")}}
+		}
+	})
+	defer cleanup()
+
+	c, err := NewClient(host, http.DefaultClient)
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	ctx := c.NewContext(context.Background())
+	in, out := &basepb.StringProto{Value: proto.String("hi")}, &basepb.StringProto{}
+
+	err = internal.Call(ctx, "echo", "AppError", in, out)
+	if !errors.Is(err, &internal.APIError{Service: "echo", Method: "AppError", Code: 5}) {
+		t.Errorf("AppError error = %#v, want an APIError with code 5", err)
+	}
+	err = internal.Call(ctx, "echo", "RPCError", in, out)
+	if !internal.IsOverQuota(err) {
+		t.Errorf("RPCError error = %#v, want an over quota CallError", err)
+	}
+	if want := "echo.RPCError: Over quota: no more"; err == nil || err.Error() != want {
+		t.Errorf("RPCError error = %v, want %q", err, want)
+	}
+}
+
 func ExampleClient() {
 	c, err := NewClient("example.appspot.com", http.DefaultClient)
 	if err != nil {
@@ -41,3 +332,14 @@ func ExampleClient() {
 		log.Fatal(err)
 	}
 }
+
+func ExampleNewDefaultRemoteContext() {
+	ctx, err := NewDefaultRemoteContext(context.Background(), "example.appspot.com")
+	if err != nil {
+		log.Fatal(err)
+	}
+	_, err = datastore.Put(ctx, datastore.NewIncompleteKey(ctx, "Foo", nil), struct{ Bar int }{42})
+	if err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,20 @@
+// Copyright 2026 Google LLC. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package runtime
+
+import "time"
+
+// DetachedOption configures a call to RunDetached.
+type DetachedOption func(*detachedOptions)
+
+type detachedOptions struct {
+	timeout time.Duration
+}
+
+// TaskTimeout makes the context passed by RunDetached to its function expire
+// after d.
+func TaskTimeout(d time.Duration) DetachedOption {
+	return func(o *detachedOptions) { o.timeout = d }
+}
@@ -0,0 +1,16 @@
+// Copyright 2026 Google LLC. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+//go:build appengine
+// +build appengine
+
+package runtime
+
+import "context"
+
+// RunDetached runs f detached from the request of c. On first-generation
+// runtimes it is RunInBackground, and the options are ignored.
+func RunDetached(c context.Context, f func(context.Context), opts ...DetachedOption) error {
+	return RunInBackground(c, f)
+}
@@ -0,0 +1,87 @@
+// Copyright 2026 Google LLC. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+//go:build !appengine
+// +build !appengine
+
+package runtime
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/appengine"
+	"google.golang.org/appengine/internal"
+)
+
+func TestRunDetached(t *testing.T) {
+	c, err := appengine.Namespace(context.Background(), "tenant")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("GAE_SERVICE", "worker")
+	got := make(chan context.Context, 1)
+	if err := RunDetached(c, func(ctx context.Context) { got <- ctx }, TaskTimeout(time.Minute)); err != nil {
+		t.Fatalf("RunDetached: %v", err)
+	}
+	ctx := <-got
+	if ns := internal.NamespaceFromContext(ctx); ns != "tenant" {
+		t.Errorf("namespace = %q, want tenant", ns)
+	}
+	if id := internal.BackgroundIdentityFromContext(ctx); id == nil || id.Service != "worker" {
+		t.Errorf("background identity = %+v, want service worker", id)
+	}
+	if _, ok := ctx.Deadline(); !ok {
+		t.Error("context has no deadline despite TaskTimeout")
+	}
+}
+
+func TestWaitDetached(t *testing.T) {
+	release := make(chan bool)
+	finished := make(chan bool, 1)
+	RunDetached(context.Background(), func(ctx context.Context) {
+		<-release
+		finished <- true
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	done := make(chan bool)
+	go func() {
+		waitDetached(ctx)
+		close(done)
+	}()
+	select {
+	case <-done:
+		t.Fatal("shutdown did not wait for the running task")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	<-done
+	select {
+	case <-finished:
+	default:
+		t.Error("shutdown returned before the task finished")
+	}
+}
+
+func TestWaitDetachedDeadline(t *testing.T) {
+	canceled := make(chan bool)
+	RunDetached(context.Background(), func(ctx context.Context) {
+		<-ctx.Done()
+		close(canceled)
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	waitDetached(ctx)
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("shutdown waited %v for an overrunning task, want about 50ms", elapsed)
+	}
+	select {
+	case <-canceled:
+	case <-time.After(5 * time.Second):
+		t.Error("the context of the overrunning task was not canceled")
+	}
+}
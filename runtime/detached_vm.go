@@ -0,0 +1,97 @@
+// Copyright 2026 Google LLC. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+//go:build !appengine
+// +build !appengine
+
+package runtime
+
+import (
+	"context"
+	"sync"
+
+	"google.golang.org/appengine/internal"
+)
+
+// detached tracks the functions started by RunDetached, for the shutdown hook
+// to wait for.
+var detached struct {
+	sync.Mutex
+	running int
+	idle    chan struct{}   // closed when running drops to zero
+	ctx     context.Context // parent of the contexts of the functions
+	cancel  context.CancelFunc
+}
+
+func init() {
+	internal.RegisterShutdownHook(waitDetached)
+}
+
+// RunDetached runs f on a new goroutine, detached from the request of c, and
+// returns immediately. Unlike RunInBackground, it does not need the legacy
+// system API, and works on second-generation runtimes with any scaling type.
+//
+// f is passed a context from appengine.NewBackgroundContext that keeps the
+// namespace of c. It expires after the TaskTimeout option, if given, or when
+// the instance shuts down. When an app started with appengine.Main receives
+// SIGTERM, its shutdown waits for the running functions for as long as the
+// platform allows.
+func RunDetached(c context.Context, f func(context.Context), opts ...DetachedOption) error {
+	var o detachedOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	detached.Lock()
+	if detached.ctx == nil {
+		detached.ctx, detached.cancel = context.WithCancel(context.Background())
+	}
+	ctx := internal.NewBackgroundContext(detached.ctx)
+	if detached.running++; detached.running == 1 {
+		detached.idle = make(chan struct{})
+	}
+	detached.Unlock()
+	if ns := internal.NamespaceFromContext(c); ns != "" {
+		ctx = internal.NamespacedContext(ctx, ns)
+	}
+	cancel := func() {}
+	if o.timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, o.timeout)
+	}
+	go func() {
+		defer func() {
+			detached.Lock()
+			if detached.running--; detached.running == 0 {
+				close(detached.idle)
+			}
+			detached.Unlock()
+		}()
+		defer cancel()
+		f(ctx)
+	}()
+	return nil
+}
+
+// waitDetached is the shutdown hook that waits for the functions started by
+// RunDetached until ctx expires, and then cancels the contexts of those that
+// are still running.
+func waitDetached(ctx context.Context) {
+	detached.Lock()
+	idle := detached.idle
+	if detached.running == 0 {
+		idle = nil
+	}
+	detached.Unlock()
+	if idle != nil {
+		select {
+		case <-idle:
+		case <-ctx.Done():
+		}
+	}
+	detached.Lock()
+	if detached.cancel != nil {
+		detached.cancel()
+		detached.ctx, detached.cancel = nil, nil
+	}
+	detached.Unlock()
+}
@@ -6,7 +6,8 @@
 Package runtime exposes information about the resource usage of the application.
 It also provides a way to run code in a new background context of a module.
 
-This package does not work on App Engine "flexible environment".
+This package does not work on App Engine "flexible environment", except for
+RunDetached.
 */
 package runtime // import "google.golang.org/appengine/runtime"
 
@@ -35,7 +36,20 @@ type Statistics struct {
 	}
 }
 
+// Stats returns the statistics of the instance.
+//
+// Where the legacy system API is not available, as on second-generation
+// runtimes, they are computed from the metrics of the process: CPU from its
+// user and system time, converted to megacycles of the 1.2 GHz reference CPU
+// that App Engine used, and RAM from its resident set size, or the memory the
+// Go runtime obtained from the system where that is not available. The rates
+// and averages are approximations computed from the samples taken by previous
+// calls to Stats within the window, and cover the time since the previous
+// call if there is none.
 func Stats(c context.Context) (*Statistics, error) {
+	if useLocalStats() {
+		return localStats(), nil
+	}
 	req := &pb.GetSystemStatsRequest{}
 	res := &pb.GetSystemStatsResponse{}
 	if err := internal.Call(c, "system", "GetSystemStats", req, res); err != nil {
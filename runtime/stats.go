@@ -0,0 +1,209 @@
+// Copyright 2026 Google LLC. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package runtime
+
+import (
+	"io/ioutil"
+	"os"
+	goruntime "runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/appengine/internal"
+)
+
+// This file computes Statistics from local process metrics, for runtimes
+// without the legacy system API.
+
+const (
+	// megacyclesPerSecond is the clock rate of the reference CPU that App
+	// Engine measured CPU usage against.
+	megacyclesPerSecond = 1200
+
+	// clockTicksPerSecond is the unit of the CPU times in /proc/self/stat,
+	// USER_HZ, which is 100 on all Linux platforms App Engine runs on.
+	clockTicksPerSecond = 100
+
+	// statsHistoryLength is the longest window that the rates and averages of
+	// Statistics cover.
+	statsHistoryLength = 10 * time.Minute
+
+	// statsBucketLength is the interval that the samples of Statistics are
+	// merged over, so that the history is bounded however often Stats is
+	// called.
+	statsBucketLength = 10 * time.Second
+
+	// statsBuckets is the number of buckets of the history: those of
+	// statsHistoryLength, plus one older bucket as the baseline of the
+	// longest window.
+	statsBuckets = int(statsHistoryLength/statsBucketLength) + 1
+)
+
+type statsSample struct {
+	t   time.Time
+	cpu float64 // megacycles consumed since the process started
+	ram float64 // megabytes in use
+}
+
+// statsBucket merges the samples taken within a statsBucketLength interval.
+type statsBucket struct {
+	start  time.Time // of the interval
+	t      time.Time // of the newest sample
+	cpu    float64   // of the newest sample
+	ramSum float64   // of all the samples
+	n      int       // number of samples
+}
+
+// statsHistory is a ring of the buckets of the samples taken by localStats,
+// whose first sample is taken when the package is initialized. next is the
+// index of the bucket that is overwritten next, and n the number of buckets
+// in use.
+var statsHistory struct {
+	sync.Mutex
+	buckets [statsBuckets]statsBucket
+	next, n int
+}
+
+func init() {
+	addStatsSample(takeStatsSample())
+}
+
+// useLocalStats reports whether Stats must be computed locally, which is
+// everywhere except on first-generation standard runtimes. It is a variable
+// so that tests can override it.
+var useLocalStats = func() bool {
+	return !internal.IsStandard() || internal.IsSecondGen()
+}
+
+func takeStatsSample() statsSample {
+	return statsSample{t: time.Now(), cpu: cpuSeconds() * megacyclesPerSecond, ram: memoryMegabytes()}
+}
+
+// addStatsSample merges s into the newest bucket of statsHistory if it is of
+// the same interval, or else into a new bucket, which replaces the oldest one
+// once the ring is full. statsHistory must be locked, except during init.
+func addStatsSample(s statsSample) {
+	h := &statsHistory
+	start := s.t.Truncate(statsBucketLength)
+	if h.n > 0 {
+		if b := &h.buckets[(h.next+statsBuckets-1)%statsBuckets]; b.start.Equal(start) {
+			b.t, b.cpu = s.t, s.cpu
+			b.ramSum += s.ram
+			b.n++
+			return
+		}
+	}
+	h.buckets[h.next] = statsBucket{start: start, t: s.t, cpu: s.cpu, ramSum: s.ram, n: 1}
+	h.next = (h.next + 1) % statsBuckets
+	if h.n < statsBuckets {
+		h.n++
+	}
+}
+
+// localStats samples the process and returns its statistics. The rates and
+// averages are computed from the samples taken by previous calls, so they are
+// approximations that improve as Stats is called regularly.
+func localStats() *Statistics {
+	now := takeStatsSample()
+	statsHistory.Lock()
+	defer statsHistory.Unlock()
+	addStatsSample(now)
+	var ring [statsBuckets]statsBucket
+	h := ring[:0]
+	for i := statsHistory.n; i > 0; i-- {
+		h = append(h, statsHistory.buckets[(statsHistory.next+statsBuckets-i)%statsBuckets])
+	}
+
+	s := &Statistics{}
+	s.CPU.Total = now.cpu
+	s.CPU.Rate1M = cpuRate(h, time.Minute)
+	s.CPU.Rate10M = cpuRate(h, 10*time.Minute)
+	s.RAM.Current = now.ram
+	s.RAM.Average1M = ramAverage(h, time.Minute)
+	s.RAM.Average10M = ramAverage(h, 10*time.Minute)
+	return s
+}
+
+// windowStart returns the index of the oldest bucket of h, oldest first,
+// within window of the last one, or of the newest one before it if there is
+// none.
+func windowStart(h []statsBucket, window time.Duration) int {
+	last := h[len(h)-1]
+	i := len(h) - 1
+	for i > 0 && last.t.Sub(h[i-1].t) <= window {
+		i--
+	}
+	if i == len(h)-1 && i > 0 {
+		i--
+	}
+	return i
+}
+
+// cpuRate returns the CPU consumption rate, in megacycles per second, between
+// the start of window and the last bucket of h.
+func cpuRate(h []statsBucket, window time.Duration) float64 {
+	first, last := h[windowStart(h, window)], h[len(h)-1]
+	elapsed := last.t.Sub(first.t).Seconds()
+	if elapsed <= 0 {
+		return 0
+	}
+	return (last.cpu - first.cpu) / elapsed
+}
+
+// ramAverage returns the average memory usage of the samples of the buckets
+// of h within window.
+func ramAverage(h []statsBucket, window time.Duration) float64 {
+	var sum float64
+	var n int
+	for _, b := range h[windowStart(h, window):] {
+		sum += b.ramSum
+		n += b.n
+	}
+	return sum / float64(n)
+}
+
+// cpuSeconds returns the user and system CPU time consumed by the process,
+// from /proc/self/stat, or zero where that is not available.
+func cpuSeconds() float64 {
+	b, err := ioutil.ReadFile("/proc/self/stat")
+	if err != nil {
+		return 0
+	}
+	// The command name, in parentheses, may contain spaces.
+	s := string(b)
+	if i := strings.LastIndexByte(s, ')'); i >= 0 {
+		s = s[i+1:]
+	}
+	// utime and stime are fields 14 and 15 of the line, and 12 and 13 after
+	// the command name.
+	f := strings.Fields(s)
+	if len(f) < 13 {
+		return 0
+	}
+	utime, err1 := strconv.ParseFloat(f[11], 64)
+	stime, err2 := strconv.ParseFloat(f[12], 64)
+	if err1 != nil || err2 != nil {
+		return 0
+	}
+	return (utime + stime) / clockTicksPerSecond
+}
+
+// memoryMegabytes returns the resident set size of the process from
+// /proc/self/statm, or where that is not available, the memory obtained from
+// the operating system by the Go runtime.
+func memoryMegabytes() float64 {
+	if b, err := ioutil.ReadFile("/proc/self/statm"); err == nil {
+		if f := strings.Fields(string(b)); len(f) > 1 {
+			if pages, err := strconv.ParseFloat(f[1], 64); err == nil && pages > 0 {
+				return pages * float64(os.Getpagesize()) / (1 << 20)
+			}
+		}
+	}
+	var m goruntime.MemStats
+	goruntime.ReadMemStats(&m)
+	return float64(m.Sys) / (1 << 20)
+}
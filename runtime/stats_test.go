@@ -0,0 +1,123 @@
+// Copyright 2026 Google LLC. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package runtime
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+
+	"google.golang.org/appengine/internal/aetesting"
+	pb "google.golang.org/appengine/internal/system"
+)
+
+func TestLocalStats(t *testing.T) {
+	first, err := Stats(context.Background())
+	if err != nil {
+		t.Fatalf("Stats: %v", err)
+	}
+	if first.RAM.Current <= 0 {
+		t.Errorf("RAM.Current = %v, want > 0", first.RAM.Current)
+	}
+	// Burn some CPU so that the total has a chance to advance.
+	for start := time.Now(); time.Since(start) < 50*time.Millisecond; {
+	}
+	second, err := Stats(context.Background())
+	if err != nil {
+		t.Fatalf("Stats: %v", err)
+	}
+	if second.CPU.Total < first.CPU.Total {
+		t.Errorf("CPU.Total went from %v to %v, want non-decreasing", first.CPU.Total, second.CPU.Total)
+	}
+	if second.CPU.Rate1M < 0 || second.RAM.Average1M <= 0 || second.RAM.Average10M <= 0 {
+		t.Errorf("Stats = %+v, want non-negative rate and positive averages", second)
+	}
+}
+
+func TestStatsWindows(t *testing.T) {
+	base := time.Now()
+	h := []statsBucket{
+		{t: base, cpu: 0, ramSum: 10, n: 1},
+		{t: base.Add(5 * time.Minute), cpu: 600, ramSum: 20, n: 1},
+		{t: base.Add(9*time.Minute + 30*time.Second), cpu: 900, ramSum: 30, n: 1},
+		{t: base.Add(10 * time.Minute), cpu: 960, ramSum: 80, n: 2},
+	}
+	if got, want := cpuRate(h, time.Minute), 60.0/30; got != want {
+		t.Errorf("cpuRate(1m) = %v, want %v", got, want)
+	}
+	if got, want := cpuRate(h, 10*time.Minute), 960.0/600; got != want {
+		t.Errorf("cpuRate(10m) = %v, want %v", got, want)
+	}
+	if got, want := ramAverage(h, time.Minute), 110.0/3; got != want {
+		t.Errorf("ramAverage(1m) = %v, want %v", got, want)
+	}
+	if got, want := ramAverage(h, 10*time.Minute), 140.0/5; got != want {
+		t.Errorf("ramAverage(10m) = %v, want %v", got, want)
+	}
+	// With a single sample in the window, the rate covers the time since the
+	// previous one.
+	if got, want := cpuRate(h[:2], time.Minute), 600.0/300; got != want {
+		t.Errorf("cpuRate(1m) of two samples = %v, want %v", got, want)
+	}
+}
+
+func TestStatsHistoryBounded(t *testing.T) {
+	statsHistory.Lock()
+	saved := statsHistory.buckets
+	savedNext, savedN := statsHistory.next, statsHistory.n
+	statsHistory.Unlock()
+	defer func() {
+		statsHistory.Lock()
+		statsHistory.buckets, statsHistory.next, statsHistory.n = saved, savedNext, savedN
+		statsHistory.Unlock()
+	}()
+
+	for i := 0; i < 10000; i++ {
+		if _, err := Stats(context.Background()); err != nil {
+			t.Fatalf("Stats: %v", err)
+		}
+	}
+	statsHistory.Lock()
+	defer statsHistory.Unlock()
+	if statsHistory.n > statsBuckets {
+		t.Errorf("history has %d buckets, want at most %d", statsHistory.n, statsBuckets)
+	}
+	var samples int
+	for _, b := range statsHistory.buckets[:statsHistory.n] {
+		samples += b.n
+	}
+	if samples < 10000 {
+		t.Errorf("history merged %d samples, want all of them", samples)
+	}
+
+	// Samples of later intervals replace the oldest buckets.
+	base := time.Now().Truncate(statsBucketLength)
+	for i := 0; i < 3*statsBuckets; i++ {
+		addStatsSample(statsSample{t: base.Add(time.Duration(i) * statsBucketLength), ram: 1})
+	}
+	if statsHistory.n != statsBuckets {
+		t.Errorf("history has %d buckets, want %d", statsHistory.n, statsBuckets)
+	}
+}
+
+func TestLegacyStats(t *testing.T) {
+	defer func(f func() bool) { useLocalStats = f }(useLocalStats)
+	useLocalStats = func() bool { return false }
+
+	c := aetesting.FakeSingleContext(t, "system", "GetSystemStats", func(req *pb.GetSystemStatsRequest, res *pb.GetSystemStatsResponse) error {
+		res.Cpu = &pb.SystemStat{Total: proto.Float64(1.5)}
+		res.Memory = &pb.SystemStat{Current: proto.Float64(64)}
+		return nil
+	})
+	s, err := Stats(c)
+	if err != nil {
+		t.Fatalf("Stats: %v", err)
+	}
+	if s.CPU.Total != 1.5 || s.RAM.Current != 64 {
+		t.Errorf("Stats = %+v, want CPU.Total 1.5 and RAM.Current 64", s)
+	}
+}
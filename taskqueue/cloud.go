@@ -0,0 +1,566 @@
+// Copyright 2026 Google LLC. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package taskqueue
+
+// This file implements the Cloud Tasks backend of the package, for runtimes
+// without the legacy task queue API.
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	cloudtasks "google.golang.org/api/cloudtasks/v2"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+
+	"google.golang.org/appengine"
+	"google.golang.org/appengine/internal"
+	pb "google.golang.org/appengine/internal/taskqueue"
+)
+
+// cloudTasksOptions holds extra client options used when constructing the
+// Cloud Tasks client. It is a variable so that tests can point the client at
+// a fake server.
+var cloudTasksOptions []option.ClientOption
+
+// useCloudAPI reports whether TASKQUEUE_USE_CLOUD_API selects the Cloud
+// Tasks backend.
+func useCloudAPI() (bool, error) {
+	return envBool("TASKQUEUE_USE_CLOUD_API")
+}
+
+// strictRetryOptions reports whether TASKQUEUE_STRICT_RETRY_OPTIONS makes
+// adding tasks with RetryOptions fail with the Cloud Tasks backend.
+func strictRetryOptions() (bool, error) {
+	return envBool("TASKQUEUE_STRICT_RETRY_OPTIONS")
+}
+
+// envBool is internal.EnvBool, with the errors of this package.
+func envBool(name string) (bool, error) {
+	v, err := internal.EnvBool(name)
+	if err != nil {
+		return false, fmt.Errorf("taskqueue: %v", err)
+	}
+	return v, nil
+}
+
+// cloudQueue is a queue of the Cloud Tasks backend.
+type cloudQueue struct {
+	svc     *cloudtasks.Service
+	project string
+	name    string // short name, as in "default"
+	path    string // resource name, as in "projects/p/locations/l/queues/default"
+}
+
+// taskPath returns the resource name of the task with the given short name.
+func (q *cloudQueue) taskPath(name string) string {
+	return q.path + "/tasks/" + name
+}
+
+// newCloudQueue returns the named queue, or the default queue if name is
+// empty, of the project of the running app, in the location given by
+// TASKQUEUE_LOCATION or found with the Locations API.
+func newCloudQueue(c context.Context, name string) (*cloudQueue, error) {
+	if name == "" {
+		name = "default"
+	}
+	project := internal.ProjectID(c)
+	if project == "" {
+		return nil, errors.New("taskqueue: cannot determine the project ID; set GOOGLE_CLOUD_PROJECT")
+	}
+	opts := append([]option.ClientOption{option.WithUserAgent("appengine-taskqueue-go-client")}, cloudTasksOptions...)
+	svc, err := cloudtasks.NewService(c, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("taskqueue: creating Cloud Tasks client: %v", err)
+	}
+	location, err := cloudLocation(c, svc, project)
+	if err != nil {
+		return nil, err
+	}
+	return &cloudQueue{
+		svc:     svc,
+		project: project,
+		name:    name,
+		path:    fmt.Sprintf("projects/%s/locations/%s/queues/%s", project, location, name),
+	}, nil
+}
+
+// locationCache holds the Cloud Tasks location of each project, which is
+// that of its App Engine app and never changes.
+var locationCache struct {
+	sync.Mutex
+	locations map[string]string
+}
+
+// cloudLocation returns the location of the queues of project:
+// TASKQUEUE_LOCATION, or the only location that the Locations API lists for
+// it.
+func cloudLocation(c context.Context, svc *cloudtasks.Service, project string) (string, error) {
+	if l := os.Getenv("TASKQUEUE_LOCATION"); l != "" {
+		return l, nil
+	}
+	locationCache.Lock()
+	defer locationCache.Unlock()
+	if l, ok := locationCache.locations[project]; ok {
+		return l, nil
+	}
+	res, err := svc.Projects.Locations.List("projects/" + project).Context(c).Do()
+	if err != nil {
+		return "", fmt.Errorf("taskqueue: listing Cloud Tasks locations (%v); set TASKQUEUE_LOCATION", err)
+	}
+	if len(res.Locations) != 1 {
+		return "", fmt.Errorf("taskqueue: found %d Cloud Tasks locations for project %s; set TASKQUEUE_LOCATION", len(res.Locations), project)
+	}
+	l := res.Locations[0].LocationId
+	if locationCache.locations == nil {
+		locationCache.locations = make(map[string]string)
+	}
+	locationCache.locations[project] = l
+	return l, nil
+}
+
+// newCloudTask converts task to an App Engine task of Cloud Tasks for q, as
+// newAddReq does for the legacy API.
+func newCloudTask(c context.Context, task *Task, q *cloudQueue) (*cloudtasks.Task, error) {
+	method := task.method()
+	if method == "PULL" {
+		return nil, ErrPullQueuesUnsupported
+	}
+	switch method {
+	case "GET", "POST", "HEAD", "PUT", "DELETE":
+	default:
+		return nil, fmt.Errorf("taskqueue: bad method %q", method)
+	}
+	if task.RetryOptions != nil {
+		if strict, err := strictRetryOptions(); err != nil {
+			return nil, err
+		} else if strict {
+			return nil, errors.New("taskqueue: Cloud Tasks has no per-task RetryOptions; configure the queue with EnsureQueueRetryConfig")
+		}
+	}
+	path := task.Path
+	if path == "" {
+		path = "/_ah/queue/" + q.name
+	}
+	eta := task.ETA
+	if eta.IsZero() {
+		eta = time.Now().Add(task.Delay)
+	} else if task.Delay != 0 {
+		panic("taskqueue: both Delay and ETA are set")
+	}
+
+	req := &cloudtasks.AppEngineHttpRequest{
+		HttpMethod:  method,
+		RelativeUri: path,
+		Headers:     make(map[string]string),
+	}
+	for k, vs := range task.Header {
+		if http.CanonicalHeaderKey(k) == "Host" {
+			if len(vs) > 0 {
+				req.AppEngineRouting = routingFromHost(vs[0])
+			}
+			continue
+		}
+		req.Headers[k] = strings.Join(vs, ", ")
+	}
+	if method == "POST" || method == "PUT" {
+		req.Body = base64.StdEncoding.EncodeToString(task.Payload)
+	}
+	if _, ok := task.Header[currentNamespace]; !ok {
+		req.Headers[currentNamespace] = internal.NamespaceFromContext(c)
+	}
+	if _, ok := task.Header[defaultNamespace]; !ok {
+		if ns := getDefaultNamespace(c); ns != "" {
+			req.Headers[defaultNamespace] = ns
+		}
+	}
+
+	t := &cloudtasks.Task{
+		AppEngineHttpRequest: req,
+		ScheduleTime:         eta.UTC().Format(time.RFC3339Nano),
+	}
+	if task.Name != "" {
+		t.Name = q.taskPath(task.Name)
+	}
+	return t, nil
+}
+
+// routingFromHost returns the App Engine routing of a task with the Host
+// header host, which names an instance, version or service of the app as in
+// "[[instance-dot-]version-dot-]service-dot-app[.REGION.r].appspot.com", or
+// the same with dots. Cloud Tasks does not send Host headers, and routes tasks
+// with the queue's routing unless they have their own. Other hosts, such as
+// custom domains, cannot be mapped to a service, so routingFromHost returns
+// nil for them and the queue's routing applies.
+func routingFromHost(host string) *cloudtasks.AppEngineRouting {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	rest, ok := strings.CutSuffix(strings.ToLower(host), ".appspot.com")
+	if !ok {
+		return nil
+	}
+	labels := strings.Split(rest, ".")
+	if n := len(labels); n >= 3 && labels[n-1] == "r" {
+		labels = labels[:n-2] // the region ID
+	}
+	if len(labels) == 1 {
+		labels = strings.Split(labels[0], "-dot-")
+	}
+	labels = labels[:len(labels)-1] // the app
+	r := &cloudtasks.AppEngineRouting{}
+	switch len(labels) {
+	case 0:
+		return nil
+	case 1:
+		r.Service = labels[0]
+	case 2:
+		r.Version, r.Service = labels[0], labels[1]
+	case 3:
+		r.Instance, r.Version, r.Service = labels[0], labels[1], labels[2]
+	default:
+		return nil
+	}
+	return r
+}
+
+// shortTaskName returns the name of a task from its resource name.
+func shortTaskName(path string) string {
+	return path[strings.LastIndex(path, "/")+1:]
+}
+
+// isAlreadyExists reports whether err is the error of Cloud Tasks for a task
+// whose name is taken, by an existing or a recently deleted task.
+func isAlreadyExists(err error) bool {
+	var gerr *googleapi.Error
+	return errors.As(err, &gerr) && gerr.Code == http.StatusConflict
+}
+
+// addCloud implements Add with the Cloud Tasks backend.
+func addCloud(c context.Context, task *Task, queueName string) (*Task, error) {
+	if task.method() == "PULL" {
+		b, err := getPullBackend()
+		if err != nil {
+			return nil, err
+		}
+		return b.Add(c, task, queueOrDefault(queueName))
+	}
+	q, err := newCloudQueue(c, queueName)
+	if err != nil {
+		return nil, err
+	}
+	return q.add(c, task)
+}
+
+func (q *cloudQueue) add(c context.Context, task *Task) (*Task, error) {
+	t, err := newCloudTask(c, task, q)
+	if err != nil {
+		return nil, err
+	}
+	created, err := q.create(c, t)
+	if err != nil {
+		return nil, err
+	}
+	return addedTask(task, created), nil
+}
+
+// addedTask returns the result of adding task as created.
+func addedTask(task *Task, created *cloudtasks.Task) *Task {
+	resultTask := *task
+	resultTask.Method = task.method()
+	if task.Name == "" {
+		resultTask.Name = shortTaskName(created.Name)
+	}
+	return &resultTask
+}
+
+// maxConcurrentCalls is the number of tasks or queues that AddMulti,
+// DeleteMulti and QueueStats handle at once, since Cloud Tasks has no batch
+// RPCs for them.
+var maxConcurrentCalls = 10
+
+// fanOut calls f for each index below n, at most maxConcurrentCalls at a
+// time, and returns the errors of the calls by index. Once c is done, the
+// remaining indexes are abandoned with the error of c.
+func fanOut(c context.Context, n int, f func(i int) error) appengine.MultiError {
+	me := make(appengine.MultiError, n)
+	sem := make(chan struct{}, maxConcurrentCalls)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		select {
+		case <-c.Done():
+		case sem <- struct{}{}:
+		}
+		if err := c.Err(); err != nil {
+			me[i] = err
+			continue
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer func() { <-sem; wg.Done() }()
+			me[i] = f(i)
+		}(i)
+	}
+	wg.Wait()
+	return me
+}
+
+// createRetries is the number of times a CreateTask call that fails with a
+// transient error is retried.
+const createRetries = 3
+
+// createRetryDelay is the delay before the first retry of a CreateTask call,
+// which doubles after each retry. It is a variable so that tests can shorten
+// it.
+var createRetryDelay = 100 * time.Millisecond
+
+// isTransient reports whether err is a Cloud Tasks error that retrying the
+// call may get past.
+func isTransient(err error) bool {
+	var gerr *googleapi.Error
+	if !errors.As(err, &gerr) {
+		return false
+	}
+	switch gerr.Code {
+	case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// create creates t in q, retrying transient errors.
+func (q *cloudQueue) create(c context.Context, t *cloudtasks.Task) (*cloudtasks.Task, error) {
+	delay := createRetryDelay
+	for i := 0; ; i++ {
+		created, err := q.svc.Projects.Locations.Queues.Tasks.Create(q.path, &cloudtasks.CreateTaskRequest{Task: t}).Context(c).Do()
+		switch {
+		case err == nil:
+			return created, nil
+		case isAlreadyExists(err):
+			return nil, ErrTaskAlreadyAdded
+		case !isTransient(err) || i == createRetries:
+			return nil, fmt.Errorf("taskqueue: creating task in %s: %w", q.path, err)
+		}
+		select {
+		case <-c.Done():
+			return nil, c.Err()
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// addMultiCloud implements AddMulti with the Cloud Tasks backend. As there
+// is no batch RPC, it creates the tasks concurrently.
+func addMultiCloud(c context.Context, tasks []*Task, queueName string) ([]*Task, error) {
+	q, err := newCloudQueue(c, queueName)
+	if err != nil {
+		return nil, err
+	}
+	// Pull tasks, left nil in ts, are added with the PullBackend.
+	var pull PullBackend
+	ts := make([]*cloudtasks.Task, len(tasks))
+	me, any := make(appengine.MultiError, len(tasks)), false
+	for i, t := range tasks {
+		if t.method() == "PULL" {
+			pull, me[i] = getPullBackend()
+		} else {
+			ts[i], me[i] = newCloudTask(c, t, q)
+		}
+		any = any || me[i] != nil
+	}
+	if any {
+		return nil, me
+	}
+
+	tasksOut := make([]*Task, len(tasks))
+	me = fanOut(c, len(ts), func(i int) error {
+		if ts[i] == nil {
+			added, err := pull.Add(c, tasks[i], q.name)
+			tasksOut[i] = added
+			return err
+		}
+		created, err := q.create(c, ts[i])
+		if err != nil {
+			return err
+		}
+		tasksOut[i] = addedTask(tasks[i], created)
+		return nil
+	})
+	for i, err := range me {
+		if err == nil {
+			continue
+		}
+		any = true
+		// Failed tasks are returned as given, like the legacy API does.
+		tasksOut[i] = new(Task)
+		*tasksOut[i] = *tasks[i]
+		tasksOut[i].Method = tasksOut[i].method()
+	}
+	if any {
+		return tasksOut, me
+	}
+	return tasksOut, nil
+}
+
+// taskResource returns the resource name of the task with the given name in
+// q. Names that are already resource names, such as those of tasks listed
+// with the Cloud Tasks API, are returned as they are.
+func (q *cloudQueue) taskResource(name string) string {
+	if strings.HasPrefix(name, "projects/") {
+		return name
+	}
+	return q.taskPath(name)
+}
+
+// deleteError converts err, from a DeleteTask call, to the error that the
+// legacy API returns for the same failure. Cloud Tasks reports deleted and
+// completed tasks as not found, like missing ones, so TOMBSTONED_TASK is never
+// returned.
+func deleteError(err error, path string) error {
+	var gerr *googleapi.Error
+	if errors.As(err, &gerr) && gerr.Code == http.StatusNotFound {
+		return &internal.APIError{
+			Service: "taskqueue",
+			Code:    int32(pb.TaskQueueServiceError_UNKNOWN_TASK),
+			Detail:  gerr.Message,
+		}
+	}
+	return fmt.Errorf("taskqueue: deleting task %s: %w", path, err)
+}
+
+// deleteMultiCloud implements DeleteMulti with the Cloud Tasks backend. As
+// there is no batch RPC, it deletes the tasks concurrently. Pull tasks are
+// deleted with the PullBackend.
+func deleteMultiCloud(c context.Context, tasks []*Task, queueName string) error {
+	q, err := newCloudQueue(c, queueName)
+	if err != nil {
+		return err
+	}
+	me := fanOut(c, len(tasks), func(i int) error {
+		if tasks[i].Method == "PULL" {
+			b, err := getPullBackend()
+			if err != nil {
+				return err
+			}
+			return b.Delete(c, tasks[i], q.name)
+		}
+		if tasks[i].Name == "" {
+			return &internal.APIError{
+				Service: "taskqueue",
+				Code:    int32(pb.TaskQueueServiceError_INVALID_TASK_NAME),
+				Detail:  "task has no name",
+			}
+		}
+		path := q.taskResource(tasks[i].Name)
+		if _, err := q.svc.Projects.Locations.Queues.Tasks.Delete(path).Context(c).Do(); err != nil {
+			return deleteError(err, path)
+		}
+		return nil
+	})
+	for _, err := range me {
+		if err != nil {
+			return me
+		}
+	}
+	return nil
+}
+
+// purgeCloud implements Purge with the Cloud Tasks backend.
+func purgeCloud(c context.Context, queueName string) error {
+	q, err := newCloudQueue(c, queueName)
+	if err != nil {
+		return err
+	}
+	if _, err := q.svc.Projects.Locations.Queues.Purge(q.path, &cloudtasks.PurgeQueueRequest{}).Context(c).Do(); err != nil {
+		return fmt.Errorf("taskqueue: purging %s: %w", q.path, err)
+	}
+	return nil
+}
+
+// durationString formats d, truncated to whole seconds, as a JSON
+// google.protobuf.Duration.
+func durationString(d time.Duration) string {
+	return strconv.FormatInt(int64(d/time.Second), 10) + "s"
+}
+
+// retryConfig converts opts to the retry configuration of a Cloud Tasks
+// queue and the update mask of the fields it sets. Like the retry parameters
+// of legacy tasks, the zero fields of opts are not set, except MaxDoublings
+// with ApplyZeroMaxDoublings.
+func (opts *RetryOptions) retryConfig() (*cloudtasks.RetryConfig, []string) {
+	rc := &cloudtasks.RetryConfig{}
+	var mask []string
+	if opts.RetryLimit > 0 {
+		rc.MaxAttempts = int64(opts.RetryLimit)
+		mask = append(mask, "retryConfig.maxAttempts")
+	}
+	if opts.AgeLimit > 0 {
+		rc.MaxRetryDuration = durationString(opts.AgeLimit)
+		mask = append(mask, "retryConfig.maxRetryDuration")
+	}
+	if opts.MinBackoff > 0 {
+		rc.MinBackoff = durationString(opts.MinBackoff)
+		mask = append(mask, "retryConfig.minBackoff")
+	}
+	if opts.MaxBackoff > 0 {
+		rc.MaxBackoff = durationString(opts.MaxBackoff)
+		mask = append(mask, "retryConfig.maxBackoff")
+	}
+	if opts.MaxDoublings > 0 || (opts.MaxDoublings == 0 && opts.ApplyZeroMaxDoublings) {
+		rc.MaxDoublings = int64(opts.MaxDoublings)
+		rc.ForceSendFields = []string{"MaxDoublings"}
+		mask = append(mask, "retryConfig.maxDoublings")
+	}
+	return rc, mask
+}
+
+// EnsureQueueRetryConfig sets the retry configuration of a named queue of
+// the Cloud Tasks backend to opts, since Cloud Tasks retries all the tasks of
+// a queue alike. An empty queue name means that the default queue will be
+// used. The fields of opts map to those of the queue as follows; zero fields
+// are left unchanged, except MaxDoublings with ApplyZeroMaxDoublings:
+//
+//	RetryLimit   maxAttempts, the same as task_retry_limit in queue.yaml
+//	AgeLimit     maxRetryDuration
+//	MinBackoff   minBackoff
+//	MaxBackoff   maxBackoff
+//	MaxDoublings maxDoublings, with the same meaning: the interval between
+//	             tries doubles MaxDoublings times, then grows linearly
+//	             by 2^MaxDoublings * MinBackoff up to MaxBackoff
+//
+// Durations are truncated to whole seconds. With the legacy API, queues are
+// configured with queue.yaml instead, and EnsureQueueRetryConfig fails.
+func EnsureQueueRetryConfig(c context.Context, queueName string, opts RetryOptions) error {
+	if cloud, err := useCloudAPI(); err != nil {
+		return err
+	} else if !cloud {
+		return errors.New("taskqueue: EnsureQueueRetryConfig requires the Cloud Tasks backend; configure legacy queues with queue.yaml")
+	}
+	rc, mask := opts.retryConfig()
+	if len(mask) == 0 {
+		return nil
+	}
+	q, err := newCloudQueue(c, queueName)
+	if err != nil {
+		return err
+	}
+	_, err = q.svc.Projects.Locations.Queues.Patch(q.path, &cloudtasks.Queue{RetryConfig: rc}).
+		UpdateMask(strings.Join(mask, ",")).Context(c).Do()
+	if err != nil {
+		return fmt.Errorf("taskqueue: updating the retry configuration of %s: %w", q.path, err)
+	}
+	return nil
+}
@@ -0,0 +1,645 @@
+// Copyright 2026 Google LLC. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package taskqueue
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	cloudtasks "google.golang.org/api/cloudtasks/v2"
+	"google.golang.org/api/option"
+
+	"google.golang.org/appengine"
+	"google.golang.org/appengine/internal"
+	pb "google.golang.org/appengine/internal/taskqueue"
+)
+
+const testQueuePath = "projects/my-project/locations/us-east1/queues/work"
+
+// fakeCloudTasks is a Cloud Tasks server for the queues of my-project in
+// us-east1.
+type fakeCloudTasks struct {
+	t *testing.T
+
+	mu       sync.Mutex
+	created  []*cloudtasks.Task
+	names    map[string]bool // of existing tasks
+	nextID   int
+	requests []string // "METHOD path" of every request
+
+	// queues holds the queues that exist, by name, and metrics the values
+	// of their metrics, by metric type and queue name, as in
+	// "cloudtasks.googleapis.com/queue/depth/work". If metricsErr is set,
+	// reading the metrics fails.
+	queues     map[string]*cloudtasks.Queue
+	metrics    map[string]int64
+	metricsErr bool
+
+	// patches holds the update mask and body of each PatchQueue request.
+	patches []string
+
+	// fail, if set, returns the HTTP and gRPC status codes to fail a
+	// CreateTask request for task with, or zero. It is called with mu held.
+	fail func(task *cloudtasks.Task) (int, string)
+}
+
+// newFakeCloudTasks starts a fake Cloud Tasks server and points the package
+// at it with the Cloud backend selected.
+func newFakeCloudTasks(t *testing.T) *fakeCloudTasks {
+	f := &fakeCloudTasks{
+		t:       t,
+		names:   make(map[string]bool),
+		queues:  make(map[string]*cloudtasks.Queue),
+		metrics: make(map[string]int64),
+	}
+	srv := httptest.NewServer(http.HandlerFunc(f.serve))
+	t.Cleanup(srv.Close)
+
+	old := cloudTasksOptions
+	oldMonitoring := monitoringOptions
+	cloudTasksOptions = []option.ClientOption{option.WithEndpoint(srv.URL), option.WithoutAuthentication()}
+	monitoringOptions = cloudTasksOptions
+	t.Cleanup(func() { cloudTasksOptions, monitoringOptions = old, oldMonitoring })
+
+	t.Setenv("TASKQUEUE_USE_CLOUD_API", "true")
+	t.Setenv("GOOGLE_CLOUD_PROJECT", "my-project")
+	t.Setenv("TASKQUEUE_LOCATION", "")
+	locationCache.Lock()
+	locationCache.locations = nil
+	locationCache.Unlock()
+	return f
+}
+
+func writeError(w http.ResponseWriter, code int, status, msg string) {
+	w.WriteHeader(code)
+	fmt.Fprintf(w, `{"error": {"code": %d, "status": %q, "message": %q}}`, code, status, msg)
+}
+
+// metricFilter matches the filters of the time series queries of
+// latestMetric.
+var metricFilter = regexp.MustCompile(`^metric.type = "([^"]+)" AND resource.type = "cloud_tasks_queue" AND resource.labels.queue_id = "([^"]+)"$`)
+
+func (f *fakeCloudTasks) serve(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/v3/projects/my-project/timeSeries" {
+		f.serveTimeSeries(w, r)
+		return
+	}
+	path := strings.TrimPrefix(r.URL.Path, "/v2/")
+	f.mu.Lock()
+	f.requests = append(f.requests, r.Method+" "+path)
+	f.mu.Unlock()
+
+	switch {
+	case r.Method == "GET" && strings.HasPrefix(path, "projects/my-project/locations/us-east1/queues/"):
+		f.mu.Lock()
+		q, ok := f.queues[strings.TrimPrefix(path, "projects/my-project/locations/us-east1/queues/")]
+		f.mu.Unlock()
+		if !ok {
+			writeError(w, http.StatusNotFound, "NOT_FOUND", "Requested entity was not found.")
+			return
+		}
+		json.NewEncoder(w).Encode(q)
+	case r.Method == "GET" && path == "projects/my-project/locations":
+		fmt.Fprint(w, `{"locations": [{"name": "projects/my-project/locations/us-east1", "locationId": "us-east1"}]}`)
+	case r.Method == "POST" && strings.HasSuffix(path, "/tasks"):
+		req := &cloudtasks.CreateTaskRequest{}
+		if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+			f.t.Errorf("decoding CreateTaskRequest: %v", err)
+		}
+		f.mu.Lock()
+		if f.fail != nil {
+			if code, status := f.fail(req.Task); code != 0 {
+				f.mu.Unlock()
+				writeError(w, code, status, "injected failure")
+				return
+			}
+		}
+		task := *req.Task
+		if task.Name == "" {
+			f.nextID++
+			task.Name = fmt.Sprintf("%s/tasks/%d", strings.TrimSuffix(path, "/tasks"), 1000+f.nextID)
+		}
+		if f.names[task.Name] {
+			f.mu.Unlock()
+			writeError(w, http.StatusConflict, "ALREADY_EXISTS", "Requested entity already exists")
+			return
+		}
+		f.names[task.Name] = true
+		f.created = append(f.created, req.Task)
+		f.mu.Unlock()
+		json.NewEncoder(w).Encode(&task)
+	case r.Method == "DELETE" && strings.Contains(path, "/tasks/"):
+		f.mu.Lock()
+		ok := f.names[path]
+		delete(f.names, path)
+		f.mu.Unlock()
+		if !ok {
+			writeError(w, http.StatusNotFound, "NOT_FOUND", "Requested entity was not found.")
+			return
+		}
+		fmt.Fprint(w, `{}`)
+	case r.Method == "PATCH" && strings.Contains(path, "/queues/"):
+		body, _ := io.ReadAll(r.Body)
+		f.mu.Lock()
+		f.patches = append(f.patches, r.FormValue("updateMask")+" "+strings.TrimSpace(string(body)))
+		f.mu.Unlock()
+		w.Write(body)
+	case r.Method == "POST" && strings.HasSuffix(path, ":purge"):
+		fmt.Fprintf(w, `{"name": %q, "state": "RUNNING"}`, strings.TrimSuffix(path, ":purge"))
+	default:
+		writeError(w, http.StatusNotFound, "NOT_FOUND", "no such resource")
+	}
+}
+
+func (f *fakeCloudTasks) serveTimeSeries(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.metricsErr {
+		writeError(w, http.StatusForbidden, "PERMISSION_DENIED", "monitoring.timeSeries.list denied")
+		return
+	}
+	m := metricFilter.FindStringSubmatch(r.FormValue("filter"))
+	if m == nil {
+		f.t.Errorf("unexpected time series filter %q", r.FormValue("filter"))
+		writeError(w, http.StatusBadRequest, "INVALID_ARGUMENT", "bad filter")
+		return
+	}
+	if r.FormValue("aggregation.crossSeriesReducer") != "REDUCE_SUM" {
+		f.t.Errorf("time series query without REDUCE_SUM: %v", r.Form)
+	}
+	v, ok := f.metrics[m[1]+"/"+m[2]]
+	if !ok {
+		fmt.Fprint(w, `{}`)
+		return
+	}
+	fmt.Fprintf(w, `{"timeSeries": [{"points": [{"value": {"int64Value": "%d"}}, {"value": {"int64Value": "1"}}]}]}`, v)
+}
+
+func TestCloudAdd(t *testing.T) {
+	f := newFakeCloudTasks(t)
+	c := context.Background()
+
+	task := NewPOSTTask("/worker", url.Values{"key": {"value"}})
+	task.Header.Add("X-Custom", "a")
+	task.Header.Add("X-Custom", "b")
+	eta := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	task.ETA = eta
+	added, err := Add(c, task, "work")
+	if err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if added.Name != "1001" || added.Method != "POST" {
+		t.Errorf("added task has name %q and method %q, want the server's 1001 and POST", added.Name, added.Method)
+	}
+	if task.Name != "" {
+		t.Errorf("Add modified the name of its argument to %q", task.Name)
+	}
+	if len(f.created) != 1 {
+		t.Fatalf("created %d tasks, want 1", len(f.created))
+	}
+	got := f.created[0]
+	req := got.AppEngineHttpRequest
+	if req == nil {
+		t.Fatalf("task %+v is not an App Engine task", got)
+	}
+	if req.HttpMethod != "POST" || req.RelativeUri != "/worker" {
+		t.Errorf("task request = %s %s, want POST /worker", req.HttpMethod, req.RelativeUri)
+	}
+	if body, _ := base64.StdEncoding.DecodeString(req.Body); string(body) != "key=value" {
+		t.Errorf("task body = %q, want key=value", body)
+	}
+	if h := req.Headers["X-Custom"]; h != "a, b" {
+		t.Errorf("X-Custom header = %q, want %q", h, "a, b")
+	}
+	if h := req.Headers["Content-Type"]; h != "application/x-www-form-urlencoded" {
+		t.Errorf("Content-Type header = %q, want the form type", h)
+	}
+	if _, ok := req.Headers[currentNamespace]; !ok {
+		t.Errorf("task lacks the %s header", currentNamespace)
+	}
+	if st, err := time.Parse(time.RFC3339Nano, got.ScheduleTime); err != nil || !st.Equal(eta) {
+		t.Errorf("schedule time = %q, want %v", got.ScheduleTime, eta)
+	}
+	if got.Name != "" {
+		t.Errorf("unnamed task sent with name %q", got.Name)
+	}
+	if want := "GET projects/my-project/locations"; f.requests[0] != want {
+		t.Errorf("first request = %q, want the location lookup %q", f.requests[0], want)
+	}
+}
+
+func TestCloudAddDefaults(t *testing.T) {
+	f := newFakeCloudTasks(t)
+	t.Setenv("TASKQUEUE_LOCATION", "us-east1")
+	c := context.Background()
+
+	before := time.Now()
+	if _, err := Add(c, &Task{Delay: time.Hour, Method: "GET", Payload: []byte("ignored")}, ""); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	got := f.created[0]
+	if req := got.AppEngineHttpRequest; req.RelativeUri != "/_ah/queue/default" || req.Body != "" {
+		t.Errorf("task request = %+v, want /_ah/queue/default without body", req)
+	}
+	st, err := time.Parse(time.RFC3339Nano, got.ScheduleTime)
+	if err != nil || st.Before(before.Add(time.Hour)) || st.After(time.Now().Add(time.Hour)) {
+		t.Errorf("schedule time = %q, want an hour from now", got.ScheduleTime)
+	}
+	for _, r := range f.requests {
+		if strings.HasSuffix(r, "/locations") {
+			t.Errorf("location looked up despite TASKQUEUE_LOCATION: %s", r)
+		}
+	}
+	if want := "POST projects/my-project/locations/us-east1/queues/default/tasks"; f.requests[0] != want {
+		t.Errorf("request = %q, want %q", f.requests[0], want)
+	}
+}
+
+func TestCloudAddNamed(t *testing.T) {
+	f := newFakeCloudTasks(t)
+	c := context.Background()
+
+	task := &Task{Path: "/worker", Name: "only-once"}
+	added, err := Add(c, task, "work")
+	if err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if added.Name != "only-once" {
+		t.Errorf("added task name = %q, want only-once", added.Name)
+	}
+	if want := testQueuePath + "/tasks/only-once"; f.created[0].Name != want {
+		t.Errorf("task sent with name %q, want %q", f.created[0].Name, want)
+	}
+	if _, err := Add(c, task, "work"); err != ErrTaskAlreadyAdded {
+		t.Errorf("adding the task again: got %v, want ErrTaskAlreadyAdded", err)
+	}
+}
+
+func TestCloudAddErrors(t *testing.T) {
+	newFakeCloudTasks(t)
+	c := context.Background()
+
+	if _, err := Add(c, &Task{Method: "PULL"}, "work"); err == nil {
+		t.Errorf("adding a pull task succeeded, want an error")
+	}
+	if _, err := Add(c, &Task{Method: "PATCH"}, "work"); err == nil {
+		t.Errorf("adding a PATCH task succeeded, want an error")
+	}
+	t.Setenv("TASKQUEUE_USE_CLOUD_API", "maybe")
+	if _, err := Add(c, &Task{}, "work"); err == nil || !strings.Contains(err.Error(), "TASKQUEUE_USE_CLOUD_API") {
+		t.Errorf("Add with an invalid TASKQUEUE_USE_CLOUD_API: got %v, want an error naming it", err)
+	}
+}
+
+func TestCloudAddMulti(t *testing.T) {
+	f := newFakeCloudTasks(t)
+	defer func(d time.Duration) { createRetryDelay = d }(createRetryDelay)
+	createRetryDelay = time.Millisecond
+	c := context.Background()
+
+	// Task 7 always fails, task 12 is a duplicate and task 3 fails once before
+	// it is added.
+	if _, err := Add(c, &Task{Path: "/worker", Name: "dup"}, "work"); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	attempts := make(map[string]int)
+	f.fail = func(task *cloudtasks.Task) (int, string) {
+		uri := task.AppEngineHttpRequest.RelativeUri
+		attempts[uri]++
+		switch {
+		case uri == "/worker/7":
+			return http.StatusBadRequest, "INVALID_ARGUMENT"
+		case uri == "/worker/3" && attempts[uri] == 1:
+			return http.StatusServiceUnavailable, "UNAVAILABLE"
+		}
+		return 0, ""
+	}
+	tasks := make([]*Task, 25)
+	for i := range tasks {
+		tasks[i] = &Task{Path: fmt.Sprintf("/worker/%d", i)}
+	}
+	tasks[12].Name = "dup"
+
+	added, err := AddMulti(c, tasks, "work")
+	me, ok := err.(appengine.MultiError)
+	if !ok || len(me) != len(tasks) {
+		t.Fatalf("AddMulti: got error %v, want a MultiError for %d tasks", err, len(tasks))
+	}
+	if len(added) != len(tasks) {
+		t.Fatalf("AddMulti returned %d tasks, want %d", len(added), len(tasks))
+	}
+	for i, err := range me {
+		switch i {
+		case 7:
+			if err == nil || err == ErrTaskAlreadyAdded {
+				t.Errorf("task 7: got error %v, want the server's", err)
+			}
+		case 12:
+			if err != ErrTaskAlreadyAdded {
+				t.Errorf("task 12: got error %v, want ErrTaskAlreadyAdded", err)
+			}
+		default:
+			if err != nil {
+				t.Errorf("task %d: unexpected error %v", i, err)
+			}
+		}
+		if added[i].Path != tasks[i].Path || added[i].Method != "POST" {
+			t.Errorf("result %d = %s %s, want POST %s", i, added[i].Method, added[i].Path, tasks[i].Path)
+		}
+		if i != 7 && added[i].Name == "" {
+			t.Errorf("result %d has no name", i)
+		}
+	}
+	if attempts["/worker/3"] != 2 {
+		t.Errorf("task 3 was sent %d times, want 2", attempts["/worker/3"])
+	}
+	if attempts["/worker/7"] != 1 {
+		t.Errorf("task 7 was sent %d times; permanent errors should not be retried", attempts["/worker/7"])
+	}
+	// All but the failed ones, and the first "dup".
+	if len(f.created) != 24 {
+		t.Errorf("created %d tasks, want 24", len(f.created))
+	}
+}
+
+func TestCloudAddMultiBadTask(t *testing.T) {
+	f := newFakeCloudTasks(t)
+	_, err := AddMulti(context.Background(), []*Task{{Path: "/a"}, {Method: "PULL"}}, "work")
+	me, ok := err.(appengine.MultiError)
+	if !ok || me[0] != nil || me[1] == nil {
+		t.Fatalf("AddMulti: got error %v, want a MultiError for the second task", err)
+	}
+	if len(f.created) != 0 {
+		t.Errorf("created %d tasks, want none when a task is bad", len(f.created))
+	}
+}
+
+func TestCloudAddMultiCancel(t *testing.T) {
+	f := newFakeCloudTasks(t)
+	defer func(n int) { maxConcurrentCalls = n }(maxConcurrentCalls)
+	maxConcurrentCalls = 1
+	c, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Cancel c while the third task is being added.
+	n := 0
+	f.fail = func(task *cloudtasks.Task) (int, string) {
+		if n++; n == 3 {
+			cancel()
+			return http.StatusServiceUnavailable, "UNAVAILABLE"
+		}
+		return 0, ""
+	}
+	tasks := make([]*Task, 10)
+	for i := range tasks {
+		tasks[i] = &Task{Path: fmt.Sprintf("/worker/%d", i)}
+	}
+	_, err := AddMulti(c, tasks, "work")
+	me, ok := err.(appengine.MultiError)
+	if !ok {
+		t.Fatalf("AddMulti: got error %v, want a MultiError", err)
+	}
+	for i, err := range me {
+		if i < 2 && err != nil {
+			t.Errorf("task %d: unexpected error %v", i, err)
+		}
+		if i >= 2 && !errors.Is(err, context.Canceled) {
+			t.Errorf("task %d: got error %v, want context.Canceled", i, err)
+		}
+	}
+	if n != 3 {
+		t.Errorf("sent %d tasks, want 3", n)
+	}
+}
+
+func TestCloudDelete(t *testing.T) {
+	f := newFakeCloudTasks(t)
+	c := context.Background()
+
+	named, err := Add(c, &Task{Path: "/worker", Name: "mine"}, "work")
+	if err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	unnamed, err := Add(c, &Task{Path: "/worker"}, "work")
+	if err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	full := &Task{Name: testQueuePath + "/tasks/ghost"}
+	f.names[full.Name] = true
+
+	f.requests = nil
+	if err := DeleteMulti(c, []*Task{named, unnamed, full}, "work"); err != nil {
+		t.Fatalf("DeleteMulti: %v", err)
+	}
+	want := map[string]bool{
+		"DELETE " + testQueuePath + "/tasks/mine":  true,
+		"DELETE " + testQueuePath + "/tasks/1001":  true,
+		"DELETE " + testQueuePath + "/tasks/ghost": true,
+	}
+	for _, r := range f.requests {
+		if !want[r] {
+			t.Errorf("unexpected request %q", r)
+		}
+		delete(want, r)
+	}
+	for r := range want {
+		t.Errorf("missing request %q", r)
+	}
+
+	err = Delete(c, named, "work")
+	if apiErr, ok := err.(*internal.APIError); !ok || apiErr.Code != int32(pb.TaskQueueServiceError_UNKNOWN_TASK) {
+		t.Errorf("deleting a deleted task: got %v, want UNKNOWN_TASK", err)
+	}
+	f.requests = nil
+	err = Delete(c, &Task{}, "work")
+	if apiErr, ok := err.(*internal.APIError); !ok || apiErr.Code != int32(pb.TaskQueueServiceError_INVALID_TASK_NAME) {
+		t.Errorf("deleting a task without name: got %v, want INVALID_TASK_NAME", err)
+	}
+	if len(f.requests) != 0 {
+		t.Errorf("deleting a task without name made requests %q", f.requests)
+	}
+}
+
+func TestCloudDeleteMultiErrors(t *testing.T) {
+	f := newFakeCloudTasks(t)
+	c := context.Background()
+
+	for _, name := range []string{"a", "c"} {
+		f.names[testQueuePath+"/tasks/"+name] = true
+	}
+	err := DeleteMulti(c, []*Task{{Name: "a"}, {Name: "b"}, {Name: "c"}}, "work")
+	me, ok := err.(appengine.MultiError)
+	if !ok || len(me) != 3 {
+		t.Fatalf("DeleteMulti: got error %v, want a MultiError for 3 tasks", err)
+	}
+	if me[0] != nil || me[2] != nil {
+		t.Errorf("errors for existing tasks: %v, %v", me[0], me[2])
+	}
+	if apiErr, ok := me[1].(*internal.APIError); !ok || apiErr.Code != int32(pb.TaskQueueServiceError_UNKNOWN_TASK) {
+		t.Errorf("error for a missing task: got %v, want UNKNOWN_TASK", me[1])
+	}
+}
+
+func TestCloudPurge(t *testing.T) {
+	f := newFakeCloudTasks(t)
+	t.Setenv("TASKQUEUE_LOCATION", "us-east1")
+	if err := Purge(context.Background(), ""); err != nil {
+		t.Fatalf("Purge: %v", err)
+	}
+	want := []string{"POST projects/my-project/locations/us-east1/queues/default:purge"}
+	if !reflect.DeepEqual(f.requests, want) {
+		t.Errorf("requests = %q, want %q", f.requests, want)
+	}
+}
+
+func TestCloudQueueStats(t *testing.T) {
+	f := newFakeCloudTasks(t)
+	c := context.Background()
+
+	f.queues["work"] = &cloudtasks.Queue{State: "RUNNING", RateLimits: &cloudtasks.RateLimits{MaxDispatchesPerSecond: 5}}
+	f.queues["default"] = &cloudtasks.Queue{State: "PAUSED", RateLimits: &cloudtasks.RateLimits{MaxDispatchesPerSecond: 500}}
+	f.metrics[depthMetric+"/work"] = 42
+	f.metrics[attemptsMetric+"/work"] = 7
+	f.metrics[depthMetric+"/default"] = 3
+
+	stats, err := QueueStats(c, []string{"work", ""})
+	if err != nil {
+		t.Fatalf("QueueStats: %v", err)
+	}
+	want := []QueueStatistics{{
+		Tasks:           42,
+		Executed1Minute: 7,
+		EnforcedRate:    5,
+		Available:       StatTasks | StatExecuted1Minute | StatEnforcedRate,
+	}, {
+		// Nothing was executed, and the queue is paused.
+		Tasks:     3,
+		Available: StatTasks | StatExecuted1Minute | StatEnforcedRate,
+	}}
+	if !reflect.DeepEqual(stats, want) {
+		t.Errorf("QueueStats = %+v, want %+v", stats, want)
+	}
+
+	f.metricsErr = true
+	stats, err = QueueStats(c, []string{"work", "missing"})
+	me, ok := err.(appengine.MultiError)
+	if !ok || me[0] != nil || me[1] == nil {
+		t.Fatalf("QueueStats of a missing queue: got error %v, want a MultiError for it", err)
+	}
+	if want := (QueueStatistics{EnforcedRate: 5, Available: StatEnforcedRate}); stats[0] != want {
+		t.Errorf("QueueStats without metrics = %+v, want %+v", stats[0], want)
+	}
+}
+
+func TestEnsureQueueRetryConfig(t *testing.T) {
+	f := newFakeCloudTasks(t)
+	c := context.Background()
+
+	tests := []struct {
+		opts RetryOptions
+		want string
+	}{
+		{
+			RetryOptions{RetryLimit: 5, AgeLimit: 90 * time.Minute, MinBackoff: 1500 * time.Millisecond, MaxBackoff: time.Hour, MaxDoublings: 3},
+			`retryConfig.maxAttempts,retryConfig.maxRetryDuration,retryConfig.minBackoff,retryConfig.maxBackoff,retryConfig.maxDoublings ` +
+				`{"retryConfig":{"maxAttempts":5,"maxBackoff":"3600s","maxDoublings":3,"maxRetryDuration":"5400s","minBackoff":"1s"}}`,
+		},
+		{
+			RetryOptions{MaxBackoff: time.Minute},
+			`retryConfig.maxBackoff {"retryConfig":{"maxBackoff":"60s"}}`,
+		},
+		{
+			RetryOptions{ApplyZeroMaxDoublings: true},
+			`retryConfig.maxDoublings {"retryConfig":{"maxDoublings":0}}`,
+		},
+	}
+	for _, tc := range tests {
+		f.patches = nil
+		if err := EnsureQueueRetryConfig(c, "work", tc.opts); err != nil {
+			t.Errorf("EnsureQueueRetryConfig(%+v): %v", tc.opts, err)
+			continue
+		}
+		if len(f.patches) != 1 || f.patches[0] != tc.want {
+			t.Errorf("EnsureQueueRetryConfig(%+v) made patches %q, want %q", tc.opts, f.patches, tc.want)
+		}
+	}
+
+	f.patches = nil
+	if err := EnsureQueueRetryConfig(c, "work", RetryOptions{}); err != nil || len(f.patches) != 0 {
+		t.Errorf("EnsureQueueRetryConfig without options: got error %v and patches %q, want neither", err, f.patches)
+	}
+	t.Setenv("TASKQUEUE_USE_CLOUD_API", "")
+	if err := EnsureQueueRetryConfig(c, "work", RetryOptions{RetryLimit: 1}); err == nil {
+		t.Errorf("EnsureQueueRetryConfig with the legacy API succeeded")
+	}
+}
+
+func TestCloudAddRetryOptions(t *testing.T) {
+	f := newFakeCloudTasks(t)
+	c := context.Background()
+
+	task := &Task{Path: "/worker", RetryOptions: &RetryOptions{RetryLimit: 3}}
+	if _, err := Add(c, task, "work"); err != nil {
+		t.Errorf("Add with RetryOptions: %v", err)
+	}
+	t.Setenv("TASKQUEUE_STRICT_RETRY_OPTIONS", "yes")
+	if _, err := Add(c, task, "work"); err == nil {
+		t.Errorf("Add with RetryOptions in strict mode succeeded")
+	}
+	if _, err := Add(c, &Task{Path: "/worker"}, "work"); err != nil {
+		t.Errorf("Add without RetryOptions in strict mode: %v", err)
+	}
+	if len(f.created) != 2 {
+		t.Errorf("created %d tasks, want 2", len(f.created))
+	}
+}
+
+func TestRoutingFromHost(t *testing.T) {
+	tests := []struct {
+		host string
+		want *cloudtasks.AppEngineRouting
+	}{
+		{"my-app.appspot.com", nil},
+		{"worker-dot-my-app.appspot.com", &cloudtasks.AppEngineRouting{Service: "worker"}},
+		{"v2-dot-worker-dot-my-app.uc.r.appspot.com", &cloudtasks.AppEngineRouting{Service: "worker", Version: "v2"}},
+		{"v2.worker.my-app.appspot.com", &cloudtasks.AppEngineRouting{Service: "worker", Version: "v2"}},
+		{"0-dot-v2-dot-worker-dot-my-app.appspot.com", &cloudtasks.AppEngineRouting{Service: "worker", Version: "v2", Instance: "0"}},
+		{"my-app.uc.r.appspot.com", nil},
+		{"worker.my-app.uc.r.appspot.com", &cloudtasks.AppEngineRouting{Service: "worker"}},
+		{"v2.worker.my-app.uc.r.appspot.com:443", &cloudtasks.AppEngineRouting{Service: "worker", Version: "v2"}},
+		{"api.example.com", nil},
+		{"worker-dot-api.example.com", nil},
+		{"localhost:8080", nil},
+	}
+	for _, tc := range tests {
+		got := routingFromHost(tc.host)
+		if fmt.Sprint(got) != fmt.Sprint(tc.want) {
+			t.Errorf("routingFromHost(%q) = %+v, want %+v", tc.host, got, tc.want)
+		}
+	}
+
+	// A Host header without values is ignored.
+	q := &cloudQueue{name: "work", path: testQueuePath}
+	ct, err := newCloudTask(context.Background(), &Task{Path: "/worker", Header: http.Header{"Host": {}}}, q)
+	if err != nil {
+		t.Fatalf("newCloudTask: %v", err)
+	}
+	if r := ct.AppEngineHttpRequest.AppEngineRouting; r != nil {
+		t.Errorf("routing of a task with an empty Host header = %+v, want nil", r)
+	}
+}
@@ -0,0 +1,140 @@
+// Copyright 2026 Google LLC. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package taskqueue
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	cloudtasks "google.golang.org/api/cloudtasks/v2"
+	monitoring "google.golang.org/api/monitoring/v3"
+	"google.golang.org/api/option"
+)
+
+// monitoringOptions holds extra client options used when constructing the
+// Cloud Monitoring client. It is a variable so that tests can point the
+// client at a fake server.
+var monitoringOptions []option.ClientOption
+
+// Cloud Monitoring metrics of Cloud Tasks queues.
+const (
+	depthMetric    = "cloudtasks.googleapis.com/queue/depth"
+	attemptsMetric = "cloudtasks.googleapis.com/queue/task_attempt_count"
+)
+
+// metricsWindow is how far back the metrics of queues are looked for, as they
+// are written with a delay of a few minutes.
+const metricsWindow = 5 * time.Minute
+
+// queueStatsCloud implements QueueStats with the Cloud Tasks backend.
+func queueStatsCloud(c context.Context, queueNames []string) ([]QueueStatistics, error) {
+	queues := make([]*cloudQueue, len(queueNames))
+	for i, name := range queueNames {
+		q, err := newCloudQueue(c, name)
+		if err != nil {
+			return nil, err
+		}
+		queues[i] = q
+	}
+	opts := append([]option.ClientOption{option.WithUserAgent("appengine-taskqueue-go-client")}, monitoringOptions...)
+	mon, err := monitoring.NewService(c, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("taskqueue: creating Cloud Monitoring client: %v", err)
+	}
+
+	qs := make([]QueueStatistics, len(queues))
+	me := fanOut(c, len(queues), func(i int) error {
+		return queues[i].stats(c, mon, &qs[i])
+	})
+	for _, err := range me {
+		if err != nil {
+			return qs, me
+		}
+	}
+	return qs, nil
+}
+
+// stats fills in st with the statistics of q, fetching its configuration and
+// metrics concurrently.
+func (q *cloudQueue) stats(c context.Context, mon *monitoring.Service, st *QueueStatistics) error {
+	var (
+		wg                  sync.WaitGroup
+		queue               *cloudtasks.Queue
+		queueErr            error
+		depth, attempts     int64
+		depthOK, attemptsOK bool
+	)
+	wg.Add(3)
+	go func() {
+		defer wg.Done()
+		queue, queueErr = q.svc.Projects.Locations.Queues.Get(q.path).Context(c).Do()
+	}()
+	go func() {
+		defer wg.Done()
+		depth, depthOK = q.latestMetric(c, mon, depthMetric, "ALIGN_MAX", false)
+	}()
+	go func() {
+		defer wg.Done()
+		attempts, attemptsOK = q.latestMetric(c, mon, attemptsMetric, "ALIGN_SUM", true)
+	}()
+	wg.Wait()
+
+	if queueErr != nil {
+		return fmt.Errorf("taskqueue: getting queue %s: %w", q.path, queueErr)
+	}
+	// Paused and disabled queues dispatch nothing, like legacy queues with
+	// an enforced rate of zero.
+	if queue.State == "RUNNING" && queue.RateLimits != nil {
+		st.EnforcedRate = queue.RateLimits.MaxDispatchesPerSecond
+	}
+	st.Available = StatEnforcedRate
+	if depthOK {
+		st.Tasks = int(depth)
+		st.Available |= StatTasks
+	}
+	if attemptsOK {
+		st.Executed1Minute = int(attempts)
+		st.Available |= StatExecuted1Minute
+	}
+	return nil
+}
+
+// latestMetric returns the value of the named metric of q, summed over its
+// time series, in the latest minute of metricsWindow that has data, and
+// whether it could be read. If zeroIfAbsent is set, a metric without data,
+// as that of a count of events that did not happen, is zero.
+func (q *cloudQueue) latestMetric(c context.Context, mon *monitoring.Service, metric, aligner string, zeroIfAbsent bool) (int64, bool) {
+	filter := fmt.Sprintf(`metric.type = %q AND resource.type = "cloud_tasks_queue" AND resource.labels.queue_id = %q`, metric, q.name)
+	now := time.Now().UTC()
+	res, err := mon.Projects.TimeSeries.List("projects/" + q.project).
+		Filter(filter).
+		IntervalStartTime(now.Add(-metricsWindow).Format(time.RFC3339Nano)).
+		IntervalEndTime(now.Format(time.RFC3339Nano)).
+		AggregationAlignmentPeriod("60s").
+		AggregationPerSeriesAligner(aligner).
+		AggregationCrossSeriesReducer("REDUCE_SUM").
+		Context(c).Do()
+	if err != nil {
+		return 0, false
+	}
+	// With a cross-series reducer there is at most one time series, whose
+	// points are newest first.
+	for _, ts := range res.TimeSeries {
+		for _, p := range ts.Points {
+			if p.Value == nil {
+				continue
+			}
+			switch {
+			case p.Value.Int64Value != nil:
+				return *p.Value.Int64Value, true
+			case p.Value.DoubleValue != nil:
+				return int64(*p.Value.DoubleValue), true
+			}
+		}
+	}
+	return 0, zeroIfAbsent
+}
@@ -0,0 +1,298 @@
+// Copyright 2026 Google LLC. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+/*
+Package pubsub provides a taskqueue.PullBackend that keeps pull queues in
+Cloud Pub/Sub, for apps that use the Cloud Tasks backend of the taskqueue
+package, which has no pull queues.
+
+Each queue is a topic and a pull subscription of it, both named "taskqueue-"
+followed by the name of the queue; they must exist before the queue is used.
+Adding a task publishes it, leasing it pulls it and extends its ack deadline
+to the lease time, and deleting it acknowledges it:
+
+	b, err := pubsub.NewBackend(ctx, "my-project")
+	if err != nil {
+		// ...
+	}
+	taskqueue.SetPullBackend(b)
+
+Pub/Sub does not delay messages, nor deduplicate them by name, so tasks cannot
+have an ETA or Delay, and tasks added twice with the same name are leased
+twice. Tags are kept in the "tag" attribute of the messages; LeaseByTag
+releases the messages it pulls with other tags right away. Lease times are at
+most 10 minutes, the longest ack deadline of Pub/Sub. Retries are governed by
+the retry and dead-letter policies of the subscription, not by the
+RetryOptions of tasks, and RetryCount is only known with a dead-letter policy.
+
+Leases are tracked in memory, so a task can only be modified or deleted with
+the Backend that leased it.
+*/
+package pubsub // import "google.golang.org/appengine/taskqueue/pubsub"
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"google.golang.org/api/option"
+	pubsub "google.golang.org/api/pubsub/v1"
+
+	"google.golang.org/appengine/taskqueue"
+)
+
+// Message attributes of tasks.
+const (
+	nameAttribute = "taskqueue-name"
+	tagAttribute  = "tag"
+)
+
+// Bounds of Pub/Sub ack deadlines.
+const (
+	minLeaseTime = 10 * time.Second
+	maxLeaseTime = 10 * time.Minute
+)
+
+// Backend is a taskqueue.PullBackend that keeps the pull queues of a project
+// in Cloud Pub/Sub. It is safe for concurrent use.
+type Backend struct {
+	svc     *pubsub.Service
+	project string
+
+	mu     sync.Mutex
+	leases map[leaseKey]*lease
+}
+
+type leaseKey struct {
+	queue, task string
+}
+
+// lease is a lease of a task by a Backend.
+type lease struct {
+	ackID string
+	end   time.Time
+}
+
+var _ taskqueue.PullBackend = (*Backend)(nil)
+
+// NewBackend returns a Backend for the pull queues of project, using the
+// Pub/Sub client configured with opts.
+func NewBackend(ctx context.Context, project string, opts ...option.ClientOption) (*Backend, error) {
+	opts = append([]option.ClientOption{option.WithUserAgent("appengine-taskqueue-pubsub-go-client")}, opts...)
+	svc, err := pubsub.NewService(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("pubsub: creating Pub/Sub client: %v", err)
+	}
+	return &Backend{
+		svc:     svc,
+		project: project,
+		leases:  make(map[leaseKey]*lease),
+	}, nil
+}
+
+func (b *Backend) topic(queueName string) string {
+	return fmt.Sprintf("projects/%s/topics/taskqueue-%s", b.project, queueName)
+}
+
+func (b *Backend) subscription(queueName string) string {
+	return fmt.Sprintf("projects/%s/subscriptions/taskqueue-%s", b.project, queueName)
+}
+
+// Add publishes task to the topic of the named queue. The returned task is
+// named after the message unless task has a name.
+func (b *Backend) Add(c context.Context, task *taskqueue.Task, queueName string) (*taskqueue.Task, error) {
+	if !task.ETA.IsZero() || task.Delay != 0 {
+		return nil, errors.New("pubsub: tasks cannot have an ETA or Delay")
+	}
+	msg := &pubsub.PubsubMessage{
+		Data:       base64.StdEncoding.EncodeToString(task.Payload),
+		Attributes: make(map[string]string),
+	}
+	if task.Name != "" {
+		msg.Attributes[nameAttribute] = task.Name
+	}
+	if task.Tag != "" {
+		msg.Attributes[tagAttribute] = task.Tag
+	}
+	res, err := b.svc.Projects.Topics.Publish(b.topic(queueName), &pubsub.PublishRequest{
+		Messages: []*pubsub.PubsubMessage{msg},
+	}).Context(c).Do()
+	if err != nil {
+		return nil, fmt.Errorf("pubsub: publishing task to %s: %w", b.topic(queueName), err)
+	}
+	if len(res.MessageIds) != 1 {
+		return nil, fmt.Errorf("pubsub: published 1 task, got %d message IDs", len(res.MessageIds))
+	}
+	added := *task
+	added.Method = "PULL"
+	if added.Name == "" {
+		added.Name = res.MessageIds[0]
+	}
+	return &added, nil
+}
+
+// ackDeadline returns the ack deadline in seconds for a lease of leaseTime.
+func ackDeadline(leaseTime time.Duration) (int64, error) {
+	if leaseTime > maxLeaseTime {
+		return 0, fmt.Errorf("pubsub: lease time %v is longer than the maximum of %v", leaseTime, maxLeaseTime)
+	}
+	if leaseTime < minLeaseTime {
+		leaseTime = minLeaseTime
+	}
+	return int64(leaseTime / time.Second), nil
+}
+
+// Lease pulls at most maxTasks messages from the subscription of the named
+// queue and extends their ack deadline to leaseTime. It may return fewer
+// tasks than are available.
+func (b *Backend) Lease(c context.Context, maxTasks int, queueName string, leaseTime time.Duration, groupByTag bool, tag string) ([]*taskqueue.Task, error) {
+	deadline, err := ackDeadline(leaseTime)
+	if err != nil {
+		return nil, err
+	}
+	sub := b.subscription(queueName)
+	res, err := b.svc.Projects.Subscriptions.Pull(sub, &pubsub.PullRequest{
+		MaxMessages: int64(maxTasks),
+	}).Context(c).Do()
+	if err != nil {
+		return nil, fmt.Errorf("pubsub: pulling tasks from %s: %w", sub, err)
+	}
+
+	var leased, released []*pubsub.ReceivedMessage
+	for _, m := range res.ReceivedMessages {
+		if groupByTag {
+			if tag == "" {
+				tag = m.Message.Attributes[tagAttribute]
+			}
+			if m.Message.Attributes[tagAttribute] != tag {
+				released = append(released, m)
+				continue
+			}
+		}
+		leased = append(leased, m)
+	}
+	if err := b.modifyAckDeadline(c, sub, released, 0); err != nil {
+		return nil, err
+	}
+	if err := b.modifyAckDeadline(c, sub, leased, deadline); err != nil {
+		return nil, err
+	}
+
+	end := time.Now().Add(time.Duration(deadline) * time.Second)
+	tasks := make([]*taskqueue.Task, len(leased))
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.pruneLeases()
+	for i, m := range leased {
+		payload, err := base64.StdEncoding.DecodeString(m.Message.Data)
+		if err != nil {
+			return nil, fmt.Errorf("pubsub: decoding message %s: %v", m.Message.MessageId, err)
+		}
+		t := &taskqueue.Task{
+			Payload: payload,
+			Name:    m.Message.Attributes[nameAttribute],
+			Method:  "PULL",
+			ETA:     end,
+			Tag:     m.Message.Attributes[tagAttribute],
+		}
+		if t.Name == "" {
+			t.Name = m.Message.MessageId
+		}
+		if m.DeliveryAttempt > 0 {
+			t.RetryCount = int32(m.DeliveryAttempt - 1)
+		}
+		b.leases[leaseKey{queueName, t.Name}] = &lease{ackID: m.AckId, end: end}
+		tasks[i] = t
+	}
+	return tasks, nil
+}
+
+// modifyAckDeadline sets the ack deadline of msgs to deadline seconds.
+func (b *Backend) modifyAckDeadline(c context.Context, sub string, msgs []*pubsub.ReceivedMessage, deadline int64) error {
+	if len(msgs) == 0 {
+		return nil
+	}
+	req := &pubsub.ModifyAckDeadlineRequest{
+		AckDeadlineSeconds: deadline,
+		// A zero deadline releases the messages.
+		ForceSendFields: []string{"AckDeadlineSeconds"},
+	}
+	for _, m := range msgs {
+		req.AckIds = append(req.AckIds, m.AckId)
+	}
+	if _, err := b.svc.Projects.Subscriptions.ModifyAckDeadline(sub, req).Context(c).Do(); err != nil {
+		return fmt.Errorf("pubsub: modifying ack deadlines in %s: %w", sub, err)
+	}
+	return nil
+}
+
+// pruneLeases forgets the leases that have ended. b.mu must be held.
+func (b *Backend) pruneLeases() {
+	now := time.Now()
+	for k, l := range b.leases {
+		if now.After(l.end) {
+			delete(b.leases, k)
+		}
+	}
+}
+
+// leaseOf returns the current lease of the named task by b.
+func (b *Backend) leaseOf(task *taskqueue.Task, queueName string) (*lease, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	l, ok := b.leases[leaseKey{queueName, task.Name}]
+	if !ok || time.Now().After(l.end) {
+		return nil, fmt.Errorf("pubsub: task %q of queue %q is not leased", task.Name, queueName)
+	}
+	return l, nil
+}
+
+// ModifyLease sets the ack deadline of the message of task to leaseTime.
+func (b *Backend) ModifyLease(c context.Context, task *taskqueue.Task, queueName string, leaseTime time.Duration) error {
+	l, err := b.leaseOf(task, queueName)
+	if err != nil {
+		return err
+	}
+	var deadline int64
+	if leaseTime > 0 {
+		if deadline, err = ackDeadline(leaseTime); err != nil {
+			return err
+		}
+	}
+	sub := b.subscription(queueName)
+	if err := b.modifyAckDeadline(c, sub, []*pubsub.ReceivedMessage{{AckId: l.ackID}}, deadline); err != nil {
+		return err
+	}
+	end := time.Now().Add(time.Duration(deadline) * time.Second)
+	b.mu.Lock()
+	if deadline == 0 {
+		delete(b.leases, leaseKey{queueName, task.Name})
+	} else {
+		l.end = end
+	}
+	b.mu.Unlock()
+	task.ETA = end
+	return nil
+}
+
+// Delete acknowledges the message of task.
+func (b *Backend) Delete(c context.Context, task *taskqueue.Task, queueName string) error {
+	l, err := b.leaseOf(task, queueName)
+	if err != nil {
+		return err
+	}
+	sub := b.subscription(queueName)
+	if _, err := b.svc.Projects.Subscriptions.Acknowledge(sub, &pubsub.AcknowledgeRequest{
+		AckIds: []string{l.ackID},
+	}).Context(c).Do(); err != nil {
+		return fmt.Errorf("pubsub: acknowledging task %q in %s: %w", task.Name, sub, err)
+	}
+	b.mu.Lock()
+	delete(b.leases, leaseKey{queueName, task.Name})
+	b.mu.Unlock()
+	return nil
+}
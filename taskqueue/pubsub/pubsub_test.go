@@ -0,0 +1,184 @@
+// Copyright 2026 Google LLC. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package pubsub
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/api/option"
+	pubsub "google.golang.org/api/pubsub/v1"
+
+	"google.golang.org/appengine/taskqueue"
+)
+
+// fakePubSub is a Pub/Sub server with one topic, projects/p/topics/taskqueue-q,
+// and its subscription.
+type fakePubSub struct {
+	t *testing.T
+
+	mu        sync.Mutex
+	messages  []*pubsub.PubsubMessage
+	deadlines map[string]time.Time // by message ID, of unacknowledged messages
+	acked     map[string]bool
+}
+
+func newBackend(t *testing.T) (*Backend, *fakePubSub) {
+	f := &fakePubSub{t: t, deadlines: make(map[string]time.Time), acked: make(map[string]bool)}
+	srv := httptest.NewServer(http.HandlerFunc(f.serve))
+	t.Cleanup(srv.Close)
+	b, err := NewBackend(context.Background(), "p", option.WithEndpoint(srv.URL), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b, f
+}
+
+func (f *fakePubSub) serve(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	decode := func(v interface{}) {
+		if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+			f.t.Errorf("decoding %s: %v", r.URL.Path, err)
+		}
+	}
+	now := time.Now()
+	switch r.URL.Path {
+	case "/v1/projects/p/topics/taskqueue-q:publish":
+		req := &pubsub.PublishRequest{}
+		decode(req)
+		res := &pubsub.PublishResponse{}
+		for _, m := range req.Messages {
+			m.MessageId = fmt.Sprint(100 + len(f.messages))
+			f.messages = append(f.messages, m)
+			res.MessageIds = append(res.MessageIds, m.MessageId)
+		}
+		json.NewEncoder(w).Encode(res)
+	case "/v1/projects/p/subscriptions/taskqueue-q:pull":
+		req := &pubsub.PullRequest{}
+		decode(req)
+		res := &pubsub.PullResponse{}
+		for _, m := range f.messages {
+			if int64(len(res.ReceivedMessages)) == req.MaxMessages {
+				break
+			}
+			if f.acked[m.MessageId] || now.Before(f.deadlines[m.MessageId]) {
+				continue
+			}
+			f.deadlines[m.MessageId] = now.Add(10 * time.Second)
+			res.ReceivedMessages = append(res.ReceivedMessages, &pubsub.ReceivedMessage{AckId: "ack-" + m.MessageId, Message: m})
+		}
+		json.NewEncoder(w).Encode(res)
+	case "/v1/projects/p/subscriptions/taskqueue-q:modifyAckDeadline":
+		req := &pubsub.ModifyAckDeadlineRequest{}
+		decode(req)
+		for _, id := range req.AckIds {
+			f.deadlines[strings.TrimPrefix(id, "ack-")] = now.Add(time.Duration(req.AckDeadlineSeconds) * time.Second)
+		}
+		fmt.Fprint(w, `{}`)
+	case "/v1/projects/p/subscriptions/taskqueue-q:acknowledge":
+		req := &pubsub.AcknowledgeRequest{}
+		decode(req)
+		for _, id := range req.AckIds {
+			f.acked[strings.TrimPrefix(id, "ack-")] = true
+		}
+		fmt.Fprint(w, `{}`)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func payloads(tasks []*taskqueue.Task) []string {
+	var s []string
+	for _, t := range tasks {
+		s = append(s, string(t.Payload))
+	}
+	return s
+}
+
+func TestBackend(t *testing.T) {
+	b, f := newBackend(t)
+	c := context.Background()
+
+	for _, task := range []*taskqueue.Task{
+		{Payload: []byte("a"), Tag: "x", Name: "first"},
+		{Payload: []byte("b"), Tag: "y"},
+		{Payload: []byte("c"), Tag: "x"},
+	} {
+		added, err := b.Add(c, task, "q")
+		if err != nil {
+			t.Fatalf("Add: %v", err)
+		}
+		if added.Name == "" || added.Method != "PULL" {
+			t.Errorf("added task has name %q and method %q", added.Name, added.Method)
+		}
+	}
+	if got := f.messages[0].Attributes; got[nameAttribute] != "first" || got[tagAttribute] != "x" {
+		t.Errorf("first message has attributes %v", got)
+	}
+
+	tasks, err := b.Lease(c, 10, "q", time.Minute, true, "")
+	if err != nil {
+		t.Fatalf("Lease: %v", err)
+	}
+	if got := fmt.Sprint(payloads(tasks)); got != "[a c]" {
+		t.Fatalf("leased tasks %s by tag, want [a c]", got)
+	}
+	if tasks[0].Name != "first" || tasks[1].Name != "102" || tasks[0].Tag != "x" {
+		t.Errorf("leased tasks %+v, want the names and tags they were added with", tasks)
+	}
+	if d := time.Until(f.deadlines["100"]); d < 50*time.Second {
+		t.Errorf("ack deadline is in %v, want a minute", d)
+	}
+	if !f.deadlines["101"].Before(time.Now().Add(time.Second)) {
+		t.Errorf("message of tag y was not released")
+	}
+
+	if err := b.ModifyLease(c, tasks[0], "q", 0); err != nil {
+		t.Fatalf("ModifyLease: %v", err)
+	}
+	if err := b.Delete(c, tasks[1], "q"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if !f.acked["102"] {
+		t.Errorf("deleted task was not acknowledged")
+	}
+	if err := b.Delete(c, tasks[0], "q"); err == nil {
+		t.Errorf("deleting a released task succeeded")
+	}
+
+	tasks, err = b.Lease(c, 10, "q", time.Minute, false, "")
+	if err != nil {
+		t.Fatalf("Lease: %v", err)
+	}
+	if got := fmt.Sprint(payloads(tasks)); got != "[a b]" {
+		t.Errorf("leased tasks %s, want [a b]", got)
+	}
+}
+
+func TestBackendErrors(t *testing.T) {
+	b, _ := newBackend(t)
+	c := context.Background()
+
+	if _, err := b.Add(c, &taskqueue.Task{Delay: time.Minute}, "q"); err == nil {
+		t.Errorf("adding a delayed task succeeded")
+	}
+	if _, err := b.Lease(c, 10, "q", time.Hour, false, ""); err == nil {
+		t.Errorf("leasing for an hour succeeded")
+	}
+	if err := b.ModifyLease(c, &taskqueue.Task{Name: "unknown"}, "q", time.Minute); err == nil {
+		t.Errorf("modifying the lease of a task that is not leased succeeded")
+	}
+	if _, err := b.Lease(c, 10, "missing", time.Minute, false, ""); err == nil {
+		t.Errorf("leasing from a queue without subscription succeeded")
+	}
+}
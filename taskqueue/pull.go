@@ -0,0 +1,91 @@
+// Copyright 2026 Google LLC. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package taskqueue
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrPullQueuesUnsupported is the error returned for pull tasks and by Lease,
+// LeaseByTag and ModifyLease with the Cloud Tasks backend, which has no pull
+// queues, unless a PullBackend is set.
+var ErrPullQueuesUnsupported = errors.New("taskqueue: pull queues are not supported by Cloud Tasks; " +
+	"migrate them to Cloud Pub/Sub, for example with SetPullBackend and the taskqueue/pubsub package")
+
+// A PullBackend implements pull queues for the Cloud Tasks backend. Pull
+// tasks, as added with Method "PULL" or returned by Lease, are passed to it;
+// queue names are never empty.
+type PullBackend interface {
+	// Add adds a pull task to the named queue and returns an equivalent task
+	// with its Name set.
+	Add(c context.Context, task *Task, queueName string) (*Task, error)
+
+	// Lease leases at most maxTasks tasks from the named queue for
+	// leaseTime. If groupByTag is set, the tasks all have tag, or the tag of
+	// the oldest task if tag is empty. The tasks have Method "PULL", and their
+	// ETA is set to the end of the lease.
+	Lease(c context.Context, maxTasks int, queueName string, leaseTime time.Duration, groupByTag bool, tag string) ([]*Task, error)
+
+	// ModifyLease changes the lease of a leased task to end leaseTime from
+	// now, and updates task.ETA to match. A zero leaseTime ends the lease.
+	ModifyLease(c context.Context, task *Task, queueName string, leaseTime time.Duration) error
+
+	// Delete deletes a leased task once it has been processed.
+	Delete(c context.Context, task *Task, queueName string) error
+}
+
+var pullBackend struct {
+	sync.Mutex
+	b PullBackend
+}
+
+// SetPullBackend sets the implementation of pull queues used with the Cloud
+// Tasks backend. A nil b removes it, so that pull queue operations fail with
+// ErrPullQueuesUnsupported again.
+func SetPullBackend(b PullBackend) {
+	pullBackend.Lock()
+	defer pullBackend.Unlock()
+	pullBackend.b = b
+}
+
+// getPullBackend returns the PullBackend that was set, or
+// ErrPullQueuesUnsupported.
+func getPullBackend() (PullBackend, error) {
+	pullBackend.Lock()
+	defer pullBackend.Unlock()
+	if pullBackend.b == nil {
+		return nil, ErrPullQueuesUnsupported
+	}
+	return pullBackend.b, nil
+}
+
+// queueOrDefault returns queueName, or "default" if it is empty.
+func queueOrDefault(queueName string) string {
+	if queueName == "" {
+		return "default"
+	}
+	return queueName
+}
+
+// leaseCloud implements Lease and LeaseByTag with the Cloud Tasks backend.
+func leaseCloud(c context.Context, maxTasks int, queueName string, leaseTime int, groupByTag bool, tag string) ([]*Task, error) {
+	b, err := getPullBackend()
+	if err != nil {
+		return nil, err
+	}
+	return b.Lease(c, maxTasks, queueOrDefault(queueName), time.Duration(leaseTime)*time.Second, groupByTag, tag)
+}
+
+// modifyLeaseCloud implements ModifyLease with the Cloud Tasks backend.
+func modifyLeaseCloud(c context.Context, task *Task, queueName string, leaseTime int) error {
+	b, err := getPullBackend()
+	if err != nil {
+		return err
+	}
+	return b.ModifyLease(c, task, queueOrDefault(queueName), time.Duration(leaseTime)*time.Second)
+}
@@ -0,0 +1,166 @@
+// Copyright 2026 Google LLC. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package taskqueue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakePullBackend is an in-memory PullBackend.
+type fakePullBackend struct {
+	mu     sync.Mutex
+	queues map[string][]*Task // of tasks that are not deleted, oldest first
+	leases map[string]time.Time
+	nextID int
+}
+
+func newFakePullBackend(t *testing.T) *fakePullBackend {
+	b := &fakePullBackend{queues: make(map[string][]*Task), leases: make(map[string]time.Time)}
+	SetPullBackend(b)
+	t.Cleanup(func() { SetPullBackend(nil) })
+	return b
+}
+
+func (b *fakePullBackend) Add(c context.Context, task *Task, queueName string) (*Task, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	t := *task
+	if t.Name == "" {
+		b.nextID++
+		t.Name = fmt.Sprintf("pull-%d", b.nextID)
+	}
+	b.queues[queueName] = append(b.queues[queueName], &t)
+	res := t
+	return &res, nil
+}
+
+func (b *fakePullBackend) Lease(c context.Context, maxTasks int, queueName string, leaseTime time.Duration, groupByTag bool, tag string) ([]*Task, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := time.Now()
+	var tasks []*Task
+	for _, t := range b.queues[queueName] {
+		if len(tasks) == maxTasks {
+			break
+		}
+		if now.Before(b.leases[t.Name]) {
+			continue
+		}
+		if groupByTag {
+			if tag == "" {
+				tag = t.Tag
+			}
+			if t.Tag != tag {
+				continue
+			}
+		}
+		b.leases[t.Name] = now.Add(leaseTime)
+		leased := *t
+		leased.ETA = b.leases[t.Name]
+		tasks = append(tasks, &leased)
+	}
+	return tasks, nil
+}
+
+func (b *fakePullBackend) ModifyLease(c context.Context, task *Task, queueName string, leaseTime time.Duration) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.leases[task.Name]; !ok {
+		return errors.New("task is not leased")
+	}
+	b.leases[task.Name] = time.Now().Add(leaseTime)
+	task.ETA = b.leases[task.Name]
+	return nil
+}
+
+func (b *fakePullBackend) Delete(c context.Context, task *Task, queueName string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	q := b.queues[queueName]
+	for i, t := range q {
+		if t.Name == task.Name {
+			b.queues[queueName] = append(q[:i:i], q[i+1:]...)
+			delete(b.leases, t.Name)
+			return nil
+		}
+	}
+	return errors.New("no such task")
+}
+
+func TestPullQueuesUnsupported(t *testing.T) {
+	newFakeCloudTasks(t)
+	c := context.Background()
+
+	if _, err := Add(c, &Task{Method: "PULL"}, "pull"); err != ErrPullQueuesUnsupported {
+		t.Errorf("Add: got %v, want ErrPullQueuesUnsupported", err)
+	}
+	if _, err := Lease(c, 10, "pull", 60); err != ErrPullQueuesUnsupported {
+		t.Errorf("Lease: got %v, want ErrPullQueuesUnsupported", err)
+	}
+	if _, err := LeaseByTag(c, 10, "pull", 60, "tag"); err != ErrPullQueuesUnsupported {
+		t.Errorf("LeaseByTag: got %v, want ErrPullQueuesUnsupported", err)
+	}
+	if err := ModifyLease(c, &Task{Name: "t", Method: "PULL"}, "pull", 60); err != ErrPullQueuesUnsupported {
+		t.Errorf("ModifyLease: got %v, want ErrPullQueuesUnsupported", err)
+	}
+	if err := Delete(c, &Task{Name: "t", Method: "PULL"}, "pull"); err != ErrPullQueuesUnsupported {
+		t.Errorf("Delete: got %v, want ErrPullQueuesUnsupported", err)
+	}
+}
+
+func TestPullBackend(t *testing.T) {
+	f := newFakeCloudTasks(t)
+	b := newFakePullBackend(t)
+	c := context.Background()
+	// Add the tasks in order, for the oldest to be known.
+	defer func(n int) { maxConcurrentCalls = n }(maxConcurrentCalls)
+	maxConcurrentCalls = 1
+
+	added, err := AddMulti(c, []*Task{
+		{Method: "PULL", Payload: []byte("a"), Tag: "x"},
+		{Method: "PULL", Payload: []byte("b"), Tag: "y"},
+		{Path: "/push"},
+		{Method: "PULL", Payload: []byte("c"), Tag: "x"},
+	}, "")
+	if err != nil {
+		t.Fatalf("AddMulti: %v", err)
+	}
+	if added[0].Name != "pull-1" || added[2].Name != "1001" {
+		t.Errorf("added tasks are named %q and %q, want pull-1 and the server's 1001", added[0].Name, added[2].Name)
+	}
+	if len(f.created) != 1 || len(b.queues["default"]) != 3 {
+		t.Fatalf("added %d push and %d pull tasks, want 1 and 3", len(f.created), len(b.queues["default"]))
+	}
+
+	tasks, err := LeaseByTag(c, 10, "", 60, "")
+	if err != nil {
+		t.Fatalf("LeaseByTag: %v", err)
+	}
+	if len(tasks) != 2 || string(tasks[0].Payload) != "a" || string(tasks[1].Payload) != "c" {
+		t.Fatalf("LeaseByTag leased %v, want tasks a and c of tag x", tasks)
+	}
+	if d := time.Until(tasks[0].ETA); d < 50*time.Second || d > time.Minute {
+		t.Errorf("lease ends in %v, want a minute", d)
+	}
+	if err := ModifyLease(c, tasks[0], "", 0); err != nil {
+		t.Fatalf("ModifyLease: %v", err)
+	}
+	if err := Delete(c, tasks[1], ""); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+
+	tasks, err = Lease(c, 10, "", 60)
+	if err != nil {
+		t.Fatalf("Lease: %v", err)
+	}
+	if len(tasks) != 2 || string(tasks[0].Payload) != "a" || string(tasks[1].Payload) != "b" {
+		t.Errorf("Lease leased %v, want the released task a and task b", tasks)
+	}
+}
@@ -13,6 +13,15 @@ taskqueue operation is to add a single POST task, NewPOSTTask makes it easy.
 		"key": {key},
 	})
 	taskqueue.Add(c, t, "") // add t to the default queue
+
+On runtimes without the legacy task queue service, setting the environment
+variable TASKQUEUE_USE_CLOUD_API to a true value such as "true" or "yes" makes
+the package use the Cloud Tasks API instead. Tasks are then added to the
+queues of the same names in the location given by TASKQUEUE_LOCATION, or by
+default the location of the app, as App Engine tasks. A "Host" header is
+translated to the routing of the task. Cloud Tasks has no pull queues: pull
+tasks and leases fail with ErrPullQueuesUnsupported, unless a PullBackend, such
+as that of the taskqueue/pubsub package, is set with SetPullBackend.
 */
 package taskqueue // import "google.golang.org/appengine/taskqueue"
 
@@ -39,6 +48,11 @@ var (
 )
 
 // RetryOptions let you control whether to retry a task and the backoff intervals between tries.
+//
+// Cloud Tasks has no per-task retry options: with the Cloud Tasks backend, the
+// RetryOptions of tasks are ignored, or make adding them fail if the environment
+// variable TASKQUEUE_STRICT_RETRY_OPTIONS is true. Use EnsureQueueRetryConfig to
+// set them on the queue instead.
 type RetryOptions struct {
 	// Number of tries/leases after which the task fails permanently and is deleted.
 	// If AgeLimit is also set, both limits must be exceeded for the task to fail permanently.
@@ -283,6 +297,11 @@ var alreadyAddedErrors = map[pb.TaskQueueServiceError_ErrorCode]bool{
 // Add returns an equivalent Task with defaults filled in, including setting
 // the task's Name field to the chosen name if the original was empty.
 func Add(c context.Context, task *Task, queueName string) (*Task, error) {
+	if cloud, err := useCloudAPI(); err != nil {
+		return nil, err
+	} else if cloud {
+		return addCloud(c, task, queueName)
+	}
 	req, err := newAddReq(c, task, queueName)
 	if err != nil {
 		return nil, err
@@ -308,7 +327,15 @@ func Add(c context.Context, task *Task, queueName string) (*Task, error) {
 // AddMulti returns a slice of equivalent tasks with defaults filled in, including setting
 // each task's Name field to the chosen name if the original was empty.
 // If a given task is badly formed or could not be added, an appengine.MultiError is returned.
+// With the Cloud Tasks backend, the tasks are added concurrently, so some of them
+// may have been added when others fail; if c is done before a task is sent, its
+// error is that of c.
 func AddMulti(c context.Context, tasks []*Task, queueName string) ([]*Task, error) {
+	if cloud, err := useCloudAPI(); err != nil {
+		return nil, err
+	} else if cloud {
+		return addMultiCloud(c, tasks, queueName)
+	}
 	req := &pb.TaskQueueBulkAddRequest{
 		AddRequest: make([]*pb.TaskQueueAddRequest, len(tasks)),
 	}
@@ -366,7 +393,14 @@ func Delete(c context.Context, task *Task, queueName string) error {
 // If a given task could not be deleted, an appengine.MultiError is returned.
 // Each task is deleted independently; one may fail to delete while the others
 // are successfully deleted.
+// With the Cloud Tasks backend, a task that does not exist, including one that
+// was deleted or has run, fails with the UNKNOWN_TASK error of the legacy API.
 func DeleteMulti(c context.Context, tasks []*Task, queueName string) error {
+	if cloud, err := useCloudAPI(); err != nil {
+		return err
+	} else if cloud {
+		return deleteMultiCloud(c, tasks, queueName)
+	}
 	taskNames := make([][]byte, len(tasks))
 	for i, t := range tasks {
 		taskNames[i] = []byte(t.Name)
@@ -433,7 +467,14 @@ func lease(c context.Context, maxTasks int, queueName string, leaseTime int, gro
 // Lease leases tasks from a queue.
 // leaseTime is in seconds.
 // The number of tasks fetched will be at most maxTasks.
+// With the Cloud Tasks backend, it uses the PullBackend set with SetPullBackend,
+// or fails with ErrPullQueuesUnsupported.
 func Lease(c context.Context, maxTasks int, queueName string, leaseTime int) ([]*Task, error) {
+	if cloud, err := useCloudAPI(); err != nil {
+		return nil, err
+	} else if cloud {
+		return leaseCloud(c, maxTasks, queueName, leaseTime, false, "")
+	}
 	return lease(c, maxTasks, queueName, leaseTime, false, nil)
 }
 
@@ -441,12 +482,27 @@ func Lease(c context.Context, maxTasks int, queueName string, leaseTime int) ([]
 // If tag is empty, then the returned tasks are grouped by the tag of the task with earliest ETA.
 // leaseTime is in seconds.
 // The number of tasks fetched will be at most maxTasks.
+// With the Cloud Tasks backend, it uses the PullBackend set with SetPullBackend,
+// or fails with ErrPullQueuesUnsupported.
 func LeaseByTag(c context.Context, maxTasks int, queueName string, leaseTime int, tag string) ([]*Task, error) {
+	if cloud, err := useCloudAPI(); err != nil {
+		return nil, err
+	} else if cloud {
+		return leaseCloud(c, maxTasks, queueName, leaseTime, true, tag)
+	}
 	return lease(c, maxTasks, queueName, leaseTime, true, []byte(tag))
 }
 
 // Purge removes all tasks from a queue.
+// With the Cloud Tasks backend, purging is eventually consistent: tasks may
+// still be dispatched up to a minute after Purge returns, and tasks added
+// right after it may be purged too.
 func Purge(c context.Context, queueName string) error {
+	if cloud, err := useCloudAPI(); err != nil {
+		return err
+	} else if cloud {
+		return purgeCloud(c, queueName)
+	}
 	if queueName == "" {
 		queueName = "default"
 	}
@@ -460,7 +516,14 @@ func Purge(c context.Context, queueName string) error {
 // ModifyLease modifies the lease of a task.
 // Used to request more processing time, or to abandon processing.
 // leaseTime is in seconds and must not be negative.
+// With the Cloud Tasks backend, it uses the PullBackend set with SetPullBackend,
+// or fails with ErrPullQueuesUnsupported.
 func ModifyLease(c context.Context, task *Task, queueName string, leaseTime int) error {
+	if cloud, err := useCloudAPI(); err != nil {
+		return err
+	} else if cloud {
+		return modifyLeaseCloud(c, task, queueName, leaseTime)
+	}
 	if queueName == "" {
 		queueName = "default"
 	}
@@ -486,10 +549,36 @@ type QueueStatistics struct {
 	Executed1Minute int     // tasks executed in the last minute
 	InFlight        int     // tasks executing now
 	EnforcedRate    float64 // requests per second
+
+	// Available holds the fields that could be computed; the others are zero.
+	Available QueueStat
 }
 
+// A QueueStat is a set of fields of QueueStatistics.
+type QueueStat uint
+
+// The fields of QueueStatistics.
+const (
+	StatTasks QueueStat = 1 << iota
+	StatOldestETA
+	StatExecuted1Minute
+	StatInFlight
+	StatEnforcedRate
+)
+
 // QueueStats retrieves statistics about queues.
+// With the Cloud Tasks backend, the statistics are approximated with the
+// configuration of the queues and their Cloud Monitoring metrics, which lag by
+// a few minutes; OldestETA and InFlight are not available, and neither are the
+// other fields when the metrics cannot be read. The queues are fetched
+// concurrently, and an appengine.MultiError is returned if some of them could
+// not be.
 func QueueStats(c context.Context, queueNames []string) ([]QueueStatistics, error) {
+	if cloud, err := useCloudAPI(); err != nil {
+		return nil, err
+	} else if cloud {
+		return queueStatsCloud(c, queueNames)
+	}
 	req := &pb.TaskQueueFetchQueueStatsRequest{
 		QueueName: make([][]byte, len(queueNames)),
 	}
@@ -506,7 +595,8 @@ func QueueStats(c context.Context, queueNames []string) ([]QueueStatistics, erro
 	qs := make([]QueueStatistics, len(res.Queuestats))
 	for i, qsg := range res.Queuestats {
 		qs[i] = QueueStatistics{
-			Tasks: int(*qsg.NumTasks),
+			Tasks:     int(*qsg.NumTasks),
+			Available: StatTasks | StatOldestETA,
 		}
 		if eta := *qsg.OldestEtaUsec; eta > -1 {
 			qs[i].OldestETA = time.Unix(0, eta*1e3)
@@ -515,6 +605,7 @@ func QueueStats(c context.Context, queueNames []string) ([]QueueStatistics, erro
 			qs[i].Executed1Minute = int(*si.ExecutedLastMinute)
 			qs[i].InFlight = int(si.GetRequestsInFlight())
 			qs[i].EnforcedRate = si.GetEnforcedRate()
+			qs[i].Available |= StatExecuted1Minute | StatInFlight | StatEnforcedRate
 		}
 	}
 	return qs, nil
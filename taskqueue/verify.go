@@ -0,0 +1,166 @@
+// Copyright 2026 Google LLC. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package taskqueue
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/api/idtoken"
+	"google.golang.org/api/option"
+
+	"google.golang.org/appengine/internal"
+)
+
+// validatorOptions holds extra client options used when constructing the
+// validator of OIDC tokens. It is a variable so that tests can serve their
+// own keys.
+var validatorOptions []option.ClientOption
+
+// tokenValidator is the validator of OIDC tokens, created on first use so
+// that it caches Google's keys.
+var tokenValidator struct {
+	sync.Mutex
+	v *idtoken.Validator
+}
+
+// googleIssuers are the issuers of the OIDC tokens of Google.
+var googleIssuers = map[string]bool{
+	"https://accounts.google.com": true,
+	"accounts.google.com":         true,
+}
+
+// VerifyOptions are the options of VerifyTaskRequest.
+type VerifyOptions struct {
+	// Audience is the audience that the OIDC token of a request must be for,
+	// as set in the tasks. The default audience of Cloud Tasks is the URL of
+	// the handler without its query, as in "https://my-project.appspot.com/task".
+	// It is required for tokens to be accepted: it is not derived from the
+	// request, whose Host header the client controls.
+	Audience string
+
+	// ServiceAccounts are the emails of the service accounts that OIDC tokens
+	// may be issued to, as set in the tasks. If empty, no token is accepted.
+	ServiceAccounts []string
+}
+
+// TaskMetadata describes the task that a request is for.
+type TaskMetadata struct {
+	QueueName      string
+	TaskName       string
+	RetryCount     int64
+	ExecutionCount int64
+	ScheduleTime   time.Time // zero if unknown
+
+	// ServiceAccount is the email of the OIDC token of the request, or empty
+	// if the request was verified by its App Engine headers.
+	ServiceAccount string
+}
+
+// VerifyTaskRequest checks that r is a request from the task queue service
+// or Cloud Tasks, and returns the metadata of its task. Requests are trusted
+// if either
+//
+//   - the app runs on App Engine, which removes the X-AppEngine-QueueName
+//     header from external requests, and r has that header; or
+//   - r has an OIDC token of Google, as set on Cloud Tasks HTTP tasks, for
+//     opts.Audience and issued to one of opts.ServiceAccounts, both of which
+//     must be set. The metadata is then read from the X-CloudTasks headers.
+//
+// Other requests, such as ones with spoofed headers, fail with an error
+// explaining both checks.
+func VerifyTaskRequest(r *http.Request, opts VerifyOptions) (*TaskMetadata, error) {
+	var headerErr error
+	if !internal.IsAppEngine() {
+		headerErr = errors.New("not running on App Engine")
+	} else if r.Header.Get("X-AppEngine-QueueName") == "" {
+		headerErr = errors.New("no X-AppEngine-QueueName header")
+	} else {
+		h := ParseRequestHeaders(r.Header)
+		return &TaskMetadata{
+			QueueName:      h.QueueName,
+			TaskName:       h.TaskName,
+			RetryCount:     h.TaskRetryCount,
+			ExecutionCount: h.TaskExecutionCount,
+			ScheduleTime:   parseETA(r.Header.Get("X-AppEngine-TaskETA")),
+		}, nil
+	}
+
+	email, tokenErr := verifyToken(r, opts)
+	if tokenErr != nil {
+		return nil, fmt.Errorf("taskqueue: request is not from a task: App Engine headers: %v; OIDC token: %v", headerErr, tokenErr)
+	}
+	md := &TaskMetadata{
+		QueueName:      r.Header.Get("X-CloudTasks-QueueName"),
+		TaskName:       r.Header.Get("X-CloudTasks-TaskName"),
+		ScheduleTime:   parseETA(r.Header.Get("X-CloudTasks-TaskETA")),
+		ServiceAccount: email,
+	}
+	md.RetryCount, _ = strconv.ParseInt(r.Header.Get("X-CloudTasks-TaskRetryCount"), 10, 64)
+	md.ExecutionCount, _ = strconv.ParseInt(r.Header.Get("X-CloudTasks-TaskExecutionCount"), 10, 64)
+	return md, nil
+}
+
+// parseETA parses an ETA header, in seconds since the epoch with a fraction,
+// ignoring malformed values.
+func parseETA(v string) time.Time {
+	secs, err := strconv.ParseFloat(v, 64)
+	if err != nil || secs <= 0 {
+		return time.Time{}
+	}
+	whole, frac := math.Modf(secs)
+	return time.Unix(int64(whole), int64(frac*1e9))
+}
+
+// verifyToken checks the OIDC token of r, and returns its email.
+func verifyToken(r *http.Request, opts VerifyOptions) (string, error) {
+	auth := r.Header.Get("Authorization")
+	tok := strings.TrimPrefix(auth, "Bearer ")
+	if tok == "" || tok == auth {
+		return "", errors.New("no bearer token")
+	}
+	if len(opts.ServiceAccounts) == 0 {
+		return "", errors.New("no service accounts are allowed")
+	}
+	if opts.Audience == "" {
+		return "", errors.New("no audience is set")
+	}
+
+	tokenValidator.Lock()
+	if tokenValidator.v == nil {
+		v, err := idtoken.NewValidator(r.Context(), validatorOptions...)
+		if err != nil {
+			tokenValidator.Unlock()
+			return "", fmt.Errorf("creating validator: %v", err)
+		}
+		tokenValidator.v = v
+	}
+	v := tokenValidator.v
+	tokenValidator.Unlock()
+
+	p, err := v.Validate(r.Context(), tok, opts.Audience)
+	if err != nil {
+		return "", err
+	}
+	if !googleIssuers[p.Issuer] {
+		return "", fmt.Errorf("token is issued by %q, not Google", p.Issuer)
+	}
+	email, _ := p.Claims["email"].(string)
+	if verified, _ := p.Claims["email_verified"].(bool); !verified {
+		return "", fmt.Errorf("email %q of token is not verified", email)
+	}
+	for _, a := range opts.ServiceAccounts {
+		if email == a {
+			return email, nil
+		}
+	}
+	return "", fmt.Errorf("token is for %q, which is not an allowed service account", email)
+}
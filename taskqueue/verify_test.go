@@ -0,0 +1,207 @@
+// Copyright 2026 Google LLC. All rights reserved.
+// Use of this source code is governed by the Apache 2.0
+// license that can be found in the LICENSE file.
+
+package taskqueue
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/api/option"
+)
+
+// fakeJWKS serves an RSA key in place of Google's, and signs tokens with it.
+type fakeJWKS struct {
+	key *rsa.PrivateKey
+}
+
+func (f *fakeJWKS) RoundTrip(req *http.Request) (*http.Response, error) {
+	rec := httptest.NewRecorder()
+	json.NewEncoder(rec).Encode(map[string]interface{}{
+		"keys": []map[string]string{{
+			"kid": "test-key",
+			"kty": "RSA",
+			"alg": "RS256",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(f.key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(f.key.E)).Bytes()),
+		}},
+	})
+	return rec.Result(), nil
+}
+
+func newFakeJWKS(t *testing.T) *fakeJWKS {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fakeJWKS{key: key}
+	old := validatorOptions
+	validatorOptions = []option.ClientOption{option.WithHTTPClient(&http.Client{Transport: f})}
+	resetValidator := func() {
+		tokenValidator.Lock()
+		tokenValidator.v = nil
+		tokenValidator.Unlock()
+	}
+	resetValidator()
+	t.Cleanup(func() {
+		validatorOptions = old
+		resetValidator()
+	})
+	return f
+}
+
+// token returns a token signed by f with the given claims, which default to
+// those of a valid token for the handler https://app.example.com/task issued
+// to tasks@my-project.iam.gserviceaccount.com.
+func (f *fakeJWKS) token(t *testing.T, claims map[string]interface{}) string {
+	c := map[string]interface{}{
+		"iss":            "https://accounts.google.com",
+		"aud":            "https://app.example.com/task",
+		"email":          "tasks@my-project.iam.gserviceaccount.com",
+		"email_verified": true,
+		"iat":            time.Now().Unix(),
+		"exp":            time.Now().Add(time.Hour).Unix(),
+	}
+	for k, v := range claims {
+		c[k] = v
+	}
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": "test-key", "typ": "JWT"})
+	payload, _ := json.Marshal(c)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	sum := sha256.Sum256([]byte(signed))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, f.key, crypto.SHA256, sum[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+var verifyOpts = VerifyOptions{
+	Audience:        "https://app.example.com/task",
+	ServiceAccounts: []string{"other@example.com", "tasks@my-project.iam.gserviceaccount.com"},
+}
+
+func taskRequest(token string) *http.Request {
+	r := httptest.NewRequest("POST", "https://app.example.com/task?x=1", nil)
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	r.Header.Set("X-CloudTasks-QueueName", "work")
+	r.Header.Set("X-CloudTasks-TaskName", "1234")
+	r.Header.Set("X-CloudTasks-TaskRetryCount", "2")
+	r.Header.Set("X-CloudTasks-TaskExecutionCount", "1")
+	r.Header.Set("X-CloudTasks-TaskETA", "1700000000.5")
+	return r
+}
+
+func TestVerifyTaskRequestHeaders(t *testing.T) {
+	t.Setenv("GAE_ENV", "standard")
+	r := httptest.NewRequest("POST", "/task", nil)
+	r.Header.Set("X-AppEngine-QueueName", "default")
+	r.Header.Set("X-AppEngine-TaskName", "t1")
+	r.Header.Set("X-AppEngine-TaskRetryCount", "3")
+	r.Header.Set("X-AppEngine-TaskExecutionCount", "2")
+	r.Header.Set("X-AppEngine-TaskETA", "1700000000.25")
+
+	md, err := VerifyTaskRequest(r, VerifyOptions{})
+	if err != nil {
+		t.Fatalf("VerifyTaskRequest: %v", err)
+	}
+	want := TaskMetadata{
+		QueueName:      "default",
+		TaskName:       "t1",
+		RetryCount:     3,
+		ExecutionCount: 2,
+		ScheduleTime:   time.Unix(1700000000, 250e6),
+	}
+	if *md != want {
+		t.Errorf("metadata = %+v, want %+v", *md, want)
+	}
+}
+
+func TestVerifyTaskRequestToken(t *testing.T) {
+	f := newFakeJWKS(t)
+	md, err := VerifyTaskRequest(taskRequest(f.token(t, nil)), verifyOpts)
+	if err != nil {
+		t.Fatalf("VerifyTaskRequest: %v", err)
+	}
+	want := TaskMetadata{
+		QueueName:      "work",
+		TaskName:       "1234",
+		RetryCount:     2,
+		ExecutionCount: 1,
+		ScheduleTime:   time.Unix(1700000000, 500e6),
+		ServiceAccount: "tasks@my-project.iam.gserviceaccount.com",
+	}
+	if *md != want {
+		t.Errorf("metadata = %+v, want %+v", *md, want)
+	}
+
+	opts := verifyOpts
+	opts.Audience = "my-audience"
+	if _, err := VerifyTaskRequest(taskRequest(f.token(t, map[string]interface{}{"aud": "my-audience"})), opts); err != nil {
+		t.Errorf("VerifyTaskRequest with an explicit audience: %v", err)
+	}
+}
+
+func TestVerifyTaskRequestSpoofed(t *testing.T) {
+	f := newFakeJWKS(t)
+	other := &fakeJWKS{}
+	var err error
+	if other.key, err = rsa.GenerateKey(rand.Reader, 2048); err != nil {
+		t.Fatal(err)
+	}
+
+	// Off App Engine, the App Engine headers are not trusted.
+	r := taskRequest("")
+	r.Header.Set("X-AppEngine-QueueName", "default")
+	if _, err := VerifyTaskRequest(r, verifyOpts); err == nil {
+		t.Errorf("request with App Engine headers off App Engine was verified")
+	} else if !strings.Contains(err.Error(), "not running on App Engine") || !strings.Contains(err.Error(), "no bearer token") {
+		t.Errorf("error %q does not explain both checks", err)
+	}
+
+	tests := []struct {
+		desc  string
+		token string
+		opts  VerifyOptions
+	}{
+		{"another service account", f.token(t, map[string]interface{}{"email": "attacker@example.com"}), verifyOpts},
+		{"an unverified email", f.token(t, map[string]interface{}{"email_verified": false}), verifyOpts},
+		{"another audience", f.token(t, map[string]interface{}{"aud": "https://evil.example.com/task"}), verifyOpts},
+		{"another issuer", f.token(t, map[string]interface{}{"iss": "https://evil.example.com"}), verifyOpts},
+		{"an expired token", f.token(t, map[string]interface{}{"exp": time.Now().Add(-time.Minute).Unix()}), verifyOpts},
+		{"another key", other.token(t, nil), verifyOpts},
+		{"no allowed accounts", f.token(t, nil), VerifyOptions{Audience: verifyOpts.Audience}},
+		{"no audience", f.token(t, nil), VerifyOptions{ServiceAccounts: verifyOpts.ServiceAccounts}},
+		{"a malformed token", "not-a-jwt", verifyOpts},
+	}
+	for _, tc := range tests {
+		if md, err := VerifyTaskRequest(taskRequest(tc.token), tc.opts); err == nil {
+			t.Errorf("request with %s was verified: %+v", tc.desc, md)
+		}
+	}
+
+	// A token for another audience is not accepted by sending it to a Host
+	// that matches that audience, whether or not an audience is set.
+	evil := f.token(t, map[string]interface{}{"aud": "https://evil.example.com/task"})
+	for _, opts := range []VerifyOptions{verifyOpts, {ServiceAccounts: verifyOpts.ServiceAccounts}} {
+		r := taskRequest(evil)
+		r.Host = "evil.example.com"
+		if md, err := VerifyTaskRequest(r, opts); err == nil {
+			t.Errorf("request with a spoofed Host and audience %q was verified: %+v", opts.Audience, md)
+		}
+	}
+}
@@ -4,17 +4,10 @@
 
 package appengine
 
-import "context"
+import "google.golang.org/appengine/internal"
 
-// IsTimeoutError reports whether err is a timeout error.
+// IsTimeoutError reports whether err is a timeout error. err may wrap such
+// an error.
 func IsTimeoutError(err error) bool {
-	if err == context.DeadlineExceeded {
-		return true
-	}
-	if t, ok := err.(interface {
-		IsTimeout() bool
-	}); ok {
-		return t.IsTimeout()
-	}
-	return false
+	return internal.IsTimeout(err)
 }